@@ -0,0 +1,48 @@
+package githelpers
+
+import "github.com/go-git/go-git/v5/plumbing"
+
+// AmendCommit replaces the last commit of the checked out branch with one holding its changes plus
+// what's staged, or, with addAll, every change in the worktree, as git commit --amend does, e.g. to
+// add a forgotten file to the previous bot commit without a noise commit. An empty newMessage keeps
+// the old message. The author is kept, and the files the new commit changes go through the checks CommitAll
+// does. A branch that was pushed already must be pushed again with ForcePush
+func (gr *GitRepo) AmendCommit(newMessage string, addAll bool) (hash plumbing.Hash, err error) {
+	head, err := gr.commitAt("HEAD")
+	if err != nil {
+		return hash, err
+	}
+	if newMessage == "" {
+		newMessage = head.Message
+	}
+	author := head.Author
+	return gr.commitAll(newMessage, commitOptions{
+		parents:    head.ParentHashes,
+		root:       len(head.ParentHashes) == 0,
+		author:     &author,
+		stagedOnly: !addAll,
+	})
+}
+
+// makeRootCommit replaces the commit at hash, which HEAD points to, with a copy that has no parents
+func (gr *GitRepo) makeRootCommit(hash plumbing.Hash) (plumbing.Hash, error) {
+	c, err := gr.Repo.CommitObject(hash)
+	if err != nil {
+		return hash, err
+	}
+	c.ParentHashes = nil
+	root, err := storeObject(gr.Repo.Storer, c)
+	if err != nil {
+		return hash, err
+	}
+
+	head, err := gr.Repo.Storer.Reference(plumbing.HEAD)
+	if err != nil {
+		return hash, err
+	}
+	branch := plumbing.HEAD
+	if head.Type() == plumbing.SymbolicReference {
+		branch = head.Target()
+	}
+	return root, gr.Repo.Storer.SetReference(plumbing.NewHashReference(branch, root))
+}
@@ -0,0 +1,42 @@
+package githelpers
+
+import "testing"
+
+func TestAmendCommit(t *testing.T) {
+	for _, root := range []bool{true, false} {
+		name := "second commit"
+		if root {
+			name = "root commit"
+		}
+		t.Run(name, func(t *testing.T) {
+			gr := newTestRepo(t, map[string]string{"README.md": "hello\n"})
+			if !root {
+				commitTestFiles(t, gr, "Add notes", map[string]string{"notes.txt": "notes\n"})
+			}
+			before := headCommit(t, gr)
+
+			err := gr.WriteFile("forgotten.txt", []byte("forgotten\n"), 0644)
+			if err != nil {
+				t.Fatal(err)
+			}
+			_, err = gr.AmendCommit("", true)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			after := headCommit(t, gr)
+			if after.Hash == before.Hash || after.Message != before.Message || after.Author.Email != before.Author.Email {
+				t.Errorf("amended %s into %s %q by %s", before.Hash, after.Hash, after.Message, after.Author.Email)
+			}
+			if len(after.ParentHashes) != len(before.ParentHashes) || (!root && after.ParentHashes[0] != before.ParentHashes[0]) {
+				t.Errorf("parents %v, want %v", after.ParentHashes, before.ParentHashes)
+			}
+			if _, err := after.File("forgotten.txt"); err != nil {
+				t.Errorf("forgotten.txt wasn't added: %v", err)
+			}
+			if _, err := after.File("README.md"); err != nil {
+				t.Errorf("README.md was lost: %v", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,158 @@
+package githelpers
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/go-git/go-git/v5/plumbing/filemode"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/xanzy/go-gitlab"
+)
+
+const (
+	// ArchiveTar is a plain tar archive
+	ArchiveTar = "tar"
+	// ArchiveTarGz is a gzipped tar archive
+	ArchiveTarGz = "tar.gz"
+	// ArchiveZip is a zip archive
+	ArchiveZip = "zip"
+)
+
+var (
+	// ErrUnknownArchiveFormat is returned for archive formats other than tar, tar.gz, and zip
+	ErrUnknownArchiveFormat = errors.New("unknown archive format")
+)
+
+// Archive writes the files of ref, without the .git directory, to w as a tar, tar.gz, or zip archive,
+// as git archive does. Every entry gets the commit's time, and executable bits and symlinks are kept
+func (gr *GitRepo) Archive(ref, format string, w io.Writer) error {
+	commit, err := gr.commitAt(ref)
+	if err != nil {
+		return err
+	}
+	tree, err := commit.Tree()
+	if err != nil {
+		return err
+	}
+	mtime := commit.Committer.When
+
+	switch format {
+	case ArchiveTar:
+		return writeTar(tree, mtime, w)
+	case ArchiveTarGz:
+		gz := gzip.NewWriter(w)
+		err = writeTar(tree, mtime, gz)
+		if err != nil {
+			return err
+		}
+		return gz.Close()
+	case ArchiveZip:
+		return writeZip(tree, mtime, w)
+	}
+	return fmt.Errorf("%w: %s", ErrUnknownArchiveFormat, format)
+}
+
+// ArchiveViaAPI streams the GitLab archive of ref to w without cloning the repository. GitLab accepts
+// more formats than Archive does, e.g. tar.bz2, and puts the files under a <project>-<ref> directory
+func (gr *GitRepo) ArchiveViaAPI(ref, format string, w io.Writer) (resp *gitlab.Response, err error) {
+	c, err := gr.gitlabClient()
+	if err != nil {
+		return resp, err
+	}
+
+	pid, resp, err := gr.getGitlabProjectID(gr.SSHURL)
+	if err != nil {
+		return resp, err
+	}
+
+	resp, err = c.Repositories.StreamArchive(pid, w, &gitlab.ArchiveOptions{Format: &format, SHA: &ref})
+	return resp, err
+}
+
+func writeTar(tree *object.Tree, mtime time.Time, w io.Writer) error {
+	tw := tar.NewWriter(w)
+	err := tree.Files().ForEach(func(f *object.File) error {
+		hdr := &tar.Header{
+			Name:    f.Name,
+			Mode:    archiveMode(f.Mode),
+			ModTime: mtime,
+			Size:    f.Size,
+		}
+
+		if f.Mode == filemode.Symlink {
+			target, err := f.Contents()
+			if err != nil {
+				return err
+			}
+			hdr.Typeflag = tar.TypeSymlink
+			hdr.Linkname = target
+			hdr.Size = 0
+			return tw.WriteHeader(hdr)
+		}
+
+		hdr.Typeflag = tar.TypeReg
+		err := tw.WriteHeader(hdr)
+		if err != nil {
+			return err
+		}
+		return copyBlob(f, tw)
+	})
+	if err != nil {
+		return err
+	}
+	return tw.Close()
+}
+
+func writeZip(tree *object.Tree, mtime time.Time, w io.Writer) error {
+	zw := zip.NewWriter(w)
+	err := tree.Files().ForEach(func(f *object.File) error {
+		hdr := &zip.FileHeader{
+			Name:     f.Name,
+			Method:   zip.Deflate,
+			Modified: mtime,
+		}
+		mode := os.FileMode(archiveMode(f.Mode))
+		if f.Mode == filemode.Symlink {
+			mode |= os.ModeSymlink
+		}
+		hdr.SetMode(mode)
+
+		fw, err := zw.CreateHeader(hdr)
+		if err != nil {
+			return err
+		}
+		// A symlink's blob is its target, which is also how zip stores symlinks
+		return copyBlob(f, fw)
+	})
+	if err != nil {
+		return err
+	}
+	return zw.Close()
+}
+
+func archiveMode(m filemode.FileMode) int64 {
+	if m == filemode.Executable {
+		return 0755
+	}
+	if m == filemode.Symlink {
+		return 0777
+	}
+	return 0644
+}
+
+func copyBlob(f *object.File, w io.Writer) error {
+	r, err := f.Reader()
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+
+	_, err = io.Copy(w, r)
+	return err
+}
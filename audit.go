@@ -0,0 +1,323 @@
+package githelpers
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/xanzy/go-gitlab"
+)
+
+const (
+	defaultAuditStaleAfter = 90 * 24 * time.Hour
+)
+
+var (
+	// licenseFiles are the names a repo's license is usually found under at the top of the tree
+	licenseFiles = []string{"LICENSE", "LICENSE.md", "LICENSE.txt", "LICENCE", "COPYING"}
+)
+
+// AuditOption customizes what Audit checks
+type AuditOption func(*auditConfig)
+
+type auditConfig struct {
+	staleAfter    time.Duration
+	largeFileSize int64
+	keyring       io.Reader
+}
+
+// WithAuditStaleAfter reports branches without commits in d as stale, instead of 90 days
+func WithAuditStaleAfter(d time.Duration) AuditOption {
+	return func(cfg *auditConfig) {
+		cfg.staleAfter = d
+	}
+}
+
+// WithAuditLargeFileSize reports files bigger than size bytes as large, instead of the GitRepo's MaxFileSize
+func WithAuditLargeFileSize(size int64) AuditOption {
+	return func(cfg *auditConfig) {
+		cfg.largeFileSize = size
+	}
+}
+
+// WithAuditKeyring reports the commits whose signatures don't verify against keyring, as
+// VerifyCommitSignatures checks them, instead of only the unsigned ones
+func WithAuditKeyring(keyring io.Reader) AuditOption {
+	return func(cfg *auditConfig) {
+		cfg.keyring = keyring
+	}
+}
+
+// AuditReport is the compliance status of a repo's default branch
+type AuditReport struct {
+	Repo          string `json:"repo"`
+	DefaultBranch string `json:"default_branch"`
+	// Nil when the GitRepo has no GitLab client to check with
+	DefaultBranchProtected *bool         `json:"default_branch_protected,omitempty"`
+	HasCodeOwners          bool          `json:"has_codeowners"`
+	HasGitignore           bool          `json:"has_gitignore"`
+	HasLicense             bool          `json:"has_license"`
+	StaleBranches          []StaleBranch `json:"stale_branches,omitempty"`
+	LargeFiles             []LargeFile   `json:"large_files,omitempty"`
+	UnsignedCommits        []string      `json:"unsigned_commits,omitempty"` // Hashes, newest first
+}
+
+// StaleBranch is a branch without recent commits
+type StaleBranch struct {
+	Name       string    `json:"name"`
+	LastCommit time.Time `json:"last_commit"`
+}
+
+// LargeFile is a file on the default branch bigger than the audit's threshold
+type LargeFile struct {
+	Path string `json:"path"`
+	Size int64  `json:"size"`
+}
+
+// Audit checks the GitRepo's default branch for what compliance tooling usually asks of a repo: branch
+// protection, CODEOWNERS, .gitignore, and LICENSE files, stale branches, large files, and unsigned
+// commits. Everything but protection is read from the clone, so fetch first. Protection and the default
+// branch come from GitLab when the GitRepo has a GitLab client, and are skipped or guessed from the
+// clone otherwise
+func (gr *GitRepo) Audit(opts ...AuditOption) (report *AuditReport, resp *gitlab.Response, err error) {
+	cfg := auditConfig{staleAfter: defaultAuditStaleAfter, largeFileSize: gr.maxFileSize()}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	report = &AuditReport{Repo: gr.SSHURL}
+
+	c, clientErr := gr.gitlabClient()
+	if clientErr == nil {
+		pid, r, err := gr.getGitlabProjectID(gr.SSHURL)
+		if err != nil {
+			return report, r, err
+		}
+		p, r, err := c.Projects.GetProject(pid, &gitlab.GetProjectOptions{})
+		if err != nil {
+			return report, r, err
+		}
+		report.DefaultBranch = p.DefaultBranch
+
+		b, r, err := c.Branches.GetBranch(pid, p.DefaultBranch)
+		if err != nil {
+			return report, r, err
+		}
+		report.DefaultBranchProtected = &b.Protected
+		resp = r
+	} else {
+		report.DefaultBranch, err = gr.localDefaultBranch()
+		if err != nil {
+			return report, resp, err
+		}
+	}
+
+	head, ref, err := gr.branchCommit(report.DefaultBranch, true)
+	if err != nil {
+		return report, resp, err
+	}
+	tree, err := head.Tree()
+	if err != nil {
+		return report, resp, err
+	}
+
+	report.HasCodeOwners = treeHasAny(tree, codeOwnersLocations)
+	report.HasGitignore = treeHasAny(tree, []string{".gitignore"})
+	report.HasLicense = treeHasAny(tree, licenseFiles)
+
+	if cfg.largeFileSize >= 0 {
+		report.LargeFiles, err = largeTreeFiles(tree, cfg.largeFileSize)
+		if err != nil {
+			return report, resp, err
+		}
+	}
+
+	report.StaleBranches, err = gr.staleBranches(report.DefaultBranch, time.Now().Add(-cfg.staleAfter))
+	if err != nil {
+		return report, resp, err
+	}
+
+	unverified, err := gr.VerifyCommitSignatures(ref, cfg.keyring)
+	if err != nil {
+		return report, resp, err
+	}
+	for _, u := range unverified {
+		if cfg.keyring != nil || u.Reason == SignatureMissing {
+			report.UnsignedCommits = append(report.UnsignedCommits, u.Hash.String())
+		}
+	}
+	return report, resp, nil
+}
+
+// Problems describes each check the repo fails, empty when it passes them all
+func (r *AuditReport) Problems() (problems []string) {
+	if r.DefaultBranchProtected != nil && !*r.DefaultBranchProtected {
+		problems = append(problems, fmt.Sprintf("default branch %s isn't protected", r.DefaultBranch))
+	}
+	if !r.HasCodeOwners {
+		problems = append(problems, "no CODEOWNERS file")
+	}
+	if !r.HasGitignore {
+		problems = append(problems, "no .gitignore file")
+	}
+	if !r.HasLicense {
+		problems = append(problems, "no LICENSE file")
+	}
+	if n := len(r.StaleBranches); n > 0 {
+		problems = append(problems, fmt.Sprintf("%d stale branches", n))
+	}
+	if n := len(r.LargeFiles); n > 0 {
+		problems = append(problems, fmt.Sprintf("%d large files", n))
+	}
+	if n := len(r.UnsignedCommits); n > 0 {
+		problems = append(problems, fmt.Sprintf("%d unsigned commits", n))
+	}
+	return problems
+}
+
+// JSON renders the report as indented JSON
+func (r *AuditReport) JSON() ([]byte, error) {
+	return json.MarshalIndent(r, "", "  ")
+}
+
+// Markdown renders the report as a checklist followed by the stale branches and large files
+func (r *AuditReport) Markdown() string {
+	var b strings.Builder
+
+	fmt.Fprintf(&b, "**Audit of %s** (%s)\n\n", r.Repo, r.DefaultBranch)
+	protected := "unknown"
+	if r.DefaultBranchProtected != nil {
+		protected = auditMark(*r.DefaultBranchProtected)
+	}
+	fmt.Fprintf(&b, "| Check | Result |\n| --- | --- |\n")
+	fmt.Fprintf(&b, "| Default branch protected | %s |\n", protected)
+	fmt.Fprintf(&b, "| CODEOWNERS | %s |\n", auditMark(r.HasCodeOwners))
+	fmt.Fprintf(&b, "| .gitignore | %s |\n", auditMark(r.HasGitignore))
+	fmt.Fprintf(&b, "| LICENSE | %s |\n", auditMark(r.HasLicense))
+	fmt.Fprintf(&b, "| Stale branches | %d |\n", len(r.StaleBranches))
+	fmt.Fprintf(&b, "| Large files | %d |\n", len(r.LargeFiles))
+	fmt.Fprintf(&b, "| Unsigned commits | %d |\n", len(r.UnsignedCommits))
+
+	if len(r.StaleBranches) > 0 {
+		b.WriteString("\n**Stale branches**\n\n")
+		for _, s := range r.StaleBranches {
+			fmt.Fprintf(&b, "- %s, last commit %s\n", s.Name, s.LastCommit.Format("2006-01-02"))
+		}
+	}
+	if len(r.LargeFiles) > 0 {
+		b.WriteString("\n**Large files**\n\n")
+		for _, f := range r.LargeFiles {
+			fmt.Fprintf(&b, "- %s, %d bytes\n", f.Path, f.Size)
+		}
+	}
+	return b.String()
+}
+
+func auditMark(ok bool) string {
+	if ok {
+		return "yes"
+	}
+	return "no"
+}
+
+// localDefaultBranch guesses the default branch from origin's HEAD, falling back to the branch checked out
+func (gr *GitRepo) localDefaultBranch() (branch string, err error) {
+	ref, err := gr.Repo.Reference(plumbing.NewRemoteReferenceName(gr.remoteName(), "HEAD"), false)
+	if err == nil && ref.Type() == plumbing.SymbolicReference {
+		return strings.TrimPrefix(ref.Target().String(), plumbing.NewRemoteReferenceName(gr.remoteName(), "").String()), nil
+	}
+
+	head, err := gr.Repo.Head()
+	if err != nil {
+		return branch, err
+	}
+	return head.Name().Short(), nil
+}
+
+// branchCommit returns the tip of branch, from either origin's copy or the local one, trying the first
+// one first. An outdated local default branch is better read from origin, a just committed source
+// branch from the local copy
+func (gr *GitRepo) branchCommit(branch string, remoteFirst bool) (c *object.Commit, rev string, err error) {
+	revs := []string{branch, gr.remoteName() + "/" + branch}
+	if remoteFirst {
+		revs[0], revs[1] = revs[1], revs[0]
+	}
+	for _, rev = range revs {
+		c, err = gr.commitAt(rev)
+		if err == nil {
+			return c, rev, nil
+		}
+	}
+	return c, rev, fmt.Errorf("resolving branch %s: %w", branch, err)
+}
+
+// staleBranches returns the branches on origin, or the local ones when the clone has no remote branches,
+// whose last commit is older than cutoff
+func (gr *GitRepo) staleBranches(defaultBranch string, cutoff time.Time) (stale []StaleBranch, err error) {
+	refs, err := gr.Repo.References()
+	if err != nil {
+		return stale, err
+	}
+	remotePrefix := plumbing.NewRemoteReferenceName(gr.remoteName(), "").String()
+	remote := map[string]plumbing.Hash{}
+	local := map[string]plumbing.Hash{}
+	err = refs.ForEach(func(ref *plumbing.Reference) error {
+		if ref.Type() != plumbing.HashReference {
+			return nil
+		}
+		name := ref.Name().String()
+		switch {
+		case strings.HasPrefix(name, remotePrefix):
+			remote[strings.TrimPrefix(name, remotePrefix)] = ref.Hash()
+		case ref.Name().IsBranch():
+			local[ref.Name().Short()] = ref.Hash()
+		}
+		return nil
+	})
+	if err != nil {
+		return stale, err
+	}
+
+	branches := remote
+	if len(remote) == 0 {
+		branches = local
+	}
+	for name, h := range branches {
+		if name == defaultBranch {
+			continue
+		}
+		c, err := gr.Repo.CommitObject(h)
+		if err != nil {
+			return stale, err
+		}
+		if c.Committer.When.Before(cutoff) {
+			stale = append(stale, StaleBranch{Name: name, LastCommit: c.Committer.When})
+		}
+	}
+	sort.Slice(stale, func(i, j int) bool { return stale[i].Name < stale[j].Name })
+	return stale, nil
+}
+
+func treeHasAny(tree *object.Tree, paths []string) bool {
+	for _, p := range paths {
+		if _, err := tree.File(p); err == nil {
+			return true
+		}
+	}
+	return false
+}
+
+func largeTreeFiles(tree *object.Tree, threshold int64) (large []LargeFile, err error) {
+	err = tree.Files().ForEach(func(f *object.File) error {
+		if f.Size > threshold {
+			large = append(large, LargeFile{Path: f.Name, Size: f.Size})
+		}
+		return nil
+	})
+	sort.Slice(large, func(i, j int) bool { return large[i].Path < large[j].Path })
+	return large, err
+}
@@ -0,0 +1,216 @@
+package githelpers
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/go-cleanhttp"
+)
+
+const (
+	awsSignAlgorithm     = "AWS4-HMAC-SHA256"
+	awsSecretsService    = "secretsmanager"
+	awsAmzDateFormat     = "20060102T150405Z"
+	awsNotFoundErrorType = "ResourceNotFoundException"
+)
+
+// AWSSecretsManagerSource reads secrets from AWS Secrets Manager over its HTTP API. A secret's name is
+// its ID or ARN, optionally followed by # and the key to read from a secret holding a JSON object,
+// e.g. ci/gitlab#token. Credentials left empty are taken from the standard AWS environment variables.
+// Shared credentials files, instance profiles, and web identity tokens aren't read, so to use those,
+// e.g. from the AWS SDK, set Credentials
+type AWSSecretsManagerSource struct {
+	Region          string // Defaults to AWS_REGION, then AWS_DEFAULT_REGION
+	AccessKeyID     string // Defaults to AWS_ACCESS_KEY_ID
+	SecretAccessKey string // Defaults to AWS_SECRET_ACCESS_KEY
+	SessionToken    string // Defaults to AWS_SESSION_TOKEN
+	Endpoint        string // e.g. a VPC endpoint. Defaults to https://secretsmanager.<region>.amazonaws.com
+	Client          *http.Client
+
+	// Called for the credentials of every request when set, instead of using the fields above and
+	// the environment, so temporary credentials can be refreshed
+	Credentials func(ctx context.Context) (AWSCredentials, error)
+}
+
+// AWSCredentials are the keys AWS requests are signed with
+type AWSCredentials struct {
+	AccessKeyID     string
+	SecretAccessKey string
+	SessionToken    string // Set for temporary credentials
+}
+
+// Secret returns the current version of the named secret: its string, its binary value, or the value
+// of one key of the JSON object in its string
+func (s *AWSSecretsManagerSource) Secret(ctx context.Context, name string) ([]byte, error) {
+	id, key := splitSecretField(name, "")
+	region := firstNonEmpty(s.Region, os.Getenv("AWS_REGION"), os.Getenv("AWS_DEFAULT_REGION"))
+	creds := AWSCredentials{
+		AccessKeyID:     firstNonEmpty(s.AccessKeyID, os.Getenv("AWS_ACCESS_KEY_ID")),
+		SecretAccessKey: firstNonEmpty(s.SecretAccessKey, os.Getenv("AWS_SECRET_ACCESS_KEY")),
+		SessionToken:    firstNonEmpty(s.SessionToken, os.Getenv("AWS_SESSION_TOKEN")),
+	}
+	if s.Credentials != nil {
+		var err error
+		creds, err = s.Credentials(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("reading %s from AWS Secrets Manager: getting credentials: %w", name, err)
+		}
+	}
+	if region == "" || creds.AccessKeyID == "" || creds.SecretAccessKey == "" {
+		return nil, fmt.Errorf("reading %s from AWS Secrets Manager: no region or credentials set", name)
+	}
+	endpoint := s.Endpoint
+	if endpoint == "" {
+		endpoint = fmt.Sprintf("https://%s.%s.amazonaws.com", awsSecretsService, region)
+	}
+
+	body, err := json.Marshal(map[string]string{"SecretId": id})
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, strings.TrimRight(endpoint, "/")+"/", bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-amz-json-1.1")
+	req.Header.Set("X-Amz-Target", "secretsmanager.GetSecretValue")
+	signAWSRequest(req, body, region, awsSecretsService, creds, time.Now())
+
+	client := s.Client
+	if client == nil {
+		client = cleanhttp.DefaultPooledClient()
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		var apiErr struct {
+			Type    string `json:"__type"`
+			Message string `json:"message"`
+		}
+		_ = json.Unmarshal(respBody, &apiErr)
+		if strings.HasSuffix(apiErr.Type, awsNotFoundErrorType) {
+			return nil, fmt.Errorf("AWS secret %s: %w", id, ErrSecretNotFound)
+		}
+		return nil, fmt.Errorf("reading AWS secret %s: %s: %s", id, resp.Status, strings.TrimSpace(string(respBody)))
+	}
+
+	var secret struct {
+		SecretString *string
+		SecretBinary []byte // Sent base64 encoded, which encoding/json decodes
+	}
+	err = json.Unmarshal(respBody, &secret)
+	if err != nil {
+		return nil, fmt.Errorf("reading AWS secret %s: %w", id, err)
+	}
+	if secret.SecretString == nil {
+		return secret.SecretBinary, nil
+	}
+	if key == "" {
+		return []byte(*secret.SecretString), nil
+	}
+
+	var fields map[string]interface{}
+	err = json.Unmarshal([]byte(*secret.SecretString), &fields)
+	if err != nil {
+		return nil, fmt.Errorf("AWS secret %s isn't a JSON object: %w", id, err)
+	}
+	v, ok := fields[key]
+	if !ok {
+		return nil, fmt.Errorf("AWS secret %s has no key %s: %w", id, key, ErrSecretNotFound)
+	}
+	str, ok := v.(string)
+	if !ok {
+		return nil, fmt.Errorf("key %s of AWS secret %s isn't a string", key, id)
+	}
+	return []byte(str), nil
+}
+
+// signAWSRequest signs req, whose body is body, with Signature Version 4, as AWS APIs require. The
+// request must not have a query string
+func signAWSRequest(req *http.Request, body []byte, region, service string, creds AWSCredentials, now time.Time) {
+	amzDate := now.UTC().Format(awsAmzDateFormat)
+	req.Header.Set("X-Amz-Date", amzDate)
+	if creds.SessionToken != "" {
+		req.Header.Set("X-Amz-Security-Token", creds.SessionToken)
+	}
+
+	headers := map[string]string{"host": req.URL.Host}
+	for k, v := range req.Header {
+		headers[strings.ToLower(k)] = strings.TrimSpace(strings.Join(v, ","))
+	}
+	names := make([]string, 0, len(headers))
+	for k := range headers {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+	var canonicalHeaders strings.Builder
+	for _, k := range names {
+		canonicalHeaders.WriteString(k + ":" + headers[k] + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	path := req.URL.EscapedPath()
+	if path == "" {
+		path = "/"
+	}
+	canonicalRequest := strings.Join([]string{
+		req.Method, path, "", canonicalHeaders.String(), signedHeaders, sha256Hex(body),
+	}, "\n")
+
+	scope := strings.Join([]string{amzDate[:8], region, service, "aws4_request"}, "/")
+	stringToSign := strings.Join([]string{awsSignAlgorithm, amzDate, scope, sha256Hex([]byte(canonicalRequest))}, "\n")
+
+	key := awsSigningKey(creds.SecretAccessKey, amzDate[:8], region, service)
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf("%s Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		awsSignAlgorithm, creds.AccessKeyID, scope, signedHeaders, signature))
+}
+
+// awsSigningKey derives the key requests on date, e.g. 20150830, are signed with for region and service
+func awsSigningKey(secretAccessKey, date, region, service string) []byte {
+	key := []byte("AWS4" + secretAccessKey)
+	for _, part := range []string{date, region, service, "aws4_request"} {
+		key = hmacSHA256(key, part)
+	}
+	return key
+}
+
+func sha256Hex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, v := range values {
+		if v != "" {
+			return v
+		}
+	}
+	return ""
+}
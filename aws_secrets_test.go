@@ -0,0 +1,91 @@
+package githelpers
+
+import (
+	"context"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// The credentials of AWS's Signature Version 4 test suite
+var awsTestCredentials = AWSCredentials{
+	AccessKeyID:     "AKIDEXAMPLE",
+	SecretAccessKey: "wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY",
+}
+
+func TestSignAWSRequest(t *testing.T) {
+	// The get-vanilla case of the test suite
+	req, err := http.NewRequest(http.MethodGet, "https://example.amazonaws.com/", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signAWSRequest(req, nil, "us-east-1", "service", awsTestCredentials, time.Date(2015, 8, 30, 12, 36, 0, 0, time.UTC))
+
+	want := "AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/20150830/us-east-1/service/aws4_request, " +
+		"SignedHeaders=host;x-amz-date, Signature=5fa00fa31553b73ebf1942676e86291e8372ff2a2260956d9b8aae1d763fbf31"
+	if got := req.Header.Get("Authorization"); got != want {
+		t.Errorf("got  %s\nwant %s", got, want)
+	}
+	if got := req.Header.Get("X-Amz-Date"); got != "20150830T123600Z" {
+		t.Errorf("X-Amz-Date %s", got)
+	}
+}
+
+func TestAWSSigningKey(t *testing.T) {
+	// The example in AWS's documentation of deriving a signing key
+	key := awsSigningKey(awsTestCredentials.SecretAccessKey, "20120215", "us-east-1", "iam")
+	if got, want := hex.EncodeToString(key), "f4780e2d9f65fa895f9c67b32ce1baf0b0d8a43505a000a1a9e090d414db404d"; got != want {
+		t.Errorf("got %s, want %s", got, want)
+	}
+}
+
+func TestAWSSecretsManagerSource(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Amz-Target") != "secretsmanager.GetSecretValue" || r.Header.Get("X-Amz-Security-Token") != "token" ||
+			!strings.HasPrefix(r.Header.Get("Authorization"), "AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/") {
+			http.Error(w, "unsigned request", http.StatusForbidden)
+			return
+		}
+		body, _ := io.ReadAll(r.Body)
+		if !strings.Contains(string(body), `"ci/gitlab"`) {
+			w.WriteHeader(http.StatusBadRequest)
+			fmt.Fprint(w, `{"__type": "ResourceNotFoundException", "message": "not found"}`)
+			return
+		}
+		fmt.Fprint(w, `{"SecretString": "{\"token\": \"glpat-123\"}"}`)
+	}))
+	defer srv.Close()
+
+	calls := 0
+	s := &AWSSecretsManagerSource{
+		Region:   "eu-west-1",
+		Endpoint: srv.URL,
+		Credentials: func(context.Context) (AWSCredentials, error) {
+			calls++
+			creds := awsTestCredentials
+			creds.SessionToken = "token"
+			return creds, nil
+		},
+	}
+	got, err := s.Secret(context.Background(), "ci/gitlab#token")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != "glpat-123" {
+		t.Errorf("got %q", got)
+	}
+	if calls != 1 {
+		t.Errorf("credentials asked for %d times", calls)
+	}
+
+	_, err = s.Secret(context.Background(), "ci/missing")
+	if !errors.Is(err, ErrSecretNotFound) {
+		t.Errorf("got %v, want ErrSecretNotFound", err)
+	}
+}
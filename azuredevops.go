@@ -0,0 +1,206 @@
+package githelpers
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"strconv"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/microsoft/azure-devops-go-api/azuredevops"
+	"github.com/microsoft/azure-devops-go-api/azuredevops/git"
+	"github.com/microsoft/azure-devops-go-api/azuredevops/policy"
+)
+
+var (
+	// azureMinimumReviewersPolicy is the ID of the built in "Minimum number of reviewers" branch policy type
+	azureMinimumReviewersPolicy = uuid.MustParse("fa4e907d-c16b-4a4c-9dfa-4906e5d171dd")
+)
+
+var _ VCSProvider = (*AzureDevOpsProvider)(nil)
+var _ BranchProtector = (*AzureDevOpsProvider)(nil)
+var _ RepoSizer = (*AzureDevOpsProvider)(nil)
+
+// AzureDevOpsProvider is the VCSProvider for Azure DevOps Repos. Git and Policy are exposed for API
+// calls this package doesn't wrap
+type AzureDevOpsProvider struct {
+	Git    git.Client
+	Policy policy.Client
+
+	orgURL string
+}
+
+// NewAzureDevOpsProvider returns an AzureDevOpsProvider for the organization at orgURL, e.g.
+// https://dev.azure.com/myorg, authenticated with a personal access token
+func NewAzureDevOpsProvider(vcsToken, orgURL string) (p *AzureDevOpsProvider, err error) {
+	conn := azuredevops.NewPatConnection(orgURL, vcsToken)
+	ctx := context.Background()
+
+	gitClient, err := git.NewClient(ctx, conn)
+	if err != nil {
+		return p, err
+	}
+	policyClient, err := policy.NewClient(ctx, conn)
+	if err != nil {
+		return p, err
+	}
+
+	return &AzureDevOpsProvider{
+		Git:    gitClient,
+		Policy: policyClient,
+		orgURL: strings.TrimSuffix(orgURL, "/"),
+	}, nil
+}
+
+// GetProjectID returns the UUID of the Azure DevOps repository at repoURL
+func (p *AzureDevOpsProvider) GetProjectID(repoURL string) (string, error) {
+	repo, err := p.getRepository(repoURL)
+	if err != nil {
+		return "", err
+	}
+	return repo.Id.String(), nil
+}
+
+// CreateProject creates a new repository called name in the Azure DevOps project at namespacePath.
+// Azure DevOps repositories inherit their visibility from the project, so opts are not applied
+func (p *AzureDevOpsProvider) CreateProject(namespacePath, name string, opts ProjectOptions) (RemoteProject, error) {
+	project := path.Base(namespacePath)
+	repo, err := p.Git.CreateRepository(context.Background(), git.CreateRepositoryArgs{
+		GitRepositoryToCreate: &git.GitRepositoryCreateOptions{Name: &name},
+		Project:               &project,
+	})
+	if err != nil {
+		return RemoteProject{}, err
+	}
+
+	return RemoteProject{
+		ID:       repo.Id.String(),
+		FullPath: project + "/" + name,
+		SSHURL:   stringValue(repo.SshUrl),
+		HTTPURL:  stringValue(repo.RemoteUrl),
+		WebURL:   stringValue(repo.WebUrl),
+	}, nil
+}
+
+// CreateMergeRequest opens a pull request from src into dest on the Azure DevOps repository at repoURL
+func (p *AzureDevOpsProvider) CreateMergeRequest(repoURL, title, src, dest string) (MergeRequestInfo, error) {
+	project, name := azureProjectAndRepo(repoURL)
+	srcRef, destRef := "refs/heads/"+src, "refs/heads/"+dest
+
+	pr, err := p.Git.CreatePullRequest(context.Background(), git.CreatePullRequestArgs{
+		GitPullRequestToCreate: &git.GitPullRequest{
+			Title:         &title,
+			SourceRefName: &srcRef,
+			TargetRefName: &destRef,
+		},
+		RepositoryId: &name,
+		Project:      &project,
+	})
+	if err != nil {
+		return MergeRequestInfo{}, err
+	}
+
+	info := MergeRequestInfo{Title: title, SourceBranch: src, TargetBranch: dest}
+	if pr.PullRequestId != nil {
+		info.Number = *pr.PullRequestId
+		info.ID = strconv.Itoa(info.Number)
+		info.URL = fmt.Sprintf("%s/%s/_git/%s/pullrequest/%d", p.orgURL, project, name, info.Number)
+	}
+	return info, nil
+}
+
+// SetStatus reports the state of an external check on a commit of the Azure DevOps repository at
+// repoURL. GitLab state names are accepted and translated to their Azure DevOps equivalents
+func (p *AzureDevOpsProvider) SetStatus(repoURL, sha, state, name, targetURL string) error {
+	project, repo := azureProjectAndRepo(repoURL)
+
+	s := azureStatusState(state)
+	_, err := p.Git.CreateCommitStatus(context.Background(), git.CreateCommitStatusArgs{
+		GitCommitStatusToCreate: &git.GitStatus{
+			Context:   &git.GitStatusContext{Name: &name},
+			State:     &s,
+			TargetUrl: &targetURL,
+		},
+		CommitId:     &sha,
+		RepositoryId: &repo,
+		Project:      &project,
+	})
+	return err
+}
+
+// ProtectBranch adds a blocking branch policy to branch on the Azure DevOps repository at repoURL so
+// that it can only be changed through approved pull requests. Azure DevOps controls push access
+// with security permissions rather than policies, so the access levels in opts are not applied
+func (p *AzureDevOpsProvider) ProtectBranch(repoURL, branch string, opts ProtectOptions) error {
+	repo, err := p.getRepository(repoURL)
+	if err != nil {
+		return err
+	}
+	project, _ := azureProjectAndRepo(repoURL)
+
+	enabled := true
+	_, err = p.Policy.CreatePolicyConfiguration(context.Background(), policy.CreatePolicyConfigurationArgs{
+		Configuration: &policy.PolicyConfiguration{
+			IsEnabled:  &enabled,
+			IsBlocking: &enabled,
+			Type:       &policy.PolicyTypeRef{Id: &azureMinimumReviewersPolicy},
+			Settings: map[string]interface{}{
+				"minimumApproverCount": 1,
+				"creatorVoteCounts":    false,
+				"scope": []map[string]interface{}{{
+					"repositoryId": repo.Id.String(),
+					"refName":      "refs/heads/" + branch,
+					"matchKind":    "exact",
+				}},
+			},
+		},
+		Project: &project,
+	})
+	return err
+}
+
+// RepoSize returns the compressed size of the Azure DevOps repository at repoURL
+func (p *AzureDevOpsProvider) RepoSize(repoURL string) (int64, error) {
+	repo, err := p.getRepository(repoURL)
+	if err != nil {
+		return 0, err
+	}
+	if repo.Size == nil {
+		return 0, ErrNotSupported
+	}
+	return int64(*repo.Size), nil
+}
+
+func (p *AzureDevOpsProvider) getRepository(repoURL string) (*git.GitRepository, error) {
+	project, name := azureProjectAndRepo(repoURL)
+	return p.Git.GetRepository(context.Background(), git.GetRepositoryArgs{
+		RepositoryId: &name,
+		Project:      &project,
+	})
+}
+
+func azureProjectAndRepo(repoURL string) (project, repo string) {
+	// ParseRepoURL leaves Azure DevOps namespaces as org/project
+	_, ns, repo := splitRepoURL(repoURL)
+	return path.Base(ns), repo
+}
+
+func azureStatusState(state string) git.GitStatusState {
+	switch state {
+	case "success":
+		return git.GitStatusStateValues.Succeeded
+	case "failed":
+		return git.GitStatusStateValues.Failed
+	case "canceled":
+		return git.GitStatusStateValues.Error
+	}
+	return git.GitStatusStateValues.Pending
+}
+
+func stringValue(s *string) string {
+	if s == nil {
+		return ""
+	}
+	return *s
+}
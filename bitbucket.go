@@ -0,0 +1,253 @@
+package githelpers
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+const (
+	bitbucketCloudAPI = "https://api.bitbucket.org/2.0"
+)
+
+var _ VCSProvider = (*BitbucketProvider)(nil)
+var _ RepoSizer = (*BitbucketProvider)(nil)
+
+// BitbucketProvider is the VCSProvider for Bitbucket Cloud and Bitbucket Server/Data Center. It talks
+// to the REST APIs directly since no single client library covers both
+type BitbucketProvider struct {
+	HTTPClient *http.Client
+	ServerURL  string // Base URL of a Bitbucket Server instance. Empty means Bitbucket Cloud
+
+	username string
+	token    string
+}
+
+// NewBitbucketProvider returns a BitbucketProvider. With a username, token is sent as a Bitbucket
+// Cloud app password over basic auth, otherwise as a bearer access token. An empty serverURL talks
+// to Bitbucket Cloud
+func NewBitbucketProvider(username, vcsToken, serverURL string) *BitbucketProvider {
+	return &BitbucketProvider{
+		HTTPClient: http.DefaultClient,
+		ServerURL:  strings.TrimSuffix(serverURL, "/"),
+		username:   username,
+		token:      vcsToken,
+	}
+}
+
+// GetProjectID returns the UUID of the Bitbucket Cloud repository, or the numeric ID of the
+// Bitbucket Server repository, at repoURL
+func (p *BitbucketProvider) GetProjectID(repoURL string) (string, error) {
+	if p.ServerURL == "" {
+		var repo struct {
+			UUID string `json:"uuid"`
+		}
+		err := p.do(http.MethodGet, p.cloudRepoURL(repoURL), nil, &repo)
+		return repo.UUID, err
+	}
+
+	var repo struct {
+		ID int `json:"id"`
+	}
+	err := p.do(http.MethodGet, p.serverRepoURL(repoURL), nil, &repo)
+	return strconv.Itoa(repo.ID), err
+}
+
+// CreateProject creates a new repository called name in the Bitbucket Cloud workspace, or the
+// Bitbucket Server project key, at namespacePath
+func (p *BitbucketProvider) CreateProject(namespacePath, name string, opts ProjectOptions) (RemoteProject, error) {
+	var repo bitbucketRepo
+	if p.ServerURL == "" {
+		body := map[string]interface{}{
+			"scm":        "git",
+			"is_private": opts.Visibility != "public",
+		}
+		if opts.Description != "" {
+			body["description"] = opts.Description
+		}
+		url := fmt.Sprintf("%s/repositories/%s/%s", bitbucketCloudAPI, namespacePath, strings.ToLower(name))
+		err := p.do(http.MethodPost, url, body, &repo)
+		return repo.remoteProject(), err
+	}
+
+	body := map[string]interface{}{
+		"name":   name,
+		"scmId":  "git",
+		"public": opts.Visibility == "public",
+	}
+	url := fmt.Sprintf("%s/rest/api/1.0/projects/%s/repos", p.ServerURL, namespacePath)
+	err := p.do(http.MethodPost, url, body, &repo)
+	return repo.remoteProject(), err
+}
+
+// CreateMergeRequest opens a pull request from src into dest on the Bitbucket repository at repoURL
+func (p *BitbucketProvider) CreateMergeRequest(repoURL, title, src, dest string) (MergeRequestInfo, error) {
+	info := MergeRequestInfo{Title: title, SourceBranch: src, TargetBranch: dest}
+
+	if p.ServerURL == "" {
+		body := map[string]interface{}{
+			"title":       title,
+			"source":      map[string]interface{}{"branch": map[string]string{"name": src}},
+			"destination": map[string]interface{}{"branch": map[string]string{"name": dest}},
+		}
+		var pr struct {
+			ID    int `json:"id"`
+			Links struct {
+				HTML struct {
+					Href string `json:"href"`
+				} `json:"html"`
+			} `json:"links"`
+		}
+		err := p.do(http.MethodPost, p.cloudRepoURL(repoURL)+"/pullrequests", body, &pr)
+		info.ID, info.Number, info.URL = strconv.Itoa(pr.ID), pr.ID, pr.Links.HTML.Href
+		return info, err
+	}
+
+	body := map[string]interface{}{
+		"title":   title,
+		"fromRef": map[string]string{"id": "refs/heads/" + src},
+		"toRef":   map[string]string{"id": "refs/heads/" + dest},
+	}
+	var pr struct {
+		ID    int `json:"id"`
+		Links struct {
+			Self []struct {
+				Href string `json:"href"`
+			} `json:"self"`
+		} `json:"links"`
+	}
+	err := p.do(http.MethodPost, p.serverRepoURL(repoURL)+"/pull-requests", body, &pr)
+	info.ID, info.Number = strconv.Itoa(pr.ID), pr.ID
+	if len(pr.Links.Self) > 0 {
+		info.URL = pr.Links.Self[0].Href
+	}
+	return info, err
+}
+
+// SetStatus reports the state of an external build on a commit of the Bitbucket repository at
+// repoURL. GitLab state names are accepted and translated to their Bitbucket equivalents
+func (p *BitbucketProvider) SetStatus(repoURL, sha, state, name, targetURL string) error {
+	body := map[string]string{
+		"state": bitbucketState(state),
+		"key":   name,
+		"name":  name,
+		"url":   targetURL,
+	}
+
+	if p.ServerURL == "" {
+		return p.do(http.MethodPost, p.cloudRepoURL(repoURL)+"/commit/"+sha+"/statuses/build", body, nil)
+	}
+	return p.do(http.MethodPost, p.ServerURL+"/rest/build-status/1.0/commits/"+sha, body, nil)
+}
+
+// RepoSize returns the size of the Bitbucket Cloud repository at repoURL. Bitbucket Server doesn't
+// report repository sizes, so ErrNotSupported is returned for it
+func (p *BitbucketProvider) RepoSize(repoURL string) (int64, error) {
+	if p.ServerURL != "" {
+		return 0, ErrNotSupported
+	}
+	var repo struct {
+		Size int64 `json:"size"`
+	}
+	err := p.do(http.MethodGet, p.cloudRepoURL(repoURL), nil, &repo)
+	return repo.Size, err
+}
+
+func (p *BitbucketProvider) cloudRepoURL(repoURL string) string {
+	_, workspace, name := splitRepoURL(repoURL)
+	return fmt.Sprintf("%s/repositories/%s/%s", bitbucketCloudAPI, workspace, name)
+}
+
+func (p *BitbucketProvider) serverRepoURL(repoURL string) string {
+	_, project, name := splitRepoURL(repoURL)
+	return fmt.Sprintf("%s/rest/api/1.0/projects/%s/repos/%s", p.ServerURL, project, name)
+}
+
+func (p *BitbucketProvider) do(method, url string, body, out interface{}) error {
+	var reqBody bytes.Buffer
+	if body != nil {
+		err := json.NewEncoder(&reqBody).Encode(body)
+		if err != nil {
+			return err
+		}
+	}
+
+	req, err := http.NewRequest(method, url, &reqBody)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if p.username != "" {
+		req.SetBasicAuth(p.username, p.token)
+	} else {
+		req.Header.Set("Authorization", "Bearer "+p.token)
+	}
+
+	resp, err := p.HTTPClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		msg, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("%s %s: %d %s", method, url, resp.StatusCode, strings.TrimSpace(string(msg)))
+	}
+	if out == nil {
+		return nil
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+// bitbucketRepo holds the fields shared by Bitbucket Cloud and Server repository responses
+type bitbucketRepo struct {
+	ID       int    `json:"id"`
+	UUID     string `json:"uuid"`
+	FullName string `json:"full_name"`
+	Slug     string `json:"slug"`
+	Links    struct {
+		Clone []struct {
+			Name string `json:"name"`
+			Href string `json:"href"`
+		} `json:"clone"`
+		HTML struct {
+			Href string `json:"href"`
+		} `json:"html"`
+	} `json:"links"`
+}
+
+func (r bitbucketRepo) remoteProject() RemoteProject {
+	rp := RemoteProject{ID: r.UUID, FullPath: r.FullName, WebURL: r.Links.HTML.Href}
+	if rp.ID == "" {
+		rp.ID = strconv.Itoa(r.ID)
+		rp.FullPath = r.Slug
+	}
+	for _, c := range r.Links.Clone {
+		switch c.Name {
+		case "ssh":
+			rp.SSHURL = c.Href
+		case "https", "http":
+			rp.HTTPURL = c.Href
+		}
+	}
+	return rp
+}
+
+func bitbucketState(state string) string {
+	switch state {
+	case "success":
+		return "SUCCESSFUL"
+	case "failed":
+		return "FAILED"
+	case "canceled":
+		return "STOPPED"
+	}
+	return "INPROGRESS"
+}
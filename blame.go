@@ -0,0 +1,92 @@
+package githelpers
+
+import (
+	"sort"
+	"time"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+)
+
+// BlameLine is a line of a file with the commit that last changed it
+type BlameLine struct {
+	Number      int // 1 based
+	Text        string
+	Commit      plumbing.Hash
+	AuthorName  string
+	AuthorEmail string
+	Date        time.Time
+}
+
+// Blame annotates every line of the file at path with the commit, author, and date that last changed
+// it as of ref. An empty ref means HEAD
+func (gr *GitRepo) Blame(path, ref string) (lines []BlameLine, err error) {
+	if ref == "" {
+		ref = "HEAD"
+	}
+	c, err := gr.commitAt(ref)
+	if err != nil {
+		return lines, err
+	}
+
+	result, err := git.Blame(c, path)
+	if err != nil {
+		return lines, err
+	}
+
+	// go-git only keeps the author's email per line, so look the names up once per commit
+	names := map[plumbing.Hash]string{}
+	for i, l := range result.Lines {
+		name, ok := names[l.Hash]
+		if !ok {
+			commit, err := gr.Repo.CommitObject(l.Hash)
+			if err != nil {
+				return lines, err
+			}
+			name = commit.Author.Name
+			names[l.Hash] = name
+		}
+
+		lines = append(lines, BlameLine{
+			Number:      i + 1,
+			Text:        l.Text,
+			Commit:      l.Hash,
+			AuthorName:  name,
+			AuthorEmail: l.Author,
+			Date:        l.Date,
+		})
+	}
+	return lines, nil
+}
+
+// RecentEditors returns the emails of the authors of lines first through last of a Blame result,
+// most recent first and without duplicates. A last of 0 means the end of the file
+func RecentEditors(lines []BlameLine, first, last int) (emails []string) {
+	if last <= 0 || last > len(lines) {
+		last = len(lines)
+	}
+	if first < 1 {
+		first = 1
+	}
+
+	latest := map[string]time.Time{}
+	for _, l := range lines {
+		if l.Number < first || l.Number > last {
+			continue
+		}
+		if d, ok := latest[l.AuthorEmail]; !ok || l.Date.After(d) {
+			latest[l.AuthorEmail] = l.Date
+		}
+	}
+
+	for e := range latest {
+		emails = append(emails, e)
+	}
+	sort.Slice(emails, func(i, j int) bool {
+		if latest[emails[i]].Equal(latest[emails[j]]) {
+			return emails[i] < emails[j]
+		}
+		return latest[emails[i]].After(latest[emails[j]])
+	})
+	return emails
+}
@@ -0,0 +1,178 @@
+package githelpers
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+)
+
+const (
+	defaultRandomSuffixLength = 8
+	maxBranchCollisions       = 100
+)
+
+var (
+	// ErrBranchNameTaken is returned when WithBranchCollisionCheck can't find a free branch name
+	ErrBranchNameTaken = errors.New("no free branch name found")
+)
+
+// BranchOption customizes the name NewBranchWithOptions gives a branch
+type BranchOption func(*branchConfig)
+
+type branchConfig struct {
+	prefix         string
+	suffixes       []func(gr *GitRepo) (string, error)
+	checkCollision bool
+}
+
+// WithBranchPrefix starts the branch name with prefix, e.g. "bot/"
+func WithBranchPrefix(prefix string) BranchOption {
+	return func(cfg *branchConfig) {
+		cfg.prefix = prefix
+	}
+}
+
+// WithBranchEpochSuffix appends the current Unix time, as NewBranch's uniqSuffix does
+func WithBranchEpochSuffix() BranchOption {
+	return withBranchSuffix(func(gr *GitRepo) (string, error) {
+		return strconv.FormatInt(time.Now().Unix(), 10), nil
+	})
+}
+
+// WithBranchDateSuffix appends the current UTC time formatted with layout, e.g. "20060102"
+func WithBranchDateSuffix(layout string) BranchOption {
+	return withBranchSuffix(func(gr *GitRepo) (string, error) {
+		return time.Now().UTC().Format(layout), nil
+	})
+}
+
+// WithBranchSHASuffix appends the first 7 characters of the HEAD commit's hash
+func WithBranchSHASuffix() BranchOption {
+	return withBranchSuffix(func(gr *GitRepo) (string, error) {
+		head, err := gr.Repo.Head()
+		if err != nil {
+			return "", err
+		}
+		return head.Hash().String()[:7], nil
+	})
+}
+
+// WithBranchRandomSuffix appends a random hex token of length characters. Defaults to 8 when length isn't positive
+func WithBranchRandomSuffix(length int) BranchOption {
+	if length <= 0 {
+		length = defaultRandomSuffixLength
+	}
+	return withBranchSuffix(func(gr *GitRepo) (string, error) {
+		b := make([]byte, (length+1)/2)
+		_, err := rand.Read(b)
+		if err != nil {
+			return "", err
+		}
+		return hex.EncodeToString(b)[:length], nil
+	})
+}
+
+// WithBranchCollisionCheck makes sure no branch with the generated name exists locally or on the
+// default remote, appending -2, -3, and so on until the name is free
+func WithBranchCollisionCheck() BranchOption {
+	return func(cfg *branchConfig) {
+		cfg.checkCollision = true
+	}
+}
+
+func withBranchSuffix(fn func(gr *GitRepo) (string, error)) BranchOption {
+	return func(cfg *branchConfig) {
+		cfg.suffixes = append(cfg.suffixes, fn)
+	}
+}
+
+// BranchName builds a branch name from name and the given options without creating the branch.
+// Suffixes are joined with "-" in the order the options are given, and the result goes through SanitizeBranchName
+func (gr *GitRepo) BranchName(name string, opts ...BranchOption) (branch string, err error) {
+	cfg := branchConfig{}
+	for _, o := range opts {
+		o(&cfg)
+	}
+
+	branch = cfg.prefix + name
+	for _, suffix := range cfg.suffixes {
+		s, err := suffix(gr)
+		if err != nil {
+			return branch, err
+		}
+		branch = branch + "-" + s
+	}
+
+	branch, err = SanitizeBranchName(branch)
+	if err != nil {
+		return branch, err
+	}
+
+	if !cfg.checkCollision {
+		return branch, nil
+	}
+
+	existing, err := gr.branchNames()
+	if err != nil {
+		return branch, err
+	}
+	if !existing[branch] {
+		return branch, nil
+	}
+	for i := 2; i <= maxBranchCollisions; i++ {
+		candidate := appendBranchSuffix(branch, strconv.Itoa(i))
+		if !existing[candidate] {
+			return candidate, nil
+		}
+	}
+	return branch, ErrBranchNameTaken
+}
+
+// NewBranchWithOptions creates and checks out a new branch named by BranchName
+func (gr *GitRepo) NewBranchWithOptions(name string, opts ...BranchOption) (string, error) {
+	branch, err := gr.BranchName(name, opts...)
+	if err != nil {
+		return branch, err
+	}
+	return branch, gr.checkoutNewBranch(branch)
+}
+
+func (gr *GitRepo) branchNames() (names map[string]bool, err error) {
+	names = map[string]bool{}
+
+	branches, err := gr.Repo.Branches()
+	if err != nil {
+		return names, err
+	}
+	err = branches.ForEach(func(ref *plumbing.Reference) error {
+		names[ref.Name().Short()] = true
+		return nil
+	})
+	if err != nil {
+		return names, err
+	}
+
+	remote, err := gr.Repo.Remote(gr.remoteName())
+	if err == git.ErrRemoteNotFound {
+		return names, nil
+	}
+	if err != nil {
+		return names, err
+	}
+
+	refs, err := remote.List(&git.ListOptions{Auth: gr.remoteAuth(gr.remoteName())})
+	if err != nil {
+		return names, err
+	}
+	for _, ref := range refs {
+		if ref.Name().IsBranch() {
+			names[ref.Name().Short()] = true
+		}
+	}
+	return names, nil
+}
@@ -0,0 +1,125 @@
+package githelpers
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+)
+
+const (
+	// Leaves room for "refs/heads/" within the 255 byte file name limit most filesystems have
+	maxBranchNameLength = 244
+)
+
+var (
+	// Characters git check-ref-format rejects anywhere in a ref name
+	invalidRefChars = regexp.MustCompile(`[\x00-\x20\x7f~^:?*\[\\]+`)
+	repeatedDashes  = regexp.MustCompile(`-{2,}`)
+)
+
+// InvalidBranchNameError is returned when a branch name breaks the git check-ref-format rules
+type InvalidBranchNameError struct {
+	Name   string
+	Reason string
+}
+
+func (e *InvalidBranchNameError) Error() string {
+	return fmt.Sprintf("invalid branch name %q: %s", e.Name, e.Reason)
+}
+
+// ValidateBranchName checks name against the rules of git check-ref-format --branch, returning an
+// *InvalidBranchNameError describing the first rule it breaks
+func ValidateBranchName(name string) error {
+	invalid := func(reason string) error {
+		return &InvalidBranchNameError{Name: name, Reason: reason}
+	}
+
+	switch {
+	case name == "":
+		return invalid("name is empty")
+	case name == "@":
+		return invalid(`name is "@"`)
+	case name == "HEAD":
+		return invalid(`name is "HEAD"`)
+	case len(name) > maxBranchNameLength:
+		return invalid(fmt.Sprintf("name is longer than %d bytes", maxBranchNameLength))
+	case strings.HasPrefix(name, "-"):
+		return invalid(`name starts with "-"`)
+	case strings.HasPrefix(name, "/") || strings.HasSuffix(name, "/"):
+		return invalid(`name starts or ends with "/"`)
+	case strings.HasSuffix(name, "."):
+		return invalid(`name ends with "."`)
+	case strings.Contains(name, "//"):
+		return invalid(`name contains "//"`)
+	case strings.Contains(name, ".."):
+		return invalid(`name contains ".."`)
+	case strings.Contains(name, "@{"):
+		return invalid(`name contains "@{"`)
+	case invalidRefChars.MatchString(name):
+		return invalid("name contains a space, control character, or one of ~^:?*[\\")
+	}
+
+	for _, component := range strings.Split(name, "/") {
+		if strings.HasPrefix(component, ".") {
+			return invalid(`a path component starts with "."`)
+		}
+		if strings.HasSuffix(component, ".lock") {
+			return invalid(`a path component ends with ".lock"`)
+		}
+	}
+	return nil
+}
+
+// SanitizeBranchName turns name into a valid branch name: it lowercases it, replaces invalid
+// characters with "-", drops the dots and slashes git doesn't allow, and truncates it to 244 bytes.
+// An *InvalidBranchNameError is returned if nothing usable is left
+func SanitizeBranchName(name string) (branch string, err error) {
+	branch = strings.ToLower(name)
+	branch = invalidRefChars.ReplaceAllString(branch, "-")
+	branch = strings.Replace(branch, "@{", "-", -1)
+	for strings.Contains(branch, "..") {
+		branch = strings.Replace(branch, "..", ".", -1)
+	}
+
+	var components []string
+	for _, c := range strings.Split(branch, "/") {
+		c = strings.TrimLeft(c, ".")
+		for strings.HasSuffix(c, ".lock") {
+			c = strings.TrimSuffix(c, ".lock")
+		}
+		if c != "" {
+			components = append(components, c)
+		}
+	}
+	branch = repeatedDashes.ReplaceAllString(strings.Join(components, "/"), "-")
+
+	branch = strings.TrimLeft(branch, "-/")
+	branch = truncateBranchName(branch, maxBranchNameLength)
+
+	return branch, ValidateBranchName(branch)
+}
+
+// truncateBranchName cuts branch down to at most max bytes, without splitting a multi-byte character,
+// and drops what git doesn't allow at the end of a name the cut may leave there
+func truncateBranchName(branch string, max int) string {
+	if len(branch) > max {
+		for max > 0 && !utf8.RuneStart(branch[max]) {
+			max--
+		}
+		branch = branch[:max]
+	}
+	for {
+		trimmed := strings.TrimSuffix(strings.TrimRight(branch, "-./"), ".lock")
+		if trimmed == branch {
+			return branch
+		}
+		branch = trimmed
+	}
+}
+
+// appendBranchSuffix joins suffix to branch with "-", shortening branch as needed for the result to
+// fit within the branch name length limit
+func appendBranchSuffix(branch, suffix string) string {
+	return truncateBranchName(branch, maxBranchNameLength-len(suffix)-1) + "-" + suffix
+}
@@ -0,0 +1,268 @@
+package githelpers
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/config"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/format/packfile"
+	"github.com/go-git/go-git/v5/plumbing/revlist"
+)
+
+const (
+	bundleSignature = "# v2 git bundle"
+	// Same delta search window as git pack-objects
+	bundlePackWindow = 10
+)
+
+var (
+	// ErrInvalidBundle is returned for files that aren't git bundles in the v2 format
+	ErrInvalidBundle = errors.New("not a v2 git bundle")
+	// ErrIncrementalBundle is returned by CloneFromBundle for bundles that need objects from another repo
+	ErrIncrementalBundle = errors.New("bundle has prerequisites and can't be cloned from on its own")
+)
+
+// CreateBundle writes the given refs and everything reachable from them to a bundle file at path,
+// which git clone, git fetch, and CloneFromBundle can read, e.g. to move a repo between GitLab
+// instances that can't reach each other. Refs are full names like refs/heads/main, branch or tag
+// names, or HEAD. No refs means every branch and tag, plus HEAD
+func (gr *GitRepo) CreateBundle(path string, refs []string) error {
+	gr.mu.Lock()
+	defer gr.mu.Unlock()
+
+	bundled, err := gr.bundleRefs(refs)
+	if err != nil {
+		return err
+	}
+
+	var tips []plumbing.Hash
+	for _, r := range bundled {
+		tips = append(tips, r.Hash())
+	}
+	objects, err := revlist.Objects(gr.Repo.Storer, tips, nil)
+	if err != nil {
+		return err
+	}
+
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	w := bufio.NewWriter(f)
+	fmt.Fprintln(w, bundleSignature)
+	for _, r := range bundled {
+		fmt.Fprintf(w, "%s %s\n", r.Hash(), r.Name())
+	}
+	fmt.Fprintln(w)
+
+	_, err = packfile.NewEncoder(w, gr.Repo.Storer, false).Encode(objects, bundlePackWindow)
+	if err != nil {
+		return err
+	}
+	err = w.Flush()
+	if err != nil {
+		return err
+	}
+	return f.Close()
+}
+
+// CloneFromBundle clones the GitRepo's Dir from the bundle file at path instead of from a remote. The
+// bundle's branches become origin's remote-tracking branches and the one HEAD points at is checked out.
+// origin is set to SSHURL when the GitRepo has one, so the clone can be pushed to its new home, and to
+// the bundle otherwise
+func (gr *GitRepo) CloneFromBundle(path string) (repo *git.Repository, err error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return repo, err
+	}
+	defer f.Close()
+
+	r := bufio.NewReader(f)
+	refs, prerequisites, err := readBundleHeader(r)
+	if err != nil {
+		return repo, err
+	}
+	if len(prerequisites) > 0 {
+		return repo, ErrIncrementalBundle
+	}
+
+	gr.mu.Lock()
+	defer gr.mu.Unlock()
+
+	repo, err = gr.initRepo(false)
+	if err != nil {
+		return repo, err
+	}
+
+	err = packfile.UpdateObjectStorage(repo.Storer, r)
+	if err != nil {
+		return repo, err
+	}
+
+	url := gr.SSHURL
+	if url == "" {
+		url = path
+	}
+	_, err = repo.CreateRemote(&config.RemoteConfig{
+		Name: gr.remoteName(),
+		URLs: []string{url},
+	})
+	if err != nil {
+		return repo, err
+	}
+
+	var head *plumbing.Reference
+	var branches []*plumbing.Reference
+	for _, ref := range refs {
+		switch {
+		case ref.Name() == plumbing.HEAD:
+			head = ref
+		case ref.Name().IsBranch():
+			branches = append(branches, ref)
+			err = repo.Storer.SetReference(plumbing.NewHashReference(
+				plumbing.NewRemoteReferenceName(gr.remoteName(), ref.Name().Short()), ref.Hash()))
+		default:
+			err = repo.Storer.SetReference(ref)
+		}
+		if err != nil {
+			return repo, err
+		}
+	}
+
+	// Bundles record what HEAD points at but not its name, so check out the first branch it matches.
+	// Without a HEAD, the first branch will do
+	var checkout *plumbing.Reference
+	for _, b := range branches {
+		if head == nil || b.Hash() == head.Hash() {
+			checkout = b
+			break
+		}
+	}
+
+	wt, err := repo.Worktree()
+	if err != nil {
+		return repo, err
+	}
+	gr.Repo = repo
+	gr.Worktree = wt
+
+	switch {
+	case checkout != nil:
+		err = repo.Storer.SetReference(checkout)
+		if err != nil {
+			return repo, err
+		}
+		err = repo.CreateBranch(&config.Branch{Name: checkout.Name().Short(), Remote: gr.remoteName(), Merge: checkout.Name()})
+		if err != nil {
+			return repo, err
+		}
+		return repo, wt.Checkout(&git.CheckoutOptions{Branch: checkout.Name(), Force: true})
+	case head != nil:
+		return repo, wt.Checkout(&git.CheckoutOptions{Hash: head.Hash(), Force: true})
+	}
+	return repo, nil
+}
+
+// BundleRefs lists the refs stored in the bundle file at path
+func BundleRefs(path string) (refs []*plumbing.Reference, err error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return refs, err
+	}
+	defer f.Close()
+
+	refs, _, err = readBundleHeader(bufio.NewReader(f))
+	return refs, err
+}
+
+func (gr *GitRepo) bundleRefs(names []string) (refs []*plumbing.Reference, err error) {
+	if len(names) == 0 {
+		iter, err := gr.Repo.References()
+		if err != nil {
+			return refs, err
+		}
+		// List the branch HEAD is on first, since CloneFromBundle checks out the first branch matching HEAD
+		head, _ := gr.Repo.Reference(plumbing.HEAD, false)
+		err = iter.ForEach(func(r *plumbing.Reference) error {
+			switch {
+			case head != nil && r.Name() == head.Target():
+				refs = append([]*plumbing.Reference{r}, refs...)
+			case r.Name().IsBranch() || r.Name().IsTag():
+				refs = append(refs, r)
+			}
+			return nil
+		})
+		if err != nil {
+			return refs, err
+		}
+		names = []string{plumbing.HEAD.String()}
+	}
+
+	for _, name := range names {
+		var ref *plumbing.Reference
+		for _, candidate := range []plumbing.ReferenceName{
+			plumbing.ReferenceName(name),
+			plumbing.NewBranchReferenceName(name),
+			plumbing.NewTagReferenceName(name),
+		} {
+			ref, err = gr.Repo.Reference(candidate, true)
+			if err == nil {
+				break
+			}
+		}
+		if err != nil {
+			return refs, fmt.Errorf("bundling %s: %w", name, err)
+		}
+		// Keep the name asked for, since resolving HEAD returns the branch it points at
+		name := plumbing.ReferenceName(name)
+		if name != plumbing.HEAD {
+			name = ref.Name()
+		}
+		refs = append(refs, plumbing.NewHashReference(name, ref.Hash()))
+	}
+	return refs, nil
+}
+
+func readBundleHeader(r *bufio.Reader) (refs []*plumbing.Reference, prerequisites []plumbing.Hash, err error) {
+	line, err := r.ReadString('\n')
+	if err != nil || strings.TrimSuffix(line, "\n") != bundleSignature {
+		return refs, prerequisites, ErrInvalidBundle
+	}
+
+	for {
+		line, err = r.ReadString('\n')
+		if err == io.EOF {
+			return refs, prerequisites, ErrInvalidBundle
+		}
+		if err != nil {
+			return refs, prerequisites, err
+		}
+		line = strings.TrimSuffix(line, "\n")
+		if line == "" {
+			return refs, prerequisites, nil
+		}
+
+		// Prerequisite lines are "-<hash> <comment>"
+		if strings.HasPrefix(line, "-") {
+			h := strings.SplitN(line[1:], " ", 2)[0]
+			if !plumbing.IsHash(h) {
+				return refs, prerequisites, ErrInvalidBundle
+			}
+			prerequisites = append(prerequisites, plumbing.NewHash(h))
+			continue
+		}
+		fields := strings.SplitN(line, " ", 2)
+		if len(fields) != 2 || !plumbing.IsHash(fields[0]) {
+			return refs, prerequisites, ErrInvalidBundle
+		}
+		refs = append(refs, plumbing.NewHashReference(plumbing.ReferenceName(fields[1]), plumbing.NewHash(fields[0])))
+	}
+}
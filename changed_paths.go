@@ -0,0 +1,81 @@
+package githelpers
+
+import (
+	"context"
+	"sort"
+
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/go-git/go-git/v5/utils/merkletrie"
+)
+
+// ChangeType is the kind of change git reports for a path, using the letters of git diff --name-status
+type ChangeType string
+
+const (
+	// ChangeAdded is a new file
+	ChangeAdded ChangeType = "A"
+	// ChangeModified is a file whose content or mode changed
+	ChangeModified ChangeType = "M"
+	// ChangeDeleted is a removed file
+	ChangeDeleted ChangeType = "D"
+	// ChangeRenamed is a file moved to a new path, possibly with changes
+	ChangeRenamed ChangeType = "R"
+)
+
+// ChangedPath is a file that differs between two refs
+type ChangedPath struct {
+	Type    ChangeType
+	Path    string // The new path, or the old one for deletions
+	OldPath string // Set for renames only
+}
+
+// ChangedPaths lists the files that differ between the trees of the base and head refs, sorted by
+// path, with renames detected. An empty head means HEAD
+func (gr *GitRepo) ChangedPaths(base, head string) (paths []ChangedPath, err error) {
+	if head == "" {
+		head = "HEAD"
+	}
+	from, err := gr.commitAt(base)
+	if err != nil {
+		return paths, err
+	}
+	to, err := gr.commitAt(head)
+	if err != nil {
+		return paths, err
+	}
+
+	fromTree, err := from.Tree()
+	if err != nil {
+		return paths, err
+	}
+	toTree, err := to.Tree()
+	if err != nil {
+		return paths, err
+	}
+
+	changes, err := object.DiffTreeWithOptions(context.Background(), fromTree, toTree, object.DefaultDiffTreeOptions)
+	if err != nil {
+		return paths, err
+	}
+
+	for _, ch := range changes {
+		action, err := ch.Action()
+		if err != nil {
+			return paths, err
+		}
+
+		switch {
+		case action == merkletrie.Insert:
+			paths = append(paths, ChangedPath{Type: ChangeAdded, Path: ch.To.Name})
+		case action == merkletrie.Delete:
+			paths = append(paths, ChangedPath{Type: ChangeDeleted, Path: ch.From.Name})
+		case ch.From.Name != ch.To.Name:
+			paths = append(paths, ChangedPath{Type: ChangeRenamed, Path: ch.To.Name, OldPath: ch.From.Name})
+		default:
+			paths = append(paths, ChangedPath{Type: ChangeModified, Path: ch.To.Name})
+		}
+	}
+
+	sort.Slice(paths, func(i, j int) bool { return paths[i].Path < paths[j].Path })
+	return paths, nil
+}
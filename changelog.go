@@ -0,0 +1,140 @@
+package githelpers
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+)
+
+// changelogSections orders the changelog, mapping Conventional Commit types to headings. Commits of
+// other types, or that aren't Conventional Commits, go under "Other changes"
+var changelogSections = []struct {
+	Type    string
+	Heading string
+}{
+	{"feat", "Features"},
+	{"fix", "Bug fixes"},
+	{"perf", "Performance improvements"},
+	{"revert", "Reverts"},
+}
+
+// GenerateChangelog renders a Markdown changelog of the commits after fromTag up to and including
+// toTag, grouped by Conventional Commit type, ready to use as GitLab release notes. An empty fromTag
+// starts from the first commit, and an empty toTag ends at HEAD
+func (gr *GitRepo) GenerateChangelog(fromTag, toTag string) (changelog string, err error) {
+	commits, err := gr.commitsBetween(fromTag, toTag)
+	if err != nil {
+		return changelog, err
+	}
+
+	var breaking []string
+	sections := map[string][]string{}
+	for _, c := range commits {
+		msg, err := ParseCommitMessage(c.Message)
+		if err != nil {
+			subject := strings.SplitN(strings.TrimSpace(c.Message), "\n", 2)[0]
+			sections[""] = append(sections[""], changelogEntry("", subject, c.Hash))
+			continue
+		}
+
+		entry := changelogEntry(msg.Scope, msg.Subject, c.Hash)
+		if msg.Breaking {
+			breaking = append(breaking, entry)
+		}
+		if !isChangelogType(msg.Type) {
+			sections[""] = append(sections[""], entry)
+			continue
+		}
+		sections[msg.Type] = append(sections[msg.Type], entry)
+	}
+
+	title := toTag
+	if title == "" {
+		title = "Unreleased"
+	}
+	var b strings.Builder
+	b.WriteString("## " + title)
+	if len(commits) > 0 {
+		b.WriteString(" (" + commits[0].Committer.When.Format("2006-01-02") + ")")
+	}
+	b.WriteString("\n")
+
+	writeSection := func(heading string, entries []string) {
+		if len(entries) == 0 {
+			return
+		}
+		b.WriteString("\n### " + heading + "\n\n")
+		for _, e := range entries {
+			b.WriteString(e + "\n")
+		}
+	}
+	writeSection("Breaking changes", breaking)
+	for _, s := range changelogSections {
+		writeSection(s.Heading, sections[s.Type])
+	}
+	writeSection("Other changes", sections[""])
+
+	return b.String(), nil
+}
+
+func (gr *GitRepo) commitsBetween(fromRev, toRev string) (commits []*object.Commit, err error) {
+	// Returns the commits reachable from toRev but not from fromRev, newest first
+	if toRev == "" {
+		toRev = "HEAD"
+	}
+	to, err := gr.Repo.ResolveRevision(plumbing.Revision(toRev))
+	if err != nil {
+		return commits, fmt.Errorf("resolving %s: %w", toRev, err)
+	}
+
+	seen := map[plumbing.Hash]bool{}
+	if fromRev != "" {
+		from, err := gr.Repo.ResolveRevision(plumbing.Revision(fromRev))
+		if err != nil {
+			return commits, fmt.Errorf("resolving %s: %w", fromRev, err)
+		}
+		iter, err := gr.Repo.Log(&git.LogOptions{From: *from})
+		if err != nil {
+			return commits, err
+		}
+		err = iter.ForEach(func(c *object.Commit) error {
+			seen[c.Hash] = true
+			return nil
+		})
+		if err != nil {
+			return commits, err
+		}
+	}
+
+	iter, err := gr.Repo.Log(&git.LogOptions{From: *to})
+	if err != nil {
+		return commits, err
+	}
+	err = iter.ForEach(func(c *object.Commit) error {
+		if !seen[c.Hash] {
+			commits = append(commits, c)
+		}
+		return nil
+	})
+	return commits, err
+}
+
+func changelogEntry(scope, subject string, hash plumbing.Hash) string {
+	entry := "- "
+	if scope != "" {
+		entry += "**" + scope + ":** "
+	}
+	return entry + subject + " (" + hash.String()[:8] + ")"
+}
+
+func isChangelogType(t string) bool {
+	for _, s := range changelogSections {
+		if s.Type == t {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,92 @@
+package githelpers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/go-git/go-billy/v5"
+)
+
+var (
+	// ErrChangerNotFound is returned when looking up a Changer name nothing was registered under
+	ErrChangerNotFound = errors.New("no changer registered under this name")
+
+	changersMu sync.RWMutex
+	changers   = map[string]ChangerFactory{}
+)
+
+// Changer is a pluggable change to a repo's files, e.g. a code generator or a formatter. worktree is
+// rooted at the top of the clone. changed reports whether Apply touched anything
+type Changer interface {
+	Apply(ctx context.Context, worktree billy.Filesystem) (changed bool, err error)
+}
+
+// ChangerFunc adapts a function to the Changer interface
+type ChangerFunc func(ctx context.Context, worktree billy.Filesystem) (changed bool, err error)
+
+// Apply calls f
+func (f ChangerFunc) Apply(ctx context.Context, worktree billy.Filesystem) (changed bool, err error) {
+	return f(ctx, worktree)
+}
+
+// ChangerFactory builds a Changer from its options, e.g. the options of a changer edit in a RunSpec
+type ChangerFactory func(options map[string]interface{}) (Changer, error)
+
+// RegisterChanger makes a Changer available under name to NewChanger, and so to RunSpec changer edits
+// and the CLI. It's meant to be called from init functions, like database/sql drivers register, and
+// panics when name is already taken
+func RegisterChanger(name string, factory ChangerFactory) {
+	changersMu.Lock()
+	defer changersMu.Unlock()
+
+	if _, ok := changers[name]; ok {
+		panic(fmt.Sprintf("githelpers: changer %q registered twice", name))
+	}
+	changers[name] = factory
+}
+
+// NewChanger builds the Changer registered under name with the given options
+func NewChanger(name string, options map[string]interface{}) (Changer, error) {
+	changersMu.RLock()
+	factory, ok := changers[name]
+	changersMu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("%s: %w", name, ErrChangerNotFound)
+	}
+	return factory(options)
+}
+
+// Changers returns the names of the registered Changers, sorted
+func Changers() (names []string) {
+	changersMu.RLock()
+	defer changersMu.RUnlock()
+
+	for name := range changers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// ChangersFunc returns a ChangeFunc that applies cs to the repo's worktree in order, for use with
+// Fleet.Run and RunInTempClone
+func ChangersFunc(ctx context.Context, cs ...Changer) ChangeFunc {
+	return func(gr *GitRepo) error {
+		fs := gr.worktreeFilesystem()
+		for _, c := range cs {
+			_, err := c.Apply(ctx, fs)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
+
+// RunChangers is Run with the change made by cs, as ChangersFunc applies them
+func (f *Fleet) RunChangers(ctx context.Context, commitMsg, branch string, cs ...Changer) []FleetResult {
+	return f.Run(ctx, commitMsg, branch, ChangersFunc(ctx, cs...))
+}
@@ -0,0 +1,227 @@
+package githelpers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	gitSSH "github.com/go-git/go-git/v5/plumbing/transport/ssh"
+)
+
+var (
+	diskUsagePollInterval = time.Second
+)
+
+// DiskLimitError is returned by RunInTempClone when a repo is, or grows while cloning to be, bigger
+// than the limit set with WithCloneMaxSize
+type DiskLimitError struct {
+	URL   string
+	Size  int64 // Size in bytes reported by the VCS platform, or reached by the clone's directory
+	Limit int64
+}
+
+func (e *DiskLimitError) Error() string {
+	return fmt.Sprintf("repo %s is %d MB, over the %d MB clone limit", e.URL, e.Size>>20, e.Limit>>20)
+}
+
+// CloneOption customizes the GitRepo RunInTempClone clones into
+type CloneOption func(*cloneConfig)
+
+type cloneConfig struct {
+	sshKey      *gitSSH.PublicKeys
+	vcsClient   VCSProvider
+	tempDirOpts []TempDirOption
+	maxSize     int64
+	cache       *CloneCache
+	timeout     time.Duration
+	remoteName  string
+}
+
+// WithCloneSSHKey authenticates the clone, and any push made by the callback, with sshKey
+func WithCloneSSHKey(sshKey *gitSSH.PublicKeys) CloneOption {
+	return func(cfg *cloneConfig) {
+		cfg.sshKey = sshKey
+	}
+}
+
+// WithCloneVCSClient gives the cloned GitRepo a VCS client, e.g. for opening an MR from the callback
+func WithCloneVCSClient(p VCSProvider) CloneOption {
+	return func(cfg *cloneConfig) {
+		cfg.vcsClient = p
+	}
+}
+
+// WithCloneTempDir passes opts on to the NewTempDir call that creates the clone's directory
+func WithCloneTempDir(opts ...TempDirOption) CloneOption {
+	return func(cfg *cloneConfig) {
+		cfg.tempDirOpts = append(cfg.tempDirOpts, opts...)
+	}
+}
+
+// WithCloneMaxSize caps the size of the clone at maxMB megabytes. When the VCS client is a RepoSizer
+// the repo's size is checked before cloning, and the clone's directory is watched while cloning
+// either way. Going over the cap aborts with a *DiskLimitError
+func WithCloneMaxSize(maxMB int64) CloneOption {
+	return func(cfg *cloneConfig) {
+		cfg.maxSize = maxMB << 20
+	}
+}
+
+// WithCloneCache makes the clone from a mirror kept by cc, which only fetches what changed since the
+// last clone of the same repo. The size limit of WithCloneMaxSize then only checks the repo's reported size
+func WithCloneCache(cc *CloneCache) CloneOption {
+	return func(cfg *cloneConfig) {
+		cfg.cache = cc
+	}
+}
+
+// WithCloneRemoteName calls the clone's remote name instead of origin, and makes it the GitRepo's RemoteName
+func WithCloneRemoteName(name string) CloneOption {
+	return func(cfg *cloneConfig) {
+		cfg.remoteName = name
+	}
+}
+
+// RunInTempClone clones the repo at url into a new tmp directory, checks out ref, and runs fn on the
+// clone. The directory is always removed afterwards, whether fn succeeds, fails, or panics. An empty
+// ref clones the default branch. Branch names are accepted as is, as are full refs like refs/tags/v1.0.0
+func RunInTempClone(url, ref string, fn func(*GitRepo) error, opts ...CloneOption) error {
+	cfg := newCloneConfig(opts)
+
+	gr, err := cfg.timedClone(url, ref)
+	if err != nil {
+		return err
+	}
+	tmp := TempDir{DirName: gr.TempDir}
+	defer tmp.Close()
+
+	return fn(gr)
+}
+
+func newCloneConfig(opts []CloneOption) (cfg cloneConfig) {
+	for _, o := range opts {
+		o(&cfg)
+	}
+	return cfg
+}
+
+func (cfg *cloneConfig) tempClone(ctx context.Context, url, ref string) (gr *GitRepo, err error) {
+	tmp, err := NewTempDir(cfg.tempDirOpts...)
+	if err != nil {
+		return gr, err
+	}
+
+	gr = &GitRepo{
+		Dir:        tmp.DirName,
+		SSHKey:     cfg.sshKey,
+		SSHURL:     url,
+		TempDir:    tmp.DirName,
+		VCSClient:  cfg.vcsClient,
+		RemoteName: cfg.remoteName,
+	}
+
+	refName := plumbing.ReferenceName(ref)
+	if ref != "" && !strings.HasPrefix(ref, "refs/") {
+		refName = plumbing.NewBranchReferenceName(ref)
+	}
+
+	switch {
+	case cfg.cache != nil:
+		err = cfg.checkRepoSize(gr.SSHURL)
+		if err == nil {
+			gr.Repo, err = cfg.cache.cloneContext(ctx, gr, refName)
+		}
+	case cfg.maxSize > 0:
+		gr.Repo, err = cfg.cloneWithinLimit(ctx, gr, refName)
+	default:
+		gr.Repo, err = gr.cloneContext(ctx, refName)
+	}
+	if err == nil {
+		gr.Worktree, err = gr.Repo.Worktree()
+	}
+	if err != nil {
+		tmp.Close()
+		return nil, err
+	}
+	return gr, nil
+}
+
+func (cfg *cloneConfig) checkRepoSize(url string) error {
+	sizer, ok := cfg.vcsClient.(RepoSizer)
+	if cfg.maxSize <= 0 || !ok {
+		return nil
+	}
+
+	size, err := sizer.RepoSize(url)
+	if err != nil && !errors.Is(err, ErrNotSupported) {
+		return err
+	}
+	if size > cfg.maxSize {
+		return &DiskLimitError{URL: url, Size: size, Limit: cfg.maxSize}
+	}
+	return nil
+}
+
+func (cfg *cloneConfig) cloneWithinLimit(ctx context.Context, gr *GitRepo, ref plumbing.ReferenceName) (*git.Repository, error) {
+	err := cfg.checkRepoSize(gr.SSHURL)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	// Watch the directory while cloning and cancel the clone as soon as it's over the limit
+	exceeded := make(chan int64, 1)
+	done := make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(diskUsagePollInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+			}
+			size, err := dirSize(gr.Dir)
+			if err == nil && size > cfg.maxSize {
+				exceeded <- size
+				cancel()
+				return
+			}
+		}
+	}()
+
+	repo, err := gr.cloneContext(ctx, ref)
+	close(done)
+
+	select {
+	case size := <-exceeded:
+		return nil, &DiskLimitError{URL: gr.SSHURL, Size: size, Limit: cfg.maxSize}
+	default:
+	}
+	return repo, err
+}
+
+func dirSize(dir string) (size int64, err error) {
+	err = filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			// Files come and go while git is writing, so skip the ones that vanished
+			if os.IsNotExist(err) {
+				return nil
+			}
+			return err
+		}
+		if !info.IsDir() {
+			size += info.Size()
+		}
+		return nil
+	})
+	return size, err
+}
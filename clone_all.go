@@ -0,0 +1,85 @@
+package githelpers
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+const (
+	defaultCloneConcurrency = 4
+)
+
+// CloneResult is the outcome of cloning one repo with CloneAll. Exactly one of Repo and Err is set
+type CloneResult struct {
+	Repo *GitRepo
+	Err  error
+}
+
+// WithCloneTimeout gives up on a clone that takes longer than d. CloneAll applies it to each repo separately,
+// and RunInTempClone only to the clone, not to the callback
+func WithCloneTimeout(d time.Duration) CloneOption {
+	return func(cfg *cloneConfig) {
+		cfg.timeout = d
+	}
+}
+
+// CloneAll clones every repo in urls into its own tmp directory, at most concurrency at a time, and
+// returns the outcome of each keyed by URL. A concurrency below 1 means 4. When any clone fails the
+// error is a *MultiError with one ItemError per failed URL, and the repos that did clone are still returned. The caller owns the
+// clones and removes each one's TempDir when done with it
+func CloneAll(urls []string, concurrency int, opts ...CloneOption) (results map[string]CloneResult, err error) {
+	cfg := newCloneConfig(opts)
+	if concurrency < 1 {
+		concurrency = defaultCloneConcurrency
+	}
+
+	results = map[string]CloneResult{}
+	var mu sync.Mutex
+
+	jobs := make(chan string)
+	var wg sync.WaitGroup
+	for w := 0; w < concurrency; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for url := range jobs {
+				gr, err := cfg.timedClone(url, "")
+
+				mu.Lock()
+				results[url] = CloneResult{Repo: gr, Err: err}
+				mu.Unlock()
+			}
+		}()
+	}
+
+	seen := map[string]bool{}
+	for _, url := range urls {
+		if !seen[url] {
+			seen[url] = true
+			jobs <- url
+		}
+	}
+	close(jobs)
+	wg.Wait()
+
+	// Report failures in the order the URLs were given rather than the order the clones finished
+	failed := &MultiError{}
+	for _, url := range urls {
+		if seen[url] {
+			failed.Add(url, results[url].Err)
+			delete(seen, url)
+		}
+	}
+	return results, failed.ErrorOrNil()
+}
+
+func (cfg *cloneConfig) timedClone(url, ref string) (*GitRepo, error) {
+	ctx := context.Background()
+	if cfg.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, cfg.timeout)
+		defer cancel()
+	}
+	return cfg.tempClone(ctx, url, ref)
+}
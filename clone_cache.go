@@ -0,0 +1,263 @@
+package githelpers
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/config"
+	"github.com/go-git/go-git/v5/plumbing"
+	gitSSH "github.com/go-git/go-git/v5/plumbing/transport/ssh"
+)
+
+var (
+	mirrorRefSpecs = []config.RefSpec{"+refs/heads/*:refs/heads/*", "+refs/tags/*:refs/tags/*"}
+)
+
+// CloneCache keeps bare mirrors of remote repos under Dir and makes working clones that borrow the
+// mirror's objects through objects/info/alternates, as git clone --reference does. Only what changed
+// since the last run is fetched, and the clones themselves hold little more than a checkout.
+// Clones depend on the mirror, so Dir must outlive them. A CloneCache is safe for concurrent use
+type CloneCache struct {
+	Dir string
+
+	mu      sync.Mutex
+	mirrors map[string]*sync.Mutex
+}
+
+// NewCloneCache returns a CloneCache keeping its mirrors under dir, creating dir if needed
+func NewCloneCache(dir string) (cc *CloneCache, err error) {
+	dir, err = filepath.Abs(dir)
+	if err != nil {
+		return cc, err
+	}
+
+	err = os.MkdirAll(dir, 0755)
+	if err != nil {
+		return cc, err
+	}
+	return &CloneCache{Dir: dir, mirrors: map[string]*sync.Mutex{}}, nil
+}
+
+// MirrorPath returns the directory the mirror of the repo at url lives in, e.g. <Dir>/gitlab.com/group/repo.git.
+// URLs ParseRepoURL doesn't understand, like local paths, get a directory named after their hash under <Dir>/local
+func (cc *CloneCache) MirrorPath(url string) (path string, err error) {
+	r, err := ParseRepoURL(url)
+	if err != nil {
+		sum := sha256.Sum256([]byte(url))
+		return filepath.Join(cc.Dir, "local", hex.EncodeToString(sum[:8])+".git"), nil
+	}
+	return filepath.Join(cc.Dir, r.Host, filepath.FromSlash(r.FullPath())+".git"), nil
+}
+
+// Update creates or refreshes the mirror of the repo at url and returns it. Branches deleted on the
+// remote are deleted from the mirror too
+func (cc *CloneCache) Update(url string, sshKey *gitSSH.PublicKeys) (mirror *git.Repository, err error) {
+	return cc.update(context.Background(), url, sshKey)
+}
+
+func (cc *CloneCache) update(ctx context.Context, url string, sshKey *gitSSH.PublicKeys) (mirror *git.Repository, err error) {
+	path, err := cc.MirrorPath(url)
+	if err != nil {
+		return mirror, err
+	}
+
+	lock := cc.mirrorLock(path)
+	lock.Lock()
+	defer lock.Unlock()
+
+	mirror, err = git.PlainOpen(path)
+	if err == git.ErrRepositoryNotExists {
+		mirror, err = git.PlainInit(path, true)
+		if err != nil {
+			return mirror, err
+		}
+		_, err = mirror.CreateRemote(&config.RemoteConfig{
+			Name:  defaultRemoteName,
+			URLs:  []string{url},
+			Fetch: mirrorRefSpecs,
+		})
+	}
+	if err != nil {
+		return mirror, err
+	}
+
+	remote, err := mirror.Remote(defaultRemoteName)
+	if err != nil {
+		return mirror, err
+	}
+	refs, err := remote.List(&git.ListOptions{Auth: sshKey})
+	if err != nil {
+		return mirror, err
+	}
+
+	err = remote.FetchContext(ctx, &git.FetchOptions{Auth: sshKey, RefSpecs: mirrorRefSpecs, Tags: git.NoTags})
+	if err != nil && err != git.NoErrAlreadyUpToDate {
+		return mirror, err
+	}
+
+	return mirror, syncMirrorRefs(mirror, refs)
+}
+
+// Clone makes a working clone of the GitRepo's SSHURL in its Dir, refreshing the mirror first, and
+// checks out ref. Like GitRepo.Clone, an empty ref checks out the remote's default branch
+func (cc *CloneCache) Clone(gr *GitRepo, ref plumbing.ReferenceName) (repo *git.Repository, err error) {
+	return cc.cloneContext(context.Background(), gr, ref)
+}
+
+func (cc *CloneCache) cloneContext(ctx context.Context, gr *GitRepo, ref plumbing.ReferenceName) (repo *git.Repository, err error) {
+	mirror, err := cc.update(ctx, gr.SSHURL, gr.SSHKey)
+	if err != nil {
+		return repo, err
+	}
+	mirrorPath, err := cc.MirrorPath(gr.SSHURL)
+	if err != nil {
+		return repo, err
+	}
+
+	gr.mu.Lock()
+	defer gr.mu.Unlock()
+
+	err = gr.absDir()
+	if err != nil {
+		return repo, err
+	}
+
+	repo, err = git.PlainInit(gr.Dir, false)
+	if err != nil {
+		return repo, err
+	}
+
+	info := filepath.Join(gr.Dir, git.GitDirName, "objects", "info")
+	err = os.MkdirAll(info, 0755)
+	if err != nil {
+		return repo, err
+	}
+	err = os.WriteFile(filepath.Join(info, "alternates"), []byte(filepath.Join(mirrorPath, "objects")+"\n"), 0644)
+	if err != nil {
+		return repo, err
+	}
+
+	_, err = repo.CreateRemote(&config.RemoteConfig{
+		Name: gr.remoteName(),
+		URLs: []string{gr.SSHURL},
+	})
+	if err != nil {
+		return repo, err
+	}
+
+	head, err := mirror.Reference(plumbing.HEAD, false)
+	if err != nil {
+		return repo, err
+	}
+	if ref == "" {
+		ref = head.Target()
+	}
+
+	// Lay the refs out as a fresh clone would, so fetches and pushes only send what's new
+	refs, err := mirror.References()
+	if err != nil {
+		return repo, err
+	}
+	err = refs.ForEach(func(r *plumbing.Reference) error {
+		switch {
+		case r.Type() != plumbing.HashReference:
+			return nil
+		case r.Name().IsBranch():
+			remoteRef := plumbing.NewRemoteReferenceName(gr.remoteName(), r.Name().Short())
+			return repo.Storer.SetReference(plumbing.NewHashReference(remoteRef, r.Hash()))
+		case r.Name().IsTag():
+			return repo.Storer.SetReference(r)
+		}
+		return nil
+	})
+	if err != nil {
+		return repo, err
+	}
+	err = repo.Storer.SetReference(plumbing.NewSymbolicReference(
+		plumbing.NewRemoteReferenceName(gr.remoteName(), plumbing.HEAD.String()),
+		plumbing.NewRemoteReferenceName(gr.remoteName(), head.Target().Short()),
+	))
+	if err != nil {
+		return repo, err
+	}
+
+	target, err := mirror.Reference(ref, true)
+	if err != nil {
+		return repo, err
+	}
+
+	wt, err := repo.Worktree()
+	if err != nil {
+		return repo, err
+	}
+
+	if !ref.IsBranch() {
+		return repo, wt.Checkout(&git.CheckoutOptions{Hash: target.Hash(), Force: true})
+	}
+
+	err = repo.Storer.SetReference(plumbing.NewHashReference(ref, target.Hash()))
+	if err != nil {
+		return repo, err
+	}
+	err = repo.CreateBranch(&config.Branch{Name: ref.Short(), Remote: gr.remoteName(), Merge: ref})
+	if err != nil {
+		return repo, err
+	}
+	return repo, wt.Checkout(&git.CheckoutOptions{Branch: ref, Force: true})
+}
+
+func (cc *CloneCache) mirrorLock(path string) *sync.Mutex {
+	cc.mu.Lock()
+	defer cc.mu.Unlock()
+
+	if cc.mirrors == nil {
+		cc.mirrors = map[string]*sync.Mutex{}
+	}
+	if _, ok := cc.mirrors[path]; !ok {
+		cc.mirrors[path] = &sync.Mutex{}
+	}
+	return cc.mirrors[path]
+}
+
+func syncMirrorRefs(mirror *git.Repository, remoteRefs []*plumbing.Reference) error {
+	// Fetch can't prune, so drop the branches and tags the remote no longer has by hand, and point
+	// HEAD at the remote's default branch
+	live := map[plumbing.ReferenceName]bool{}
+	for _, r := range remoteRefs {
+		live[r.Name()] = true
+		if r.Name() == plumbing.HEAD && r.Type() == plumbing.SymbolicReference {
+			err := mirror.Storer.SetReference(plumbing.NewSymbolicReference(plumbing.HEAD, r.Target()))
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	refs, err := mirror.References()
+	if err != nil {
+		return err
+	}
+	var stale []plumbing.ReferenceName
+	err = refs.ForEach(func(r *plumbing.Reference) error {
+		if (r.Name().IsBranch() || r.Name().IsTag()) && !live[r.Name()] {
+			stale = append(stale, r.Name())
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, name := range stale {
+		err = mirror.Storer.RemoveReference(name)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,395 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/go-git/go-git/v5/plumbing"
+	githelpers "github.com/tochukwuvictor/go-githelpers"
+)
+
+func runClone(ctx context.Context, fs *flag.FlagSet, args []string) error {
+	creds := addCredentialFlags(fs)
+	ref := fs.String("ref", "", "branch, or full ref like refs/tags/v1.0.0, to check out. Defaults to the default branch")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if fs.NArg() < 1 || fs.NArg() > 2 {
+		return errUsage
+	}
+
+	url := fs.Arg(0)
+	dir := fs.Arg(1)
+	if dir == "" {
+		dir = strings.TrimSuffix(filepath.Base(url), ".git")
+	}
+
+	key, err := creds.auth()
+	if err != nil {
+		return err
+	}
+	refName := plumbing.ReferenceName(*ref)
+	if *ref != "" && !strings.HasPrefix(*ref, "refs/") {
+		refName = plumbing.NewBranchReferenceName(*ref)
+	}
+
+	gr := &githelpers.GitRepo{Dir: dir, SSHURL: url, SSHKey: key}
+	_, err = gr.Clone(refName)
+	if err != nil {
+		return err
+	}
+	fmt.Println(gr.Dir)
+	return nil
+}
+
+// changeFlags are the flags of the commands that change repos through a Fleet
+type changeFlags struct {
+	creds       *credentials
+	run         string
+	changers    stringList
+	timeout     time.Duration
+	branch      string
+	message     string
+	target      string
+	unique      bool
+	cacheDir    string
+	concurrency int
+	format      string
+}
+
+func addChangeFlags(fs *flag.FlagSet) *changeFlags {
+	c := &changeFlags{creds: addCredentialFlags(fs)}
+	fs.StringVar(&c.run, "run", "", "shell command run in the root of each clone to make the change")
+	fs.DurationVar(&c.timeout, "run-timeout", 0, "kill -run after this long. Defaults to 10 minutes")
+	fs.Var(&c.changers, "changer", "name of a registered changer applied to each clone, after -run. Repeat to apply several")
+	fs.StringVar(&c.branch, "branch", "", "branch the change is committed on (required)")
+	fs.StringVar(&c.message, "message", "", "commit message and MR title (required)")
+	fs.StringVar(&c.target, "target", "", "branch the MRs target. Defaults to each repo's default branch")
+	fs.BoolVar(&c.unique, "unique", false, "add a unique suffix to the branch name")
+	fs.StringVar(&c.cacheDir, "cache", "", "directory of mirrors to clone from, so repeated runs only fetch what changed")
+	fs.IntVar(&c.concurrency, "concurrency", 0, "number of repos processed at the same time. Defaults to 4")
+	fs.StringVar(&c.format, "format", "text", "output format: text, json, or markdown")
+	return c
+}
+
+func (c *changeFlags) fleet(urls []string) (f *githelpers.Fleet, err error) {
+	if (c.run == "" && len(c.changers) == 0) || c.branch == "" || c.message == "" || len(urls) == 0 {
+		return nil, errUsage
+	}
+
+	key, err := c.creds.auth()
+	if err != nil {
+		return nil, err
+	}
+	p, err := c.creds.vcsClient(urls[0])
+	if err != nil {
+		return nil, err
+	}
+
+	f = &githelpers.Fleet{
+		URLs:         urls,
+		SSHKey:       key,
+		VCSClient:    p,
+		Concurrency:  c.concurrency,
+		TargetBranch: c.target,
+		UniqueBranch: c.unique,
+	}
+	if c.cacheDir != "" {
+		f.Cache, err = githelpers.NewCloneCache(c.cacheDir)
+	}
+	return f, err
+}
+
+// execute runs the change over f and prints the outcome, failing when any repo failed
+func (c *changeFlags) execute(ctx context.Context, f *githelpers.Fleet) error {
+	var cs []githelpers.Changer
+	for _, name := range c.changers {
+		changer, err := githelpers.NewChanger(name, nil)
+		if err != nil {
+			return err
+		}
+		cs = append(cs, changer)
+	}
+
+	results := f.Run(ctx, c.message, c.branch, func(gr *githelpers.GitRepo) error {
+		if c.run != "" {
+			cmd := gr.Command("sh", "-c", c.run)
+			cmd.Env = []string{"GITHELPERS_REPO_URL=" + gr.SSHURL}
+			cmd.Timeout = c.timeout
+			_, err := cmd.Run(ctx)
+			if err != nil {
+				return err
+			}
+		}
+		return githelpers.ChangersFunc(ctx, cs...)(gr)
+	})
+
+	report := githelpers.NewFleetReport(results)
+	switch c.format {
+	case "json":
+		b, err := report.JSON()
+		if err != nil {
+			return err
+		}
+		fmt.Println(string(b))
+	case "markdown":
+		fmt.Print(report.Markdown())
+	default:
+		for _, res := range results {
+			switch {
+			case res.Err != nil:
+				fmt.Printf("%s\t%s\t%s\n", res.Status, res.URL, res.Err)
+			case res.MR.URL != "":
+				fmt.Printf("%s\t%s\t%s\n", res.Status, res.URL, res.MR.URL)
+			default:
+				fmt.Printf("%s\t%s\n", res.Status, res.URL)
+			}
+		}
+	}
+	return githelpers.FleetErrors(results)
+}
+
+// stringList is a flag that can be repeated, collecting every value
+type stringList []string
+
+func (l *stringList) String() string {
+	return strings.Join(*l, ",")
+}
+
+func (l *stringList) Set(v string) error {
+	*l = append(*l, v)
+	return nil
+}
+
+func runProposeChange(ctx context.Context, fs *flag.FlagSet, args []string) error {
+	c := addChangeFlags(fs)
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		return errUsage
+	}
+
+	f, err := c.fleet(fs.Args())
+	if err != nil {
+		return err
+	}
+	return c.execute(ctx, f)
+}
+
+func runBulkRun(ctx context.Context, fs *flag.FlagSet, args []string) error {
+	c := addChangeFlags(fs)
+	reposFile := fs.String("repos-file", "", "file listing repo URLs, one per line. - reads them from stdin")
+	group := fs.String("group", "", "full path of a GitLab group whose projects, subgroups included, are all changed")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+
+	urls := fs.Args()
+	if *reposFile != "" {
+		listed, err := readURLs(*reposFile)
+		if err != nil {
+			return err
+		}
+		urls = append(urls, listed...)
+	}
+	if *group != "" {
+		// Groups are GitLab only
+		p, err := githelpers.NewVCSProvider(githelpers.ProviderGitlab, c.creds.token, c.creds.baseURL)
+		if err != nil {
+			return err
+		}
+		gr := &githelpers.GitRepo{VCSClient: p}
+		projects, _, err := gr.FindProjects("", *group)
+		if err != nil {
+			return err
+		}
+		for _, p := range projects {
+			urls = append(urls, p.SSHURL)
+		}
+	}
+
+	f, err := c.fleet(urls)
+	if err != nil {
+		return err
+	}
+	return c.execute(ctx, f)
+}
+
+func readURLs(path string) (urls []string, err error) {
+	r := os.Stdin
+	if path != "-" {
+		r, err = os.Open(path)
+		if err != nil {
+			return urls, err
+		}
+		defer r.Close()
+	}
+
+	sc := bufio.NewScanner(r)
+	for sc.Scan() {
+		line := strings.TrimSpace(sc.Text())
+		if line != "" && !strings.HasPrefix(line, "#") {
+			urls = append(urls, line)
+		}
+	}
+	return urls, sc.Err()
+}
+
+func runCreateMR(ctx context.Context, fs *flag.FlagSet, args []string) error {
+	creds := addCredentialFlags(fs)
+	src := fs.String("source", "", "source branch (required)")
+	dest := fs.String("target", "", "target branch (required)")
+	title := fs.String("title", "", "MR title (required)")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 || *src == "" || *dest == "" || *title == "" {
+		return errUsage
+	}
+
+	p, err := creds.vcsClient(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	gr := &githelpers.GitRepo{SSHURL: fs.Arg(0), VCSClient: p}
+	mr, err := gr.NewMergeRequest(*title, *src, *dest)
+	if err != nil {
+		return err
+	}
+	fmt.Println(mr.URL)
+	return nil
+}
+
+func runCreateRelease(ctx context.Context, fs *flag.FlagSet, args []string) error {
+	creds := addCredentialFlags(fs)
+	tag := fs.String("tag", "", "tag to release, created if it doesn't exist yet (required)")
+	name := fs.String("name", "", "release name. Defaults to the tag")
+	notes := fs.String("notes", "", "release notes")
+	notesFile := fs.String("notes-file", "", "file to read the release notes from. - reads them from stdin")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 || *tag == "" || (*notes != "" && *notesFile != "") {
+		return errUsage
+	}
+
+	if *name == "" {
+		*name = *tag
+	}
+	if *notesFile != "" {
+		b, err := readFileOrStdin(*notesFile)
+		if err != nil {
+			return err
+		}
+		*notes = string(b)
+	}
+
+	p, err := creds.vcsClient(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	gr := &githelpers.GitRepo{SSHURL: fs.Arg(0), VCSClient: p}
+	rel, err := gr.NewRelease(*tag, *name, *notes)
+	if err != nil {
+		return err
+	}
+	fmt.Println(rel.URL)
+	return nil
+}
+
+func readFileOrStdin(path string) ([]byte, error) {
+	if path == "-" {
+		return io.ReadAll(os.Stdin)
+	}
+	return os.ReadFile(path)
+}
+
+func runMirror(ctx context.Context, fs *flag.FlagSet, args []string) error {
+	creds := addCredentialFlags(fs)
+	destKey := fs.String("dest-ssh-key", "", "path of the SSH private key for the destination. Defaults to -ssh-key")
+	cacheDir := fs.String("cache", "", "directory the source mirror is kept in. Defaults to githelpers under the user cache dir")
+	policy := fs.String("policy", githelpers.MirrorPolicyForce, "what to do with diverged destination refs: force, skip, or fail")
+	prune := fs.Bool("prune", false, "delete destination branches and tags the source no longer has")
+	interval := fs.Duration("interval", 0, "keep syncing at this interval until interrupted. Syncs once when left out")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if fs.NArg() != 2 {
+		return errUsage
+	}
+	switch *policy {
+	case githelpers.MirrorPolicyForce, githelpers.MirrorPolicySkip, githelpers.MirrorPolicyFail:
+	default:
+		return errUsage
+	}
+
+	if *cacheDir == "" {
+		dir, err := os.UserCacheDir()
+		if err != nil {
+			return err
+		}
+		*cacheDir = filepath.Join(dir, "githelpers")
+	}
+
+	cache, err := githelpers.NewCloneCache(*cacheDir)
+	if err != nil {
+		return err
+	}
+	m := githelpers.NewMirror(fs.Arg(0), fs.Arg(1), cache)
+	m.Policy = *policy
+	m.Prune = *prune
+	m.SourceAuth, err = creds.auth()
+	if err != nil {
+		return err
+	}
+	m.DestAuth = m.SourceAuth
+	if *destKey != "" {
+		m.DestAuth, err = githelpers.KeyPath(*destKey).SetupGitSSHPubKeys()
+		if err != nil {
+			return err
+		}
+	}
+
+	if *interval <= 0 {
+		res, err := m.Sync()
+		printMirrorResult(res)
+		return err
+	}
+
+	// Sync here rather than with Mirror.Run so every sync's outcome can be printed as it happens
+	ticker := time.NewTicker(*interval)
+	defer ticker.Stop()
+	for {
+		res, err := m.Sync()
+		printMirrorResult(res)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "githelpers mirror: %s\n", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+func printMirrorResult(res githelpers.MirrorSyncResult) {
+	for _, r := range res.Updated {
+		fmt.Printf("updated\t%s\n", r)
+	}
+	for _, r := range res.Deleted {
+		fmt.Printf("deleted\t%s\n", r)
+	}
+	for _, r := range res.Skipped {
+		fmt.Printf("skipped\t%s\n", r)
+	}
+}
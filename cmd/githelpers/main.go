@@ -0,0 +1,180 @@
+// Command githelpers exposes the githelpers package to shell scripts and other non-Go callers.
+//
+// Usage:
+//
+//	githelpers <command> [flags] [args]
+//
+// Run githelpers <command> -h for the flags of a command. Credentials are read from flags or, when the
+// flags are left out, from GITHELPERS_SSH_KEY (path of a private key) and GITHELPERS_TOKEN (VCS API token).
+//
+// propose-change and bulk-run can apply changers registered with githelpers.RegisterChanger. This
+// command registers none itself, so build a copy that imports the packages registering yours
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"syscall"
+
+	gitSSH "github.com/go-git/go-git/v5/plumbing/transport/ssh"
+	githelpers "github.com/tochukwuvictor/go-githelpers"
+)
+
+type command struct {
+	name    string
+	usage   string
+	summary string
+	run     func(ctx context.Context, fs *flag.FlagSet, args []string) error
+}
+
+var commands = []command{
+	{"clone", "clone [flags] <url> [dir]", "Clone a repo", runClone},
+	{"propose-change", "propose-change [flags] <url>", "Run a command in a repo and open an MR with what it changed", runProposeChange},
+	{"bulk-run", "bulk-run [flags] [url...]", "Run a command across many repos and open an MR in each", runBulkRun},
+	{"create-mr", "create-mr [flags] <url>", "Open an MR between two existing branches", runCreateMR},
+	{"create-release", "create-release [flags] <url>", "Publish a release for a tag", runCreateRelease},
+	{"mirror", "mirror [flags] <source-url> <dest-url>", "Sync the branches and tags of one repo to another", runMirror},
+}
+
+var (
+	// errUsage makes run print the command's usage and exit with status 2
+	errUsage = errors.New("invalid usage")
+	// errFlags makes run exit with status 2, for flags the flag package printed the usage for already
+	errFlags = errors.New("invalid flags")
+)
+
+func main() {
+	exit(run())
+}
+
+// exit removes the temporary clones left by the command before exiting, which os.Exit alone would
+// skip, as it skips deferred calls
+func exit(code int) {
+	githelpers.CleanupTempDirs()
+	os.Exit(code)
+}
+
+// run runs the command named by the arguments and returns the status to exit with
+func run() int {
+	if len(os.Args) < 2 || os.Args[1] == "-h" || os.Args[1] == "--help" || os.Args[1] == "help" {
+		usage()
+		return 2
+	}
+
+	var cmd *command
+	for i := range commands {
+		if commands[i].name == os.Args[1] {
+			cmd = &commands[i]
+		}
+	}
+	if cmd == nil {
+		fmt.Fprintf(os.Stderr, "githelpers: unknown command %q\n\n", os.Args[1])
+		usage()
+		return 2
+	}
+
+	// Errors are handled below rather than by the flag package exiting, so they go through exit too
+	fs := flag.NewFlagSet(cmd.name, flag.ContinueOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: githelpers %s\n\n%s\n\nFlags:\n", cmd.usage, cmd.summary)
+		fs.PrintDefaults()
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	err := cmd.run(ctx, fs, os.Args[2:])
+	switch {
+	case errors.Is(err, flag.ErrHelp):
+		return 0
+	case errors.Is(err, errUsage):
+		fs.Usage()
+		return 2
+	case errors.Is(err, errFlags):
+		return 2
+	case err != nil:
+		fmt.Fprintf(os.Stderr, "githelpers %s: %s\n", cmd.name, err)
+		return 1
+	}
+	return 0
+}
+
+// parseFlags parses args into fs, returning flag.ErrHelp for -h and errFlags for flags it rejects
+func parseFlags(fs *flag.FlagSet, args []string) error {
+	err := fs.Parse(args)
+	if err != nil && !errors.Is(err, flag.ErrHelp) {
+		return errFlags
+	}
+	return err
+}
+
+func usage() {
+	fmt.Fprintf(os.Stderr, "Usage: githelpers <command> [flags] [args]\n\nCommands:\n")
+	for _, c := range commands {
+		fmt.Fprintf(os.Stderr, "  %-16s %s\n", c.name, c.summary)
+	}
+	fmt.Fprintf(os.Stderr, "\nRun githelpers <command> -h for the flags of a command\n")
+}
+
+// credentials are the flags shared by every command that talks to a remote
+type credentials struct {
+	sshKey   string
+	token    string
+	provider string
+	baseURL  string
+}
+
+func addCredentialFlags(fs *flag.FlagSet) *credentials {
+	c := &credentials{}
+	fs.StringVar(&c.sshKey, "ssh-key", os.Getenv("GITHELPERS_SSH_KEY"), "path of the SSH private key. Defaults to $GITHELPERS_SSH_KEY, then ~/.ssh/id_ed25519 or ~/.ssh/id_rsa")
+	fs.StringVar(&c.token, "token", os.Getenv("GITHELPERS_TOKEN"), "VCS API token. Defaults to $GITHELPERS_TOKEN")
+	fs.StringVar(&c.provider, "provider", "", "VCS platform: gitlab, github, gitea, bitbucket, or azuredevops. Detected from the URL when left out")
+	fs.StringVar(&c.baseURL, "base-url", "", "API base URL of a self-managed instance. Derived from the URL when left out")
+	return c
+}
+
+// auth returns the SSH credentials to clone and push with
+func (c *credentials) auth() (*gitSSH.PublicKeys, error) {
+	key := c.sshKey
+	if key == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return nil, err
+		}
+		for _, name := range []string{"id_ed25519", "id_rsa"} {
+			path := filepath.Join(home, ".ssh", name)
+			if _, err := os.Stat(path); err == nil {
+				key = path
+				break
+			}
+		}
+	}
+	if key == "" {
+		return nil, errors.New("an SSH key is required: pass -ssh-key or set GITHELPERS_SSH_KEY")
+	}
+	return githelpers.KeyPath(key).SetupGitSSHPubKeys()
+}
+
+// vcsClient returns a client for the platform hosting repoURL
+func (c *credentials) vcsClient(repoURL string) (githelpers.VCSProvider, error) {
+	if c.token == "" {
+		return nil, errors.New("a VCS token is required: pass -token or set GITHELPERS_TOKEN")
+	}
+
+	kind := githelpers.ProviderKind(c.provider)
+	if c.baseURL != "" {
+		if kind == "" {
+			kind = githelpers.DetectProvider(repoURL)
+		}
+		return githelpers.NewVCSProvider(kind, c.token, c.baseURL)
+	}
+
+	gr := &githelpers.GitRepo{SSHURL: repoURL}
+	err := gr.AddVCSClient(c.token, kind)
+	return gr.VCSClient, err
+}
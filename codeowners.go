@@ -0,0 +1,272 @@
+package githelpers
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/format/gitignore"
+	"github.com/xanzy/go-gitlab"
+)
+
+const (
+	// CodeOwnerUser is an owner written as @username
+	CodeOwnerUser = "user"
+	// CodeOwnerGroup is an owner written as @group/subgroup
+	CodeOwnerGroup = "group"
+	// CodeOwnerEmail is an owner written as an email address
+	CodeOwnerEmail = "email"
+)
+
+var (
+	// codeOwnersLocations are where GitLab and GitHub look for the CODEOWNERS file, in order
+	codeOwnersLocations = []string{"CODEOWNERS", ".gitlab/CODEOWNERS", ".github/CODEOWNERS", "docs/CODEOWNERS"}
+
+	codeOwnersSectionPattern = regexp.MustCompile(`^\^?\[([^\]]+)\](?:\[\d+\])?\s*(.*)$`)
+)
+
+// CodeOwners is a parsed CODEOWNERS file, with GitLab sections
+type CodeOwners struct {
+	sections []codeOwnersSection
+}
+
+type codeOwnersSection struct {
+	name  string
+	rules []codeOwnersRule
+}
+
+type codeOwnersRule struct {
+	pattern gitignore.Pattern
+	owners  []string
+}
+
+// CodeOwner is an owner resolved to a GitLab user or group
+type CodeOwner struct {
+	Name string // Username, group path, or email as written in CODEOWNERS, without the @
+	Kind string // One of CodeOwnerUser, CodeOwnerGroup, or CodeOwnerEmail
+	ID   int    // GitLab user ID, or group ID for groups
+}
+
+// ParseCodeOwners parses a CODEOWNERS file
+func ParseCodeOwners(r io.Reader) (co *CodeOwners, err error) {
+	co = &CodeOwners{sections: []codeOwnersSection{{}}}
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		if m := codeOwnersSectionPattern.FindStringSubmatch(line); m != nil {
+			// Default owners given on a section header apply to rules without owners of their own
+			co.sections = append(co.sections, codeOwnersSection{name: m[1]})
+			if defaults := strings.Fields(m[2]); len(defaults) > 0 {
+				co.sections[len(co.sections)-1].rules = append(co.sections[len(co.sections)-1].rules, codeOwnersRule{owners: defaults})
+			}
+			continue
+		}
+
+		fields := strings.Fields(strings.Replace(line, `\ `, "\x00", -1))
+		pattern := strings.Replace(fields[0], "\x00", " ", -1)
+		owners := fields[1:]
+
+		s := &co.sections[len(co.sections)-1]
+		if len(owners) == 0 && len(s.rules) > 0 && s.rules[0].pattern == nil {
+			owners = s.rules[0].owners
+		}
+		s.rules = append(s.rules, codeOwnersRule{
+			pattern: gitignore.ParsePattern(pattern, nil),
+			owners:  owners,
+		})
+	}
+	return co, scanner.Err()
+}
+
+// Owners returns the owners of path as written in the file. Within each section the last matching
+// rule wins, and the owners of every section are combined
+func (co *CodeOwners) Owners(path string) (owners []string) {
+	segments := strings.Split(strings.Trim(path, "/"), "/")
+
+	seen := map[string]bool{}
+	for _, s := range co.sections {
+		for i := len(s.rules) - 1; i >= 0; i-- {
+			r := s.rules[i]
+			if r.pattern == nil || r.pattern.Match(segments, false) != gitignore.Exclude {
+				continue
+			}
+			for _, o := range r.owners {
+				if !seen[o] {
+					seen[o] = true
+					owners = append(owners, o)
+				}
+			}
+			break
+		}
+	}
+	return owners
+}
+
+// ReadCodeOwners parses the CODEOWNERS file in the GitRepo's worktree, looking in the same places
+// GitLab does. It returns an empty CodeOwners when the repo has none
+func (gr *GitRepo) ReadCodeOwners() (co *CodeOwners, err error) {
+	fs := gr.worktreeFilesystem()
+	for _, name := range codeOwnersLocations {
+		f, err := fs.Open(name)
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return co, err
+		}
+		defer f.Close()
+		return ParseCodeOwners(f)
+	}
+	return &CodeOwners{}, nil
+}
+
+// ResolveOwners matches paths against the GitRepo's CODEOWNERS file and looks the owners up on GitLab.
+// Owners are returned sorted by name
+func (gr *GitRepo) ResolveOwners(paths []string) (owners []CodeOwner, resp *gitlab.Response, err error) {
+	c, err := gr.gitlabClient()
+	if err != nil {
+		return owners, resp, err
+	}
+
+	co, err := gr.ReadCodeOwners()
+	if err != nil {
+		return owners, resp, err
+	}
+
+	names := map[string]bool{}
+	for _, p := range paths {
+		for _, o := range co.Owners(p) {
+			names[o] = true
+		}
+	}
+	sorted := make([]string, 0, len(names))
+	for n := range names {
+		sorted = append(sorted, n)
+	}
+	sort.Strings(sorted)
+
+	for _, n := range sorted {
+		var owner CodeOwner
+		owner, resp, err = resolveCodeOwner(c, n)
+		if err != nil {
+			return owners, resp, err
+		}
+		owners = append(owners, owner)
+	}
+	return owners, resp, err
+}
+
+// CodeOwnerReviewerIDs turns owners into the GitLab user IDs to request reviews from, expanding
+// groups into their members
+func (gr *GitRepo) CodeOwnerReviewerIDs(owners []CodeOwner) (ids []int, resp *gitlab.Response, err error) {
+	c, err := gr.gitlabClient()
+	if err != nil {
+		return ids, resp, err
+	}
+
+	seen := map[int]bool{}
+	add := func(id int) {
+		if !seen[id] {
+			seen[id] = true
+			ids = append(ids, id)
+		}
+	}
+
+	for _, o := range owners {
+		if o.Kind != CodeOwnerGroup {
+			add(o.ID)
+			continue
+		}
+
+		opts := &gitlab.ListGroupMembersOptions{ListOptions: defaultListOpts}
+		for {
+			var members []*gitlab.GroupMember
+			members, resp, err = c.Groups.ListGroupMembers(o.ID, opts)
+			if err != nil {
+				return ids, resp, err
+			}
+			for _, m := range members {
+				add(m.ID)
+			}
+
+			if resp.NextPage == 0 {
+				break
+			}
+			opts.Page = resp.NextPage
+		}
+	}
+	return ids, resp, err
+}
+
+func resolveCodeOwner(c *gitlab.Client, name string) (owner CodeOwner, resp *gitlab.Response, err error) {
+	if !strings.HasPrefix(name, "@") {
+		owner = CodeOwner{Name: name, Kind: CodeOwnerEmail}
+		users, resp, err := c.Users.ListUsers(&gitlab.ListUsersOptions{Search: &name})
+		if err != nil {
+			return owner, resp, err
+		}
+		for _, u := range users {
+			if strings.EqualFold(u.Email, name) || strings.EqualFold(u.PublicEmail, name) {
+				owner.ID = u.ID
+				return owner, resp, nil
+			}
+		}
+		return owner, resp, fmt.Errorf("gitlab user not found for code owner: %s", name)
+	}
+
+	// @name is a user unless no user has that username, in which case it's a group
+	name = strings.TrimPrefix(name, "@")
+	if !strings.Contains(name, "/") {
+		users, resp, err := c.Users.ListUsers(&gitlab.ListUsersOptions{Username: &name})
+		if err != nil {
+			return owner, resp, err
+		}
+		if len(users) > 0 {
+			return CodeOwner{Name: name, Kind: CodeOwnerUser, ID: users[0].ID}, resp, nil
+		}
+	}
+
+	g, resp, err := c.Groups.GetGroup(name)
+	if err != nil {
+		return owner, resp, fmt.Errorf("resolving code owner @%s: %w", name, err)
+	}
+	return CodeOwner{Name: name, Kind: CodeOwnerGroup, ID: g.ID}, resp, nil
+}
+
+// createMergeRequestOptions adds the options the GitLab client doesn't know about yet to the create MR request
+type createMergeRequestOptions struct {
+	*gitlab.CreateMergeRequestOptions
+	ReviewerIDs []int `json:"reviewer_ids,omitempty"`
+}
+
+func (gr *GitRepo) codeOwnerReviewers(src, dest string) (ids []int, resp *gitlab.Response, err error) {
+	// Diff against the remote tracking branch, since the local copy of dest may not exist or be stale
+	changes, err := gr.ChangedPaths(plumbing.NewRemoteReferenceName(gr.remoteName(), dest).String(), src)
+	if err != nil {
+		return ids, resp, err
+	}
+
+	var paths []string
+	for _, c := range changes {
+		paths = append(paths, c.Path)
+		if c.OldPath != "" {
+			paths = append(paths, c.OldPath)
+		}
+	}
+
+	owners, resp, err := gr.ResolveOwners(paths)
+	if err != nil || len(owners) == 0 {
+		return ids, resp, err
+	}
+	return gr.CodeOwnerReviewerIDs(owners)
+}
@@ -0,0 +1,154 @@
+package githelpers
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+const (
+	defaultCommandTimeout = 10 * time.Minute
+	// Output kept in a CommandError, so a chatty tool doesn't make the error unreadable
+	commandErrorOutputLimit = 4 << 10
+)
+
+var (
+	// ErrNoWorktreeDir is returned when running a command in a GitRepo whose worktree isn't on disk,
+	// e.g. one on a memfs Filesystem
+	ErrNoWorktreeDir = errors.New("external commands need a worktree on disk")
+
+	// Variables kept by a RepoCommand with CleanEnv set, so tools can still be found and find their config
+	cleanEnvKeep = []string{"PATH", "HOME", "TMPDIR", "LANG"}
+)
+
+// CommandResult is the captured outcome of a RepoCommand
+type CommandResult struct {
+	Stdout   []byte
+	Stderr   []byte
+	ExitCode int
+	Duration time.Duration
+}
+
+// CommandError is returned when a RepoCommand fails to start, exits non-zero, or times out
+type CommandError struct {
+	Command  string
+	ExitCode int    // -1 when the command didn't exit on its own
+	Stderr   []byte // The end of stderr, at most 4 KB
+	Err      error
+}
+
+func (e *CommandError) Error() string {
+	msg := fmt.Sprintf("%s: %s", e.Command, e.Err)
+	if stderr := strings.TrimSpace(string(e.Stderr)); stderr != "" {
+		msg += ": " + stderr
+	}
+	return msg
+}
+
+func (e *CommandError) Unwrap() error {
+	return e.Err
+}
+
+// RepoCommand is an external tool run inside a GitRepo's worktree, e.g. go mod tidy or terraform fmt.
+// Create one with Command, adjust its fields, and call Run
+type RepoCommand struct {
+	Name     string
+	Args     []string
+	Dir      string        // Directory to run in, relative to the root of the worktree. Defaults to the root
+	Env      []string      // KEY=value pairs added to the environment
+	CleanEnv bool          // Starts from an environment of only PATH, HOME, TMPDIR, and LANG instead of the process's
+	Timeout  time.Duration // Kills the command after this long. Defaults to 10 minutes, negative means no limit
+	Stdin    io.Reader
+
+	gr *GitRepo
+}
+
+// Command returns a RepoCommand running name with args in the GitRepo's worktree
+func (gr *GitRepo) Command(name string, args ...string) *RepoCommand {
+	return &RepoCommand{Name: name, Args: args, gr: gr}
+}
+
+// RunCommand runs name with args in the root of the GitRepo's worktree with the default settings of
+// Command, and returns its captured output
+func (gr *GitRepo) RunCommand(ctx context.Context, name string, args ...string) (res CommandResult, err error) {
+	return gr.Command(name, args...).Run(ctx)
+}
+
+// Run runs the command and waits for it. A failed command returns a *CommandError along with the
+// output captured so far
+func (c *RepoCommand) Run(ctx context.Context) (res CommandResult, err error) {
+	if c.gr.Filesystem != nil {
+		return res, ErrNoWorktreeDir
+	}
+	dir := c.gr.Dir
+	if c.Dir != "" {
+		dir = filepath.Join(dir, filepath.FromSlash(c.Dir))
+	}
+
+	timeout := c.Timeout
+	if timeout == 0 {
+		timeout = defaultCommandTimeout
+	}
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, c.Name, c.Args...)
+	cmd.Dir = dir
+	cmd.Env = c.environ()
+	cmd.Stdin = c.Stdin
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	start := time.Now()
+	err = cmd.Run()
+	res = CommandResult{
+		Stdout:   stdout.Bytes(),
+		Stderr:   stderr.Bytes(),
+		ExitCode: -1,
+		Duration: time.Since(start),
+	}
+	if cmd.ProcessState != nil {
+		res.ExitCode = cmd.ProcessState.ExitCode()
+	}
+	if err == nil {
+		return res, nil
+	}
+
+	if ctx.Err() != nil {
+		// Report why the command was killed rather than the signal that killed it
+		err = ctx.Err()
+	}
+	tail := res.Stderr
+	if len(tail) > commandErrorOutputLimit {
+		tail = tail[len(tail)-commandErrorOutputLimit:]
+	}
+	return res, &CommandError{Command: c.String(), ExitCode: res.ExitCode, Stderr: tail, Err: err}
+}
+
+// String returns the command line
+func (c *RepoCommand) String() string {
+	return strings.Join(append([]string{c.Name}, c.Args...), " ")
+}
+
+func (c *RepoCommand) environ() (env []string) {
+	if !c.CleanEnv {
+		return append(os.Environ(), c.Env...)
+	}
+	for _, k := range cleanEnvKeep {
+		if v, ok := os.LookupEnv(k); ok {
+			env = append(env, k+"="+v)
+		}
+	}
+	return append(env, c.Env...)
+}
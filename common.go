@@ -1,22 +1,26 @@
 package githelpers
 
 import (
-	"path/filepath"
-	"strings"
+	"fmt"
 )
 
-func unpack(s []string, vars ...*string) {
-	for i, str := range s {
-		*vars[i] = str
-	}
+func splitRepoURL(url string) (host, ns, name string) {
+	// Convenience wrapper for callers that only need the parts. An unparseable URL leaves
+	// them empty, which the VCS APIs reject with their own errors
+	r, _ := ParseRepoURL(url)
+	return r.Host, r.Namespace, r.Name
 }
 
-func splitRepoURL(url string) (vcs, ns, name string) {
-	var sansVcs string
-	unpack(strings.Split(url, ":"), &vcs, &sansVcs) // This will break if http url. Fix.
-	fullPath := strings.TrimSuffix(sansVcs, ".git")
-	name = filepath.Base(fullPath)
-	ns = strings.TrimSuffix(fullPath, "/"+name)
+func gitlabBaseURL(url string) string {
+	// The port of an SSH URL is the SSH daemon's, so only keep it for HTTP(S) remotes
+	r, _ := ParseRepoURL(url)
+	if r.Scheme == "https" || r.Scheme == "http" {
+		return fmt.Sprintf("%s://%s", r.Scheme, r.HostWithPort())
+	}
+	return "https://" + r.Host
+}
 
-	return vcs, ns, name
+func repoHost(url string) string {
+	host, _, _ := splitRepoURL(url)
+	return host
 }
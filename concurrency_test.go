@@ -0,0 +1,329 @@
+package githelpers
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	gitSSH "github.com/go-git/go-git/v5/plumbing/transport/ssh"
+	"golang.org/x/crypto/ssh"
+)
+
+// These tests are meant to be run with -race, which is what catches unsynchronized access
+
+const (
+	testWorkers = 4
+	testRepos   = 8
+)
+
+func TestGitRepoConcurrentUse(t *testing.T) {
+	requireGit(t)
+	remoteDir := filepath.Join(t.TempDir(), "remote.git")
+	remote := newBareRepo(t, remoteDir)
+	gr := newTestClone(t, remoteDir)
+	first, err := gr.Repo.Head()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var wg sync.WaitGroup
+	errs := make(chan error, testRepos*4)
+	for i := 0; i < testRepos; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			err := os.WriteFile(filepath.Join(gr.Dir, fmt.Sprintf("file%d.txt", i)), []byte("content\n"), 0644)
+			if err == nil {
+				_, err = gr.CommitAll(fmt.Sprintf("Add file %d", i))
+			}
+			errs <- err
+			errs <- gr.AppendNote(first.Hash().String(), fmt.Sprintf("note %d", i))
+			errs <- ignoreUpToDate(gr.Push())
+			errs <- gr.Fetch()
+		}(i)
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Error(err)
+		}
+	}
+
+	note, err := gr.Note(first.Hash().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < testRepos; i++ {
+		if !strings.Contains(note, fmt.Sprintf("note %d", i)) {
+			t.Errorf("note %d was lost: %q", i, note)
+		}
+	}
+
+	err = ignoreUpToDate(gr.Push())
+	if err != nil {
+		t.Fatal(err)
+	}
+	head, err := gr.Repo.Head()
+	if err != nil {
+		t.Fatal(err)
+	}
+	pushed, err := remote.Reference(head.Name(), true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pushed.Hash() != head.Hash() {
+		t.Errorf("remote has %s, want %s", pushed.Hash(), head.Hash())
+	}
+}
+
+func TestFleetRunConcurrently(t *testing.T) {
+	requireGit(t)
+	dir := t.TempDir()
+	var urls []string
+	addr := serveGitSSH(t, dir)
+	for i := 0; i < testRepos; i++ {
+		newBareRepo(t, filepath.Join(dir, "group", fmt.Sprintf("repo%d.git", i)))
+		urls = append(urls, fmt.Sprintf("ssh://git@%s/group/repo%d.git", addr, i))
+	}
+
+	api := &fakeGitlab{}
+	srv := httptest.NewServer(api)
+	defer srv.Close()
+	p, err := NewGitlabProvider("token", WithGitlabBaseURL(srv.URL))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	key := &gitSSH.PublicKeys{User: "git", Signer: newTestSigner(t)}
+	key.HostKeyCallback = ssh.InsecureIgnoreHostKey()
+	f := &Fleet{URLs: urls, SSHKey: key, VCSClient: p, Concurrency: testWorkers, TargetBranch: "master"}
+
+	results := f.Run(context.Background(), "Add fleet file", "fleet", func(gr *GitRepo) error {
+		return os.WriteFile(filepath.Join(gr.Dir, "fleet.txt"), []byte("fleet\n"), 0644)
+	})
+	for _, res := range results {
+		if res.Status != FleetStatusProposed {
+			t.Errorf("%s: status %s: %v", res.URL, res.Status, res.Err)
+		}
+	}
+	if n := atomic.LoadInt32(&api.mergeRequests); n != testRepos {
+		t.Errorf("opened %d MRs, want %d", n, testRepos)
+	}
+	for i := 0; i < testRepos; i++ {
+		repo, err := git.PlainOpen(filepath.Join(dir, "group", fmt.Sprintf("repo%d.git", i)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, err = repo.Reference(plumbing.NewBranchReferenceName("fleet"), true)
+		if err != nil {
+			t.Errorf("repo%d: fleet branch wasn't pushed: %v", i, err)
+		}
+	}
+}
+
+func TestMain(m *testing.M) {
+	// go-git commits as the user in the global git config, which CI machines often don't have. It
+	// caches where the home directory is, so HOME has to be set before the first test runs
+	home, err := os.MkdirTemp("", "githelpers-test-home")
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	err = os.WriteFile(filepath.Join(home, ".gitconfig"), []byte("[user]\n\tname = Test\n\temail = test@example.com\n"), 0644)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	os.Setenv("HOME", home)
+
+	code := m.Run()
+	os.RemoveAll(home)
+	os.Exit(code)
+}
+
+func requireGit(t *testing.T) {
+	t.Helper()
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git isn't installed")
+	}
+}
+
+// ignoreUpToDate drops the error Push returns when another goroutine pushed its commit already
+func ignoreUpToDate(err error) error {
+	if errors.Is(err, git.NoErrAlreadyUpToDate) {
+		return nil
+	}
+	return err
+}
+
+// newBareRepo creates a bare repo at dir whose master branch has one commit
+func newBareRepo(t *testing.T, dir string) *git.Repository {
+	t.Helper()
+	src := &GitRepo{Dir: t.TempDir()}
+	err := src.Init(false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = os.WriteFile(filepath.Join(src.Dir, "README.md"), []byte("readme\n"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = src.CommitAll("Initial commit")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	out, err := exec.Command("git", "clone", "--quiet", "--bare", src.Dir, dir).CombinedOutput()
+	if err != nil {
+		t.Fatalf("git clone --bare: %v: %s", err, out)
+	}
+	repo, err := git.PlainOpen(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return repo
+}
+
+// newTestClone clones the repo at url into a temporary directory
+func newTestClone(t *testing.T, url string) *GitRepo {
+	t.Helper()
+	gr := &GitRepo{Dir: t.TempDir(), SSHURL: url}
+	repo, err := gr.Clone("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	gr.Repo = repo
+	gr.Worktree, err = repo.Worktree()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return gr
+}
+
+func newTestSigner(t *testing.T) ssh.Signer {
+	t.Helper()
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signer, err := ssh.NewSignerFromKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return signer
+}
+
+// serveGitSSH serves the bare repos under dir over SSH, running git-upload-pack and git-receive-pack
+// for whoever connects, and returns the server's address
+func serveGitSSH(t *testing.T, dir string) string {
+	t.Helper()
+	cfg := &ssh.ServerConfig{
+		PublicKeyCallback: func(ssh.ConnMetadata, ssh.PublicKey) (*ssh.Permissions, error) { return nil, nil },
+	}
+	cfg.AddHostKey(newTestSigner(t))
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { l.Close() })
+
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go serveGitSSHConn(conn, cfg, dir)
+		}
+	}()
+	return l.Addr().String()
+}
+
+func serveGitSSHConn(conn net.Conn, cfg *ssh.ServerConfig, dir string) {
+	_, chans, reqs, err := ssh.NewServerConn(conn, cfg)
+	if err != nil {
+		return
+	}
+	go ssh.DiscardRequests(reqs)
+
+	for nc := range chans {
+		if nc.ChannelType() != "session" {
+			nc.Reject(ssh.UnknownChannelType, "only sessions are supported")
+			continue
+		}
+		ch, chReqs, err := nc.Accept()
+		if err != nil {
+			continue
+		}
+		go func() {
+			defer ch.Close()
+			for req := range chReqs {
+				if req.Type != "exec" {
+					req.Reply(false, nil)
+					continue
+				}
+				var exec struct{ Command string }
+				if ssh.Unmarshal(req.Payload, &exec) != nil {
+					req.Reply(false, nil)
+					continue
+				}
+				req.Reply(true, nil)
+				status := runGitCommand(exec.Command, dir, ch)
+				ch.SendRequest("exit-status", false, ssh.Marshal(struct{ Status uint32 }{status}))
+				return
+			}
+		}()
+	}
+}
+
+// runGitCommand runs a command like git-upload-pack '/group/repo.git' on the repo under dir
+func runGitCommand(command, dir string, ch ssh.Channel) uint32 {
+	parts := strings.SplitN(command, " ", 2)
+	if len(parts) != 2 || (parts[0] != "git-upload-pack" && parts[0] != "git-receive-pack") {
+		return 1
+	}
+	repo := filepath.Join(dir, filepath.FromSlash(strings.Trim(parts[1], "'/")))
+	cmd := exec.Command("git", strings.TrimPrefix(parts[0], "git-"), repo)
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = ch, ch, ch.Stderr()
+	if cmd.Run() != nil {
+		return 1
+	}
+	return 0
+}
+
+// fakeGitlab answers the project lookups and MR creation a Fleet run makes
+type fakeGitlab struct {
+	mergeRequests int32
+}
+
+func (g *fakeGitlab) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	path := strings.TrimPrefix(r.URL.EscapedPath(), "/api/v4/projects/")
+	switch {
+	case r.Method == http.MethodGet && !strings.Contains(path, "/"):
+		name, _ := url.PathUnescape(path)
+		fmt.Fprintf(w, `{"id": %d, "path_with_namespace": %q}`, len(name), name)
+	case r.Method == http.MethodPost && strings.HasSuffix(path, "/merge_requests"):
+		n := atomic.AddInt32(&g.mergeRequests, 1)
+		w.WriteHeader(http.StatusCreated)
+		fmt.Fprintf(w, `{"id": %d, "iid": %d}`, n, n)
+	default:
+		http.NotFound(w, r)
+	}
+}
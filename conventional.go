@@ -0,0 +1,143 @@
+package githelpers
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+var (
+	// ErrInvalidCommitMessage is wrapped by the errors ValidateCommitMessage returns
+	ErrInvalidCommitMessage = errors.New("invalid conventional commit message")
+
+	// ConventionalCommitTypes are the commit types ValidateCommitMessage accepts
+	ConventionalCommitTypes = []string{"feat", "fix", "docs", "style", "refactor", "perf", "test", "build", "ci", "chore", "revert"}
+
+	commitHeaderPattern  = regexp.MustCompile(`^([a-z]+)(?:\(([^()\s]+)\))?(!)?: (\S.*)$`)
+	commitTrailerPattern = regexp.MustCompile(`^([A-Za-z0-9-]+|BREAKING CHANGE): (.+)$`)
+)
+
+// Trailer is a "Key: Value" line at the end of a commit message, e.g. Signed-off-by
+type Trailer struct {
+	Key   string
+	Value string
+}
+
+// CommitMessage is a commit message following the Conventional Commits spec, see
+// https://www.conventionalcommits.org
+type CommitMessage struct {
+	Type     string // e.g. "feat" or "fix"
+	Scope    string
+	Subject  string
+	Body     string
+	Breaking bool // Marks the header with a "!"
+	Trailers []Trailer
+}
+
+// SignedOffBy returns the Signed-off-by trailer for the given author
+func SignedOffBy(name, email string) Trailer {
+	return Trailer{Key: "Signed-off-by", Value: fmt.Sprintf("%s <%s>", name, email)}
+}
+
+// ChangeIDTrailer returns a Change-Id trailer with a new random ID, as Gerrit expects
+func ChangeIDTrailer() (t Trailer, err error) {
+	b := make([]byte, 20)
+	_, err = rand.Read(b)
+	if err != nil {
+		return t, err
+	}
+	return Trailer{Key: "Change-Id", Value: "I" + hex.EncodeToString(b)}, nil
+}
+
+// String renders the message as "type(scope)!: subject", followed by the body and trailers
+// separated by blank lines
+func (m CommitMessage) String() string {
+	var b strings.Builder
+	b.WriteString(m.Type)
+	if m.Scope != "" {
+		b.WriteString("(" + m.Scope + ")")
+	}
+	if m.Breaking {
+		b.WriteString("!")
+	}
+	b.WriteString(": " + m.Subject)
+
+	if body := strings.TrimSpace(m.Body); body != "" {
+		b.WriteString("\n\n" + body)
+	}
+	if len(m.Trailers) > 0 {
+		b.WriteString("\n")
+		for _, t := range m.Trailers {
+			b.WriteString("\n" + t.Key + ": " + t.Value)
+		}
+	}
+	return b.String()
+}
+
+// ParseCommitMessage parses msg as a Conventional Commit, returning an error wrapping
+// ErrInvalidCommitMessage if it doesn't follow the spec
+func ParseCommitMessage(msg string) (m CommitMessage, err error) {
+	msg = strings.TrimSpace(strings.Replace(msg, "\r\n", "\n", -1))
+	lines := strings.Split(msg, "\n")
+
+	match := commitHeaderPattern.FindStringSubmatch(lines[0])
+	if match == nil {
+		return m, fmt.Errorf("%w: header %q isn't \"type(scope): subject\"", ErrInvalidCommitMessage, lines[0])
+	}
+	m.Type, m.Scope, m.Breaking, m.Subject = match[1], match[2], match[3] == "!", strings.TrimSpace(match[4])
+
+	if !stringInSlice(m.Type, ConventionalCommitTypes) {
+		return m, fmt.Errorf("%w: unknown type %q", ErrInvalidCommitMessage, m.Type)
+	}
+	if len(lines) > 1 && lines[1] != "" {
+		return m, fmt.Errorf("%w: the header must be followed by a blank line", ErrInvalidCommitMessage)
+	}
+	if len(lines) <= 2 {
+		return m, nil
+	}
+
+	paragraphs := strings.Split(strings.Join(lines[2:], "\n"), "\n\n")
+	last := paragraphs[len(paragraphs)-1]
+	trailers, ok := parseTrailers(last)
+	if ok {
+		m.Trailers = trailers
+		paragraphs = paragraphs[:len(paragraphs)-1]
+	}
+	m.Body = strings.TrimSpace(strings.Join(paragraphs, "\n\n"))
+
+	for _, t := range m.Trailers {
+		if t.Key == "BREAKING CHANGE" || t.Key == "BREAKING-CHANGE" {
+			m.Breaking = true
+		}
+	}
+	return m, nil
+}
+
+// ValidateCommitMessage returns an error wrapping ErrInvalidCommitMessage if msg isn't a Conventional Commit
+func ValidateCommitMessage(msg string) error {
+	_, err := ParseCommitMessage(msg)
+	return err
+}
+
+func parseTrailers(paragraph string) (trailers []Trailer, ok bool) {
+	for _, line := range strings.Split(strings.TrimSpace(paragraph), "\n") {
+		match := commitTrailerPattern.FindStringSubmatch(line)
+		if match == nil {
+			return nil, false
+		}
+		trailers = append(trailers, Trailer{Key: match[1], Value: match[2]})
+	}
+	return trailers, len(trailers) > 0
+}
+
+func stringInSlice(s string, list []string) bool {
+	for _, l := range list {
+		if l == s {
+			return true
+		}
+	}
+	return false
+}
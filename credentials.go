@@ -0,0 +1,241 @@
+package githelpers
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/go-git/go-git/v5/plumbing/transport"
+	githttp "github.com/go-git/go-git/v5/plumbing/transport/http"
+)
+
+const (
+	// Helpers may talk to a keychain or a secrets service, but shouldn't keep a run waiting forever
+	credentialTimeout = time.Minute
+)
+
+var (
+	// ErrNoCredentials is returned when neither git's credential helpers nor an askpass program
+	// supply credentials for a URL
+	ErrNoCredentials = errors.New("no credentials found")
+)
+
+// GitCredentialFill asks the credential helpers git is configured with for the username and password
+// of the HTTPS url, as git credential fill does, so the credentials developers and CI jobs already
+// set up for git are used as they are. Terminal prompts are turned off, but GIT_ASKPASS is still
+// asked when no helper has credentials, as git would. Needs git on the PATH
+func GitCredentialFill(rawURL string) (auth *githttp.BasicAuth, err error) {
+	in, err := credentialRequest(rawURL, "")
+	if err != nil {
+		return auth, err
+	}
+	out, err := runGitCredential("fill", in)
+	if err != nil {
+		return auth, err
+	}
+
+	fields := parseCredential(out)
+	if fields["password"] == "" {
+		return auth, fmt.Errorf("%s: %w", rawURL, ErrNoCredentials)
+	}
+	return &githttp.BasicAuth{Username: fields["username"], Password: fields["password"]}, nil
+}
+
+// ApproveGitCredential tells git's credential helpers that auth worked for url, so the ones that
+// store credentials, e.g. store or cache, keep them, as git credential approve does
+func ApproveGitCredential(rawURL string, auth *githttp.BasicAuth) error {
+	return reportGitCredential("approve", rawURL, auth)
+}
+
+// RejectGitCredential tells git's credential helpers that auth was turned down for url, so they forget
+// it, as git credential reject does
+func RejectGitCredential(rawURL string, auth *githttp.BasicAuth) error {
+	return reportGitCredential("reject", rawURL, auth)
+}
+
+// AskPassCredential asks the askpass program in GIT_ASKPASS, or else SSH_ASKPASS, for the username
+// and password of the HTTPS url, prompting it as git does. A username in url is used as is
+func AskPassCredential(rawURL string) (auth *githttp.BasicAuth, err error) {
+	askPass := os.Getenv("GIT_ASKPASS")
+	if askPass == "" {
+		askPass = os.Getenv("SSH_ASKPASS")
+	}
+	if askPass == "" {
+		return auth, fmt.Errorf("%s: no GIT_ASKPASS or SSH_ASKPASS set: %w", rawURL, ErrNoCredentials)
+	}
+
+	u, err := credentialURL(rawURL)
+	if err != nil {
+		return auth, err
+	}
+	auth = &githttp.BasicAuth{}
+	if u.User != nil {
+		auth.Username = u.User.Username()
+	}
+	if auth.Username == "" {
+		auth.Username, err = runAskPass(askPass, fmt.Sprintf("Username for '%s://%s': ", u.Scheme, u.Host))
+		if err != nil {
+			return auth, err
+		}
+	}
+	auth.Password, err = runAskPass(askPass,
+		fmt.Sprintf("Password for '%s://%s@%s': ", u.Scheme, url.PathEscape(auth.Username), u.Host))
+	if err != nil {
+		return auth, err
+	}
+	if auth.Password == "" {
+		return auth, fmt.Errorf("%s: %w", rawURL, ErrNoCredentials)
+	}
+	return auth, nil
+}
+
+// CredentialsFromEnvironment returns the credentials git would use for the HTTPS url, from its
+// credential helpers, or from the askpass program when git isn't installed
+func CredentialsFromEnvironment(rawURL string) (auth *githttp.BasicAuth, err error) {
+	auth, err = GitCredentialFill(rawURL)
+	if errors.Is(err, exec.ErrNotFound) {
+		return AskPassCredential(rawURL)
+	}
+	return auth, err
+}
+
+// UseGitCredentials makes the GitRepo authenticate with remote using the credentials
+// CredentialsFromEnvironment finds for its URL, or for SSHURL when remote is the GitRepo's own remote
+// and the repo isn't cloned yet
+func (gr *GitRepo) UseGitCredentials(remote string) error {
+	gr.mu.Lock()
+	defer gr.mu.Unlock()
+
+	rawURL := ""
+	if gr.Repo != nil {
+		r, err := gr.Repo.Remote(remote)
+		if err == nil && len(r.Config().URLs) > 0 {
+			rawURL = r.Config().URLs[0]
+		}
+	}
+	if rawURL == "" && remote == gr.remoteName() {
+		rawURL = gr.SSHURL
+	}
+	if rawURL == "" {
+		return fmt.Errorf("no URL for remote %s", remote)
+	}
+
+	auth, err := CredentialsFromEnvironment(rawURL)
+	if err != nil {
+		return err
+	}
+	if gr.RemoteAuth == nil {
+		gr.RemoteAuth = map[string]transport.AuthMethod{}
+	}
+	gr.RemoteAuth[remote] = auth
+	return nil
+}
+
+func reportGitCredential(action, rawURL string, auth *githttp.BasicAuth) error {
+	in, err := credentialRequest(rawURL, auth.Username)
+	if err != nil {
+		return err
+	}
+	_, err = runGitCredential(action, in+"password="+auth.Password+"\n")
+	return err
+}
+
+// credentialURL parses rawURL, which credential helpers only know how to answer for when it's HTTP(S)
+func credentialURL(rawURL string) (*url.URL, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil && strings.Contains(rawURL, "://") {
+		return nil, err
+	}
+	if err != nil || (u.Scheme != "https" && u.Scheme != "http") {
+		return nil, fmt.Errorf("credential helpers only handle http and https URLs, not %s", rawURL)
+	}
+	return u, nil
+}
+
+// credentialRequest describes url in the key=value format of git credential, without the blank line
+// that ends it, so more fields can follow
+func credentialRequest(rawURL, username string) (string, error) {
+	u, err := credentialURL(rawURL)
+	if err != nil {
+		return "", err
+	}
+	if username == "" && u.User != nil {
+		username = u.User.Username()
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "protocol=%s\nhost=%s\n", u.Scheme, u.Host)
+	if p := strings.TrimPrefix(u.Path, "/"); p != "" {
+		fmt.Fprintf(&b, "path=%s\n", p)
+	}
+	if username != "" {
+		fmt.Fprintf(&b, "username=%s\n", username)
+	}
+	return b.String(), nil
+}
+
+func runGitCredential(action, in string) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), credentialTimeout)
+	defer cancel()
+
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, "git", "credential", action)
+	cmd.Env = append(os.Environ(), "GIT_TERMINAL_PROMPT=0")
+	cmd.Stdin = strings.NewReader(in + "\n")
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	err := cmd.Run()
+	if err == nil {
+		return stdout.Bytes(), nil
+	}
+	cmdErr := &CommandError{Command: "git credential " + action, ExitCode: commandExitCode(cmd),
+		Stderr: stderr.Bytes(), Err: err}
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) && action == "fill" {
+		// git gives up this way when no helper has credentials and it isn't allowed to prompt
+		cmdErr.Err = ErrNoCredentials
+	}
+	return nil, cmdErr
+}
+
+func parseCredential(out []byte) map[string]string {
+	fields := map[string]string{}
+	s := bufio.NewScanner(bytes.NewReader(out))
+	for s.Scan() {
+		kv := strings.SplitN(s.Text(), "=", 2)
+		if len(kv) == 2 {
+			fields[kv[0]] = kv[1]
+		}
+	}
+	return fields
+}
+
+func runAskPass(askPass, prompt string) (string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), credentialTimeout)
+	defer cancel()
+
+	var stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, askPass, prompt)
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return "", &CommandError{Command: askPass, ExitCode: commandExitCode(cmd), Stderr: stderr.Bytes(), Err: err}
+	}
+	return strings.TrimRight(string(out), "\r\n"), nil
+}
+
+// commandExitCode returns the exit code of cmd, or -1 when it didn't exit on its own
+func commandExitCode(cmd *exec.Cmd) int {
+	if cmd.ProcessState == nil {
+		return -1
+	}
+	return cmd.ProcessState.ExitCode()
+}
@@ -0,0 +1,110 @@
+package githelpers
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/config"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+)
+
+const (
+	signedOffByKey = "Signed-off-by"
+)
+
+// MissingSignOffError lists the commits without a Signed-off-by trailer from their author, as the
+// Developer Certificate of Origin requires
+type MissingSignOffError struct {
+	Commits []plumbing.Hash
+}
+
+func (e *MissingSignOffError) Error() string {
+	var short []string
+	for _, h := range e.Commits {
+		short = append(short, h.String()[:7])
+	}
+	return "commits missing a Signed-off-by from their author: " + strings.Join(short, ", ")
+}
+
+// VerifySignOffs checks that every commit reachable from head but not from base is signed off by its
+// author, returning a *MissingSignOffError listing those that aren't. An empty head means HEAD
+func (gr *GitRepo) VerifySignOffs(base, head string) error {
+	commits, err := gr.commitsBetween(base, head)
+	if err != nil {
+		return err
+	}
+
+	var missing []plumbing.Hash
+	for _, c := range commits {
+		if !signedOffByAuthor(c) {
+			missing = append(missing, c.Hash)
+		}
+	}
+	if len(missing) > 0 {
+		return &MissingSignOffError{Commits: missing}
+	}
+	return nil
+}
+
+// SignOffPrePushHook returns a hook for AddPrePushHook that runs VerifySignOffs on everything HEAD
+// adds on top of base, e.g. origin/main
+func SignOffPrePushHook(base string) PrePushHook {
+	return func(gr *GitRepo) error {
+		return gr.VerifySignOffs(base, "HEAD")
+	}
+}
+
+func signedOffByAuthor(c *object.Commit) bool {
+	want := SignedOffBy(c.Author.Name, c.Author.Email).Value
+	for _, t := range messageTrailers(c.Message) {
+		if t.Key == signedOffByKey && t.Value == want {
+			return true
+		}
+	}
+	return false
+}
+
+// messageTrailers returns the trailers in the last paragraph of msg, if it's made of trailers only
+func messageTrailers(msg string) []Trailer {
+	paragraphs := strings.Split(strings.TrimSpace(strings.Replace(msg, "\r\n", "\n", -1)), "\n\n")
+	if len(paragraphs) < 2 {
+		return nil
+	}
+	trailers, _ := parseTrailers(paragraphs[len(paragraphs)-1])
+	return trailers
+}
+
+// appendTrailer adds t to the trailers of msg, starting a trailer paragraph when msg has none, and
+// leaves msg alone when it already carries t
+func appendTrailer(msg string, t Trailer) string {
+	for _, existing := range messageTrailers(msg) {
+		if existing == t {
+			return msg
+		}
+	}
+
+	msg = strings.TrimRight(msg, "\n")
+	line := t.Key + ": " + t.Value
+	if len(messageTrailers(msg)) > 0 {
+		return msg + "\n" + line
+	}
+	return msg + "\n\n" + line
+}
+
+// commitAuthor returns the identity CommitAll commits as, found in the repo, global, and system git
+// config the same way go-git looks it up
+func commitAuthor(repo *git.Repository) (name, email string, err error) {
+	cfg, err := repo.ConfigScoped(config.SystemScope)
+	if err != nil {
+		return name, email, err
+	}
+	switch {
+	case cfg.Author.Name != "" && cfg.Author.Email != "":
+		return cfg.Author.Name, cfg.Author.Email, nil
+	case cfg.User.Name != "" && cfg.User.Email != "":
+		return cfg.User.Name, cfg.User.Email, nil
+	}
+	return name, email, fmt.Errorf("signing off: %w", git.ErrMissingAuthor)
+}
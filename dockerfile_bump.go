@@ -0,0 +1,115 @@
+package githelpers
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+var (
+	// dockerfileNames are the globs of the files BumpDockerfileBase rewrites
+	dockerfileNames = []string{"Dockerfile", "Dockerfile.*", "*.Dockerfile", "*.dockerfile", "Containerfile"}
+
+	// dockerfileFrom matches FROM [--platform=...] image [AS name]
+	dockerfileFrom   = regexp.MustCompile(`(?i)^(\s*FROM\s+(?:--\S+\s+)*)(\S+)(.*)$`)
+	dockerfileFromAs = regexp.MustCompile(`(?i)^\s+AS\s+(\S+)`)
+)
+
+// BumpDockerfileBase points the FROM lines using image, in every stage of every Dockerfile and
+// Containerfile in the GitRepo's worktree, at ref, and returns the files it changed. ref is either a
+// tag, e.g. 3.19, which drops any digest the line was pinned to, a digest, e.g. sha256:..., which
+// pins the image and keeps its tag, or both, as in 3.19@sha256:.... Docker Hub names match with or
+// without docker.io/ and library/, and stages built FROM an earlier stage are left alone
+func (gr *GitRepo) BumpDockerfileBase(image, ref string) (changed []string, err error) {
+	files, err := gr.worktreeFiles(dockerfileNames...)
+	if err != nil {
+		return changed, err
+	}
+
+	found := false
+	fs := gr.worktreeFilesystem()
+	for _, f := range files {
+		data, err := readWorktreeFile(fs, f.Path)
+		if err != nil {
+			return changed, err
+		}
+
+		lines := strings.Split(string(data), "\n")
+		stages := map[string]bool{}
+		for i, line := range lines {
+			m := dockerfileFrom.FindStringSubmatch(line)
+			if m == nil {
+				continue
+			}
+			name, tag, _ := splitImageRef(m[2])
+			if !stages[strings.ToLower(name)] && normalizeImageName(name) == normalizeImageName(image) {
+				found = true
+				lines[i] = m[1] + bumpImageRef(name, tag, ref) + m[3]
+			}
+			if as := dockerfileFromAs.FindStringSubmatch(m[3]); as != nil {
+				stages[strings.ToLower(as[1])] = true
+			}
+		}
+
+		out := strings.Join(lines, "\n")
+		if out == string(data) {
+			continue
+		}
+		err = gr.WriteFile(f.Path, []byte(out), f.Mode.Perm())
+		if err != nil {
+			return changed, err
+		}
+		changed = append(changed, f.Path)
+	}
+
+	if !found {
+		return changed, fmt.Errorf("%s: %w", image, ErrImageNotFound)
+	}
+	return changed, nil
+}
+
+// bumpImageRef returns the image reference for name at ref, a tag, a digest, or tag@digest. A digest
+// alone keeps the current tag, and a tag alone drops the current digest, which would otherwise still
+// pin the old image
+func bumpImageRef(name, tag, ref string) string {
+	ref = strings.TrimPrefix(ref, "@")
+	newTag, digest := ref, ""
+	switch i := strings.Index(ref, "@"); {
+	case i >= 0:
+		newTag, digest = ref[:i], ref[i+1:]
+	case strings.HasPrefix(ref, "sha256:") || strings.HasPrefix(ref, "sha512:"):
+		newTag, digest = tag, ref
+	}
+
+	out := name
+	if newTag != "" {
+		out += ":" + newTag
+	}
+	if digest != "" {
+		out += "@" + digest
+	}
+	return out
+}
+
+// splitImageRef splits an image reference such as registry:5000/app:1.0@sha256:... into its name,
+// tag, and digest, minding registry ports
+func splitImageRef(ref string) (name, tag, digest string) {
+	name = ref
+	if i := strings.Index(name, "@"); i >= 0 {
+		name, digest = name[:i], name[i+1:]
+	}
+	if i := strings.LastIndex(name, ":"); i > strings.LastIndex(name, "/") {
+		name, tag = name[:i], name[i+1:]
+	}
+	return name, tag, digest
+}
+
+// normalizeImageName drops the parts of a Docker Hub image name that are implied, so that
+// docker.io/library/alpine is alpine
+func normalizeImageName(name string) string {
+	name = strings.ToLower(name)
+	for _, registry := range []string{"docker.io/", "index.docker.io/", "registry-1.docker.io/"} {
+		name = strings.TrimPrefix(name, registry)
+	}
+	return strings.TrimPrefix(name, "library/")
+}
@@ -0,0 +1,370 @@
+package githelpers
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	// DriftAdded is the DriftChange kind for a file, document, or value only the to side has
+	DriftAdded = "added"
+	// DriftRemoved is the DriftChange kind for a file, document, or value only the from side has
+	DriftRemoved = "removed"
+	// DriftChanged is the DriftChange kind for a value that differs between the sides
+	DriftChanged = "changed"
+)
+
+var (
+	manifestExtensions = []string{".yaml", ".yml", ".json"}
+)
+
+// DriftSource is one side of a Drift comparison: a file or directory of manifests in a repo at a ref
+type DriftSource struct {
+	Repo *GitRepo
+	Ref  string // Defaults to HEAD
+	Path string // File or directory, relative to the root of the repo. Empty means the whole repo
+}
+
+// DriftChange is a semantic difference between two manifests
+type DriftChange struct {
+	// Relative to the source Path, or the file's name when Path is a file. Two files with different
+	// names are reported as from -> to, e.g. staging.yaml -> prod.yaml
+	File string
+	// The document of a multi-document file, as Kind/namespace/name for Kubernetes objects or #n by
+	// position otherwise. Empty for single documents
+	Document string
+	Path     string // Key path of the value, as SetYAMLValue takes them. Empty for a whole file or document
+	Kind     string // DriftAdded, DriftRemoved, or DriftChanged
+	From     interface{}
+	To       interface{}
+}
+
+func (c DriftChange) String() string {
+	where := c.File
+	if c.Document != "" {
+		where += " " + c.Document
+	}
+	if c.Path != "" {
+		where += " " + c.Path
+	}
+	switch c.Kind {
+	case DriftChanged:
+		return fmt.Sprintf("%s: changed from %v to %v", where, c.From, c.To)
+	case DriftAdded:
+		if c.Path == "" {
+			return where + ": added"
+		}
+		return fmt.Sprintf("%s: added as %v", where, c.To)
+	}
+	return where + ": removed"
+}
+
+// Drift compares the manifests under path at fromRef and toRef, e.g. main and release, as the
+// package level Drift does
+func (gr *GitRepo) Drift(path, fromRef, toRef string) (changes []DriftChange, err error) {
+	return Drift(DriftSource{Repo: gr, Ref: fromRef, Path: path}, DriftSource{Repo: gr, Ref: toRef, Path: path})
+}
+
+// Drift compares the YAML and JSON files of two sources, which can be in different repos, and reports
+// how they differ semantically: formatting, comments, key order, and the order of Kubernetes objects
+// in multi-document files are ignored. Files are matched by their path relative to each source's
+// Path, and other files are skipped. Changes are sorted by file
+func Drift(from, to DriftSource) (changes []DriftChange, err error) {
+	fromFiles, fromFile, err := from.manifests()
+	if err != nil {
+		return changes, err
+	}
+	toFiles, toFile, err := to.manifests()
+	if err != nil {
+		return changes, err
+	}
+	if fromFile != "" && toFile != "" && fromFile != toFile {
+		// Two files, e.g. envs/staging.yaml and envs/prod.yaml, are compared whatever their names
+		name := fromFile + " -> " + toFile
+		fromFiles = map[string][]byte{name: fromFiles[fromFile]}
+		toFiles = map[string][]byte{name: toFiles[toFile]}
+	}
+
+	names := map[string]bool{}
+	for name := range fromFiles {
+		names[name] = true
+	}
+	for name := range toFiles {
+		names[name] = true
+	}
+	sorted := make([]string, 0, len(names))
+	for name := range names {
+		sorted = append(sorted, name)
+	}
+	sort.Strings(sorted)
+
+	for _, name := range sorted {
+		a, inFrom := fromFiles[name]
+		b, inTo := toFiles[name]
+		switch {
+		case !inTo:
+			changes = append(changes, DriftChange{File: name, Kind: DriftRemoved})
+		case !inFrom:
+			changes = append(changes, DriftChange{File: name, Kind: DriftAdded})
+		case !bytes.Equal(a, b):
+			found, err := manifestDrift(name, a, b)
+			if err != nil {
+				return changes, err
+			}
+			changes = append(changes, found...)
+		}
+	}
+	return changes, nil
+}
+
+// manifests returns the contents of the source's YAML and JSON files by their path relative to Path,
+// and, when Path is a file, its name, by which it's returned
+func (s DriftSource) manifests() (files map[string][]byte, file string, err error) {
+	ref := s.Ref
+	if ref == "" {
+		ref = "HEAD"
+	}
+	c, err := s.Repo.commitAt(ref)
+	if err != nil {
+		return files, file, fmt.Errorf("resolving %s: %w", ref, err)
+	}
+	tree, err := c.Tree()
+	if err != nil {
+		return files, file, err
+	}
+
+	files = map[string][]byte{}
+	p := strings.Trim(s.Path, "/")
+	if p != "" && p != "." {
+		f, err := tree.File(p)
+		if err == nil {
+			file = path.Base(p)
+			contents, err := f.Contents()
+			files[file] = []byte(contents)
+			return files, file, err
+		}
+		tree, err = tree.Tree(p)
+		if err != nil {
+			return files, file, fmt.Errorf("%s at %s: %w", p, ref, err)
+		}
+	}
+
+	err = tree.Files().ForEach(func(f *object.File) error {
+		if !isManifest(f.Name) {
+			return nil
+		}
+		contents, err := f.Contents()
+		files[f.Name] = []byte(contents)
+		return err
+	})
+	return files, file, err
+}
+
+func isManifest(name string) bool {
+	ext := strings.ToLower(path.Ext(name))
+	for _, e := range manifestExtensions {
+		if ext == e {
+			return true
+		}
+	}
+	return false
+}
+
+func manifestDrift(file string, a, b []byte) (changes []DriftChange, err error) {
+	fromDocs, err := decodeManifest(a)
+	if err != nil {
+		return changes, fmt.Errorf("%s: %w", file, err)
+	}
+	toDocs, err := decodeManifest(b)
+	if err != nil {
+		return changes, fmt.Errorf("%s: %w", file, err)
+	}
+
+	if len(fromDocs) <= 1 && len(toDocs) <= 1 {
+		var x, y interface{}
+		if len(fromDocs) == 1 {
+			x = fromDocs[0]
+		}
+		if len(toDocs) == 1 {
+			y = toDocs[0]
+		}
+		return valueDrift(changes, DriftChange{File: file}, x, y), nil
+	}
+
+	fromIDs, toIDs := documentIDs(fromDocs), documentIDs(toDocs)
+	if fromIDs == nil || toIDs == nil {
+		// Not all Kubernetes objects, so documents can only be matched by position
+		fromIDs, toIDs = positionIDs(len(fromDocs)), positionIDs(len(toDocs))
+	}
+	byID := map[string]interface{}{}
+	for i, id := range toIDs {
+		byID[id] = toDocs[i]
+	}
+
+	seen := map[string]bool{}
+	for i, id := range fromIDs {
+		seen[id] = true
+		doc := DriftChange{File: file, Document: id}
+		y, ok := byID[id]
+		if !ok {
+			doc.Kind = DriftRemoved
+			changes = append(changes, doc)
+			continue
+		}
+		changes = valueDrift(changes, doc, fromDocs[i], y)
+	}
+	for _, id := range toIDs {
+		if !seen[id] {
+			changes = append(changes, DriftChange{File: file, Document: id, Kind: DriftAdded})
+		}
+	}
+	return changes, nil
+}
+
+func decodeManifest(data []byte) (docs []interface{}, err error) {
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	for {
+		var doc interface{}
+		err := dec.Decode(&doc)
+		if errors.Is(err, io.EOF) {
+			return docs, nil
+		}
+		if err != nil {
+			return docs, err
+		}
+		if doc != nil {
+			docs = append(docs, normalizeYAML(doc))
+		}
+	}
+}
+
+// normalizeYAML turns the mappings with non-string keys yaml decodes as map[interface{}]interface{}
+// into map[string]interface{}, so every mapping compares the same way
+func normalizeYAML(v interface{}) interface{} {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		for k, e := range v {
+			v[k] = normalizeYAML(e)
+		}
+		return v
+	case map[interface{}]interface{}:
+		m := make(map[string]interface{}, len(v))
+		for k, e := range v {
+			m[fmt.Sprint(k)] = normalizeYAML(e)
+		}
+		return m
+	case []interface{}:
+		for i, e := range v {
+			v[i] = normalizeYAML(e)
+		}
+		return v
+	}
+	return v
+}
+
+// documentIDs names each document Kind/namespace/name, or returns nil when some aren't Kubernetes
+// objects or two share a name
+func documentIDs(docs []interface{}) (ids []string) {
+	seen := map[string]bool{}
+	for _, d := range docs {
+		m, _ := d.(map[string]interface{})
+		meta, _ := m["metadata"].(map[string]interface{})
+		kind, _ := m["kind"].(string)
+		name, _ := meta["name"].(string)
+		if kind == "" || name == "" {
+			return nil
+		}
+		id := kind + "/" + name
+		if ns, _ := meta["namespace"].(string); ns != "" {
+			id = kind + "/" + ns + "/" + name
+		}
+		if seen[id] {
+			return nil
+		}
+		seen[id] = true
+		ids = append(ids, id)
+	}
+	return ids
+}
+
+func positionIDs(n int) (ids []string) {
+	for i := 0; i < n; i++ {
+		ids = append(ids, fmt.Sprintf("#%d", i))
+	}
+	return ids
+}
+
+// valueDrift appends the differences between x and y to changes, using at for the file, document,
+// and key path they were found at
+func valueDrift(changes []DriftChange, at DriftChange, x, y interface{}) []DriftChange {
+	xm, xIsMap := x.(map[string]interface{})
+	ym, yIsMap := y.(map[string]interface{})
+	if xIsMap && yIsMap {
+		keys := make([]string, 0, len(xm)+len(ym))
+		for k := range xm {
+			keys = append(keys, k)
+		}
+		for k := range ym {
+			if _, ok := xm[k]; !ok {
+				keys = append(keys, k)
+			}
+		}
+		sort.Strings(keys)
+
+		for _, k := range keys {
+			child := at
+			child.Path = k
+			if at.Path != "" {
+				child.Path = at.Path + "." + k
+			}
+			xv, inX := xm[k]
+			yv, inY := ym[k]
+			switch {
+			case !inY:
+				child.Kind, child.From = DriftRemoved, xv
+				changes = append(changes, child)
+			case !inX:
+				child.Kind, child.To = DriftAdded, yv
+				changes = append(changes, child)
+			default:
+				changes = valueDrift(changes, child, xv, yv)
+			}
+		}
+		return changes
+	}
+
+	xs, xIsSeq := x.([]interface{})
+	ys, yIsSeq := y.([]interface{})
+	if xIsSeq && yIsSeq {
+		for i := 0; i < len(xs) || i < len(ys); i++ {
+			child := at
+			child.Path = fmt.Sprintf("%s[%d]", at.Path, i)
+			switch {
+			case i >= len(ys):
+				child.Kind, child.From = DriftRemoved, xs[i]
+				changes = append(changes, child)
+			case i >= len(xs):
+				child.Kind, child.To = DriftAdded, ys[i]
+				changes = append(changes, child)
+			default:
+				changes = valueDrift(changes, child, xs[i], ys[i])
+			}
+		}
+		return changes
+	}
+
+	if !reflect.DeepEqual(x, y) {
+		at.Kind, at.From, at.To = DriftChanged, x, y
+		changes = append(changes, at)
+	}
+	return changes
+}
@@ -0,0 +1,64 @@
+package githelpers
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestDriftBetweenFiles(t *testing.T) {
+	gr := newTestRepo(t, map[string]string{
+		"envs/staging.yaml": "apiVersion: apps/v1\nkind: Deployment\nmetadata:\n  name: api\nspec:\n  replicas: 1\n",
+		"envs/prod.yaml":    "# Production\napiVersion: apps/v1\nkind: Deployment\nmetadata: {name: api}\nspec:\n  replicas: 3\n",
+		"envs/dev.yaml":     "kind: Deployment\nspec:\n  replicas: 1\n",
+	})
+
+	tests := []struct {
+		from, to string
+		want     []DriftChange
+	}{
+		{"envs/staging.yaml", "envs/prod.yaml", []DriftChange{
+			{File: "staging.yaml -> prod.yaml", Path: "spec.replicas", Kind: DriftChanged, From: 1, To: 3},
+		}},
+		{"envs/prod.yaml", "envs/prod.yaml", nil},
+		{"envs", "envs", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.from+" "+tt.to, func(t *testing.T) {
+			changes, err := Drift(DriftSource{Repo: gr, Path: tt.from}, DriftSource{Repo: gr, Path: tt.to})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(changes, tt.want) {
+				t.Errorf("got %+v, want %+v", changes, tt.want)
+			}
+		})
+	}
+}
+
+func TestDriftBetweenRefs(t *testing.T) {
+	gr := newTestRepo(t, map[string]string{
+		"envs/dev.yaml":  "kind: Deployment\nspec:\n  replicas: 1\n",
+		"envs/old.yaml":  "kind: ConfigMap\n",
+		"README.md":      "not a manifest\n",
+		"other/app.json": "{}",
+	})
+	first := headCommit(t, gr)
+	commitTestFiles(t, gr, "Scale dev", map[string]string{
+		"envs/dev.yaml": "kind: Deployment\nspec:\n  replicas: 2\n",
+		"envs/old.yaml": "",
+		"envs/new.yml":  "kind: Secret\n",
+	})
+
+	changes, err := gr.Drift("envs", first.Hash.String(), "HEAD")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []DriftChange{
+		{File: "dev.yaml", Path: "spec.replicas", Kind: DriftChanged, From: 1, To: 2},
+		{File: "new.yml", Kind: DriftAdded},
+		{File: "old.yaml", Kind: DriftRemoved},
+	}
+	if !reflect.DeepEqual(changes, want) {
+		t.Errorf("got %+v, want %+v", changes, want)
+	}
+}
@@ -0,0 +1,60 @@
+package githelpers
+
+import (
+	"time"
+
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+)
+
+// CommitEmpty commits msg with no file changes, as git commit --allow-empty does, e.g. to trigger
+// a pipeline or record a marker. The commit has the same tree as HEAD, so changes in the worktree,
+// staged or not, are left out and left alone. A branch with no commits yet gets an empty root
+// commit. SignOff and ConventionalCommits apply as they do to CommitAll
+func (gr *GitRepo) CommitEmpty(msg string) (hash plumbing.Hash, err error) {
+	gr.mu.Lock()
+	defer gr.mu.Unlock()
+
+	msg, err = gr.commitMessage(msg, nil)
+	if err != nil {
+		return hash, err
+	}
+	name, email, err := commitAuthor(gr.Repo)
+	if err != nil {
+		return hash, err
+	}
+
+	// HEAD itself, to find the branch it's on even when that branch has no commits yet
+	head, err := gr.Repo.Storer.Reference(plumbing.HEAD)
+	if err != nil {
+		return hash, err
+	}
+	branch := plumbing.HEAD
+	if head.Type() == plumbing.SymbolicReference {
+		branch = head.Target()
+	}
+
+	sig := object.Signature{Name: name, Email: email, When: time.Now()}
+	c := &object.Commit{Author: sig, Committer: sig, Message: msg}
+	tip, err := gr.Repo.Reference(branch, true)
+	switch {
+	case err == plumbing.ErrReferenceNotFound:
+		c.TreeHash, err = storeObject(gr.Repo.Storer, &object.Tree{})
+	case err == nil:
+		var parent *object.Commit
+		parent, err = gr.Repo.CommitObject(tip.Hash())
+		if err == nil {
+			c.TreeHash = parent.TreeHash
+			c.ParentHashes = []plumbing.Hash{parent.Hash}
+		}
+	}
+	if err != nil {
+		return hash, err
+	}
+
+	hash, err = storeObject(gr.Repo.Storer, c)
+	if err != nil {
+		return hash, err
+	}
+	return hash, gr.Repo.Storer.SetReference(plumbing.NewHashReference(branch, hash))
+}
@@ -0,0 +1,109 @@
+package githelpers
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/go-git/go-billy/v5"
+	"github.com/go-git/go-billy/v5/osfs"
+	"github.com/go-git/go-billy/v5/util"
+)
+
+var (
+	// ErrSymlinkRejected is returned by CommitAll when RejectSymlinks is set and a symlink would be committed
+	ErrSymlinkRejected = errors.New("symlinks are not allowed in this repo")
+)
+
+// WriteFile writes data to the file at path in the GitRepo's worktree, creating it if needed, and
+// sets its mode to perm even when the file already existed. Use 0755 for scripts so the commit
+// records them as executable
+func (gr *GitRepo) WriteFile(path string, data []byte, perm os.FileMode) error {
+	fs := gr.worktreeFilesystem()
+
+	err := util.WriteFile(fs, path, data, perm)
+	if err != nil {
+		return err
+	}
+	return gr.chmod(fs, path, perm)
+}
+
+// SetExecutable adds or removes the executable bits of the file at path in the GitRepo's worktree
+func (gr *GitRepo) SetExecutable(path string, executable bool) error {
+	fs := gr.worktreeFilesystem()
+
+	info, err := fs.Lstat(path)
+	if err != nil {
+		return err
+	}
+	if info.Mode()&os.ModeSymlink != 0 || info.IsDir() {
+		return fmt.Errorf("%s is not a regular file", path)
+	}
+
+	mode := info.Mode().Perm() &^ 0111
+	if executable {
+		// Give execute to whoever can read, as chmod +x does
+		mode |= (mode & 0444) >> 2
+	}
+	return gr.chmod(fs, path, mode)
+}
+
+// Symlink creates a symlink at link in the GitRepo's worktree pointing to target. Keep target
+// relative so the link still resolves in other clones
+func (gr *GitRepo) Symlink(target, link string) error {
+	fs := gr.worktreeFilesystem()
+
+	err := fs.MkdirAll(filepath.Dir(link), 0755)
+	if err != nil {
+		return err
+	}
+	return fs.Symlink(target, link)
+}
+
+func (gr *GitRepo) worktreeFilesystem() billy.Filesystem {
+	switch {
+	case gr.Worktree != nil:
+		return gr.Worktree.Filesystem
+	case gr.Filesystem != nil:
+		return gr.Filesystem
+	}
+	return osfs.New(gr.Dir)
+}
+
+func readWorktreeFile(fs billy.Filesystem, path string) ([]byte, error) {
+	f, err := fs.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return io.ReadAll(f)
+}
+
+func (gr *GitRepo) chmod(fs billy.Filesystem, path string, mode os.FileMode) error {
+	if c, ok := fs.(billy.Change); ok {
+		return c.Chmod(path, mode)
+	}
+	if gr.Filesystem == nil {
+		return os.Chmod(filepath.Join(fs.Root(), path), mode)
+	}
+
+	// Filesystems without Chmod, like memfs, only take the mode when a file is created, so
+	// recreate the file with the new mode
+	f, err := fs.Open(path)
+	if err != nil {
+		return err
+	}
+	data, err := io.ReadAll(f)
+	f.Close()
+	if err != nil {
+		return err
+	}
+
+	err = fs.Remove(path)
+	if err != nil {
+		return err
+	}
+	return util.WriteFile(fs, path, data, mode)
+}
@@ -0,0 +1,124 @@
+package githelpers
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	gitSSH "github.com/go-git/go-git/v5/plumbing/transport/ssh"
+)
+
+const (
+	defaultSyncBranch = "sync-files"
+)
+
+// SyncOption customizes where SyncFiles reads from and the branch, commit, and MRs it makes
+type SyncOption func(*syncConfig)
+
+type syncConfig struct {
+	ref     string
+	branch  string
+	message string
+}
+
+// WithSyncRef reads the files from ref of the source repo, instead of its default branch
+func WithSyncRef(ref string) SyncOption {
+	return func(cfg *syncConfig) {
+		cfg.ref = ref
+	}
+}
+
+// WithSyncBranch is the name of the branch made in every destination repo, instead of sync-files
+func WithSyncBranch(name string) SyncOption {
+	return func(cfg *syncConfig) {
+		cfg.branch = name
+	}
+}
+
+// WithSyncMessage is the commit message and MR title, instead of one listing the synced paths
+func WithSyncMessage(msg string) SyncOption {
+	return func(cfg *syncConfig) {
+		cfg.message = msg
+	}
+}
+
+// SyncFiles copies canonical files, such as CI templates, CODEOWNERS, or linter configs, from the
+// source repo to every destination repo and opens an MR in each one they differ in, through a Fleet
+// built as NewFleet does. See Fleet.SyncFiles
+func SyncFiles(ctx context.Context, sourceRepo string, destRepos []string, paths map[string]string, sshKey *gitSSH.PublicKeys, vcsToken string, opts ...SyncOption) (results []FleetResult, err error) {
+	f, err := NewFleet(destRepos, sshKey, vcsToken)
+	if err != nil {
+		return results, err
+	}
+	return f.SyncFiles(ctx, sourceRepo, paths, opts...)
+}
+
+// SyncFiles copies files from the source repo, cloned once, to every repo of the Fleet and proposes
+// them as MRs. paths maps each file or directory of the source repo to where it goes in the
+// destinations, with an empty destination meaning the same path. Directories are replaced, so files
+// removed from the source are removed from the destinations too. Repos that already have the same
+// files are reported as FleetStatusUnchanged and get no MR. Results are returned per repo, along with
+// a *MultiError of the repos that failed, as FleetErrors builds
+func (f *Fleet) SyncFiles(ctx context.Context, sourceRepo string, paths map[string]string, opts ...SyncOption) (results []FleetResult, err error) {
+	var sources []string
+	for from := range paths {
+		sources = append(sources, from)
+	}
+	sort.Strings(sources)
+
+	cfg := syncConfig{
+		branch:  defaultSyncBranch,
+		message: fmt.Sprintf("Sync %s from %s", strings.Join(sources, ", "), sourceRepo),
+	}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+
+	var dests []string
+	synced := map[string]map[string]treeFile{}
+	err = RunInTempClone(sourceRepo, cfg.ref, func(gr *GitRepo) error {
+		head, err := gr.Repo.Head()
+		if err != nil {
+			return err
+		}
+		c, err := gr.Repo.CommitObject(head.Hash())
+		if err != nil {
+			return err
+		}
+		tree, err := c.Tree()
+		if err != nil {
+			return err
+		}
+
+		for _, from := range sources {
+			to := paths[from]
+			if to == "" {
+				to = from
+			}
+			to = strings.Trim(to, "/")
+			dests = append(dests, to)
+			synced[to], err = readTreePath(tree, strings.Trim(from, "/"), to)
+			if err != nil {
+				return fmt.Errorf("syncing %s: %w", from, err)
+			}
+		}
+		return nil
+	}, WithCloneSSHKey(f.SSHKey), WithCloneVCSClient(f.VCSClient), WithCloneCache(f.Cache))
+	if err != nil {
+		return results, fmt.Errorf("%s: %w", sourceRepo, err)
+	}
+
+	// Replace directories before any destination inside them
+	sort.Strings(dests)
+	results = f.Run(ctx, cfg.message, cfg.branch, func(gr *GitRepo) error {
+		for _, to := range dests {
+			err := gr.replaceWorktreePath(to, synced[to])
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	return results, FleetErrors(results)
+}
@@ -0,0 +1,210 @@
+package githelpers
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing/format/index"
+)
+
+const (
+	defaultMaxFileSize = 100 << 20
+	// binarySniffLen is how much of a file git looks at for a NUL byte to decide it's binary
+	binarySniffLen = 8000
+)
+
+// FlaggedFile is a changed file that DetectLargeOrBinaryFiles objected to
+type FlaggedFile struct {
+	Path   string
+	Size   int64
+	Large  bool
+	Binary bool
+}
+
+// FileCheckError lists the changed files that are too large or binary
+type FileCheckError struct {
+	Files []FlaggedFile
+}
+
+func (e *FileCheckError) Error() string {
+	var reasons []string
+	for _, f := range e.Files {
+		switch {
+		case f.Large && f.Binary:
+			reasons = append(reasons, fmt.Sprintf("%s (binary, %d bytes)", f.Path, f.Size))
+		case f.Large:
+			reasons = append(reasons, fmt.Sprintf("%s (%d bytes)", f.Path, f.Size))
+		default:
+			reasons = append(reasons, f.Path+" (binary)")
+		}
+	}
+	return "refusing to commit large or binary files: " + strings.Join(reasons, ", ")
+}
+
+// DetectLargeOrBinaryFiles checks the files CommitAll would stage and returns a *FileCheckError
+// listing those bigger than threshold bytes or that look binary
+func (gr *GitRepo) DetectLargeOrBinaryFiles(threshold int64) error {
+	files, err := pendingFiles(gr.Repo, gr.Worktree, false)
+	if err != nil {
+		return err
+	}
+	return checkFiles(files, threshold, true)
+}
+
+func (gr *GitRepo) maxFileSize() int64 {
+	if gr.MaxFileSize == 0 {
+		return defaultMaxFileSize
+	}
+	return gr.MaxFileSize
+}
+
+func checkFiles(files []pendingFile, threshold int64, binary bool) (err error) {
+	var flagged []FlaggedFile
+	for _, pf := range files {
+		if pf.deleted || !pf.regular {
+			continue
+		}
+
+		f := FlaggedFile{Path: pf.path, Size: pf.size, Large: threshold >= 0 && pf.size > threshold}
+		if binary {
+			f.Binary, err = isBinaryFile(pf)
+			if err != nil {
+				return err
+			}
+		}
+		if f.Large || f.Binary {
+			flagged = append(flagged, f)
+		}
+	}
+
+	if len(flagged) == 0 {
+		return nil
+	}
+	sort.Slice(flagged, func(i, j int) bool { return flagged[i].Path < flagged[j].Path })
+	return &FileCheckError{Files: flagged}
+}
+
+// pendingFile is a file CommitAll would commit a change to
+type pendingFile struct {
+	path    string
+	deleted bool
+	regular bool // Not a symlink or a submodule
+	size    int64
+	open    func() (io.ReadCloser, error) // Opens the contents that would be committed
+}
+
+// pendingFiles returns the files CommitAll would commit changes to: every changed one, as it is in the
+// worktree, or with stagedOnly, only the staged ones, as they are in the index
+func pendingFiles(repo *git.Repository, wt *git.Worktree, stagedOnly bool) (files []pendingFile, err error) {
+	status, err := pendingStatus(wt)
+	if err != nil {
+		return files, err
+	}
+	var idx *index.Index
+	if stagedOnly {
+		idx, err = repo.Storer.Index()
+		if err != nil {
+			return files, err
+		}
+	}
+
+	for path, s := range status {
+		f := pendingFile{path: path}
+		switch {
+		case stagedOnly && (s.Staging == git.Unmodified || s.Staging == git.Untracked):
+			continue
+		case stagedOnly:
+			f.deleted = s.Staging == git.Deleted
+			if !f.deleted {
+				err = stagedFile(repo, idx, &f)
+			}
+		case !pendingChange(s):
+			continue
+		default:
+			f.deleted = pendingDeletion(s)
+			if !f.deleted {
+				err = worktreeFile(wt, &f)
+			}
+		}
+		if err != nil {
+			return files, err
+		}
+		files = append(files, f)
+	}
+	return files, nil
+}
+
+// pendingChange reports whether CommitAll would commit a change to the file with status s, whether
+// it's staged already or only changed in the worktree
+func pendingChange(s *git.FileStatus) bool {
+	return s.Staging != git.Unmodified || s.Worktree != git.Unmodified
+}
+
+// pendingDeletion reports whether the change CommitAll would commit to the file with status s deletes it
+func pendingDeletion(s *git.FileStatus) bool {
+	return s.Worktree == git.Deleted || (s.Staging == git.Deleted && s.Worktree == git.Unmodified)
+}
+
+func stagedFile(repo *git.Repository, idx *index.Index, f *pendingFile) error {
+	e, err := idx.Entry(f.path)
+	if err != nil {
+		return err
+	}
+	mode, err := e.Mode.ToOSFileMode()
+	if err != nil {
+		return err
+	}
+	f.regular = mode.IsRegular()
+	if !f.regular {
+		return nil
+	}
+
+	blob, err := repo.BlobObject(e.Hash)
+	if err != nil {
+		return err
+	}
+	f.size = blob.Size
+	f.open = blob.Reader
+	return nil
+}
+
+func worktreeFile(wt *git.Worktree, f *pendingFile) error {
+	info, err := wt.Filesystem.Lstat(f.path)
+	if err != nil {
+		return err
+	}
+	f.regular = info.Mode().IsRegular()
+	f.size = info.Size()
+	path := f.path
+	f.open = func() (io.ReadCloser, error) { return wt.Filesystem.Open(path) }
+	return nil
+}
+
+func (f pendingFile) contents() ([]byte, error) {
+	r, err := f.open()
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	return io.ReadAll(r)
+}
+
+func isBinaryFile(pf pendingFile) (bool, error) {
+	// Same heuristic as git: a NUL byte near the start of the file means binary
+	f, err := pf.open()
+	if err != nil {
+		return false, err
+	}
+	defer f.Close()
+
+	buf := make([]byte, binarySniffLen)
+	n, err := io.ReadFull(f, buf)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		return false, err
+	}
+	return bytes.IndexByte(buf[:n], 0) >= 0, nil
+}
@@ -0,0 +1,173 @@
+package githelpers
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"time"
+
+	"github.com/go-git/go-billy/v5"
+	"github.com/go-git/go-billy/v5/osfs"
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing/format/gitignore"
+)
+
+// WalkOptions controls which files Files returns. Globs use .gitignore syntax, so "*.go" matches at
+// any depth, "/build" only at the top, and "docs/**" everything under docs
+type WalkOptions struct {
+	Include       []string // When set, only files matching one of these globs are returned
+	Exclude       []string // Files and dirs matching one of these globs are left out
+	Gitignore     bool     // Leaves out what the .gitignore files in the walked dir and the global git excludes ignore
+	IncludeDirs   bool     // Returns directories as well as files
+	IncludeGitDir bool     // Walks into the .git dir, which is skipped by default
+}
+
+// FileInfo describes a file found by Files
+type FileInfo struct {
+	Path    string // Slash separated and relative to the walked dir
+	Size    int64
+	Mode    os.FileMode
+	ModTime time.Time
+	IsDir   bool
+}
+
+// Files walks dir and returns the files in it, in lexical order. With a Filesystem on the GitRepo,
+// dir is relative to its root, otherwise to the working directory
+func (gr *GitRepo) Files(dir string, opts WalkOptions) (files []FileInfo, err error) {
+	fs, err := gr.walkFilesystem(dir)
+	if err != nil {
+		return files, err
+	}
+
+	var ignore gitignore.Matcher
+	if opts.Gitignore {
+		global, err := globalIgnorePatterns()
+		if err != nil {
+			return files, err
+		}
+		ps, err := gitignore.ReadPatterns(fs, nil)
+		if err != nil {
+			return files, err
+		}
+		// Later patterns win, so the repo's own .gitignore files override the global excludes
+		ignore = gitignore.NewMatcher(append(global, ps...))
+	}
+
+	w := fileWalker{
+		fs:            fs,
+		ignore:        ignore,
+		include:       parseGlobs(opts.Include),
+		exclude:       parseGlobs(opts.Exclude),
+		includeDirs:   opts.IncludeDirs,
+		includeGitDir: opts.IncludeGitDir,
+	}
+	err = w.walk(nil)
+	return w.files, err
+}
+
+// PrintFiles writes the path of every file Files returns for dir to w, one per line
+func (gr *GitRepo) PrintFiles(w io.Writer, dir string, opts WalkOptions) error {
+	files, err := gr.Files(dir, opts)
+	if err != nil {
+		return err
+	}
+	for _, f := range files {
+		_, err = fmt.Fprintln(w, filepath.Join(dir, filepath.FromSlash(f.Path)))
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// worktreeFiles returns the files in the GitRepo's worktree matching one of the include globs, with
+// paths relative to its root
+func (gr *GitRepo) worktreeFiles(include ...string) (files []FileInfo, err error) {
+	root := gr.Dir
+	if gr.Filesystem != nil {
+		root = ""
+	}
+	return gr.Files(root, WalkOptions{Include: include})
+}
+
+func (gr *GitRepo) walkFilesystem(dir string) (billy.Filesystem, error) {
+	if gr.Filesystem == nil {
+		if dir == "" {
+			dir = "."
+		}
+		return osfs.New(dir), nil
+	}
+	if dir == "" || dir == "." {
+		return gr.Filesystem, nil
+	}
+	return gr.Filesystem.Chroot(dir)
+}
+
+type fileWalker struct {
+	fs            billy.Filesystem
+	ignore        gitignore.Matcher
+	include       []gitignore.Pattern
+	exclude       []gitignore.Pattern
+	includeDirs   bool
+	includeGitDir bool
+
+	files []FileInfo
+}
+
+func (w *fileWalker) walk(dir []string) error {
+	entries, err := w.fs.ReadDir(path.Join(dir...))
+	if err != nil {
+		return err
+	}
+
+	for _, e := range entries {
+		p := append(append([]string{}, dir...), e.Name())
+		if e.IsDir() && len(dir) == 0 && e.Name() == git.GitDirName && !w.includeGitDir {
+			continue
+		}
+		if w.ignore != nil && w.ignore.Match(p, e.IsDir()) {
+			continue
+		}
+		if matchesAny(w.exclude, p, e.IsDir()) {
+			continue
+		}
+
+		if !e.IsDir() || w.includeDirs {
+			if len(w.include) == 0 || matchesAny(w.include, p, e.IsDir()) {
+				w.files = append(w.files, FileInfo{
+					Path:    path.Join(p...),
+					Size:    e.Size(),
+					Mode:    e.Mode(),
+					ModTime: e.ModTime(),
+					IsDir:   e.IsDir(),
+				})
+			}
+		}
+
+		if e.IsDir() {
+			err = w.walk(p)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func parseGlobs(globs []string) (ps []gitignore.Pattern) {
+	for _, g := range globs {
+		ps = append(ps, gitignore.ParsePattern(g, nil))
+	}
+	return ps
+}
+
+func matchesAny(ps []gitignore.Pattern, p []string, isDir bool) bool {
+	for _, pattern := range ps {
+		if pattern.Match(p, isDir) == gitignore.Exclude {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,212 @@
+package githelpers
+
+import (
+	"context"
+	"sync"
+
+	gitSSH "github.com/go-git/go-git/v5/plumbing/transport/ssh"
+)
+
+const (
+	defaultFleetConcurrency = 4
+
+	// FleetStatusProposed means the change was committed, pushed, and an MR was opened
+	FleetStatusProposed = "proposed"
+	// FleetStatusUnchanged means the change function left the worktree clean, so nothing was pushed
+	FleetStatusUnchanged = "unchanged"
+	// FleetStatusAlreadyApplied means the target branch has the Fleet's ChangeID recorded already, so
+	// the repo was left alone
+	FleetStatusAlreadyApplied = "already-applied"
+	// FleetStatusFailed means a step of the run failed. The result's Err says which
+	FleetStatusFailed = "failed"
+)
+
+// ChangeFunc mutates the worktree of a freshly cloned repo. The GitRepo's Dir is the root of the clone
+type ChangeFunc func(gr *GitRepo) error
+
+// Fleet applies the same change to many repos at once, cloning each one, running a ChangeFunc on it,
+// and proposing the result as an MR. Every repo gets its own GitRepo and clone directory, so workers
+// only share the VCS client, which must be safe for concurrent use
+type Fleet struct {
+	URLs         []string
+	SSHKey       *gitSSH.PublicKeys
+	VCSClient    VCSProvider
+	Concurrency  int            // Number of repos processed at the same time. Defaults to 4
+	TargetBranch string         // Branch the MRs target. Defaults to the default branch of each repo
+	UniqueBranch bool           // Adds a unique suffix to the branch name, as NewBranch does
+	BranchOpts   []BranchOption // Names branches with NewBranchWithOptions instead. UniqueBranch is ignored when set
+	Cache        *CloneCache    // Clones from mirrors kept here, so repeated runs only fetch what changed
+	MRTitle      string         // Title of the MRs. Defaults to the commit message
+	PrePushHooks []PrePushHook  // Added to every clone with AddPrePushHook, so a failing hook keeps that repo from being pushed
+	Limits       *ChangeLimits  // Set as the Limits of every clone
+	SignOff      bool           // Signs off every commit, as GitRepo.SignOff does
+	Trailers     []Trailer      // Added to every commit, as GitRepo.Trailers are
+	ChangeID     string         // Recorded on every commit in an Applied-Change trailer. Repos whose target branch has it already are skipped
+}
+
+// FleetResult records what happened to a single repo during a Fleet run
+type FleetResult struct {
+	URL    string
+	Branch string
+	MR     MergeRequestInfo
+	Status string
+	Err    error
+}
+
+// NewFleet returns a Fleet for the given SSH repo URLs, with a GitLab client for opening MRs
+func NewFleet(urls []string, sshKey *gitSSH.PublicKeys, vcsToken string) (f *Fleet, err error) {
+	p, err := NewGitlabProvider(vcsToken)
+	if err != nil {
+		return f, err
+	}
+
+	return &Fleet{
+		URLs:      urls,
+		SSHKey:    sshKey,
+		VCSClient: p,
+	}, nil
+}
+
+// NewGitlabGroupFleet returns a Fleet for every project in the group tree at groupPath
+func NewGitlabGroupFleet(groupPath string, sshKey *gitSSH.PublicKeys, vcsToken string) (f *Fleet, err error) {
+	f, err = NewFleet(nil, sshKey, vcsToken)
+	if err != nil {
+		return f, err
+	}
+
+	gr := &GitRepo{VCSClient: f.VCSClient}
+	projects, _, err := gr.FindProjects("", groupPath)
+	if err != nil {
+		return f, err
+	}
+	for _, p := range projects {
+		f.URLs = append(f.URLs, p.SSHURL)
+	}
+	return f, nil
+}
+
+// Run clones every repo in the Fleet, applies change on a new branch, and commits, pushes, and opens
+// an MR for each repo change left changes in. With a ChangeID set, repos whose target branch records
+// it already are skipped without running change. Repos not yet started when ctx is canceled are reported
+// as failed. Results are returned in the same order as the Fleet's URLs
+func (f *Fleet) Run(ctx context.Context, commitMsg, branch string, change ChangeFunc) []FleetResult {
+	results := make([]FleetResult, len(f.URLs))
+
+	workers := f.Concurrency
+	if workers <= 0 {
+		workers = defaultFleetConcurrency
+	}
+
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				if ctx.Err() != nil {
+					results[i] = FleetResult{URL: f.URLs[i], Status: FleetStatusFailed, Err: ctx.Err()}
+					continue
+				}
+				results[i] = f.apply(f.URLs[i], commitMsg, branch, change)
+			}
+		}()
+	}
+
+	for i := range f.URLs {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+
+	return results
+}
+
+func (f *Fleet) apply(url, commitMsg, branch string, change ChangeFunc) (res FleetResult) {
+	res = FleetResult{URL: url, Status: FleetStatusFailed}
+
+	res.Err = RunInTempClone(url, "", func(gr *GitRepo) error {
+		target := f.TargetBranch
+		if target == "" {
+			head, err := gr.Repo.Head()
+			if err != nil {
+				return err
+			}
+			target = head.Name().Short()
+		}
+
+		if f.ChangeID != "" {
+			tip, _, err := gr.branchCommit(target, true)
+			if err != nil {
+				return err
+			}
+			applied, err := gr.changeApplied(tip.Hash.String(), f.ChangeID)
+			if err != nil {
+				return err
+			}
+			if applied {
+				res.Status = FleetStatusAlreadyApplied
+				return nil
+			}
+		}
+
+		var err error
+		if len(f.BranchOpts) > 0 {
+			res.Branch, err = gr.NewBranchWithOptions(branch, f.BranchOpts...)
+		} else {
+			res.Branch, err = gr.NewBranch(branch, f.UniqueBranch)
+		}
+		if err != nil {
+			return err
+		}
+
+		err = change(gr)
+		if err != nil {
+			return err
+		}
+		gr.Limits = f.Limits
+		gr.SignOff = f.SignOff
+		gr.Trailers = f.Trailers
+		if f.ChangeID != "" {
+			gr.Trailers = append(append([]Trailer(nil), f.Trailers...), Trailer{Key: AppliedChangeKey, Value: f.ChangeID})
+		}
+		for _, hook := range f.PrePushHooks {
+			gr.AddPrePushHook(hook)
+		}
+
+		status, err := gr.Worktree.Status()
+		if err != nil {
+			return err
+		}
+		if status.IsClean() {
+			res.Status = FleetStatusUnchanged
+			return nil
+		}
+
+		err = gr.CommitAndPushAll(commitMsg)
+		if err != nil {
+			return err
+		}
+
+		title := f.MRTitle
+		if title == "" {
+			title = commitMsg
+		}
+		res.MR, err = gr.NewMergeRequest(title, res.Branch, target)
+		if err == nil {
+			res.Status = FleetStatusProposed
+		}
+		return err
+	}, WithCloneSSHKey(f.SSHKey), WithCloneVCSClient(f.VCSClient), WithCloneCache(f.Cache))
+	return res
+}
+
+// FleetErrors gathers the errors of the failed results into a *MultiError keyed by repo URL, or returns
+// nil when every repo succeeded
+func FleetErrors(results []FleetResult) error {
+	failed := &MultiError{}
+	for _, res := range results {
+		failed.Add(res.URL, res.Err)
+	}
+	return failed.ErrorOrNil()
+}
@@ -0,0 +1,80 @@
+package githelpers
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// FleetReportEntry is the outcome of a Fleet run for a single repo, flattened for rendering
+type FleetReportEntry struct {
+	Repo   string `json:"repo"`
+	Branch string `json:"branch,omitempty"`
+	MRURL  string `json:"mr_url,omitempty"`
+	Status string `json:"status"`
+	Error  string `json:"error,omitempty"`
+}
+
+// FleetReport summarizes a Fleet run so it can be posted to chat or an issue
+type FleetReport struct {
+	Entries []FleetReportEntry `json:"results"`
+}
+
+// NewFleetReport builds a FleetReport from the results returned by Fleet.Run
+func NewFleetReport(results []FleetResult) FleetReport {
+	r := FleetReport{Entries: make([]FleetReportEntry, 0, len(results))}
+	for _, res := range results {
+		e := FleetReportEntry{
+			Repo:   res.URL,
+			Branch: res.Branch,
+			Status: res.Status,
+		}
+		e.MRURL = res.MR.URL
+		if res.Err != nil {
+			e.Error = res.Err.Error()
+		}
+		r.Entries = append(r.Entries, e)
+	}
+	return r
+}
+
+// Counts returns the number of entries per status
+func (r FleetReport) Counts() map[string]int {
+	counts := map[string]int{}
+	for _, e := range r.Entries {
+		counts[e.Status]++
+	}
+	return counts
+}
+
+// JSON renders the report as indented JSON
+func (r FleetReport) JSON() ([]byte, error) {
+	return json.MarshalIndent(r, "", "  ")
+}
+
+// Markdown renders the report as a status summary followed by a table with one row per repo
+func (r FleetReport) Markdown() string {
+	var b strings.Builder
+
+	counts := r.Counts()
+	fmt.Fprintf(&b, "**%d repos:** %d proposed, %d unchanged, %d already applied, %d failed\n\n", len(r.Entries),
+		counts[FleetStatusProposed], counts[FleetStatusUnchanged], counts[FleetStatusAlreadyApplied],
+		counts[FleetStatusFailed])
+
+	b.WriteString("| Repo | Branch | MR | Status | Error |\n")
+	b.WriteString("| --- | --- | --- | --- | --- |\n")
+	for _, e := range r.Entries {
+		mr := ""
+		if e.MRURL != "" {
+			mr = fmt.Sprintf("[link](%s)", e.MRURL)
+		}
+		fmt.Fprintf(&b, "| %s | %s | %s | %s | %s |\n", markdownCell(e.Repo), markdownCell(e.Branch), mr,
+			e.Status, markdownCell(e.Error))
+	}
+	return b.String()
+}
+
+func markdownCell(s string) string {
+	s = strings.Replace(s, "|", `\|`, -1)
+	return strings.Replace(s, "\n", " ", -1)
+}
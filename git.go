@@ -1,17 +1,24 @@
 package githelpers
 
 import (
-	"fmt"
-	"io/ioutil"
+	"context"
 	"os"
+	"path/filepath"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
+	"github.com/go-git/go-billy/v5"
 	"github.com/go-git/go-git/v5"
 	"github.com/go-git/go-git/v5/config"
 	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/cache"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/go-git/go-git/v5/plumbing/transport"
 	gitSSH "github.com/go-git/go-git/v5/plumbing/transport/ssh"
+	"github.com/go-git/go-git/v5/storage"
+	"github.com/go-git/go-git/v5/storage/filesystem"
 	"golang.org/x/crypto/ssh"
 )
 
@@ -19,21 +26,46 @@ const (
 	defaultRemoteName = "origin"
 )
 
-// TempDir holds the directory name of the tmp dir created by NewTempDir().
-// It should probably store the fullpath instead
+// TempDir holds the absolute path of the tmp dir created by NewTempDir()
 type TempDir struct {
 	DirName string
 }
 
+// NewTempDir creates a new tmp directory, under the system tmp dir unless WithTempDirBase says
+// otherwise, and stores its absolute path in the returned TempDir struct. Unlike EnterNewTempDir,
+// it leaves the working directory alone, so it's safe to use from many goroutines
+func NewTempDir(opts ...TempDirOption) (t TempDir, err error) {
+	cfg := tempDirConfig{prefix: defaultTempDirPrefix}
+	for _, o := range opts {
+		o(&cfg)
+	}
+
+	d, err := os.MkdirTemp(cfg.baseDir, cfg.prefix)
+	if err != nil {
+		return TempDir{}, err
+	}
+
+	d, err = filepath.Abs(d)
+	if err != nil {
+		return TempDir{}, err
+	}
+
+	if cfg.signalCleanup {
+		registerTempDir(d)
+	}
+	return TempDir{DirName: d}, nil
+}
+
 // EnterNewTempDir creates a new tmp directory, makes it the working directory,
-// and stores it in the returned TempDir struct
+// and stores it in the returned TempDir struct. The working directory is process wide,
+// so this must not be used while other goroutines rely on it
 func EnterNewTempDir() (t TempDir, err error) {
 	dir, err := os.Getwd()
 	if err != nil {
 		return TempDir{}, err
 	}
 
-	d, err := ioutil.TempDir(dir, "")
+	d, err := os.MkdirTemp(dir, "")
 	if err != nil {
 		return TempDir{}, err
 	}
@@ -48,19 +80,36 @@ func EnterNewTempDir() (t TempDir, err error) {
 
 // CleanTempDir removes the tmp directory stored in the TempDir struct
 func (t *TempDir) CleanTempDir() (err error) {
+	unregisterTempDir(t.DirName)
 	return os.RemoveAll(t.DirName)
 }
 
+// Close removes the tmp directory, so a TempDir can be cleaned up with defer t.Close()
+func (t *TempDir) Close() error {
+	return t.CleanTempDir()
+}
+
 // KeyPath type handles managing the retrieval of SSH public keys
 type KeyPath string
 
 // SetupGitSSHPubKeys fetches SSH public keys based on the key path
 func (k KeyPath) SetupGitSSHPubKeys() (*gitSSH.PublicKeys, error) {
-	pem, err := ioutil.ReadFile(string(k))
+	return SSHKeyFromSecret(context.Background(), FileSecretSource{}, string(k))
+}
+
+// SetupGitSSHPubKeysAs is SetupGitSSHPubKeys for servers that expect a login user other than git, e.g.
+// Gerrit or a plain SSH host
+func (k KeyPath) SetupGitSSHPubKeysAs(user string) (*gitSSH.PublicKeys, error) {
+	keys, err := k.SetupGitSSHPubKeys()
 	if err != nil {
-		return &gitSSH.PublicKeys{}, err
+		return keys, err
 	}
+	keys.User = user
+	return keys, nil
+}
 
+// sshPublicKeys returns the SSH auth for a PEM encoded private key
+func sshPublicKeys(pem []byte) (*gitSSH.PublicKeys, error) {
 	signer, err := ssh.ParsePrivateKey(pem)
 	if err != nil {
 		return &gitSSH.PublicKeys{}, err
@@ -75,7 +124,8 @@ func (k KeyPath) SetupGitSSHPubKeys() (*gitSSH.PublicKeys, error) {
 	}, nil
 }
 
-// GitRepo represents a collection of the git repository name, SSH URL, and the configuration that specifies what file content to change and how
+// GitRepo represents a collection of the git repository name, SSH URL, and the configuration that specifies what file content to change and how.
+// Its git operations hold an internal lock, so one GitRepo can be shared by goroutines as long as the exported fields aren't changed while it's in use
 type GitRepo struct {
 	Dir                   string
 	Namespace             string
@@ -84,8 +134,24 @@ type GitRepo struct {
 	SSHURL                string
 	InitialTargetRevision string
 	TempDir               string
-	VCSClient             interface{} // This package only supports GitLab at the moment
+	VCSClient             VCSProvider
 	Worktree              *git.Worktree
+	Filesystem            billy.Filesystem                // When set, the repo lives on this filesystem, e.g. memfs, instead of in Dir
+	RejectSymlinks        bool                            // Makes CommitAll fail with ErrSymlinkRejected rather than commit a symlink
+	MaxFileSize           int64                           // CommitAll refuses files bigger than this many bytes. Defaults to 100 MB, negative turns the check off
+	RejectBinaryFiles     bool                            // Makes CommitAll refuse binary files too
+	ConventionalCommits   bool                            // Makes CommitAll reject messages that aren't Conventional Commits
+	AssignCodeOwners      bool                            // Makes NewGitlabMergeRequest request reviews from the CODEOWNERS of the changed files
+	TrackUpstream         bool                            // Makes Push set the checked out branch's upstream to the same branch on its remote
+	SecretScanner         *SecretScanner                  // When set, CommitAll refuses files it finds potential secrets in
+	Limits                *ChangeLimits                   // When set, CommitAll refuses changes breaking these limits
+	SignOff               bool                            // Makes CommitAll add a Signed-off-by trailer for the commit author, as git commit -s does
+	Trailers              []Trailer                       // Added to the message of every commit CommitAll makes, e.g. to record which automation run made it
+	RemoteName            string                          // Remote that pushes, fetches, and pulls go to, e.g. a fork's. Defaults to origin
+	RemoteAuth            map[string]transport.AuthMethod // Auth per remote name, e.g. a GitHub token for a mirror. Remotes left out use SSHKey
+
+	mu           sync.Mutex
+	prePushHooks []PrePushHook
 }
 
 // NewGitRepo returns a GitRepo with the minimum configs required for using the struct
@@ -108,35 +174,172 @@ func NewGitRepo(commitMsg, initType, repoDir, repoURL string, sshKey *gitSSH.Pub
 		return gr, err
 	}
 
-	masterRef := fmt.Sprintf("%s/.git/refs/heads/master", gr.Dir)
-	err = os.Remove(masterRef)
+	// Through the storer, so the ref goes wherever the repo keeps it, e.g. in memory or packed-refs
+	err = gr.Repo.Storer.RemoveReference(plumbing.NewBranchReferenceName("master"))
 
 	return gr, err
 }
 
 // Clone uses a given reference name to clone a Git repo
 func (gr *GitRepo) Clone(ref plumbing.ReferenceName) (*git.Repository, error) {
-	// Clones the repository into the given dir, just as a normal git clone does
-	repo, err := git.PlainClone(gr.Dir, false, &git.CloneOptions{
-		Auth:          gr.SSHKey,
+	return gr.cloneContext(context.Background(), ref)
+}
+
+func (gr *GitRepo) cloneContext(ctx context.Context, ref plumbing.ReferenceName) (*git.Repository, error) {
+	gr.mu.Lock()
+	defer gr.mu.Unlock()
+
+	opts := &git.CloneOptions{
+		Auth:          gr.remoteAuth(gr.remoteName()),
 		URL:           gr.SSHURL,
 		ReferenceName: ref,
-	})
+		RemoteName:    gr.remoteName(),
+	}
+
+	if gr.Filesystem != nil {
+		s, wt, err := gr.storage(false)
+		if err != nil {
+			return nil, err
+		}
+		return git.CloneContext(ctx, s, wt, opts)
+	}
+
+	err := gr.absDir()
+	if err != nil {
+		return nil, err
+	}
+
+	// Clones the repository into the given dir, just as a normal git clone does
+	repo, err := git.PlainCloneContext(ctx, gr.Dir, false, opts)
 
 	return repo, err
 }
 
-// CommitAll stages all changes on the provided Worktree
+// CommitAll stages all changes on the provided Worktree, leaving out files ignored by .gitignore
+// or the global git excludes, and commits them. Files over MaxFileSize, and binary files when
+// RejectBinaryFiles is set, fail the commit with a *FileCheckError. Potential secrets found by the
+// SecretScanner, when one is set, fail it with a *SecretsFoundError, and changes breaking Limits with
+// a *ForbiddenPathError or *ChangeLimitError
 func (gr *GitRepo) CommitAll(commitMsg string) (hash plumbing.Hash, err error) {
-	err = gr.Worktree.AddGlob(".")
+	return gr.commitAll(commitMsg, commitOptions{})
+}
+
+// commitOptions change what commitAll commits, which otherwise is what CommitAll does
+type commitOptions struct {
+	parents    []plumbing.Hash   // Instead of HEAD
+	root       bool              // Commits with no parents at all, which go-git takes to mean HEAD
+	author     *object.Signature // Instead of the configured user
+	stagedOnly bool              // Commits what's staged already rather than staging all changes first
+}
+
+func (gr *GitRepo) commitAll(commitMsg string, opts commitOptions) (hash plumbing.Hash, err error) {
+	gr.mu.Lock()
+	defer gr.mu.Unlock()
+
+	return gr.commit(commitMsg, opts)
+}
+
+// commit is commitAll for callers holding the lock already
+func (gr *GitRepo) commit(commitMsg string, opts commitOptions) (hash plumbing.Hash, err error) {
+	commitMsg, err = gr.commitMessage(commitMsg, opts.author)
+	if err != nil {
+		return hash, err
+	}
+
+	err = gr.checkPendingFiles(opts.stagedOnly)
 	if err != nil {
 		return hash, err
 	}
 
+	if !opts.stagedOnly {
+		err = stageAll(gr.Worktree, gr.RejectSymlinks)
+		if err != nil {
+			return hash, err
+		}
+	}
+
+	var committer *object.Signature
+	if opts.author != nil {
+		// go-git would commit as the author too
+		name, email, err := commitAuthor(gr.Repo)
+		if err != nil {
+			return hash, err
+		}
+		committer = &object.Signature{Name: name, Email: email, When: time.Now()}
+	}
+
 	hash, err = gr.Worktree.Commit(commitMsg, &git.CommitOptions{
-		All: true,
+		All:       !opts.stagedOnly,
+		Author:    opts.author,
+		Committer: committer,
+		Parents:   opts.parents,
 	})
-	return hash, err
+	if err != nil || !opts.root {
+		return hash, err
+	}
+	return gr.makeRootCommit(hash)
+}
+
+// checkPendingFiles runs CommitAll's checks on the files it would commit, only the staged ones with stagedOnly
+func (gr *GitRepo) checkPendingFiles(stagedOnly bool) error {
+	checkFileSizes := gr.maxFileSize() >= 0 || gr.RejectBinaryFiles
+	if !checkFileSizes && gr.Limits == nil && gr.SecretScanner == nil {
+		return nil
+	}
+	files, err := pendingFiles(gr.Repo, gr.Worktree, stagedOnly)
+	if err != nil {
+		return err
+	}
+
+	if checkFileSizes {
+		err = checkFiles(files, gr.maxFileSize(), gr.RejectBinaryFiles)
+		if err != nil {
+			return err
+		}
+	}
+
+	if gr.Limits != nil {
+		stats, err := pendingDiffStats(gr.Repo, files)
+		if err != nil {
+			return err
+		}
+		err = gr.Limits.Check(stats)
+		if err != nil {
+			return err
+		}
+	}
+
+	if gr.SecretScanner != nil {
+		return scanSecrets(files, gr.SecretScanner)
+	}
+	return nil
+}
+
+// commitMessage returns msg with the GitRepo's Trailers, signed off by author, or the configured user
+// when author is nil, when SignOff is set, or an error when ConventionalCommits is set and msg isn't one
+func (gr *GitRepo) commitMessage(msg string, author *object.Signature) (string, error) {
+	msg = AddTrailers(msg, gr.Trailers...)
+	if gr.SignOff {
+		signer := author
+		if signer == nil {
+			name, email, err := commitAuthor(gr.Repo)
+			if err != nil {
+				return msg, err
+			}
+			signer = &object.Signature{Name: name, Email: email}
+		}
+		// The author's sign-off, which VerifySignOffs looks for, even when the author isn't the configured
+		// user, e.g. in an amended commit
+		msg = appendTrailer(msg, SignedOffBy(signer.Name, signer.Email))
+	}
+
+	if gr.ConventionalCommits {
+		err := ValidateCommitMessage(msg)
+		if err != nil {
+			return msg, err
+		}
+	}
+	return msg, nil
 }
 
 // CommitAndPushAll stages all changes on the provided Worktree and pushes to the default remotes of the provided repo
@@ -152,7 +355,10 @@ func (gr *GitRepo) CommitAndPushAll(commitMsg string) error {
 
 // Init uses the stored git repo directory info to initialize a new repo
 func (gr *GitRepo) Init(isBare bool) error {
-	repo, err := git.PlainInit(gr.Dir, isBare)
+	gr.mu.Lock()
+	defer gr.mu.Unlock()
+
+	repo, err := gr.initRepo(isBare)
 	if err != nil {
 		return err
 	}
@@ -169,13 +375,16 @@ func (gr *GitRepo) Init(isBare bool) error {
 
 // InitAndPushNewRepo does a full init, commit, and push to the main branch
 func (gr *GitRepo) InitAndPushNewRepo(commitMsg string) (*git.Repository, error) {
-	repo, err := git.PlainInit(gr.Dir, false)
+	gr.mu.Lock()
+	defer gr.mu.Unlock()
+
+	repo, err := gr.initRepo(false)
 	if err != nil {
 		return repo, err
 	}
 
 	_, err = repo.CreateRemote(&config.RemoteConfig{
-		Name: defaultRemoteName,
+		Name: gr.remoteName(),
 		URLs: []string{gr.SSHURL},
 	})
 	if err != nil {
@@ -190,8 +399,7 @@ func (gr *GitRepo) InitAndPushNewRepo(commitMsg string) (*git.Repository, error)
 
 	initFiles := []string{".gitignore", "CODEOWNERS"}
 	for _, fileName := range initFiles {
-		f := fmt.Sprintf("%s/%s", gr.Dir, fileName)
-		yes, err := fileExists(f)
+		yes, err := fileExists(wt.Filesystem, fileName)
 		if err != nil {
 			return repo, err
 		}
@@ -210,8 +418,8 @@ func (gr *GitRepo) InitAndPushNewRepo(commitMsg string) (*git.Repository, error)
 	}
 
 	err = repo.Push(&git.PushOptions{
-		Auth:       gr.SSHKey,
-		RemoteName: defaultRemoteName,
+		Auth:       gr.remoteAuth(gr.remoteName()),
+		RemoteName: gr.remoteName(),
 		RefSpecs:   []config.RefSpec{"refs/heads/master:refs/heads/main"},
 	})
 	if err != nil {
@@ -221,45 +429,197 @@ func (gr *GitRepo) InitAndPushNewRepo(commitMsg string) (*git.Repository, error)
 	return repo, err
 }
 
-// NewBranch creates a new branch on the provided repo
+// NewBranch creates a new branch on the provided repo, named after name as cleaned up by SanitizeBranchName.
+// See NewBranchWithOptions for other naming schemes
 func (gr *GitRepo) NewBranch(name string, uniqSuffix bool) (string, error) {
-	newBranch := strings.Replace(name, " ", "-", -1)
+	newBranch, err := SanitizeBranchName(name)
+	if err != nil {
+		return newBranch, err
+	}
 
 	if uniqSuffix {
 		now := time.Now()
 		epochTs := strconv.FormatInt(now.Unix(), 10)
-		newBranch = newBranch + "-" + epochTs
+		newBranch = appendBranchSuffix(newBranch, epochTs)
 	}
 
-	newBranchRefName := plumbing.NewBranchReferenceName(newBranch)
+	return newBranch, gr.checkoutNewBranch(newBranch)
+}
+
+func (gr *GitRepo) checkoutNewBranch(name string) error {
+	gr.mu.Lock()
+	defer gr.mu.Unlock()
 
 	wt, err := gr.Repo.Worktree()
 	if err != nil {
-		return newBranch, err
+		return err
 	}
 
 	err = wt.Checkout(&git.CheckoutOptions{
-		Branch: newBranchRefName,
+		Branch: plumbing.NewBranchReferenceName(name),
 		Create: true,
 		Keep:   true,
 	})
 
 	gr.Worktree = wt
 
-	return newBranch, err
+	return err
 }
 
-// Push sends all staged commits to the default remotes of the provided repo
+// Push sends all staged commits to the GitRepo's remote, origin unless RemoteName says otherwise. With
+// TrackUpstream set, the checked out branch is then set to track its copy on the remote, as git push -u
+// does. The hooks added with AddPrePushHook run first, and the first one to fail aborts the push with a *PrePushError
 func (gr *GitRepo) Push() error {
-	err := gr.Repo.Push(&git.PushOptions{
-		Auth:       gr.SSHKey,
-		RemoteName: defaultRemoteName,
+	err := gr.runPrePushHooks()
+	if err != nil {
+		return err
+	}
+
+	gr.mu.Lock()
+	defer gr.mu.Unlock()
+
+	err = gr.Repo.Push(&git.PushOptions{
+		Auth:       gr.remoteAuth(gr.remoteName()),
+		RemoteName: gr.remoteName(),
+	})
+	if err != nil && err != git.NoErrAlreadyUpToDate {
+		return err
+	}
+
+	if gr.TrackUpstream {
+		trackErr := gr.trackHead()
+		if trackErr != nil {
+			return trackErr
+		}
+	}
+	return err
+}
+
+// Fetch updates the remote-tracking branches and tags from the GitRepo's remote
+func (gr *GitRepo) Fetch() error {
+	gr.mu.Lock()
+	defer gr.mu.Unlock()
+
+	err := gr.Repo.Fetch(&git.FetchOptions{
+		Auth:       gr.remoteAuth(gr.remoteName()),
+		RemoteName: gr.remoteName(),
+	})
+	if err != nil && err != git.NoErrAlreadyUpToDate {
+		return err
+	}
+	return nil
+}
+
+// Pull fetches the checked out branch from the GitRepo's remote and fast forwards it, failing with
+// git.ErrNonFastForwardUpdate when the branches diverged, since go-git can't merge
+func (gr *GitRepo) Pull() error {
+	gr.mu.Lock()
+	defer gr.mu.Unlock()
+
+	head, err := gr.Repo.Head()
+	if err != nil {
+		return err
+	}
+	err = gr.Worktree.Pull(&git.PullOptions{
+		Auth:          gr.remoteAuth(gr.remoteName()),
+		RemoteName:    gr.remoteName(),
+		ReferenceName: head.Name(),
 	})
+	if err != nil && err != git.NoErrAlreadyUpToDate {
+		return err
+	}
+	return nil
+}
+
+// ForcePush overwrites refs on the GitRepo's remote with the GitRepo's copies, as git push --force
+// does, e.g. after FilterHistory rewrote them. Refs no longer in the GitRepo are deleted from the
+// remote. With no refs, the checked out branch is pushed. The hooks added with AddPrePushHook run first
+func (gr *GitRepo) ForcePush(refs ...plumbing.ReferenceName) error {
+	err := gr.runPrePushHooks()
+	if err != nil {
+		return err
+	}
+
+	gr.mu.Lock()
+	defer gr.mu.Unlock()
+
+	if len(refs) == 0 {
+		head, err := gr.Repo.Head()
+		if err != nil {
+			return err
+		}
+		refs = append(refs, head.Name())
+	}
+
+	var specs []config.RefSpec
+	for _, ref := range refs {
+		_, err := gr.Repo.Reference(ref, false)
+		switch {
+		case err == plumbing.ErrReferenceNotFound:
+			specs = append(specs, config.RefSpec(":"+ref.String()))
+		case err != nil:
+			return err
+		default:
+			specs = append(specs, config.RefSpec("+"+ref.String()+":"+ref.String()))
+		}
+	}
+
+	err = gr.Repo.Push(&git.PushOptions{
+		Auth:       gr.remoteAuth(gr.remoteName()),
+		RemoteName: gr.remoteName(),
+		RefSpecs:   specs,
+	})
+	if err != nil && err != git.NoErrAlreadyUpToDate {
+		return err
+	}
+	return nil
+}
+
+// remoteName returns the name of the remote the GitRepo works with
+func (gr *GitRepo) remoteName() string {
+	if gr.RemoteName == "" {
+		return defaultRemoteName
+	}
+	return gr.RemoteName
+}
+
+func (gr *GitRepo) initRepo(isBare bool) (*git.Repository, error) {
+	if gr.Filesystem == nil {
+		err := gr.absDir()
+		if err != nil {
+			return nil, err
+		}
+		return git.PlainInit(gr.Dir, isBare)
+	}
+
+	s, wt, err := gr.storage(isBare)
+	if err != nil {
+		return nil, err
+	}
+	return git.Init(s, wt)
+}
+
+func (gr *GitRepo) storage(isBare bool) (s storage.Storer, wt billy.Filesystem, err error) {
+	// Lay the repo out on the Filesystem the same way PlainInit does on disk
+	if isBare {
+		return filesystem.NewStorage(gr.Filesystem, cache.NewObjectLRUDefault()), nil, nil
+	}
+
+	dotGit, err := gr.Filesystem.Chroot(git.GitDirName)
+	if err != nil {
+		return s, wt, err
+	}
+	return filesystem.NewStorage(dotGit, cache.NewObjectLRUDefault()), gr.Filesystem, nil
+}
+
+func (gr *GitRepo) absDir() (err error) {
+	// Resolve Dir once so later operations don't depend on the process working directory
+	gr.Dir, err = filepath.Abs(gr.Dir)
 	return err
 }
 
-func fileExists(f string) (bool, error) {
-	_, err := os.Stat(f)
+func fileExists(fs billy.Filesystem, f string) (bool, error) {
+	_, err := fs.Stat(f)
 	if os.IsNotExist(err) {
 		return false, nil
 	} else if err != nil {
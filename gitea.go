@@ -0,0 +1,152 @@
+package githelpers
+
+import (
+	"strconv"
+
+	"code.gitea.io/sdk/gitea"
+	"github.com/xanzy/go-gitlab"
+)
+
+var _ VCSProvider = (*GiteaProvider)(nil)
+var _ BranchProtector = (*GiteaProvider)(nil)
+var _ ReleaseCreator = (*GiteaProvider)(nil)
+var _ RepoSizer = (*GiteaProvider)(nil)
+
+// GiteaProvider is the VCSProvider for Gitea and Forgejo. Client is exposed for API calls this
+// package doesn't wrap
+type GiteaProvider struct {
+	Client *gitea.Client
+}
+
+// NewGiteaProvider returns a GiteaProvider for the instance at baseURL, authenticated with the given token
+func NewGiteaProvider(vcsToken, baseURL string) (p *GiteaProvider, err error) {
+	c, err := gitea.NewClient(baseURL, gitea.SetToken(vcsToken))
+	if err != nil {
+		return p, err
+	}
+	return &GiteaProvider{Client: c}, nil
+}
+
+// GetProjectID returns the numeric Gitea repository ID of the repo at repoURL
+func (p *GiteaProvider) GetProjectID(repoURL string) (string, error) {
+	_, owner, name := splitRepoURL(repoURL)
+	repo, _, err := p.Client.GetRepo(owner, name)
+	if err != nil {
+		return "", err
+	}
+	return strconv.FormatInt(repo.ID, 10), nil
+}
+
+// CreateProject creates a new Gitea repository called name, owned by the organization at
+// namespacePath or by the authenticated user when namespacePath is their login
+func (p *GiteaProvider) CreateProject(namespacePath, name string, opts ProjectOptions) (RemoteProject, error) {
+	user, _, err := p.Client.GetMyUserInfo()
+	if err != nil {
+		return RemoteProject{}, err
+	}
+
+	repoOpts := gitea.CreateRepoOption{
+		Name:          name,
+		Description:   opts.Description,
+		Private:       opts.Visibility != "public",
+		DefaultBranch: opts.DefaultBranch,
+	}
+
+	var repo *gitea.Repository
+	if namespacePath == user.UserName {
+		repo, _, err = p.Client.CreateRepo(repoOpts)
+	} else {
+		repo, _, err = p.Client.CreateOrgRepo(namespacePath, repoOpts)
+	}
+	if err != nil {
+		return RemoteProject{}, err
+	}
+	return RemoteProject{
+		ID:       strconv.FormatInt(repo.ID, 10),
+		FullPath: repo.FullName,
+		SSHURL:   repo.SSHURL,
+		HTTPURL:  repo.CloneURL,
+		WebURL:   repo.HTMLURL,
+	}, nil
+}
+
+// CreateMergeRequest opens a pull request from src into dest on the Gitea repository at repoURL
+func (p *GiteaProvider) CreateMergeRequest(repoURL, title, src, dest string) (MergeRequestInfo, error) {
+	_, owner, name := splitRepoURL(repoURL)
+	pr, _, err := p.Client.CreatePullRequest(owner, name, gitea.CreatePullRequestOption{
+		Title: title,
+		Head:  src,
+		Base:  dest,
+	})
+	if err != nil {
+		return MergeRequestInfo{}, err
+	}
+
+	info := MergeRequestInfo{
+		ID:     strconv.FormatInt(pr.ID, 10),
+		Number: int(pr.Index),
+		Title:  pr.Title,
+		URL:    pr.HTMLURL,
+	}
+	if pr.Head != nil {
+		info.SourceBranch = pr.Head.Ref
+	}
+	if pr.Base != nil {
+		info.TargetBranch = pr.Base.Ref
+	}
+	return info, nil
+}
+
+// SetStatus reports the state of an external check on a commit of the Gitea repository at repoURL.
+// GitLab state names are accepted and translated to their Gitea equivalents
+func (p *GiteaProvider) SetStatus(repoURL, sha, state, name, targetURL string) error {
+	_, owner, repo := splitRepoURL(repoURL)
+	_, _, err := p.Client.CreateStatus(owner, repo, sha, gitea.CreateStatusOption{
+		State:     gitea.StatusState(commitStatusState(state)),
+		Context:   name,
+		TargetURL: targetURL,
+	})
+	return err
+}
+
+// ProtectBranch protects branch on the Gitea repository at repoURL. Pushes are disabled when
+// PushAccessLevel is gitlab.NoPermissions, and CodeOwnerApprovalRequired requires one approval
+func (p *GiteaProvider) ProtectBranch(repoURL, branch string, opts ProtectOptions) error {
+	_, owner, repo := splitRepoURL(repoURL)
+
+	protection := gitea.CreateBranchProtectionOption{
+		BranchName: branch,
+		EnablePush: opts.PushAccessLevel == nil || *opts.PushAccessLevel != gitlab.NoPermissions,
+	}
+	if opts.CodeOwnerApprovalRequired {
+		protection.RequiredApprovals = 1
+	}
+
+	_, _, err := p.Client.CreateBranchProtection(owner, repo, protection)
+	return err
+}
+
+// CreateRelease creates a Gitea release for tag, creating the tag from the default branch if needed
+func (p *GiteaProvider) CreateRelease(repoURL, tag, name, notes string) (ReleaseInfo, error) {
+	_, owner, repo := splitRepoURL(repoURL)
+	r, _, err := p.Client.CreateRelease(owner, repo, gitea.CreateReleaseOption{
+		TagName: tag,
+		Title:   name,
+		Note:    notes,
+	})
+	if err != nil {
+		return ReleaseInfo{}, err
+	}
+	return ReleaseInfo{Tag: r.TagName, Name: r.Title, URL: r.URL}, nil
+}
+
+// RepoSize returns the size Gitea reports for the repository at repoURL
+func (p *GiteaProvider) RepoSize(repoURL string) (int64, error) {
+	_, owner, name := splitRepoURL(repoURL)
+	repo, _, err := p.Client.GetRepo(owner, name)
+	if err != nil {
+		return 0, err
+	}
+	// Gitea reports the size in kilobytes
+	return int64(repo.Size) * 1024, nil
+}
@@ -0,0 +1,163 @@
+package githelpers
+
+import (
+	"context"
+	"strconv"
+
+	"github.com/google/go-github/v32/github"
+	"golang.org/x/oauth2"
+)
+
+var _ VCSProvider = (*GithubProvider)(nil)
+var _ ReleaseCreator = (*GithubProvider)(nil)
+
+// GithubProvider is the VCSProvider for GitHub and GitHub Enterprise. Client is exposed for API calls
+// this package doesn't wrap
+type GithubProvider struct {
+	Client *github.Client
+}
+
+// NewGithubProvider returns a GithubProvider authenticated with the given token. An empty baseURL
+// talks to github.com, otherwise it's the API URL of a GitHub Enterprise instance
+func NewGithubProvider(vcsToken, baseURL string) (p *GithubProvider, err error) {
+	httpClient := oauth2.NewClient(context.Background(), oauth2.StaticTokenSource(&oauth2.Token{AccessToken: vcsToken}))
+
+	c := github.NewClient(httpClient)
+	if baseURL != "" {
+		c, err = github.NewEnterpriseClient(baseURL, baseURL, httpClient)
+		if err != nil {
+			return p, err
+		}
+	}
+	return &GithubProvider{Client: c}, nil
+}
+
+// AddGithubClient takes a GitHub token and saves the client to the GitRepo receiver
+func (gr *GitRepo) AddGithubClient(vcsToken, baseURL string) error {
+	p, err := NewGithubProvider(vcsToken, baseURL)
+	if err != nil {
+		return err
+	}
+
+	gr.VCSClient = p
+	return nil
+}
+
+// GetProjectID returns the numeric GitHub repository ID of the repo at repoURL
+func (p *GithubProvider) GetProjectID(repoURL string) (string, error) {
+	owner, name := githubOwnerAndRepo(repoURL)
+	repo, _, err := p.Client.Repositories.Get(context.Background(), owner, name)
+	if err != nil {
+		return "", err
+	}
+	return strconv.FormatInt(repo.GetID(), 10), nil
+}
+
+// CreateProject creates a new GitHub repository called name, owned by the organization at
+// namespacePath or by the authenticated user when namespacePath is their login
+func (p *GithubProvider) CreateProject(namespacePath, name string, opts ProjectOptions) (RemoteProject, error) {
+	ctx := context.Background()
+
+	user, _, err := p.Client.Users.Get(ctx, "")
+	if err != nil {
+		return RemoteProject{}, err
+	}
+	org := namespacePath
+	if org == user.GetLogin() {
+		org = ""
+	}
+
+	repo := &github.Repository{
+		Name:    &name,
+		Private: github.Bool(opts.Visibility != "public"),
+	}
+	if opts.Description != "" {
+		repo.Description = &opts.Description
+	}
+	switch opts.MergeMethod {
+	case "merge":
+		repo.AllowMergeCommit, repo.AllowRebaseMerge, repo.AllowSquashMerge = github.Bool(true), github.Bool(false), github.Bool(false)
+	case "rebase_merge", "ff":
+		repo.AllowMergeCommit, repo.AllowRebaseMerge, repo.AllowSquashMerge = github.Bool(false), github.Bool(true), github.Bool(false)
+	}
+
+	repo, _, err = p.Client.Repositories.Create(ctx, org, repo)
+	if err != nil {
+		return RemoteProject{}, err
+	}
+	return RemoteProject{
+		ID:       strconv.FormatInt(repo.GetID(), 10),
+		FullPath: repo.GetFullName(),
+		SSHURL:   repo.GetSSHURL(),
+		HTTPURL:  repo.GetCloneURL(),
+		WebURL:   repo.GetHTMLURL(),
+	}, nil
+}
+
+// CreateMergeRequest opens a pull request from src into dest on the GitHub repository at repoURL
+func (p *GithubProvider) CreateMergeRequest(repoURL, title, src, dest string) (MergeRequestInfo, error) {
+	owner, name := githubOwnerAndRepo(repoURL)
+	pr, _, err := p.Client.PullRequests.Create(context.Background(), owner, name, &github.NewPullRequest{
+		Title: &title,
+		Head:  &src,
+		Base:  &dest,
+	})
+	if err != nil {
+		return MergeRequestInfo{}, err
+	}
+	return MergeRequestInfo{
+		ID:           strconv.FormatInt(pr.GetID(), 10),
+		Number:       pr.GetNumber(),
+		Title:        pr.GetTitle(),
+		SourceBranch: pr.GetHead().GetRef(),
+		TargetBranch: pr.GetBase().GetRef(),
+		URL:          pr.GetHTMLURL(),
+	}, nil
+}
+
+// SetStatus reports the state of an external check on a commit of the GitHub repository at repoURL.
+// GitLab state names are accepted and translated to their GitHub equivalents
+func (p *GithubProvider) SetStatus(repoURL, sha, state, name, targetURL string) error {
+	owner, repo := githubOwnerAndRepo(repoURL)
+
+	status := &github.RepoStatus{
+		State:   github.String(commitStatusState(state)),
+		Context: &name,
+	}
+	if targetURL != "" {
+		status.TargetURL = &targetURL
+	}
+
+	_, _, err := p.Client.Repositories.CreateStatus(context.Background(), owner, repo, sha, status)
+	return err
+}
+
+// CreateRelease creates a GitHub release for tag, creating the tag from the default branch if needed
+func (p *GithubProvider) CreateRelease(repoURL, tag, name, notes string) (ReleaseInfo, error) {
+	owner, repo := githubOwnerAndRepo(repoURL)
+	r, _, err := p.Client.Repositories.CreateRelease(context.Background(), owner, repo, &github.RepositoryRelease{
+		TagName: &tag,
+		Name:    &name,
+		Body:    &notes,
+	})
+	if err != nil {
+		return ReleaseInfo{}, err
+	}
+	return ReleaseInfo{Tag: r.GetTagName(), Name: r.GetName(), URL: r.GetHTMLURL()}, nil
+}
+
+// RepoSize returns the size GitHub reports for the repository at repoURL
+func (p *GithubProvider) RepoSize(repoURL string) (int64, error) {
+	owner, name := githubOwnerAndRepo(repoURL)
+	repo, _, err := p.Client.Repositories.Get(context.Background(), owner, name)
+	if err != nil {
+		return 0, err
+	}
+	// GitHub reports the size in kilobytes
+	return int64(repo.GetSize()) * 1024, nil
+}
+
+func githubOwnerAndRepo(repoURL string) (owner, repo string) {
+	_, owner, repo = splitRepoURL(repoURL)
+	return owner, repo
+}
@@ -0,0 +1,80 @@
+package githelpers
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/go-git/go-billy/v5/osfs"
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing/format/gitignore"
+)
+
+// globalIgnorePatterns loads the system wide and user wide excludes files that git itself honors,
+// i.e. core.excludesfile from /etc/gitconfig and ~/.gitconfig
+func globalIgnorePatterns() (ps []gitignore.Pattern, err error) {
+	root := osfs.New("/")
+
+	system, err := gitignore.LoadSystemPatterns(root)
+	if err != nil {
+		return ps, err
+	}
+	global, err := gitignore.LoadGlobalPatterns(root)
+	if err != nil {
+		return ps, err
+	}
+	return append(system, global...), nil
+}
+
+func pendingStatus(wt *git.Worktree) (status git.Status, err error) {
+	// The status already honors .gitignore files, and the global excludes are added for the call. go-git
+	// puts Excludes after the .gitignore patterns, and later patterns win, so the .gitignore patterns
+	// are repeated after the global ones to keep them winning, as they do in git
+	global, err := globalIgnorePatterns()
+	if err != nil {
+		return status, err
+	}
+	repo, err := gitignore.ReadPatterns(wt.Filesystem, nil)
+	if err != nil {
+		return status, err
+	}
+
+	excludes := wt.Excludes
+	patterns := append(append([]gitignore.Pattern{}, global...), repo...)
+	wt.Excludes = append(patterns, excludes...)
+	defer func() { wt.Excludes = excludes }()
+
+	return wt.Status()
+}
+
+func stageAll(wt *git.Worktree, rejectSymlinks bool) error {
+	// Worktree.AddGlob stages ignored files too, so stage from the status instead
+	status, err := pendingStatus(wt)
+	if err != nil {
+		return err
+	}
+
+	for path, s := range status {
+		switch s.Worktree {
+		case git.Unmodified:
+			continue
+		case git.Deleted:
+			_, err = wt.Remove(path)
+		default:
+			if rejectSymlinks {
+				info, err := wt.Filesystem.Lstat(path)
+				if err != nil {
+					return err
+				}
+				if info.Mode()&os.ModeSymlink != 0 {
+					return fmt.Errorf("%w: %s", ErrSymlinkRejected, path)
+				}
+			}
+			// Add records the exec bit and symlinks from the Lstat mode of the file
+			_, err = wt.Add(path)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
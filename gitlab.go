@@ -2,65 +2,133 @@ package githelpers
 
 import (
 	"fmt"
+	"net/http"
 	"os"
-	"path/filepath"
+	"strings"
 
 	"github.com/xanzy/go-gitlab"
 )
 
 var (
-	defaultListOpts = gitlab.ListOptions{PerPage: 1000}
+	// GitLab caps page sizes at 100, so list helpers page through results
+	defaultListOpts = gitlab.ListOptions{PerPage: 100}
 )
 
 // AddGitlabClient takes a Gitlab token and saves the client to the GitRepo receiver
-func (gr *GitRepo) AddGitlabClient(vcsToken string) error {
-	c, err := gitlab.NewClient(vcsToken)
-	gr.VCSClient = c
-	return err
-}
+func (gr *GitRepo) AddGitlabClient(vcsToken string, opts ...GitlabClientOption) error {
+	p, err := NewGitlabProvider(vcsToken, opts...)
+	if err != nil {
+		return err
+	}
 
-func (gr *GitRepo) getGitlabGroups() (groups []*gitlab.Group, resp *gitlab.Response, err error) {
-	// Move list groups logic into a new func to DRY out the client declaration and
-	// allow retrieval of a param other than ID
-	client := gr.VCSClient.(*gitlab.Client)
-	groups, resp, err = client.Groups.ListGroups(&gitlab.ListGroupsOptions{
-		ListOptions: defaultListOpts,
-	})
-	return groups, resp, err
+	gr.VCSClient = p
+	return nil
 }
 
 func (gr *GitRepo) getGitlabGroupID(groupPath string) (id int, resp *gitlab.Response, err error) {
-	groups, resp, err := gr.getGitlabGroups()
-	for _, g := range groups {
-		if g.FullPath == groupPath {
-			id = g.ID
+	// Look the group up by its full path so subgroups of any depth resolve
+	client, err := gr.gitlabClient()
+	if err != nil {
+		return id, resp, err
+	}
+
+	cache := gr.gitlabIDCache()
+	key := GitlabGroupCacheKey(client.BaseURL().Host, groupPath)
+	if id, ok := cache.Get(key); ok {
+		return id, resp, nil
+	}
+
+	g, resp, err := client.Groups.GetGroup(groupPath)
+	if err != nil {
+		return id, resp, err
+	}
+	return g.ID, resp, cache.Set(key, g.ID)
+}
+
+// EnsureGitlabGroup creates any missing groups and subgroups along fullPath and returns the ID of the last one
+func (gr *GitRepo) EnsureGitlabGroup(fullPath string) (id int, resp *gitlab.Response, err error) {
+	client, err := gr.gitlabClient()
+	if err != nil {
+		return id, resp, err
+	}
+
+	var parentID *int
+	var path string
+	for _, segment := range strings.Split(strings.Trim(fullPath, "/"), "/") {
+		if path == "" {
+			path = segment
+		} else {
+			path = path + "/" + segment
+		}
+
+		var g *gitlab.Group
+		g, resp, err = client.Groups.GetGroup(path)
+		if err != nil && !gitlabNotFound(resp) {
+			return id, resp, err
 		}
+		if err != nil {
+			g, resp, err = client.Groups.CreateGroup(&gitlab.CreateGroupOptions{
+				Name:     gitlab.String(segment),
+				Path:     gitlab.String(segment),
+				ParentID: parentID,
+			})
+			if err != nil {
+				return id, resp, err
+			}
+		}
+
+		id = g.ID
+		parentID = &g.ID
 	}
 	return id, resp, err
 }
 
+func gitlabNotFound(resp *gitlab.Response) bool {
+	return resp != nil && resp.StatusCode == http.StatusNotFound
+}
+
 func (gr *GitRepo) getGitlabProjectID(url string) (id int, resp *gitlab.Response, err error) {
-	// Move list projects logic into a new func to DRY out the client declaration and
-	// allow retrieval of a param other than ID
-	client := gr.VCSClient.(*gitlab.Client)
-	_, parentGroupPath, name := splitRepoURL(url)
+	r, err := ParseRepoURL(url)
+	if err != nil {
+		return id, resp, err
+	}
 
-	parentID, _, err := gr.getGitlabGroupID(parentGroupPath)
+	client, err := gr.gitlabClient()
+	if err != nil {
+		return id, resp, err
+	}
 
-	projects, resp, err := client.Groups.ListGroupProjects(parentID, &gitlab.ListGroupProjectsOptions{ListOptions: defaultListOpts})
+	cache := gr.gitlabIDCache()
+	key := GitlabProjectCacheKey(client.BaseURL().Host, r.FullPath())
+	if id, ok := cache.Get(key); ok {
+		return id, resp, nil
+	}
 
-	for _, p := range projects {
-		// fmt.Printf("Checking whether %s matches %s\n", p.Path, name)
-		if p.Path == name {
-			id = p.ID
-		}
+	p, resp, err := gr.GetGitlabProject(r.FullPath())
+	if err != nil {
+		return id, resp, err
 	}
-	return id, resp, err
+	return p.ID, resp, cache.Set(key, p.ID)
+}
+
+// GetGitlabProject looks up a GitLab project by its full path, e.g. group/subgroup/repo
+func (gr *GitRepo) GetGitlabProject(fullPath string) (p *gitlab.Project, resp *gitlab.Response, err error) {
+	client, err := gr.gitlabClient()
+	if err != nil {
+		return p, resp, err
+	}
+
+	p, resp, err = client.Projects.GetProject(fullPath, &gitlab.GetProjectOptions{})
+	return p, resp, err
 }
 
-// NewGitlabMergeRequest creates a new MR in Gitlab
+// NewGitlabMergeRequest creates a new MR in Gitlab. With AssignCodeOwners set, the code owners of the files
+// changed between the remote dest branch and src are added as reviewers
 func (gr *GitRepo) NewGitlabMergeRequest(commitMsg, src, dest string) (mr *gitlab.MergeRequest, resp *gitlab.Response, err error) {
-	c := gr.VCSClient.(*gitlab.Client)
+	c, err := gr.gitlabClient()
+	if err != nil {
+		return mr, resp, err
+	}
 
 	mrOpts := &gitlab.CreateMergeRequestOptions{
 		Title:        &commitMsg,
@@ -72,7 +140,23 @@ func (gr *GitRepo) NewGitlabMergeRequest(commitMsg, src, dest string) (mr *gitla
 		return mr, resp, err
 	}
 
-	mr, resp, err = c.MergeRequests.CreateMergeRequest(pid, mrOpts)
+	if !gr.AssignCodeOwners {
+		mr, resp, err = c.MergeRequests.CreateMergeRequest(pid, mrOpts)
+		return mr, resp, err
+	}
+
+	opts := createMergeRequestOptions{CreateMergeRequestOptions: mrOpts}
+	opts.ReviewerIDs, resp, err = gr.codeOwnerReviewers(src, dest)
+	if err != nil {
+		return mr, resp, err
+	}
+
+	req, err := c.NewRequest(http.MethodPost, fmt.Sprintf("projects/%d/merge_requests", pid), opts, nil)
+	if err != nil {
+		return mr, resp, err
+	}
+
+	resp, err = c.Do(req, &mr)
 	return mr, resp, err
 }
 
@@ -83,22 +167,7 @@ func (gr *GitRepo) ShowPwd() (err error) {
 	return err
 }
 
-// ListFiles prints all files in a directory
+// ListFiles prints all files and directories in a directory. See Files for a version returning data
 func (gr *GitRepo) ListFiles(dir string) (err error) {
-	files, err := gr.getFiles(dir)
-	if err != nil {
-		return err
-	}
-	for _, file := range files {
-		fmt.Println(file)
-	}
-	return err
-}
-
-func (gr *GitRepo) getFiles(dir string) (files []string, err error) {
-	err = filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
-		files = append(files, path)
-		return nil
-	})
-	return files, err
+	return gr.PrintFiles(os.Stdout, dir, WalkOptions{IncludeDirs: true, IncludeGitDir: true})
 }
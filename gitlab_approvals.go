@@ -0,0 +1,91 @@
+package githelpers
+
+import (
+	"github.com/xanzy/go-gitlab"
+)
+
+// ListGitlabApprovalRules lists the approval rules that apply to the MR with the given IID
+func (gr *GitRepo) ListGitlabApprovalRules(mrIID int) (rules []*gitlab.MergeRequestApprovalRule, resp *gitlab.Response, err error) {
+	c, err := gr.gitlabClient()
+	if err != nil {
+		return rules, resp, err
+	}
+
+	pid, resp, err := gr.getGitlabProjectID(gr.SSHURL)
+	if err != nil {
+		return rules, resp, err
+	}
+
+	rules, resp, err = c.MergeRequestApprovals.GetApprovalRules(pid, mrIID)
+	return rules, resp, err
+}
+
+// GitlabMergeRequestApproved reports whether the MR with the given IID has all of its required approvals
+func (gr *GitRepo) GitlabMergeRequestApproved(mrIID int) (approved bool, resp *gitlab.Response, err error) {
+	c, err := gr.gitlabClient()
+	if err != nil {
+		return approved, resp, err
+	}
+
+	pid, resp, err := gr.getGitlabProjectID(gr.SSHURL)
+	if err != nil {
+		return approved, resp, err
+	}
+
+	approvals, resp, err := c.MergeRequestApprovals.GetConfiguration(pid, mrIID)
+	if err != nil {
+		return approved, resp, err
+	}
+
+	return approvals.ApprovalsLeft == 0, resp, err
+}
+
+// ApproveGitlabMergeRequest approves the MR with the given IID. If botToken is set, the approval is
+// made as the owner of that token instead of the user behind the GitRepo's own client
+func (gr *GitRepo) ApproveGitlabMergeRequest(mrIID int, botToken string) (approvals *gitlab.MergeRequestApprovals, resp *gitlab.Response, err error) {
+	c, err := gr.gitlabClientForToken(botToken)
+	if err != nil {
+		return approvals, resp, err
+	}
+
+	pid, resp, err := gr.getGitlabProjectID(gr.SSHURL)
+	if err != nil {
+		return approvals, resp, err
+	}
+
+	approvals, resp, err = c.MergeRequestApprovals.ApproveMergeRequest(pid, mrIID, &gitlab.ApproveMergeRequestOptions{})
+	return approvals, resp, err
+}
+
+// UnapproveGitlabMergeRequest removes the approval given to the MR with the given IID. If botToken
+// is set, the approval removed is the one made by the owner of that token
+func (gr *GitRepo) UnapproveGitlabMergeRequest(mrIID int, botToken string) (resp *gitlab.Response, err error) {
+	c, err := gr.gitlabClientForToken(botToken)
+	if err != nil {
+		return resp, err
+	}
+
+	pid, resp, err := gr.getGitlabProjectID(gr.SSHURL)
+	if err != nil {
+		return resp, err
+	}
+
+	resp, err = c.MergeRequestApprovals.UnapproveMergeRequest(pid, mrIID)
+	return resp, err
+}
+
+func (gr *GitRepo) gitlabClientForToken(token string) (*gitlab.Client, error) {
+	// An empty token means act as the GitRepo's own client. Otherwise build a client for
+	// the same GitLab instance so a second bot can act on MRs
+	c, err := gr.gitlabClient()
+	if err != nil || token == "" {
+		return c, err
+	}
+
+	cfg := newGitlabClientConfig(gr.VCSClient.(*GitlabProvider).opts)
+	opts, err := cfg.clientOptions()
+	if err != nil {
+		return c, err
+	}
+	return newGitlabClient(token, cfg, append(opts, gitlab.WithBaseURL(c.BaseURL().String())))
+}
@@ -0,0 +1,182 @@
+package githelpers
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/xanzy/go-gitlab"
+)
+
+// GitlabAuthType is the kind of token handed to AddGitlabClient
+type GitlabAuthType int
+
+const (
+	// GitlabPrivateToken is a personal, project, or group access token. This is the default
+	GitlabPrivateToken GitlabAuthType = iota
+	// GitlabOAuthToken is an OAuth2 access token
+	GitlabOAuthToken
+	// GitlabJobToken is the CI_JOB_TOKEN of a running GitLab CI job. GitLab only accepts job tokens
+	// on a small set of API endpoints
+	GitlabJobToken
+)
+
+const (
+	headerPrivateToken  = "PRIVATE-TOKEN"
+	headerJobToken      = "JOB-TOKEN"
+	headerAuthorization = "Authorization"
+)
+
+// WithGitlabAuth sets how the token handed to AddGitlabClient is sent to GitLab
+func WithGitlabAuth(authType GitlabAuthType) GitlabClientOption {
+	return func(cfg *gitlabClientConfig) {
+		cfg.authType = authType
+	}
+}
+
+// AddGitlabClientFromCIEnv creates a GitLab client from the environment of a GitLab CI job, using
+// CI_JOB_TOKEN against the instance in CI_SERVER_URL
+func (gr *GitRepo) AddGitlabClientFromCIEnv(opts ...GitlabClientOption) error {
+	token := os.Getenv("CI_JOB_TOKEN")
+	if token == "" {
+		return errors.New("CI_JOB_TOKEN is not set")
+	}
+
+	opts = append(opts, WithGitlabAuth(GitlabJobToken))
+	if url := os.Getenv("CI_SERVER_URL"); url != "" {
+		opts = append(opts, WithGitlabBaseURL(url))
+	}
+	return gr.AddGitlabClient(token, opts...)
+}
+
+func newGitlabClient(token string, cfg *gitlabClientConfig, opts []gitlab.ClientOptionFunc) (*gitlab.Client, error) {
+	if cfg.authType == GitlabOAuthToken {
+		return gitlab.NewOAuthClient(token, opts...)
+	}
+	// Job tokens go out as private tokens, and jobTokenTransport moves them to the right header
+	return gitlab.NewClient(token, opts...)
+}
+
+// jobTokenTransport sends the token set by the GitLab client as a job token instead of a private token
+type jobTokenTransport struct {
+	next http.RoundTripper
+}
+
+func (t *jobTokenTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	token := req.Header.Get(headerPrivateToken)
+	if token == "" {
+		return t.next.RoundTrip(req)
+	}
+
+	req = req.Clone(req.Context())
+	req.Header.Del(headerPrivateToken)
+	req.Header.Set(headerJobToken, token)
+	return t.next.RoundTrip(req)
+}
+
+// TokenRefresher returns a fresh GitLab token, e.g. a new short-lived OAuth or project access token,
+// to replace one GitLab no longer accepts
+type TokenRefresher func(ctx context.Context) (token string, err error)
+
+// WithGitlabTokenRefresh makes the GitLab client call refresh when GitLab answers a request with 401
+// Unauthorized, e.g. because its short-lived token expired during a long Fleet run, and retry the
+// request once with the new token, which it then sends with every later request. Requests failing
+// at the same time share one refresh
+func WithGitlabTokenRefresh(refresh TokenRefresher) GitlabClientOption {
+	return func(cfg *gitlabClientConfig) {
+		cfg.tokenRefresh = refresh
+	}
+}
+
+// SecretTokenRefresher returns a TokenRefresher reading the token src keeps under name again, for
+// tokens a secrets manager rotates, e.g. a Vault GitLab secrets engine
+func SecretTokenRefresher(src SecretSource, name string) TokenRefresher {
+	return func(ctx context.Context) (string, error) {
+		token, err := src.Secret(ctx, name)
+		return string(bytes.TrimSpace(token)), err
+	}
+}
+
+// tokenRefreshTransport swaps the token of requests GitLab rejects with 401 for a refreshed one and
+// retries them. It sits in front of jobTokenTransport, so it only sees the headers the GitLab client sets
+type tokenRefreshTransport struct {
+	next    http.RoundTripper
+	refresh TokenRefresher
+
+	mu    sync.Mutex
+	token string // The refreshed token, empty until the first refresh
+}
+
+func (t *tokenRefreshTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	// The body is read by the first attempt, so keep a copy for the retry
+	var body []byte
+	if req.Body != nil && req.Body != http.NoBody {
+		var err error
+		body, err = io.ReadAll(req.Body)
+		req.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	t.mu.Lock()
+	token := t.token
+	t.mu.Unlock()
+
+	resp, err := t.next.RoundTrip(withGitlabToken(req, token, body))
+	if err != nil || resp.StatusCode != http.StatusUnauthorized {
+		return resp, err
+	}
+
+	fresh, err := t.refreshed(req.Context(), token)
+	if err != nil {
+		resp.Body.Close()
+		return nil, fmt.Errorf("refreshing GitLab token: %w", err)
+	}
+	io.Copy(io.Discard, resp.Body)
+	resp.Body.Close()
+	return t.next.RoundTrip(withGitlabToken(req, fresh, body))
+}
+
+// refreshed returns a token newer than stale, refreshing it unless another request did already
+func (t *tokenRefreshTransport) refreshed(ctx context.Context, stale string) (string, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.token != stale {
+		return t.token, nil
+	}
+	token, err := t.refresh(ctx)
+	if err != nil {
+		return "", err
+	}
+	if token == "" {
+		return "", errors.New("refresh returned an empty token")
+	}
+	t.token = token
+	return token, nil
+}
+
+// withGitlabToken returns a copy of req with body and, when token is set, token in place of the one
+// the GitLab client put in whichever header it uses for the auth type
+func withGitlabToken(req *http.Request, token string, body []byte) *http.Request {
+	req = req.Clone(req.Context())
+	if body != nil {
+		req.Body = io.NopCloser(bytes.NewReader(body))
+	}
+	if token == "" {
+		return req
+	}
+	if strings.HasPrefix(req.Header.Get(headerAuthorization), "Bearer ") {
+		req.Header.Set(headerAuthorization, "Bearer "+token)
+	} else {
+		req.Header.Set(headerPrivateToken, token)
+	}
+	return req
+}
@@ -0,0 +1,50 @@
+package githelpers
+
+import (
+	"github.com/xanzy/go-gitlab"
+)
+
+// ProtectOptions holds the access levels applied to a protected branch. Nil fields are left for
+// GitLab to default, which is maintainers only
+type ProtectOptions struct {
+	PushAccessLevel           *gitlab.AccessLevelValue
+	MergeAccessLevel          *gitlab.AccessLevelValue
+	CodeOwnerApprovalRequired bool
+}
+
+// ProtectBranch protects the named branch (or wildcard) on the GitRepo's GitLab project
+func (gr *GitRepo) ProtectBranch(name string, opts ProtectOptions) (b *gitlab.ProtectedBranch, resp *gitlab.Response, err error) {
+	c, err := gr.gitlabClient()
+	if err != nil {
+		return b, resp, err
+	}
+
+	pid, resp, err := gr.getGitlabProjectID(gr.SSHURL)
+	if err != nil {
+		return b, resp, err
+	}
+
+	b, resp, err = c.ProtectedBranches.ProtectRepositoryBranches(pid, &gitlab.ProtectRepositoryBranchesOptions{
+		Name:                      &name,
+		PushAccessLevel:           opts.PushAccessLevel,
+		MergeAccessLevel:          opts.MergeAccessLevel,
+		CodeOwnerApprovalRequired: &opts.CodeOwnerApprovalRequired,
+	})
+	return b, resp, err
+}
+
+// UnprotectBranch removes the protection from the named branch (or wildcard) on the GitRepo's GitLab project
+func (gr *GitRepo) UnprotectBranch(name string) (resp *gitlab.Response, err error) {
+	c, err := gr.gitlabClient()
+	if err != nil {
+		return resp, err
+	}
+
+	pid, resp, err := gr.getGitlabProjectID(gr.SSHURL)
+	if err != nil {
+		return resp, err
+	}
+
+	resp, err = c.ProtectedBranches.UnprotectRepositoryBranches(pid, name)
+	return resp, err
+}
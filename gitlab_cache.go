@@ -0,0 +1,151 @@
+package githelpers
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// IDCache remembers the GitLab IDs of projects and groups by path, so repeated MR creation and other
+// lookups don't hit the API every time. A cache can be shared by any number of GitRepos and clients,
+// and is safe for concurrent use
+type IDCache struct {
+	ttl  time.Duration
+	path string
+
+	mu      sync.Mutex
+	entries map[string]idCacheEntry
+}
+
+type idCacheEntry struct {
+	ID      int       `json:"id"`
+	Expires time.Time `json:"expires"`
+}
+
+// NewIDCache returns an in-memory IDCache whose entries expire after ttl. A zero ttl never expires them
+func NewIDCache(ttl time.Duration) *IDCache {
+	return &IDCache{ttl: ttl, entries: map[string]idCacheEntry{}}
+}
+
+// NewFileIDCache returns an IDCache backed by the JSON file at path, so lookups survive across runs.
+// The file is created on the first write if it doesn't exist
+func NewFileIDCache(path string, ttl time.Duration) (c *IDCache, err error) {
+	c = NewIDCache(ttl)
+	c.path = path
+
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return c, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	err = json.Unmarshal(data, &c.entries)
+	if err != nil {
+		return nil, fmt.Errorf("reading ID cache %s: %w", path, err)
+	}
+	if c.entries == nil {
+		// The file held null
+		c.entries = map[string]idCacheEntry{}
+	}
+	return c, nil
+}
+
+// WithGitlabIDCache makes the GitLab client look project and group IDs up in c before asking the API
+func WithGitlabIDCache(c *IDCache) GitlabClientOption {
+	return func(cfg *gitlabClientConfig) {
+		cfg.idCache = c
+	}
+}
+
+// Get returns the cached ID for key, if there is one that hasn't expired
+func (c *IDCache) Get(key string) (id int, ok bool) {
+	if c == nil {
+		return id, false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	e, ok := c.entries[key]
+	if !ok {
+		return id, false
+	}
+	if !e.Expires.IsZero() && time.Now().After(e.Expires) {
+		delete(c.entries, key)
+		return id, false
+	}
+	return e.ID, true
+}
+
+// Set caches id for key
+func (c *IDCache) Set(key string, id int) error {
+	if c == nil {
+		return nil
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	e := idCacheEntry{ID: id}
+	if c.ttl > 0 {
+		e.Expires = time.Now().Add(c.ttl)
+	}
+	c.entries[key] = e
+	return c.save()
+}
+
+// Invalidate drops the cached ID for key, e.g. after the project it points to was moved or deleted
+func (c *IDCache) Invalidate(key string) error {
+	if c == nil {
+		return nil
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	delete(c.entries, key)
+	return c.save()
+}
+
+// Clear drops every cached ID
+func (c *IDCache) Clear() error {
+	if c == nil {
+		return nil
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.entries = map[string]idCacheEntry{}
+	return c.save()
+}
+
+func (c *IDCache) save() error {
+	// Callers hold c.mu
+	if c.path == "" {
+		return nil
+	}
+	data, err := json.Marshal(c.entries)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(c.path, data, 0600)
+}
+
+// GitlabProjectCacheKey returns the IDCache key of the project at fullPath on the GitLab instance at host
+func GitlabProjectCacheKey(host, fullPath string) string {
+	return "project:" + host + "/" + strings.Trim(fullPath, "/")
+}
+
+// GitlabGroupCacheKey returns the IDCache key of the group at fullPath on the GitLab instance at host
+func GitlabGroupCacheKey(host, fullPath string) string {
+	return "group:" + host + "/" + strings.Trim(fullPath, "/")
+}
+
+func (gr *GitRepo) gitlabIDCache() *IDCache {
+	p, ok := gr.VCSClient.(*GitlabProvider)
+	if !ok {
+		return nil
+	}
+	return p.idCache
+}
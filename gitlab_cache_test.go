@@ -0,0 +1,55 @@
+package githelpers
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestNewFileIDCache(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		wantErr bool
+	}{
+		{"missing file", "", false},
+		{"null", "null", false},
+		{"empty object", "{}", false},
+		{"corrupt", "{\"a\": ", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "ids.json")
+			if tt.content != "" {
+				err := os.WriteFile(path, []byte(tt.content), 0644)
+				if err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			c, err := NewFileIDCache(path, time.Hour)
+			if tt.wantErr {
+				if err == nil || c != nil {
+					t.Errorf("got %v, %v, want no cache and an error", c, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			err = c.Set("gitlab.com/group/repo", 42)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			reloaded, err := NewFileIDCache(path, time.Hour)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if id, ok := reloaded.Get("gitlab.com/group/repo"); !ok || id != 42 {
+				t.Errorf("reloaded %d, %v, want 42", id, ok)
+			}
+		})
+	}
+}
@@ -0,0 +1,115 @@
+package githelpers
+
+import (
+	"crypto/tls"
+	"net/http"
+
+	"github.com/xanzy/go-gitlab"
+)
+
+// GitlabClientOption customizes the GitLab client created by AddGitlabClient
+type GitlabClientOption func(*gitlabClientConfig)
+
+type gitlabClientConfig struct {
+	authType  GitlabAuthType
+	baseURL   string
+	transport transportConfig
+	baseRT    http.RoundTripper
+	rateLimit *RateLimitOptions
+	keyset    bool
+	idCache   *IDCache
+
+	tokenRefresh TokenRefresher
+}
+
+// WithGitlabBaseURL points the GitLab client at a self-managed instance, e.g. "https://gitlab.example.com"
+func WithGitlabBaseURL(baseURL string) GitlabClientOption {
+	return func(cfg *gitlabClientConfig) {
+		cfg.baseURL = baseURL
+	}
+}
+
+// WithGitlabCACert makes the GitLab client trust the PEM encoded CA certificates in the file at
+// path, on top of the system pool, for instances using an internal CA
+func WithGitlabCACert(path string) GitlabClientOption {
+	return func(cfg *gitlabClientConfig) {
+		WithCACert(path)(&cfg.transport)
+	}
+}
+
+// WithGitlabTLSConfig sets the TLS configuration the GitLab client uses for its connections
+func WithGitlabTLSConfig(tlsConfig *tls.Config) GitlabClientOption {
+	return func(cfg *gitlabClientConfig) {
+		WithTLSConfig(tlsConfig)(&cfg.transport)
+	}
+}
+
+// WithGitlabProxy sends the GitLab client's requests through the proxy at proxyURL, as WithProxy does
+func WithGitlabProxy(proxyURL string) GitlabClientOption {
+	return func(cfg *gitlabClientConfig) {
+		WithProxy(proxyURL)(&cfg.transport)
+	}
+}
+
+// WithGitlabClientCert makes the GitLab client present a client certificate, as WithClientCert does
+func WithGitlabClientCert(certFile, keyFile string) GitlabClientOption {
+	return func(cfg *gitlabClientConfig) {
+		WithClientCert(certFile, keyFile)(&cfg.transport)
+	}
+}
+
+// WithGitlabHTTPTransport makes the GitLab client send its requests with rt, e.g. one wrapping a
+// company's own transport, instead of building one. The proxy and TLS options are ignored then
+func WithGitlabHTTPTransport(rt http.RoundTripper) GitlabClientOption {
+	return func(cfg *gitlabClientConfig) {
+		cfg.baseRT = rt
+	}
+}
+
+// AddGitlabClientWithBaseURL is AddGitlabClient for a self-managed GitLab instance. If baseURL is
+// empty, it's derived from the host of the GitRepo's SSH URL
+func (gr *GitRepo) AddGitlabClientWithBaseURL(vcsToken, baseURL string, opts ...GitlabClientOption) error {
+	if baseURL == "" {
+		baseURL = gitlabBaseURL(gr.SSHURL)
+	}
+	return gr.AddGitlabClient(vcsToken, append(opts, WithGitlabBaseURL(baseURL))...)
+}
+
+func newGitlabClientConfig(opts []GitlabClientOption) *gitlabClientConfig {
+	cfg := &gitlabClientConfig{}
+	for _, o := range opts {
+		o(cfg)
+	}
+	return cfg
+}
+
+func (cfg *gitlabClientConfig) clientOptions() ([]gitlab.ClientOptionFunc, error) {
+	var opts []gitlab.ClientOptionFunc
+
+	if cfg.baseURL != "" {
+		opts = append(opts, gitlab.WithBaseURL(cfg.baseURL))
+	}
+
+	// Start from the same pooled transport the GitLab client uses by default, unless given one
+	transport := cfg.baseRT
+	if transport == nil {
+		t, err := cfg.transport.transport()
+		if err != nil {
+			return opts, err
+		}
+		transport = t
+	}
+	if cfg.authType == GitlabJobToken {
+		transport = &jobTokenTransport{next: transport}
+	}
+	if cfg.rateLimit != nil {
+		transport = newRateLimitTransport(transport, *cfg.rateLimit)
+		opts = append(opts, cfg.rateLimit.clientOptions()...)
+	}
+	if cfg.tokenRefresh != nil {
+		transport = &tokenRefreshTransport{next: transport, refresh: cfg.tokenRefresh}
+	}
+
+	opts = append(opts, gitlab.WithHTTPClient(&http.Client{Transport: transport}))
+	return opts, nil
+}
@@ -0,0 +1,109 @@
+package githelpers
+
+import (
+	"encoding/base64"
+	"errors"
+
+	"github.com/xanzy/go-gitlab"
+)
+
+var (
+	// ErrCompareTimeout is returned along with the comparison when GitLab gave up computing every diff,
+	// leaving the diffs incomplete
+	ErrCompareTimeout = errors.New("GitLab timed out comparing the refs, so the diffs are incomplete")
+)
+
+// CommitAction is a single file change made by CommitViaAPI. Action is one of gitlab.FileCreate,
+// gitlab.FileUpdate, gitlab.FileDelete, or gitlab.FileMove. PreviousPath is only used by moves
+type CommitAction struct {
+	Action       gitlab.FileAction
+	Path         string
+	PreviousPath string
+	Content      string
+}
+
+// CommitViaAPI makes one commit on branch containing all of the given actions through the GitLab
+// Commits API, as a clone-free alternative to CommitAndPushAll
+func (gr *GitRepo) CommitViaAPI(branch string, actions []CommitAction, msg string) (commit *gitlab.Commit, resp *gitlab.Response, err error) {
+	c, err := gr.gitlabClient()
+	if err != nil {
+		return commit, resp, err
+	}
+
+	pid, resp, err := gr.getGitlabProjectID(gr.SSHURL)
+	if err != nil {
+		return commit, resp, err
+	}
+
+	opts := &gitlab.CreateCommitOptions{
+		Branch:        &branch,
+		CommitMessage: &msg,
+	}
+	for _, a := range actions {
+		action := a.Action
+		path := a.Path
+		opt := &gitlab.CommitActionOptions{
+			Action:   &action,
+			FilePath: &path,
+		}
+		if a.PreviousPath != "" {
+			prev := a.PreviousPath
+			opt.PreviousPath = &prev
+		}
+		// Deletes carry no content, and a move without content keeps the file as it is
+		if action != gitlab.FileDelete && (action != gitlab.FileMove || a.Content != "") {
+			content := base64.StdEncoding.EncodeToString([]byte(a.Content))
+			opt.Content = &content
+			opt.Encoding = gitlab.String("base64")
+		}
+		opts.Actions = append(opts.Actions, opt)
+	}
+
+	commit, resp, err = c.Commits.CreateCommit(pid, opts)
+	return commit, resp, err
+}
+
+// SetCommitStatus reports the state ("pending", "running", "success", "failed", or "canceled") of an
+// external check called name on the commit with the given SHA. targetURL links to the check's details
+func (gr *GitRepo) SetCommitStatus(sha, state, name, targetURL string) (status *gitlab.CommitStatus, resp *gitlab.Response, err error) {
+	c, err := gr.gitlabClient()
+	if err != nil {
+		return status, resp, err
+	}
+
+	pid, resp, err := gr.getGitlabProjectID(gr.SSHURL)
+	if err != nil {
+		return status, resp, err
+	}
+
+	opts := &gitlab.SetCommitStatusOptions{
+		State: gitlab.BuildStateValue(state),
+		Name:  &name,
+	}
+	if targetURL != "" {
+		opts.TargetURL = &targetURL
+	}
+
+	status, resp, err = c.Commits.SetCommitStatus(pid, sha, opts)
+	return status, resp, err
+}
+
+// CompareRefs returns the commits and file diffs head adds on top of its merge base with base, through
+// the GitLab compare API, so no clone is needed. base and head can be branches, tags, or SHAs
+func (gr *GitRepo) CompareRefs(base, head string) (cmp *gitlab.Compare, resp *gitlab.Response, err error) {
+	c, err := gr.gitlabClient()
+	if err != nil {
+		return cmp, resp, err
+	}
+
+	pid, resp, err := gr.getGitlabProjectID(gr.SSHURL)
+	if err != nil {
+		return cmp, resp, err
+	}
+
+	cmp, resp, err = c.Repositories.Compare(pid, &gitlab.CompareOptions{From: &base, To: &head})
+	if err == nil && cmp.CompareTimeout {
+		err = ErrCompareTimeout
+	}
+	return cmp, resp, err
+}
@@ -0,0 +1,80 @@
+package githelpers
+
+import (
+	"time"
+
+	"github.com/xanzy/go-gitlab"
+)
+
+// AddGitlabDeployKey adds an SSH public key as a deploy key on the GitRepo's GitLab project
+func (gr *GitRepo) AddGitlabDeployKey(title, key string, canPush bool) (k *gitlab.DeployKey, resp *gitlab.Response, err error) {
+	c, err := gr.gitlabClient()
+	if err != nil {
+		return k, resp, err
+	}
+
+	pid, resp, err := gr.getGitlabProjectID(gr.SSHURL)
+	if err != nil {
+		return k, resp, err
+	}
+
+	k, resp, err = c.DeployKeys.AddDeployKey(pid, &gitlab.AddDeployKeyOptions{
+		Title:   &title,
+		Key:     &key,
+		CanPush: &canPush,
+	})
+	return k, resp, err
+}
+
+// RemoveGitlabDeployKey removes the deploy key with the given ID from the GitRepo's GitLab project
+func (gr *GitRepo) RemoveGitlabDeployKey(id int) (resp *gitlab.Response, err error) {
+	c, err := gr.gitlabClient()
+	if err != nil {
+		return resp, err
+	}
+
+	pid, resp, err := gr.getGitlabProjectID(gr.SSHURL)
+	if err != nil {
+		return resp, err
+	}
+
+	resp, err = c.DeployKeys.DeleteDeployKey(pid, id)
+	return resp, err
+}
+
+// CreateGitlabDeployToken creates a deploy token with the given scopes (e.g. "read_repository") on the
+// GitRepo's GitLab project. A nil expiresAt creates a token that never expires
+func (gr *GitRepo) CreateGitlabDeployToken(name string, scopes []string, expiresAt *time.Time) (t *gitlab.DeployToken, resp *gitlab.Response, err error) {
+	c, err := gr.gitlabClient()
+	if err != nil {
+		return t, resp, err
+	}
+
+	pid, resp, err := gr.getGitlabProjectID(gr.SSHURL)
+	if err != nil {
+		return t, resp, err
+	}
+
+	t, resp, err = c.DeployTokens.CreateProjectDeployToken(pid, &gitlab.CreateProjectDeployTokenOptions{
+		Name:      &name,
+		Scopes:    scopes,
+		ExpiresAt: expiresAt,
+	})
+	return t, resp, err
+}
+
+// RemoveGitlabDeployToken revokes the deploy token with the given ID on the GitRepo's GitLab project
+func (gr *GitRepo) RemoveGitlabDeployToken(id int) (resp *gitlab.Response, err error) {
+	c, err := gr.gitlabClient()
+	if err != nil {
+		return resp, err
+	}
+
+	pid, resp, err := gr.getGitlabProjectID(gr.SSHURL)
+	if err != nil {
+		return resp, err
+	}
+
+	resp, err = c.DeployTokens.DeleteProjectDeployToken(pid, id)
+	return resp, err
+}
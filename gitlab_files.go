@@ -0,0 +1,138 @@
+package githelpers
+
+import (
+	"encoding/base64"
+
+	"github.com/xanzy/go-gitlab"
+)
+
+// UpdateFileViaAPI commits content to the file at path on branch through the GitLab Repository Files
+// API, creating the file if it doesn't exist yet. No clone is needed
+func (gr *GitRepo) UpdateFileViaAPI(path, content, branch, commitMsg string) (info *gitlab.FileInfo, resp *gitlab.Response, err error) {
+	c, err := gr.gitlabClient()
+	if err != nil {
+		return info, resp, err
+	}
+
+	pid, resp, err := gr.getGitlabProjectID(gr.SSHURL)
+	if err != nil {
+		return info, resp, err
+	}
+
+	// Content is always sent base64 encoded so binary files survive the round trip
+	encoded := base64.StdEncoding.EncodeToString([]byte(content))
+	encoding := "base64"
+
+	_, resp, err = c.RepositoryFiles.GetFileMetaData(pid, path, &gitlab.GetFileMetaDataOptions{Ref: &branch})
+	if err != nil && !gitlabNotFound(resp) {
+		return info, resp, err
+	}
+	if err != nil {
+		info, resp, err = c.RepositoryFiles.CreateFile(pid, path, &gitlab.CreateFileOptions{
+			Branch:        &branch,
+			Encoding:      &encoding,
+			Content:       &encoded,
+			CommitMessage: &commitMsg,
+		})
+		return info, resp, err
+	}
+
+	info, resp, err = c.RepositoryFiles.UpdateFile(pid, path, &gitlab.UpdateFileOptions{
+		Branch:        &branch,
+		Encoding:      &encoding,
+		Content:       &encoded,
+		CommitMessage: &commitMsg,
+	})
+	return info, resp, err
+}
+
+// DeleteFileViaAPI removes the file at path on branch through the GitLab Repository Files API
+func (gr *GitRepo) DeleteFileViaAPI(path, branch, commitMsg string) (resp *gitlab.Response, err error) {
+	c, err := gr.gitlabClient()
+	if err != nil {
+		return resp, err
+	}
+
+	pid, resp, err := gr.getGitlabProjectID(gr.SSHURL)
+	if err != nil {
+		return resp, err
+	}
+
+	resp, err = c.RepositoryFiles.DeleteFile(pid, path, &gitlab.DeleteFileOptions{
+		Branch:        &branch,
+		CommitMessage: &commitMsg,
+	})
+	return resp, err
+}
+
+// ListRepoTreeViaAPI lists the files and directories directly under path at ref through the GitLab
+// Repositories API, following all result pages. An empty path lists the repository root
+func (gr *GitRepo) ListRepoTreeViaAPI(path, ref string) (nodes []*gitlab.TreeNode, resp *gitlab.Response, err error) {
+	c, err := gr.gitlabClient()
+	if err != nil {
+		return nodes, resp, err
+	}
+
+	pid, resp, err := gr.getGitlabProjectID(gr.SSHURL)
+	if err != nil {
+		return nodes, resp, err
+	}
+
+	opts := &gitlab.ListTreeOptions{
+		ListOptions: defaultListOpts,
+	}
+	if path != "" {
+		opts.Path = &path
+	}
+	if ref != "" {
+		opts.Ref = &ref
+	}
+
+	for {
+		page, resp, err := c.Repositories.ListTree(pid, opts)
+		if err != nil {
+			return nodes, resp, err
+		}
+		nodes = append(nodes, page...)
+
+		if resp.NextPage == 0 {
+			return nodes, resp, err
+		}
+		opts.Page = resp.NextPage
+	}
+}
+
+// GetFileViaAPI returns the raw content of the file at path at ref through the GitLab Repository Files API
+func (gr *GitRepo) GetFileViaAPI(path, ref string) (content []byte, resp *gitlab.Response, err error) {
+	c, err := gr.gitlabClient()
+	if err != nil {
+		return content, resp, err
+	}
+
+	pid, resp, err := gr.getGitlabProjectID(gr.SSHURL)
+	if err != nil {
+		return content, resp, err
+	}
+
+	content, resp, err = c.RepositoryFiles.GetRawFile(pid, path, &gitlab.GetRawFileOptions{Ref: &ref})
+	return content, resp, err
+}
+
+// FileExistsViaAPI reports whether a file exists at path at ref without cloning the repository
+func (gr *GitRepo) FileExistsViaAPI(path, ref string) (exists bool, resp *gitlab.Response, err error) {
+	c, err := gr.gitlabClient()
+	if err != nil {
+		return exists, resp, err
+	}
+
+	pid, resp, err := gr.getGitlabProjectID(gr.SSHURL)
+	if err != nil {
+		return exists, resp, err
+	}
+
+	_, resp, err = c.RepositoryFiles.GetFileMetaData(pid, path, &gitlab.GetFileMetaDataOptions{Ref: &ref})
+	if gitlabNotFound(resp) {
+		return false, resp, nil
+	}
+	return err == nil, resp, err
+}
@@ -0,0 +1,119 @@
+package githelpers
+
+import (
+	"fmt"
+
+	"github.com/xanzy/go-gitlab"
+)
+
+// AddGitlabProjectMember gives the user with the given username access to the GitRepo's GitLab project
+func (gr *GitRepo) AddGitlabProjectMember(username string, level gitlab.AccessLevelValue) (m *gitlab.ProjectMember, resp *gitlab.Response, err error) {
+	c, err := gr.gitlabClient()
+	if err != nil {
+		return m, resp, err
+	}
+
+	uid, resp, err := gr.getGitlabUserID(username)
+	if err != nil {
+		return m, resp, err
+	}
+
+	pid, resp, err := gr.getGitlabProjectID(gr.SSHURL)
+	if err != nil {
+		return m, resp, err
+	}
+
+	m, resp, err = c.ProjectMembers.AddProjectMember(pid, &gitlab.AddProjectMemberOptions{
+		UserID:      &uid,
+		AccessLevel: &level,
+	})
+	return m, resp, err
+}
+
+// RemoveGitlabProjectMember removes the user with the given username from the GitRepo's GitLab project
+func (gr *GitRepo) RemoveGitlabProjectMember(username string) (resp *gitlab.Response, err error) {
+	c, err := gr.gitlabClient()
+	if err != nil {
+		return resp, err
+	}
+
+	uid, resp, err := gr.getGitlabUserID(username)
+	if err != nil {
+		return resp, err
+	}
+
+	pid, resp, err := gr.getGitlabProjectID(gr.SSHURL)
+	if err != nil {
+		return resp, err
+	}
+
+	resp, err = c.ProjectMembers.DeleteProjectMember(pid, uid)
+	return resp, err
+}
+
+// ShareGitlabProjectWithGroup gives the members of the group at groupPath access to the GitRepo's GitLab project
+func (gr *GitRepo) ShareGitlabProjectWithGroup(groupPath string, level gitlab.AccessLevelValue) (resp *gitlab.Response, err error) {
+	c, err := gr.gitlabClient()
+	if err != nil {
+		return resp, err
+	}
+
+	gid, resp, err := gr.getGitlabGroupID(groupPath)
+	if err != nil {
+		return resp, err
+	}
+	if gid == 0 {
+		return resp, fmt.Errorf("gitlab group not found: %s", groupPath)
+	}
+
+	pid, resp, err := gr.getGitlabProjectID(gr.SSHURL)
+	if err != nil {
+		return resp, err
+	}
+
+	resp, err = c.Projects.ShareProjectWithGroup(pid, &gitlab.ShareWithGroupOptions{
+		GroupID:     &gid,
+		GroupAccess: &level,
+	})
+	return resp, err
+}
+
+// UnshareGitlabProjectWithGroup revokes the access given to the group at groupPath on the GitRepo's GitLab project
+func (gr *GitRepo) UnshareGitlabProjectWithGroup(groupPath string) (resp *gitlab.Response, err error) {
+	c, err := gr.gitlabClient()
+	if err != nil {
+		return resp, err
+	}
+
+	gid, resp, err := gr.getGitlabGroupID(groupPath)
+	if err != nil {
+		return resp, err
+	}
+	if gid == 0 {
+		return resp, fmt.Errorf("gitlab group not found: %s", groupPath)
+	}
+
+	pid, resp, err := gr.getGitlabProjectID(gr.SSHURL)
+	if err != nil {
+		return resp, err
+	}
+
+	resp, err = c.Projects.DeleteSharedProjectFromGroup(pid, gid)
+	return resp, err
+}
+
+func (gr *GitRepo) getGitlabUserID(username string) (id int, resp *gitlab.Response, err error) {
+	c, err := gr.gitlabClient()
+	if err != nil {
+		return id, resp, err
+	}
+
+	users, resp, err := c.Users.ListUsers(&gitlab.ListUsersOptions{Username: &username})
+	if err != nil {
+		return id, resp, err
+	}
+	if len(users) == 0 {
+		return id, resp, fmt.Errorf("gitlab user not found: %s", username)
+	}
+	return users[0].ID, resp, err
+}
@@ -0,0 +1,113 @@
+package githelpers
+
+import (
+	"time"
+
+	"github.com/xanzy/go-gitlab"
+)
+
+// MRFilter narrows down the merge requests returned by ListGitlabMergeRequests. Zero values match everything
+type MRFilter struct {
+	State        string // One of "opened", "closed", "locked", or "merged"
+	Author       string // Username of the MR author
+	Labels       []string
+	TargetBranch string
+	UpdatedSince time.Time
+}
+
+// ListGitlabMergeRequests lists every MR on the GitRepo's GitLab project that matches filter, following all result pages
+func (gr *GitRepo) ListGitlabMergeRequests(filter MRFilter) (mrs []*gitlab.MergeRequest, resp *gitlab.Response, err error) {
+	c, err := gr.gitlabClient()
+	if err != nil {
+		return mrs, resp, err
+	}
+
+	pid, resp, err := gr.getGitlabProjectID(gr.SSHURL)
+	if err != nil {
+		return mrs, resp, err
+	}
+
+	opts := &gitlab.ListProjectMergeRequestsOptions{
+		ListOptions: defaultListOpts,
+		Labels:      gitlab.Labels(filter.Labels),
+	}
+	if filter.State != "" {
+		opts.State = &filter.State
+	}
+	if filter.TargetBranch != "" {
+		opts.TargetBranch = &filter.TargetBranch
+	}
+	if !filter.UpdatedSince.IsZero() {
+		opts.UpdatedAfter = &filter.UpdatedSince
+	}
+	if filter.Author != "" {
+		uid, resp, err := gr.getGitlabUserID(filter.Author)
+		if err != nil {
+			return mrs, resp, err
+		}
+		opts.AuthorID = &uid
+	}
+
+	for {
+		page, resp, err := c.MergeRequests.ListProjectMergeRequests(pid, opts)
+		if err != nil {
+			return mrs, resp, err
+		}
+		mrs = append(mrs, page...)
+
+		if resp.NextPage == 0 {
+			return mrs, resp, err
+		}
+		opts.Page = resp.NextPage
+	}
+}
+
+// CloseStaleMRs closes the open MRs carrying label that haven't been updated in olderThan and returns them
+func (gr *GitRepo) CloseStaleMRs(olderThan time.Duration, label string) (closed []*gitlab.MergeRequest, resp *gitlab.Response, err error) {
+	c, err := gr.gitlabClient()
+	if err != nil {
+		return closed, resp, err
+	}
+
+	filter := MRFilter{State: "opened"}
+	if label != "" {
+		filter.Labels = []string{label}
+	}
+	mrs, resp, err := gr.ListGitlabMergeRequests(filter)
+	if err != nil {
+		return closed, resp, err
+	}
+
+	cutoff := time.Now().Add(-olderThan)
+	for _, mr := range mrs {
+		if mr.UpdatedAt == nil || !mr.UpdatedAt.Before(cutoff) {
+			continue
+		}
+
+		mr, resp, err = c.MergeRequests.UpdateMergeRequest(mr.ProjectID, mr.IID, &gitlab.UpdateMergeRequestOptions{
+			StateEvent: gitlab.String("close"),
+		})
+		if err != nil {
+			return closed, resp, err
+		}
+		closed = append(closed, mr)
+	}
+	return closed, resp, err
+}
+
+// RebaseMR asks GitLab to rebase the source branch of the MR with the given IID onto its target branch.
+// The rebase runs asynchronously on the GitLab side
+func (gr *GitRepo) RebaseMR(iid int) (resp *gitlab.Response, err error) {
+	c, err := gr.gitlabClient()
+	if err != nil {
+		return resp, err
+	}
+
+	pid, resp, err := gr.getGitlabProjectID(gr.SSHURL)
+	if err != nil {
+		return resp, err
+	}
+
+	resp, err = c.MergeRequests.RebaseMergeRequest(pid, iid)
+	return resp, err
+}
@@ -0,0 +1,54 @@
+package githelpers
+
+import (
+	"net/url"
+	"regexp"
+
+	"github.com/hashicorp/go-retryablehttp"
+	"github.com/xanzy/go-gitlab"
+)
+
+var (
+	linkNextPattern = regexp.MustCompile(`<([^>]+)>;\s*rel="next"`)
+)
+
+// WithGitlabKeysetPagination makes list helpers use keyset pagination on the endpoints that support
+// it. Keyset pagination stays fast on very large instances, where offset pagination slows down or
+// is capped by GitLab
+func WithGitlabKeysetPagination() GitlabClientOption {
+	return func(cfg *gitlabClientConfig) {
+		cfg.keyset = true
+	}
+}
+
+func (gr *GitRepo) gitlabKeysetPagination() bool {
+	p, ok := gr.VCSClient.(*GitlabProvider)
+	return ok && newGitlabClientConfig(p.opts).keyset
+}
+
+// withGitlabKeyset asks for keyset pagination, continuing from cursor when it's set
+func withGitlabKeyset(cursor url.Values) gitlab.RequestOptionFunc {
+	return func(req *retryablehttp.Request) error {
+		q := req.URL.Query()
+		q.Set("pagination", "keyset")
+		for k, v := range cursor {
+			q[k] = v
+		}
+		req.URL.RawQuery = q.Encode()
+		return nil
+	}
+}
+
+// nextGitlabKeyset returns the query of the next page's link from a keyset paginated response, or
+// nil on the last page
+func nextGitlabKeyset(resp *gitlab.Response) url.Values {
+	m := linkNextPattern.FindStringSubmatch(resp.Header.Get("Link"))
+	if m == nil {
+		return nil
+	}
+	next, err := url.Parse(m[1])
+	if err != nil {
+		return nil
+	}
+	return next.Query()
+}
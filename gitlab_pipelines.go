@@ -0,0 +1,144 @@
+package githelpers
+
+import (
+	"context"
+	"regexp"
+	"sort"
+	"time"
+
+	"github.com/xanzy/go-gitlab"
+)
+
+var (
+	pipelinePollInterval = 10 * time.Second
+	shaPattern           = regexp.MustCompile(`^[0-9a-f]{40}$`)
+)
+
+// WaitForPipeline polls the latest GitLab pipeline for a branch/tag name or commit SHA until it
+// finishes, the timeout elapses, or ctx is canceled. The returned pipeline's Status holds the
+// final state, e.g. "success", "failed", "canceled", or "skipped"
+func (gr *GitRepo) WaitForPipeline(ctx context.Context, refOrSHA string, timeout time.Duration) (pipeline *gitlab.PipelineInfo, resp *gitlab.Response, err error) {
+	c, err := gr.gitlabClient()
+	if err != nil {
+		return pipeline, resp, err
+	}
+
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	pid, resp, err := gr.getGitlabProjectID(gr.SSHURL)
+	if err != nil {
+		return pipeline, resp, err
+	}
+
+	opts := &gitlab.ListProjectPipelinesOptions{
+		ListOptions: gitlab.ListOptions{PerPage: 1},
+		OrderBy:     gitlab.String("id"),
+		Sort:        gitlab.String("desc"),
+	}
+	if shaPattern.MatchString(refOrSHA) {
+		opts.SHA = &refOrSHA
+	} else {
+		opts.Ref = &refOrSHA
+	}
+
+	ticker := time.NewTicker(pipelinePollInterval)
+	defer ticker.Stop()
+
+	for {
+		pipelines, resp, err := c.Pipelines.ListProjectPipelines(pid, opts, gitlab.WithContext(ctx))
+		if err != nil {
+			if ctx.Err() != nil {
+				return pipeline, resp, ctx.Err()
+			}
+			return pipeline, resp, err
+		}
+
+		// The pipeline may not have been created yet right after a push, so keep polling
+		if len(pipelines) > 0 {
+			pipeline = pipelines[0]
+			if pipelineFinished(pipeline.Status) {
+				return pipeline, resp, nil
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return pipeline, resp, ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+func pipelineFinished(status string) bool {
+	switch gitlab.BuildStateValue(status) {
+	case gitlab.Success, gitlab.Failed, gitlab.Canceled, gitlab.Skipped:
+		return true
+	}
+	return false
+}
+
+// TriggerPipeline starts a new pipeline on the given ref, passing variables through to the jobs
+func (gr *GitRepo) TriggerPipeline(ref string, variables map[string]string) (pipeline *gitlab.Pipeline, resp *gitlab.Response, err error) {
+	c, err := gr.gitlabClient()
+	if err != nil {
+		return pipeline, resp, err
+	}
+
+	pid, resp, err := gr.getGitlabProjectID(gr.SSHURL)
+	if err != nil {
+		return pipeline, resp, err
+	}
+
+	keys := make([]string, 0, len(variables))
+	for k := range variables {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	vars := make([]*gitlab.PipelineVariable, 0, len(keys))
+	for _, k := range keys {
+		vars = append(vars, &gitlab.PipelineVariable{Key: k, Value: variables[k]})
+	}
+
+	pipeline, resp, err = c.Pipelines.CreatePipeline(pid, &gitlab.CreatePipelineOptions{
+		Ref:       &ref,
+		Variables: vars,
+	})
+	return pipeline, resp, err
+}
+
+// CancelPipeline cancels the running jobs of the pipeline with the given ID
+func (gr *GitRepo) CancelPipeline(id int) (pipeline *gitlab.Pipeline, resp *gitlab.Response, err error) {
+	c, err := gr.gitlabClient()
+	if err != nil {
+		return pipeline, resp, err
+	}
+
+	pid, resp, err := gr.getGitlabProjectID(gr.SSHURL)
+	if err != nil {
+		return pipeline, resp, err
+	}
+
+	pipeline, resp, err = c.Pipelines.CancelPipelineBuild(pid, id)
+	return pipeline, resp, err
+}
+
+// RetryPipeline retries the failed or canceled jobs of the pipeline with the given ID
+func (gr *GitRepo) RetryPipeline(id int) (pipeline *gitlab.Pipeline, resp *gitlab.Response, err error) {
+	c, err := gr.gitlabClient()
+	if err != nil {
+		return pipeline, resp, err
+	}
+
+	pid, resp, err := gr.getGitlabProjectID(gr.SSHURL)
+	if err != nil {
+		return pipeline, resp, err
+	}
+
+	pipeline, resp, err = c.Pipelines.RetryPipelineBuild(pid, id)
+	return pipeline, resp, err
+}
@@ -0,0 +1,145 @@
+package githelpers
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/xanzy/go-gitlab"
+)
+
+// ProjectOptions holds the settings applied when creating a GitLab project. Empty fields are left
+// for GitLab to default
+type ProjectOptions struct {
+	Description   string
+	DefaultBranch string
+	MergeMethod   gitlab.MergeMethodValue
+	Visibility    gitlab.VisibilityValue
+	ProtectMain   *ProtectOptions // Protects the main branch after the initial push when set
+}
+
+// CreateGitlabProject creates a new project called name under the group or user namespace at namespacePath
+func (gr *GitRepo) CreateGitlabProject(namespacePath, name string, opts ProjectOptions) (p *gitlab.Project, resp *gitlab.Response, err error) {
+	c, err := gr.gitlabClient()
+	if err != nil {
+		return p, resp, err
+	}
+
+	ns, resp, err := c.Namespaces.GetNamespace(namespacePath)
+	if err != nil {
+		return p, resp, err
+	}
+
+	projOpts := &gitlab.CreateProjectOptions{
+		Name:        &name,
+		Path:        &name,
+		NamespaceID: &ns.ID,
+	}
+	if opts.Description != "" {
+		projOpts.Description = &opts.Description
+	}
+	if opts.DefaultBranch != "" {
+		projOpts.DefaultBranch = &opts.DefaultBranch
+	}
+	if opts.MergeMethod != "" {
+		projOpts.MergeMethod = gitlab.MergeMethod(opts.MergeMethod)
+	}
+	if opts.Visibility != "" {
+		projOpts.Visibility = gitlab.Visibility(opts.Visibility)
+	}
+
+	p, resp, err = c.Projects.CreateProject(projOpts)
+	return p, resp, err
+}
+
+// CreateAndPushNewRepo creates the GitLab project described by the GitRepo's SSH URL, then does a
+// full init, commit, and push to its main branch
+func (gr *GitRepo) CreateAndPushNewRepo(commitMsg string, opts ProjectOptions) (*git.Repository, error) {
+	_, ns, name := splitRepoURL(gr.SSHURL)
+
+	_, _, err := gr.CreateGitlabProject(ns, name, opts)
+	if err != nil {
+		return &git.Repository{}, err
+	}
+
+	repo, err := gr.InitAndPushNewRepo(commitMsg)
+	if err != nil || opts.ProtectMain == nil {
+		return repo, err
+	}
+
+	_, _, err = gr.ProtectBranch("main", *opts.ProtectMain)
+	return repo, err
+}
+
+// ProjectSettings holds the GitLab project settings to enforce on an existing project. Empty strings
+// and nil pointers leave the current setting unchanged
+type ProjectSettings struct {
+	DefaultBranch                    string
+	MergeMethod                      gitlab.MergeMethodValue
+	SquashOption                     string // One of "never", "always", "default_on", or "default_off"
+	RemoveSourceBranchAfterMerge     *bool
+	ApprovalsRequired                *int
+	OnlyAllowMergeIfPipelineSucceeds *bool
+	CIConfigPath                     string
+	AutoCancelPendingPipelines       string // Either "enabled" or "disabled"
+	BuildTimeout                     *int   // In seconds
+}
+
+// editProjectSettingsOptions adds the options the GitLab client doesn't know about yet to the edit project request
+type editProjectSettingsOptions struct {
+	*gitlab.EditProjectOptions
+	SquashOption *string `json:"squash_option,omitempty"`
+}
+
+// UpdateGitlabProjectSettings applies the given settings to the GitRepo's GitLab project
+func (gr *GitRepo) UpdateGitlabProjectSettings(settings ProjectSettings) (p *gitlab.Project, resp *gitlab.Response, err error) {
+	c, err := gr.gitlabClient()
+	if err != nil {
+		return p, resp, err
+	}
+
+	pid, resp, err := gr.getGitlabProjectID(gr.SSHURL)
+	if err != nil {
+		return p, resp, err
+	}
+
+	opts := editProjectSettingsOptions{
+		EditProjectOptions: &gitlab.EditProjectOptions{
+			RemoveSourceBranchAfterMerge:     settings.RemoveSourceBranchAfterMerge,
+			OnlyAllowMergeIfPipelineSucceeds: settings.OnlyAllowMergeIfPipelineSucceeds,
+			BuildTimeout:                     settings.BuildTimeout,
+		},
+	}
+	if settings.DefaultBranch != "" {
+		opts.DefaultBranch = &settings.DefaultBranch
+	}
+	if settings.MergeMethod != "" {
+		opts.MergeMethod = gitlab.MergeMethod(settings.MergeMethod)
+	}
+	if settings.SquashOption != "" {
+		opts.SquashOption = &settings.SquashOption
+	}
+	if settings.CIConfigPath != "" {
+		opts.CIConfigPath = &settings.CIConfigPath
+	}
+	if settings.AutoCancelPendingPipelines != "" {
+		opts.AutoCancelPendingPipelines = &settings.AutoCancelPendingPipelines
+	}
+
+	req, err := c.NewRequest(http.MethodPut, fmt.Sprintf("projects/%d", pid), opts, nil)
+	if err != nil {
+		return p, resp, err
+	}
+
+	resp, err = c.Do(req, &p)
+	if err != nil {
+		return p, resp, err
+	}
+
+	if settings.ApprovalsRequired != nil {
+		_, resp, err = c.Projects.ChangeApprovalConfiguration(pid, &gitlab.ChangeApprovalConfigurationOptions{
+			ApprovalsBeforeMerge: settings.ApprovalsRequired,
+		})
+	}
+	return p, resp, err
+}
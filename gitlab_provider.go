@@ -0,0 +1,142 @@
+package githelpers
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/xanzy/go-gitlab"
+)
+
+var _ VCSProvider = (*GitlabProvider)(nil)
+var _ ReleaseCreator = (*GitlabProvider)(nil)
+var _ BranchProtector = (*GitlabProvider)(nil)
+var _ RepoSizer = (*GitlabProvider)(nil)
+
+// GitlabProvider is the VCSProvider for GitLab. Client is exposed for API calls this package doesn't wrap
+type GitlabProvider struct {
+	Client *gitlab.Client
+
+	opts    []GitlabClientOption
+	idCache *IDCache
+}
+
+// NewGitlabProvider returns a GitlabProvider authenticated with the given token
+func NewGitlabProvider(vcsToken string, opts ...GitlabClientOption) (p *GitlabProvider, err error) {
+	cfg := newGitlabClientConfig(opts)
+	clientOpts, err := cfg.clientOptions()
+	if err != nil {
+		return p, err
+	}
+
+	c, err := newGitlabClient(vcsToken, cfg, clientOpts)
+	if err != nil {
+		return p, err
+	}
+	return &GitlabProvider{Client: c, opts: opts, idCache: cfg.idCache}, nil
+}
+
+// GetProjectID returns the numeric GitLab project ID of the repo at repoURL
+func (p *GitlabProvider) GetProjectID(repoURL string) (string, error) {
+	id, _, err := p.repo(repoURL).getGitlabProjectID(repoURL)
+	if err != nil {
+		return "", err
+	}
+	if id == 0 {
+		return "", fmt.Errorf("gitlab project not found: %s", repoURL)
+	}
+	return strconv.Itoa(id), nil
+}
+
+// CreateProject creates a new GitLab project called name under the namespace at namespacePath
+func (p *GitlabProvider) CreateProject(namespacePath, name string, opts ProjectOptions) (RemoteProject, error) {
+	proj, _, err := p.repo("").CreateGitlabProject(namespacePath, name, opts)
+	if err != nil {
+		return RemoteProject{}, err
+	}
+	return RemoteProject{
+		ID:       strconv.Itoa(proj.ID),
+		FullPath: proj.PathWithNamespace,
+		SSHURL:   proj.SSHURLToRepo,
+		HTTPURL:  proj.HTTPURLToRepo,
+		WebURL:   proj.WebURL,
+	}, nil
+}
+
+// CreateMergeRequest opens an MR from src into dest on the GitLab project at repoURL
+func (p *GitlabProvider) CreateMergeRequest(repoURL, title, src, dest string) (MergeRequestInfo, error) {
+	mr, _, err := p.repo(repoURL).NewGitlabMergeRequest(title, src, dest)
+	if err != nil {
+		return MergeRequestInfo{}, err
+	}
+	return MergeRequestInfo{
+		ID:           strconv.Itoa(mr.ID),
+		Number:       mr.IID,
+		Title:        mr.Title,
+		SourceBranch: mr.SourceBranch,
+		TargetBranch: mr.TargetBranch,
+		URL:          mr.WebURL,
+	}, nil
+}
+
+// SetStatus reports the state of an external check on a commit of the GitLab project at repoURL
+func (p *GitlabProvider) SetStatus(repoURL, sha, state, name, targetURL string) error {
+	_, _, err := p.repo(repoURL).SetCommitStatus(sha, state, name, targetURL)
+	return err
+}
+
+// CreateRelease creates a GitLab release for tag on the project at repoURL
+func (p *GitlabProvider) CreateRelease(repoURL, tag, name, notes string) (ReleaseInfo, error) {
+	r, _, err := p.repo(repoURL).CreateGitlabRelease(tag, name, notes, nil)
+	if err != nil {
+		return ReleaseInfo{}, err
+	}
+
+	pid, err := p.GetProjectID(repoURL)
+	if err != nil {
+		return ReleaseInfo{}, err
+	}
+	proj, _, err := p.Client.Projects.GetProject(pid, &gitlab.GetProjectOptions{})
+	if err != nil {
+		return ReleaseInfo{}, err
+	}
+	return ReleaseInfo{Tag: r.TagName, Name: r.Name, URL: proj.WebURL + "/-/releases/" + r.TagName}, nil
+}
+
+// ProtectBranch protects branch on the GitLab project at repoURL
+func (p *GitlabProvider) ProtectBranch(repoURL, branch string, opts ProtectOptions) error {
+	_, _, err := p.repo(repoURL).ProtectBranch(branch, opts)
+	return err
+}
+
+// RepoSize returns the size of the git repository of the GitLab project at repoURL, from the project statistics
+func (p *GitlabProvider) RepoSize(repoURL string) (int64, error) {
+	r, err := ParseRepoURL(repoURL)
+	if err != nil {
+		return 0, err
+	}
+	proj, _, err := p.Client.Projects.GetProject(r.FullPath(), &gitlab.GetProjectOptions{Statistics: gitlab.Bool(true)})
+	if err != nil {
+		return 0, err
+	}
+	if proj.Statistics == nil {
+		// Statistics are only returned to members with at least Reporter access
+		return 0, ErrNotSupported
+	}
+	return proj.Statistics.RepositorySize, nil
+}
+
+func (p *GitlabProvider) repo(repoURL string) *GitRepo {
+	// The GitLab helpers only need the URL and the client, so a bare GitRepo is enough to reuse them
+	return &GitRepo{SSHURL: repoURL, VCSClient: p}
+}
+
+func (gr *GitRepo) gitlabClient() (*gitlab.Client, error) {
+	if gr.VCSClient == nil {
+		return nil, ErrNoVCSClient
+	}
+	p, ok := gr.VCSClient.(*GitlabProvider)
+	if !ok {
+		return nil, ErrNotGitlab
+	}
+	return p.Client, nil
+}
@@ -0,0 +1,133 @@
+package githelpers
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	retryablehttp "github.com/hashicorp/go-retryablehttp"
+	"github.com/xanzy/go-gitlab"
+	"golang.org/x/time/rate"
+)
+
+const (
+	defaultRateLimitMaxBackoff = time.Minute
+
+	headerRateLimitRemaining = "RateLimit-Remaining"
+	headerRateLimitReset     = "RateLimit-Reset"
+	headerRetryAfter         = "Retry-After"
+)
+
+// RateLimitOptions configures how a GitLab client throttles itself to stay under the instance's rate limits
+type RateLimitOptions struct {
+	RequestsPerSecond float64       // Caps the client's request rate. Zero leaves it to the limit GitLab advertises
+	Burst             int           // Requests allowed above RequestsPerSecond in a burst. Defaults to 1
+	MinRemaining      int           // Once RateLimit-Remaining drops to this, requests wait for RateLimit-Reset
+	MaxBackoff        time.Duration // Upper bound on the wait after a 429 response. Defaults to one minute
+}
+
+// WithGitlabRateLimit makes the GitLab client throttle its requests and back off when it's rate limited
+func WithGitlabRateLimit(opts RateLimitOptions) GitlabClientOption {
+	return func(cfg *gitlabClientConfig) {
+		cfg.rateLimit = &opts
+	}
+}
+
+func (o RateLimitOptions) clientOptions() []gitlab.ClientOptionFunc {
+	opts := []gitlab.ClientOptionFunc{gitlab.WithCustomBackoff(o.backoff)}
+
+	if o.RequestsPerSecond > 0 {
+		burst := o.Burst
+		if burst <= 0 {
+			burst = 1
+		}
+		opts = append(opts, gitlab.WithCustomLimiter(rate.NewLimiter(rate.Limit(o.RequestsPerSecond), burst)))
+	}
+	return opts
+}
+
+func (o RateLimitOptions) backoff(min, max time.Duration, attemptNum int, resp *http.Response) time.Duration {
+	if resp == nil || resp.StatusCode != http.StatusTooManyRequests {
+		return retryablehttp.DefaultBackoff(min, max, attemptNum, resp)
+	}
+
+	maxBackoff := o.MaxBackoff
+	if maxBackoff <= 0 {
+		maxBackoff = defaultRateLimitMaxBackoff
+	}
+
+	// Prefer the wait GitLab asks for, and fall back to exponential backoff when it doesn't say
+	wait := time.Duration(math.Pow(2, float64(attemptNum))) * time.Second
+	if s, err := strconv.Atoi(resp.Header.Get(headerRetryAfter)); err == nil && s > 0 {
+		wait = time.Duration(s) * time.Second
+	} else if reset, err := strconv.ParseInt(resp.Header.Get(headerRateLimitReset), 10, 64); err == nil && reset > 0 {
+		if until := time.Until(time.Unix(reset, 0)); until > 0 {
+			wait = until
+		}
+	}
+
+	if wait > maxBackoff {
+		wait = maxBackoff
+	}
+	return wait
+}
+
+// rateLimitTransport pauses requests once GitLab reports that the remaining rate limit is nearly exhausted
+type rateLimitTransport struct {
+	next         http.RoundTripper
+	minRemaining int
+
+	mu        sync.Mutex
+	remaining int
+	reset     time.Time
+}
+
+func newRateLimitTransport(next http.RoundTripper, opts RateLimitOptions) *rateLimitTransport {
+	return &rateLimitTransport{
+		next:         next,
+		minRemaining: opts.MinRemaining,
+		remaining:    -1,
+	}
+}
+
+func (t *rateLimitTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	t.mu.Lock()
+	var wait time.Duration
+	if t.remaining >= 0 && t.remaining <= t.minRemaining {
+		wait = time.Until(t.reset)
+	}
+	t.mu.Unlock()
+
+	if wait > 0 {
+		timer := time.NewTimer(wait)
+		select {
+		case <-req.Context().Done():
+			timer.Stop()
+			return nil, req.Context().Err()
+		case <-timer.C:
+		}
+	}
+
+	resp, err := t.next.RoundTrip(req)
+	if err != nil {
+		return resp, err
+	}
+
+	remaining, err := strconv.Atoi(resp.Header.Get(headerRateLimitRemaining))
+	if err != nil {
+		return resp, nil
+	}
+	reset, err := strconv.ParseInt(resp.Header.Get(headerRateLimitReset), 10, 64)
+	if err != nil {
+		return resp, nil
+	}
+
+	t.mu.Lock()
+	t.remaining = remaining
+	t.reset = time.Unix(reset, 0)
+	t.mu.Unlock()
+
+	return resp, nil
+}
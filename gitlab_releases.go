@@ -0,0 +1,67 @@
+package githelpers
+
+import (
+	"github.com/xanzy/go-gitlab"
+)
+
+// ReleaseAsset is a file linked from a GitLab release. Assets with a FilePath are uploaded to the
+// project first and linked from there; otherwise URL is linked as-is
+type ReleaseAsset struct {
+	Name     string
+	URL      string
+	FilePath string
+}
+
+// CreateGitlabRelease creates a release for tag on the GitRepo's GitLab project with the given assets
+// attached. If the tag doesn't exist yet, it's created from the tip of the project's default branch
+func (gr *GitRepo) CreateGitlabRelease(tag, name, notes string, assets []ReleaseAsset) (r *gitlab.Release, resp *gitlab.Response, err error) {
+	c, err := gr.gitlabClient()
+	if err != nil {
+		return r, resp, err
+	}
+
+	pid, resp, err := gr.getGitlabProjectID(gr.SSHURL)
+	if err != nil {
+		return r, resp, err
+	}
+
+	p, resp, err := c.Projects.GetProject(pid, &gitlab.GetProjectOptions{})
+	if err != nil {
+		return r, resp, err
+	}
+
+	_, resp, err = c.Tags.GetTag(pid, tag)
+	if err != nil && !gitlabNotFound(resp) {
+		return r, resp, err
+	}
+	if err != nil {
+		_, resp, err = c.Tags.CreateTag(pid, &gitlab.CreateTagOptions{
+			TagName: &tag,
+			Ref:     &p.DefaultBranch,
+		})
+		if err != nil {
+			return r, resp, err
+		}
+	}
+
+	links := []*gitlab.ReleaseAssetLink{}
+	for _, a := range assets {
+		url := a.URL
+		if a.FilePath != "" {
+			f, resp, err := c.Projects.UploadFile(pid, a.FilePath)
+			if err != nil {
+				return r, resp, err
+			}
+			url = p.WebURL + f.URL
+		}
+		links = append(links, &gitlab.ReleaseAssetLink{Name: a.Name, URL: url})
+	}
+
+	r, resp, err = c.Releases.CreateRelease(pid, &gitlab.CreateReleaseOptions{
+		Name:        &name,
+		TagName:     &tag,
+		Description: &notes,
+		Assets:      &gitlab.ReleaseAssets{Links: links},
+	})
+	return r, resp, err
+}
@@ -0,0 +1,113 @@
+package githelpers
+
+import (
+	"net/url"
+	"strings"
+
+	"github.com/xanzy/go-gitlab"
+)
+
+// FoundProject is a GitLab project returned by FindProjects, with the clone URLs ready to pass to NewGitRepo
+type FoundProject struct {
+	ID       int
+	FullPath string
+	SSHURL   string
+	HTTPURL  string
+	WebURL   string
+	Topics   []string
+}
+
+// FindProjects returns the projects whose name or path contains query, or which carry query as a
+// topic, anywhere in the group tree at groupPath. An empty groupPath searches every project visible
+// to the client by name, and an empty query matches every project in the group tree
+func (gr *GitRepo) FindProjects(query string, groupPath string) (found []FoundProject, resp *gitlab.Response, err error) {
+	c, err := gr.gitlabClient()
+	if err != nil {
+		return found, resp, err
+	}
+
+	var projects, page []*gitlab.Project
+	if groupPath == "" {
+		opts := &gitlab.ListProjectsOptions{
+			ListOptions: defaultListOpts,
+			Search:      &query,
+		}
+		keyset := gr.gitlabKeysetPagination()
+		if keyset {
+			// Keyset pagination of projects is only available ordered by ID
+			opts.OrderBy = gitlab.String("id")
+			opts.Sort = gitlab.String("asc")
+		}
+
+		var cursor url.Values
+		for {
+			var reqOpts []gitlab.RequestOptionFunc
+			if keyset {
+				reqOpts = append(reqOpts, withGitlabKeyset(cursor))
+			}
+			page, resp, err = c.Projects.ListProjects(opts, reqOpts...)
+			if err != nil {
+				return found, resp, err
+			}
+			projects = append(projects, page...)
+
+			if keyset {
+				cursor = nextGitlabKeyset(resp)
+				if cursor == nil {
+					break
+				}
+				continue
+			}
+			if resp.NextPage == 0 {
+				break
+			}
+			opts.Page = resp.NextPage
+		}
+	} else {
+		// Topics can't be searched server side, so list the whole group tree and match locally
+		opts := &gitlab.ListGroupProjectsOptions{
+			ListOptions:      defaultListOpts,
+			IncludeSubgroups: gitlab.Bool(true),
+		}
+		for {
+			page, resp, err = c.Groups.ListGroupProjects(groupPath, opts)
+			if err != nil {
+				return found, resp, err
+			}
+			projects = append(projects, page...)
+
+			if resp.NextPage == 0 {
+				break
+			}
+			opts.Page = resp.NextPage
+		}
+	}
+
+	for _, p := range projects {
+		if groupPath != "" && !projectMatches(p, query) {
+			continue
+		}
+		found = append(found, FoundProject{
+			ID:       p.ID,
+			FullPath: p.PathWithNamespace,
+			SSHURL:   p.SSHURLToRepo,
+			HTTPURL:  p.HTTPURLToRepo,
+			WebURL:   p.WebURL,
+			Topics:   p.TagList,
+		})
+	}
+	return found, resp, err
+}
+
+func projectMatches(p *gitlab.Project, query string) bool {
+	q := strings.ToLower(query)
+	if strings.Contains(strings.ToLower(p.Name), q) || strings.Contains(strings.ToLower(p.Path), q) {
+		return true
+	}
+	for _, t := range p.TagList {
+		if strings.EqualFold(t, query) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,124 @@
+package githelpers
+
+import (
+	"github.com/xanzy/go-gitlab"
+)
+
+// VarOptions holds the settings of a CI/CD variable. EnvironmentScope only applies to project
+// variables and defaults to "*" when empty
+type VarOptions struct {
+	Masked           bool
+	Protected        bool
+	EnvironmentScope string
+	VariableType     gitlab.VariableTypeValue // Either "env_var" (the default) or "file"
+}
+
+// SetProjectVariable creates or updates the CI/CD variable called key on the GitRepo's GitLab project
+func (gr *GitRepo) SetProjectVariable(key, value string, opts VarOptions) (v *gitlab.ProjectVariable, resp *gitlab.Response, err error) {
+	c, err := gr.gitlabClient()
+	if err != nil {
+		return v, resp, err
+	}
+
+	pid, resp, err := gr.getGitlabProjectID(gr.SSHURL)
+	if err != nil {
+		return v, resp, err
+	}
+
+	var scope *string
+	if opts.EnvironmentScope != "" {
+		scope = &opts.EnvironmentScope
+	}
+	var varType *gitlab.VariableTypeValue
+	if opts.VariableType != "" {
+		varType = gitlab.VariableType(opts.VariableType)
+	}
+
+	_, resp, err = c.ProjectVariables.GetVariable(pid, key)
+	if err != nil && !gitlabNotFound(resp) {
+		return v, resp, err
+	}
+	if err != nil {
+		v, resp, err = c.ProjectVariables.CreateVariable(pid, &gitlab.CreateProjectVariableOptions{
+			Key:              &key,
+			Value:            &value,
+			VariableType:     varType,
+			Protected:        &opts.Protected,
+			Masked:           &opts.Masked,
+			EnvironmentScope: scope,
+		})
+		return v, resp, err
+	}
+
+	v, resp, err = c.ProjectVariables.UpdateVariable(pid, key, &gitlab.UpdateProjectVariableOptions{
+		Value:            &value,
+		VariableType:     varType,
+		Protected:        &opts.Protected,
+		Masked:           &opts.Masked,
+		EnvironmentScope: scope,
+	})
+	return v, resp, err
+}
+
+// RemoveProjectVariable deletes the CI/CD variable called key from the GitRepo's GitLab project
+func (gr *GitRepo) RemoveProjectVariable(key string) (resp *gitlab.Response, err error) {
+	c, err := gr.gitlabClient()
+	if err != nil {
+		return resp, err
+	}
+
+	pid, resp, err := gr.getGitlabProjectID(gr.SSHURL)
+	if err != nil {
+		return resp, err
+	}
+
+	resp, err = c.ProjectVariables.RemoveVariable(pid, key)
+	return resp, err
+}
+
+// SetGroupVariable creates or updates the CI/CD variable called key on the GitLab group at groupPath
+func (gr *GitRepo) SetGroupVariable(groupPath, key, value string, opts VarOptions) (v *gitlab.GroupVariable, resp *gitlab.Response, err error) {
+	c, err := gr.gitlabClient()
+	if err != nil {
+		return v, resp, err
+	}
+
+	var varType *gitlab.VariableTypeValue
+	if opts.VariableType != "" {
+		varType = gitlab.VariableType(opts.VariableType)
+	}
+
+	_, resp, err = c.GroupVariables.GetVariable(groupPath, key)
+	if err != nil && !gitlabNotFound(resp) {
+		return v, resp, err
+	}
+	if err != nil {
+		v, resp, err = c.GroupVariables.CreateVariable(groupPath, &gitlab.CreateGroupVariableOptions{
+			Key:          &key,
+			Value:        &value,
+			VariableType: varType,
+			Protected:    &opts.Protected,
+			Masked:       &opts.Masked,
+		})
+		return v, resp, err
+	}
+
+	v, resp, err = c.GroupVariables.UpdateVariable(groupPath, key, &gitlab.UpdateGroupVariableOptions{
+		Value:        &value,
+		VariableType: varType,
+		Protected:    &opts.Protected,
+		Masked:       &opts.Masked,
+	})
+	return v, resp, err
+}
+
+// RemoveGroupVariable deletes the CI/CD variable called key from the GitLab group at groupPath
+func (gr *GitRepo) RemoveGroupVariable(groupPath, key string) (resp *gitlab.Response, err error) {
+	c, err := gr.gitlabClient()
+	if err != nil {
+		return resp, err
+	}
+
+	resp, err = c.GroupVariables.RemoveVariable(groupPath, key)
+	return resp, err
+}
@@ -0,0 +1,99 @@
+package githelpers
+
+import (
+	"fmt"
+
+	"github.com/xanzy/go-gitlab"
+)
+
+// EnsureProjectWebhook registers a webhook for url on the GitRepo's GitLab project, or updates the
+// existing one if url is already registered. Events are named after the GitLab hook settings, e.g.
+// "push", "tag_push", "merge_requests", "issues", "note", "job", "pipeline", or "wiki_page"
+func (gr *GitRepo) EnsureProjectWebhook(url string, events []string, secretToken string) (hook *gitlab.ProjectHook, resp *gitlab.Response, err error) {
+	c, err := gr.gitlabClient()
+	if err != nil {
+		return hook, resp, err
+	}
+
+	opts, err := webhookOptions(url, events, secretToken)
+	if err != nil {
+		return hook, resp, err
+	}
+
+	pid, resp, err := gr.getGitlabProjectID(gr.SSHURL)
+	if err != nil {
+		return hook, resp, err
+	}
+
+	listOpts := gitlab.ListProjectHooksOptions(defaultListOpts)
+	for {
+		var hooks []*gitlab.ProjectHook
+		hooks, resp, err = c.Projects.ListProjectHooks(pid, &listOpts)
+		if err != nil {
+			return hook, resp, err
+		}
+
+		for _, h := range hooks {
+			if h.URL == url {
+				editOpts := gitlab.EditProjectHookOptions(*opts)
+				hook, resp, err = c.Projects.EditProjectHook(pid, h.ID, &editOpts)
+				return hook, resp, err
+			}
+		}
+
+		if resp.NextPage == 0 {
+			break
+		}
+		listOpts.Page = resp.NextPage
+	}
+
+	hook, resp, err = c.Projects.AddProjectHook(pid, opts)
+	return hook, resp, err
+}
+
+func webhookOptions(url string, events []string, secretToken string) (*gitlab.AddProjectHookOptions, error) {
+	// Every event is set explicitly so that updating a hook also turns off events no longer requested
+	opts := &gitlab.AddProjectHookOptions{
+		URL:                      &url,
+		Token:                    &secretToken,
+		EnableSSLVerification:    gitlab.Bool(true),
+		PushEvents:               gitlab.Bool(false),
+		TagPushEvents:            gitlab.Bool(false),
+		MergeRequestsEvents:      gitlab.Bool(false),
+		IssuesEvents:             gitlab.Bool(false),
+		ConfidentialIssuesEvents: gitlab.Bool(false),
+		NoteEvents:               gitlab.Bool(false),
+		ConfidentialNoteEvents:   gitlab.Bool(false),
+		JobEvents:                gitlab.Bool(false),
+		PipelineEvents:           gitlab.Bool(false),
+		WikiPageEvents:           gitlab.Bool(false),
+	}
+
+	for _, e := range events {
+		switch e {
+		case "push":
+			opts.PushEvents = gitlab.Bool(true)
+		case "tag_push":
+			opts.TagPushEvents = gitlab.Bool(true)
+		case "merge_requests":
+			opts.MergeRequestsEvents = gitlab.Bool(true)
+		case "issues":
+			opts.IssuesEvents = gitlab.Bool(true)
+		case "confidential_issues":
+			opts.ConfidentialIssuesEvents = gitlab.Bool(true)
+		case "note":
+			opts.NoteEvents = gitlab.Bool(true)
+		case "confidential_note":
+			opts.ConfidentialNoteEvents = gitlab.Bool(true)
+		case "job":
+			opts.JobEvents = gitlab.Bool(true)
+		case "pipeline":
+			opts.PipelineEvents = gitlab.Bool(true)
+		case "wiki_page":
+			opts.WikiPageEvents = gitlab.Bool(true)
+		default:
+			return opts, fmt.Errorf("unknown webhook event: %s", e)
+		}
+	}
+	return opts, nil
+}
@@ -1,13 +1,22 @@
 module github.com/tochukwuvictor/go-githelpers
 
-go 1.14
+go 1.16
 
 require (
+	code.gitea.io/sdk/gitea v0.13.2
+	github.com/go-git/go-billy/v5 v5.0.0
 	github.com/go-git/go-git/v5 v5.2.0
+	github.com/google/go-github/v32 v32.1.0
+	github.com/google/uuid v1.1.1
+	github.com/hashicorp/go-cleanhttp v0.5.1
 	github.com/hashicorp/go-hclog v0.15.0 // indirect
+	github.com/hashicorp/go-retryablehttp v0.6.4
 	github.com/imdario/mergo v0.3.11 // indirect
+	github.com/microsoft/azure-devops-go-api/azuredevops v1.0.0-b5
+	github.com/sergi/go-diff v1.1.0
 	github.com/xanzy/go-gitlab v0.39.0
 	golang.org/x/crypto v0.0.0-20201016220609-9e8e0b390897
-	golang.org/x/time v0.0.0-20200630173020-3af7569d3a1e // indirect
-
+	golang.org/x/oauth2 v0.0.0-20181106182150-f42d05182288
+	golang.org/x/time v0.0.0-20200630173020-3af7569d3a1e
+	gopkg.in/yaml.v3 v3.0.1
 )
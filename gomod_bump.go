@@ -0,0 +1,122 @@
+package githelpers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path"
+	"regexp"
+	"strings"
+)
+
+var (
+	// ErrModuleNotRequired is returned by BumpGoModule when no go.mod in the worktree requires the module
+	ErrModuleNotRequired = errors.New("no go.mod requires the module")
+)
+
+// BumpGoModule sets the version of modulePath in the require directives of every go.mod in the
+// GitRepo's worktree that requires it, runs go mod tidy next to each one it changed, and stages those
+// go.mod and go.sum files. version is a full module version, e.g. v1.4.2. Returns the go.mod files it
+// changed, or ErrModuleNotRequired when none requires the module. Needs the go tool, and a worktree
+// on disk for it to run in
+func (gr *GitRepo) BumpGoModule(ctx context.Context, modulePath, version string) (changed []string, err error) {
+	if !strings.HasPrefix(version, "v") {
+		return changed, fmt.Errorf("invalid module version %q, versions start with v", version)
+	}
+	files, err := gr.worktreeFiles("go.mod")
+	if err != nil {
+		return changed, err
+	}
+
+	required := false
+	fs := gr.worktreeFilesystem()
+	for _, f := range files {
+		data, err := readWorktreeFile(fs, f.Path)
+		if err != nil {
+			return changed, err
+		}
+		out, found := setGoModRequire(string(data), modulePath, version)
+		required = required || found
+		if out == string(data) {
+			continue
+		}
+
+		err = gr.WriteFile(f.Path, []byte(out), f.Mode.Perm())
+		if err != nil {
+			return changed, err
+		}
+		tidy := gr.Command("go", "mod", "tidy")
+		tidy.Dir = path.Dir(f.Path)
+		_, err = tidy.Run(ctx)
+		if err != nil {
+			return changed, err
+		}
+		err = gr.stageGoModFiles(path.Dir(f.Path))
+		if err != nil {
+			return changed, err
+		}
+		changed = append(changed, f.Path)
+	}
+
+	if !required {
+		return changed, fmt.Errorf("%s: %w", modulePath, ErrModuleNotRequired)
+	}
+	return changed, nil
+}
+
+// setGoModRequire sets the version of modulePath in the require directives of the go.mod contents,
+// keeping comments such as // indirect and the layout of the file
+func setGoModRequire(gomod, modulePath, version string) (out string, found bool) {
+	mod := regexp.QuoteMeta(modulePath)
+	single := regexp.MustCompile(`^(\s*require\s+"?` + mod + `"?\s+)(\S+)(.*)$`)
+	inBlock := regexp.MustCompile(`^(\s*"?` + mod + `"?\s+)(\S+)(.*)$`)
+
+	lines := strings.Split(gomod, "\n")
+	block := false
+	for i, line := range lines {
+		trimmed := strings.TrimSpace(line)
+		switch {
+		case block && strings.HasPrefix(trimmed, ")"):
+			block = false
+			continue
+		case strings.HasPrefix(trimmed, "require") && strings.HasSuffix(strings.TrimSpace(strings.TrimPrefix(trimmed, "require")), "("):
+			block = true
+			continue
+		}
+
+		re := single
+		if block {
+			re = inBlock
+		}
+		if m := re.FindStringSubmatch(line); m != nil {
+			found = true
+			lines[i] = m[1] + version + m[3]
+		}
+	}
+	return strings.Join(lines, "\n"), found
+}
+
+func (gr *GitRepo) stageGoModFiles(dir string) error {
+	gr.mu.Lock()
+	defer gr.mu.Unlock()
+
+	wt, err := gr.Repo.Worktree()
+	if err != nil {
+		return err
+	}
+	for _, name := range []string{"go.mod", "go.sum"} {
+		p := path.Join(dir, name)
+		exists, err := fileExists(wt.Filesystem, p)
+		if err != nil {
+			return err
+		}
+		if !exists {
+			continue
+		}
+		_, err = wt.Add(p)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
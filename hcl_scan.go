@@ -0,0 +1,352 @@
+package githelpers
+
+import (
+	"strconv"
+	"strings"
+)
+
+// A small, lenient reader of HCL's native syntax, just enough to find blocks, attributes, string
+// literals, and objects along with where they are in the source, so they can be edited in place
+// without reformatting the file. Expressions it doesn't need are skipped over
+
+const (
+	hclIdent   = 'i'
+	hclString  = 's'
+	hclNewline = 'n'
+	hclPunct   = 'p'
+	hclOther   = 'o'
+	hclObject  = '{'
+)
+
+type hclToken struct {
+	kind       byte
+	start, end int
+	template   bool // A string with ${ or %{ in it, which has no fixed value
+}
+
+// hclBlock is a block such as module "vpc" { ... }. open and close are the offsets of its braces
+type hclBlock struct {
+	typ         string
+	labels      []string
+	attrs       []*hclAttr
+	blocks      []*hclBlock
+	open, close int
+}
+
+type hclAttr struct {
+	name      string
+	nameStart int
+	value     *hclValue
+}
+
+// hclValue is an attribute's value. str is only set for string literals without templates, and
+// attrs for objects. start and end span the whole value, quotes and braces included
+type hclValue struct {
+	kind       byte
+	start, end int
+	str        string
+	attrs      []*hclAttr
+}
+
+func (b *hclBlock) attr(name string) *hclAttr {
+	return findHCLAttr(b.attrs, name)
+}
+
+func (v *hclValue) attr(name string) *hclAttr {
+	return findHCLAttr(v.attrs, name)
+}
+
+func findHCLAttr(attrs []*hclAttr, name string) *hclAttr {
+	for _, a := range attrs {
+		if a.name == name {
+			return a
+		}
+	}
+	return nil
+}
+
+// parseHCL returns the top level of src as the body of a block without type
+func parseHCL(src string) *hclBlock {
+	p := &hclParser{src: src, toks: scanHCL(src)}
+	root := &hclBlock{open: -1, close: len(src)}
+	p.body(root)
+	return root
+}
+
+type hclParser struct {
+	src  string
+	toks []hclToken
+	i    int
+}
+
+func (p *hclParser) peek(offset int) hclToken {
+	if p.i+offset >= len(p.toks) {
+		return hclToken{kind: 0, start: len(p.src), end: len(p.src)}
+	}
+	return p.toks[p.i+offset]
+}
+
+func (p *hclParser) text(t hclToken) string {
+	return p.src[t.start:t.end]
+}
+
+func (p *hclParser) isPunct(t hclToken, s string) bool {
+	return t.kind == hclPunct && p.text(t) == s
+}
+
+// body reads attributes and nested blocks into b until the closing brace of b, or the end of the file
+func (p *hclParser) body(b *hclBlock) {
+	for p.i < len(p.toks) {
+		t := p.peek(0)
+		switch {
+		case t.kind == hclNewline:
+			p.i++
+		case p.isPunct(t, "}"):
+			b.close = t.start
+			p.i++
+			return
+		case t.kind == hclIdent && p.isPunct(p.peek(1), "="):
+			a := &hclAttr{name: p.text(t), nameStart: t.start}
+			p.i += 2
+			a.value = p.value(false)
+			b.attrs = append(b.attrs, a)
+		case t.kind == hclIdent:
+			child := &hclBlock{typ: p.text(t), open: -1}
+			p.i++
+			for p.i < len(p.toks) {
+				l := p.peek(0)
+				if l.kind == hclString {
+					child.labels = append(child.labels, hclUnquote(p.text(l)))
+				} else if l.kind == hclIdent {
+					child.labels = append(child.labels, p.text(l))
+				} else {
+					break
+				}
+				p.i++
+			}
+			if !p.isPunct(p.peek(0), "{") {
+				p.skipLine()
+				continue
+			}
+			child.open = p.peek(0).start
+			p.i++
+			p.body(child)
+			b.blocks = append(b.blocks, child)
+		default:
+			p.skipLine()
+		}
+	}
+}
+
+// value reads an expression. Inside an object, commas end it as well as newlines
+func (p *hclParser) value(inObject bool) *hclValue {
+	t := p.peek(0)
+	if t.kind == hclString && p.ends(p.peek(1), inObject) {
+		p.i++
+		v := &hclValue{kind: hclString, start: t.start, end: t.end}
+		if !t.template {
+			v.str = hclUnquote(p.text(t))
+		}
+		return v
+	}
+	if p.isPunct(t, "{") {
+		start := p.i
+		v := p.object()
+		if p.ends(p.peek(0), inObject) {
+			return v
+		}
+		// An object that's only part of a bigger expression, such as merge({...}, var.x)
+		p.i = start
+	}
+
+	v := &hclValue{kind: hclOther, start: t.start, end: t.start}
+	depth := 0
+	for p.i < len(p.toks) {
+		t := p.peek(0)
+		if depth == 0 && p.ends(t, inObject) {
+			break
+		}
+		if t.kind == hclPunct {
+			switch p.text(t) {
+			case "{", "[", "(":
+				depth++
+			case "}", "]", ")":
+				depth--
+			}
+		}
+		v.end = t.end
+		p.i++
+	}
+	return v
+}
+
+func (p *hclParser) object() *hclValue {
+	v := &hclValue{kind: hclObject, start: p.peek(0).start}
+	p.i++
+	for p.i < len(p.toks) {
+		t := p.peek(0)
+		switch {
+		case t.kind == hclNewline || p.isPunct(t, ","):
+			p.i++
+		case p.isPunct(t, "}"):
+			v.end = t.end
+			p.i++
+			return v
+		case (t.kind == hclIdent || t.kind == hclString) && (p.isPunct(p.peek(1), "=") || p.isPunct(p.peek(1), ":")):
+			name := p.text(t)
+			if t.kind == hclString {
+				name = hclUnquote(name)
+			}
+			a := &hclAttr{name: name, nameStart: t.start}
+			p.i += 2
+			a.value = p.value(true)
+			v.attrs = append(v.attrs, a)
+		default:
+			// Not an object HCL knows, give up on it at the end of the file
+			p.i++
+		}
+	}
+	v.end = len(p.src)
+	return v
+}
+
+// ends reports whether t ends an expression
+func (p *hclParser) ends(t hclToken, inObject bool) bool {
+	switch {
+	case t.kind == 0, t.kind == hclNewline, p.isPunct(t, "}"):
+		return true
+	case inObject && p.isPunct(t, ","):
+		return true
+	}
+	return false
+}
+
+func (p *hclParser) skipLine() {
+	for p.i < len(p.toks) && p.peek(0).kind != hclNewline {
+		p.i++
+	}
+}
+
+func scanHCL(src string) (toks []hclToken) {
+	for i := 0; i < len(src); {
+		c := src[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\r':
+			i++
+		case c == '\n':
+			toks = append(toks, hclToken{kind: hclNewline, start: i, end: i + 1})
+			i++
+		case c == '#' || strings.HasPrefix(src[i:], "//"):
+			for i < len(src) && src[i] != '\n' {
+				i++
+			}
+		case strings.HasPrefix(src[i:], "/*"):
+			end := strings.Index(src[i+2:], "*/")
+			if end < 0 {
+				return toks
+			}
+			i += end + 4
+		case c == '"':
+			end, template := scanHCLString(src, i)
+			toks = append(toks, hclToken{kind: hclString, start: i, end: end, template: template})
+			i = end
+		case strings.HasPrefix(src[i:], "<<"):
+			end := scanHCLHeredoc(src, i)
+			toks = append(toks, hclToken{kind: hclOther, start: i, end: end})
+			i = end
+		case c == '_' || isASCIILetter(c):
+			end := i + 1
+			for end < len(src) && (src[end] == '_' || src[end] == '-' || isASCIILetter(src[end]) || (src[end] >= '0' && src[end] <= '9')) {
+				end++
+			}
+			toks = append(toks, hclToken{kind: hclIdent, start: i, end: end})
+			i = end
+		case c == '=' && i+1 < len(src) && (src[i+1] == '=' || src[i+1] == '>'):
+			toks = append(toks, hclToken{kind: hclOther, start: i, end: i + 2})
+			i += 2
+		case strings.IndexByte("{}[](),=:", c) >= 0:
+			toks = append(toks, hclToken{kind: hclPunct, start: i, end: i + 1})
+			i++
+		default:
+			toks = append(toks, hclToken{kind: hclOther, start: i, end: i + 1})
+			i++
+		}
+	}
+	return toks
+}
+
+// scanHCLString returns the offset just past the string starting at the quote at i, skipping over
+// the expressions of templates, which can hold strings of their own
+func scanHCLString(src string, i int) (end int, template bool) {
+	for j := i + 1; j < len(src); j++ {
+		switch src[j] {
+		case '\\':
+			j++
+		case '"', '\n':
+			return j + 1, template
+		case '$', '%':
+			if j+1 < len(src) && src[j+1] == '{' && (j == 0 || src[j-1] != src[j]) {
+				template = true
+				depth := 0
+				for j++; j < len(src); j++ {
+					if src[j] == '"' {
+						j, _ = scanHCLString(src, j)
+						j--
+					} else if src[j] == '{' {
+						depth++
+					} else if src[j] == '}' {
+						depth--
+						if depth == 0 {
+							break
+						}
+					}
+				}
+			}
+		}
+	}
+	return len(src), template
+}
+
+func scanHCLHeredoc(src string, i int) int {
+	j := i + 2
+	if j < len(src) && src[j] == '-' {
+		j++
+	}
+	nl := strings.IndexByte(src[j:], '\n')
+	if nl < 0 {
+		return len(src)
+	}
+	marker := strings.TrimSpace(src[j : j+nl])
+	for pos := j + nl + 1; pos < len(src); {
+		next := strings.IndexByte(src[pos:], '\n')
+		line := src[pos:]
+		if next >= 0 {
+			line = src[pos : pos+next]
+		}
+		if strings.TrimSpace(line) == marker {
+			return pos + len(line)
+		}
+		if next < 0 {
+			break
+		}
+		pos += next + 1
+	}
+	return len(src)
+}
+
+func isASCIILetter(c byte) bool {
+	return (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
+
+func hclUnquote(s string) string {
+	if u, err := strconv.Unquote(s); err == nil {
+		return u
+	}
+	return strings.Trim(s, `"`)
+}
+
+// hclQuote returns s as an HCL string literal
+func hclQuote(s string) string {
+	s = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`, "${", "$${", "%{", "%%{").Replace(s)
+	return `"` + s + `"`
+}
@@ -0,0 +1,75 @@
+package githelpers
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseHCL(t *testing.T) {
+	src := `# A comment with a { brace
+variable "name" {
+  default = "a \"quoted\" } value"
+}
+
+/* module "commented" {
+  source = "nowhere"
+} */
+module "app" {
+  source   = "./modules/app"
+  name     = "${var.name}-app"
+  count    = length(var.zones) // trailing comment {
+  policy   = <<-EOT
+    { "version": "2012-10-17" }
+  EOT
+  settings = {
+    size = 3, enabled = true
+  }
+
+  dynamic "rule" {
+    for_each = var.rules
+    content {
+      port = rule.value
+    }
+  }
+}
+`
+	root := parseHCL(src)
+
+	var blocks [][]string
+	for _, b := range root.blocks {
+		blocks = append(blocks, append([]string{b.typ}, b.labels...))
+	}
+	if want := [][]string{{"variable", "name"}, {"module", "app"}}; !reflect.DeepEqual(blocks, want) {
+		t.Fatalf("blocks %q, want %q", blocks, want)
+	}
+
+	if v := root.blocks[0].attr("default").value; v.kind != hclString || v.str != `a "quoted" } value` {
+		t.Errorf("default = %q", v.str)
+	}
+
+	app := root.blocks[1]
+	var names []string
+	for _, a := range app.attrs {
+		names = append(names, a.name)
+	}
+	if want := []string{"source", "name", "count", "policy", "settings"}; !reflect.DeepEqual(names, want) {
+		t.Errorf("attributes %q, want %q", names, want)
+	}
+	if v := app.attr("source").value; v.kind != hclString || v.str != "./modules/app" || src[v.start:v.end] != `"./modules/app"` {
+		t.Errorf("source = %q at %q", v.str, src[v.start:v.end])
+	}
+	if v := app.attr("name").value; v.str != "" {
+		t.Errorf("a template has no fixed value, got %q", v.str)
+	}
+	settings := app.attr("settings").value
+	if settings.kind != hclObject || settings.attr("size") == nil || settings.attr("enabled") == nil {
+		t.Errorf("settings = %q", src[settings.start:settings.end])
+	}
+
+	if len(app.blocks) != 1 || app.blocks[0].typ != "dynamic" || len(app.blocks[0].blocks) != 1 || app.blocks[0].blocks[0].typ != "content" {
+		t.Errorf("nested blocks of module app: %+v", app.blocks)
+	}
+	if src[app.open] != '{' || src[app.close] != '}' || app.close != len(src)-2 {
+		t.Errorf("module app's braces at %d and %d", app.open, app.close)
+	}
+}
@@ -0,0 +1,526 @@
+package githelpers
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/filemode"
+	"github.com/go-git/go-git/v5/plumbing/format/gitignore"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/go-git/go-git/v5/plumbing/storer"
+)
+
+const (
+	// DefaultFilterReplacement is what WithFilterReplaceText replaces matches with when given no replacement,
+	// as git filter-repo --replace-text does
+	DefaultFilterReplacement = "***REMOVED***"
+)
+
+var (
+	emptyTreeHash = plumbing.NewHash("4b825dc642cb6eb9a060e54bf8d69288fbee4904")
+)
+
+// FilterOption says what FilterHistory rewrites
+type FilterOption func(*filterConfig)
+
+type filterConfig struct {
+	remove  []gitignore.Pattern
+	replace []textReplacement
+	refs    []string
+}
+
+type textReplacement struct {
+	re   *regexp.Regexp
+	with string
+}
+
+// WithFilterRemovePaths removes the files and directories matching globs, in .gitignore syntax, from
+// every commit, e.g. "secrets.env" at any depth or "/config/prod.key" at the top only
+func WithFilterRemovePaths(globs ...string) FilterOption {
+	return func(cfg *filterConfig) {
+		cfg.remove = append(cfg.remove, parseGlobs(globs)...)
+	}
+}
+
+// WithFilterReplaceText replaces the matches of re in every text file of every commit with
+// replacement, which may refer to groups as $1, or with DefaultFilterReplacement when it's empty.
+// Binary files are left alone
+func WithFilterReplaceText(re *regexp.Regexp, replacement string) FilterOption {
+	if replacement == "" {
+		replacement = DefaultFilterReplacement
+	}
+	return func(cfg *filterConfig) {
+		cfg.replace = append(cfg.replace, textReplacement{re: re, with: replacement})
+	}
+}
+
+// WithFilterRefs rewrites the history of these branches and tags only, instead of every local branch and tag
+func WithFilterRefs(refs ...string) FilterOption {
+	return func(cfg *filterConfig) {
+		cfg.refs = append(cfg.refs, refs...)
+	}
+}
+
+// FilterResult is what FilterHistory rewrote
+type FilterResult struct {
+	Refs    []RewrittenRef                  // The refs that moved, by name
+	Commits map[plumbing.Hash]plumbing.Hash // Every commit that changed, to what replaced it, or the zero hash when it was dropped
+}
+
+// RewrittenRef is a ref FilterHistory moved. New is the zero hash when none of its commits were left and the ref was deleted
+type RewrittenRef struct {
+	Name plumbing.ReferenceName
+	Old  plumbing.Hash
+	New  plumbing.Hash
+}
+
+// RefNames returns the names of the refs that moved, to be pushed with ForcePush
+func (res *FilterResult) RefNames() (names []plumbing.ReferenceName) {
+	for _, r := range res.Refs {
+		names = append(names, r.Name)
+	}
+	return names
+}
+
+// FilterHistory rewrites the history of the GitRepo's branches and tags, removing paths and
+// replacing text in every commit, as a minimal git filter-repo, e.g. to get a leaked secret out of a
+// repo. Commits left empty by the rewrite are dropped, annotated tags are recreated, and signatures
+// are lost. The checked out branch's worktree is reset to its new tip, so it must be clean. Nothing
+// leaves the clone until the rewritten refs are pushed with ForcePush, and secrets stay readable
+// wherever else the old history was cloned or cached, so rotate them regardless
+func (gr *GitRepo) FilterHistory(opts ...FilterOption) (res *FilterResult, err error) {
+	var cfg filterConfig
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	if len(cfg.remove) == 0 && len(cfg.replace) == 0 {
+		return res, errors.New("no paths to remove nor text to replace")
+	}
+
+	gr.mu.Lock()
+	defer gr.mu.Unlock()
+
+	if gr.Worktree != nil {
+		status, err := gr.Worktree.Status()
+		if err != nil {
+			return res, err
+		}
+		if !status.IsClean() {
+			return res, ErrUncommittedChanges
+		}
+	}
+
+	refs, err := gr.filterRefs(cfg.refs)
+	if err != nil {
+		return res, err
+	}
+
+	f := &historyFilter{
+		s:       gr.Repo.Storer,
+		cfg:     cfg,
+		commits: map[plumbing.Hash]plumbing.Hash{},
+		trees:   map[string]plumbing.Hash{},
+		blobs:   map[plumbing.Hash]plumbing.Hash{},
+	}
+	res = &FilterResult{Commits: map[plumbing.Hash]plumbing.Hash{}}
+	for _, ref := range refs {
+		h, err := f.ref(ref.Hash())
+		if err != nil {
+			return res, fmt.Errorf("%s: %w", ref.Name(), err)
+		}
+		if h == ref.Hash() {
+			continue
+		}
+
+		if h.IsZero() {
+			err = gr.Repo.Storer.RemoveReference(ref.Name())
+		} else {
+			err = gr.Repo.Storer.SetReference(plumbing.NewHashReference(ref.Name(), h))
+		}
+		if err != nil {
+			return res, err
+		}
+		res.Refs = append(res.Refs, RewrittenRef{Name: ref.Name(), Old: ref.Hash(), New: h})
+	}
+	for old, h := range f.commits {
+		if old != h {
+			res.Commits[old] = h
+		}
+	}
+
+	if gr.Worktree == nil || len(res.Refs) == 0 {
+		return res, nil
+	}
+	head, err := gr.Repo.Head()
+	if err != nil {
+		return res, err
+	}
+	for _, r := range res.Refs {
+		if r.Name == head.Name() && !r.New.IsZero() {
+			err = gr.Worktree.Reset(&git.ResetOptions{Commit: r.New, Mode: git.HardReset})
+			break
+		}
+	}
+	return res, err
+}
+
+// filterRefs returns the refs named, as branches or tags, or every local branch and tag when none are
+func (gr *GitRepo) filterRefs(names []string) (refs []*plumbing.Reference, err error) {
+	if len(names) == 0 {
+		iter, err := gr.Repo.References()
+		if err != nil {
+			return refs, err
+		}
+		err = iter.ForEach(func(r *plumbing.Reference) error {
+			if r.Type() == plumbing.HashReference && (r.Name().IsBranch() || r.Name().IsTag()) {
+				refs = append(refs, r)
+			}
+			return nil
+		})
+		sort.Slice(refs, func(i, j int) bool { return refs[i].Name() < refs[j].Name() })
+		return refs, err
+	}
+
+	for _, name := range names {
+		var ref *plumbing.Reference
+		for _, full := range []plumbing.ReferenceName{
+			plumbing.ReferenceName(name),
+			plumbing.NewBranchReferenceName(name),
+			plumbing.NewTagReferenceName(name),
+		} {
+			ref, err = gr.Repo.Reference(full, true)
+			if err == nil && (full.IsBranch() || full.IsTag()) {
+				break
+			}
+			ref = nil
+		}
+		if ref == nil {
+			return refs, fmt.Errorf("%s: %w", name, plumbing.ErrReferenceNotFound)
+		}
+		refs = append(refs, ref)
+	}
+	return refs, nil
+}
+
+// historyFilter rewrites objects, remembering what each one became so shared history is rewritten once
+type historyFilter struct {
+	s   storer.EncodedObjectStorer
+	cfg filterConfig
+
+	commits map[plumbing.Hash]plumbing.Hash // Zero when the commit was dropped with no parent to stand in for it
+	trees   map[string]plumbing.Hash        // Keyed by dir and hash, as which paths are removed depends on where the tree is
+	blobs   map[plumbing.Hash]plumbing.Hash
+}
+
+// ref returns what the commit or annotated tag at h was rewritten to
+func (f *historyFilter) ref(h plumbing.Hash) (plumbing.Hash, error) {
+	tag, err := object.GetTag(f.s, h)
+	if err == plumbing.ErrObjectNotFound {
+		return f.commit(h)
+	}
+	if err != nil {
+		return h, err
+	}
+	if tag.TargetType != plumbing.CommitObject && tag.TargetType != plumbing.TagObject {
+		return h, nil
+	}
+
+	target, err := f.ref(tag.Target)
+	switch {
+	case err != nil:
+		return h, err
+	case target == tag.Target:
+		return h, nil
+	case target.IsZero():
+		return target, nil
+	}
+	rewritten := &object.Tag{
+		Name:       tag.Name,
+		Tagger:     tag.Tagger,
+		Message:    tag.Message,
+		TargetType: tag.TargetType,
+		Target:     target,
+	}
+	return storeObject(f.s, rewritten)
+}
+
+// commit returns what the commit at h was rewritten to, rewriting its ancestors first, parents before
+// children, with a stack of its own as histories are deeper than recursion allows
+func (f *historyFilter) commit(h plumbing.Hash) (plumbing.Hash, error) {
+	stack := []plumbing.Hash{h}
+	for len(stack) > 0 {
+		top := stack[len(stack)-1]
+		if _, done := f.commits[top]; done {
+			stack = stack[:len(stack)-1]
+			continue
+		}
+		c, err := object.GetCommit(f.s, top)
+		if err != nil {
+			return h, err
+		}
+
+		pending := false
+		for _, p := range c.ParentHashes {
+			if _, done := f.commits[p]; !done {
+				stack = append(stack, p)
+				pending = true
+			}
+		}
+		if pending {
+			continue
+		}
+
+		f.commits[top], err = f.rewriteCommit(c)
+		if err != nil {
+			return h, err
+		}
+		stack = stack[:len(stack)-1]
+	}
+	return f.commits[h], nil
+}
+
+func (f *historyFilter) rewriteCommit(c *object.Commit) (plumbing.Hash, error) {
+	var parents []plumbing.Hash
+	seen := map[plumbing.Hash]bool{}
+	changed := false
+	for _, p := range c.ParentHashes {
+		h := f.commits[p]
+		changed = changed || h != p
+		if h.IsZero() || seen[h] {
+			continue
+		}
+		seen[h] = true
+		parents = append(parents, h)
+	}
+
+	tree, err := f.tree(c.TreeHash, nil)
+	if err != nil {
+		return c.Hash, err
+	}
+	if !changed && tree == c.TreeHash {
+		return c.Hash, nil
+	}
+
+	if len(c.ParentHashes) > 1 {
+		parents, err = f.independent(parents)
+		if err != nil {
+			return c.Hash, err
+		}
+	}
+
+	if len(parents) <= 1 {
+		// Drop the commits whose only change was to what's been filtered out, and the merges left with
+		// a single side that brought nothing in
+		parentTree := emptyTreeHash
+		if len(parents) == 1 {
+			p, err := object.GetCommit(f.s, parents[0])
+			if err != nil {
+				return c.Hash, err
+			}
+			parentTree = p.TreeHash
+		}
+		if tree == parentTree && (len(c.ParentHashes) > 1 || !f.wasEmpty(c)) {
+			if len(parents) == 0 {
+				return plumbing.ZeroHash, nil
+			}
+			return parents[0], nil
+		}
+	}
+
+	rewritten := &object.Commit{
+		Author:       c.Author,
+		Committer:    c.Committer,
+		Message:      c.Message,
+		TreeHash:     tree,
+		ParentHashes: parents,
+	}
+	return storeObject(f.s, rewritten)
+}
+
+// independent returns the rewritten parents of a merge, in order, less those that are ancestors of
+// another, as they no longer bring anything to merge
+func (f *historyFilter) independent(parents []plumbing.Hash) (kept []plumbing.Hash, err error) {
+	commits := make([]*object.Commit, len(parents))
+	for i, h := range parents {
+		commits[i], err = object.GetCommit(f.s, h)
+		if err != nil {
+			return parents, err
+		}
+	}
+	for i, c := range commits {
+		ancestor := false
+		for j, other := range commits {
+			if i == j {
+				continue
+			}
+			ancestor, err = c.IsAncestor(other)
+			if err != nil {
+				return parents, err
+			}
+			if ancestor {
+				break
+			}
+		}
+		if !ancestor {
+			kept = append(kept, c.Hash)
+		}
+	}
+	return kept, nil
+}
+
+// wasEmpty reports whether c changed nothing to begin with, so it's kept, as git filter-repo does
+func (f *historyFilter) wasEmpty(c *object.Commit) bool {
+	if len(c.ParentHashes) == 0 {
+		return c.TreeHash == emptyTreeHash
+	}
+	p, err := object.GetCommit(f.s, c.ParentHashes[0])
+	return err == nil && p.TreeHash == c.TreeHash
+}
+
+// tree returns what the tree at h, found in dir, was rewritten to, or the zero hash when nothing is left of it
+func (f *historyFilter) tree(h plumbing.Hash, dir []string) (plumbing.Hash, error) {
+	key := strings.Join(dir, "/") + ":" + h.String()
+	if rewritten, ok := f.trees[key]; ok {
+		return rewritten, nil
+	}
+
+	t, err := object.GetTree(f.s, h)
+	if err != nil {
+		return h, err
+	}
+	var entries []object.TreeEntry
+	changed := false
+	for _, e := range t.Entries {
+		p := append(append([]string{}, dir...), e.Name)
+		if matchesAny(f.cfg.remove, p, e.Mode == filemode.Dir) {
+			changed = true
+			continue
+		}
+
+		rewritten := e.Hash
+		switch e.Mode {
+		case filemode.Dir:
+			rewritten, err = f.tree(e.Hash, p)
+		case filemode.Regular, filemode.Executable, filemode.Deprecated:
+			rewritten, err = f.blob(e.Hash)
+		}
+		if err != nil {
+			return h, err
+		}
+		if rewritten != e.Hash {
+			changed = true
+		}
+		if rewritten.IsZero() {
+			continue
+		}
+		entries = append(entries, object.TreeEntry{Name: e.Name, Mode: e.Mode, Hash: rewritten})
+	}
+
+	rewritten := h
+	switch {
+	case len(entries) == 0 && len(dir) > 0:
+		// Git has no empty directories
+		rewritten = plumbing.ZeroHash
+	case changed:
+		rewritten, err = storeObject(f.s, &object.Tree{Entries: entries})
+		if err != nil {
+			return h, err
+		}
+	}
+	f.trees[key] = rewritten
+	return rewritten, nil
+}
+
+// blob returns what the blob at h was rewritten to by the text replacements
+func (f *historyFilter) blob(h plumbing.Hash) (plumbing.Hash, error) {
+	if len(f.cfg.replace) == 0 {
+		return h, nil
+	}
+	if rewritten, ok := f.blobs[h]; ok {
+		return rewritten, nil
+	}
+
+	b, err := object.GetBlob(f.s, h)
+	if err != nil {
+		return h, err
+	}
+	r, err := b.Reader()
+	if err != nil {
+		return h, err
+	}
+	data, err := io.ReadAll(r)
+	r.Close()
+	if err != nil {
+		return h, err
+	}
+
+	rewritten := h
+	sniff := data
+	if len(sniff) > binarySniffLen {
+		sniff = sniff[:binarySniffLen]
+	}
+	if bytes.IndexByte(sniff, 0) < 0 {
+		out := data
+		for _, rep := range f.cfg.replace {
+			out = rep.re.ReplaceAll(out, []byte(rep.with))
+		}
+		if !bytes.Equal(out, data) {
+			rewritten, err = storeBlob(f.s, out)
+			if err != nil {
+				return h, err
+			}
+		}
+	}
+	f.blobs[h] = rewritten
+	return rewritten, nil
+}
+
+// storeBlob writes data to s as a blob
+func storeBlob(s storer.EncodedObjectStorer, data []byte) (plumbing.Hash, error) {
+	obj := s.NewEncodedObject()
+	obj.SetType(plumbing.BlobObject)
+	w, err := obj.Writer()
+	if err != nil {
+		return plumbing.ZeroHash, err
+	}
+	_, err = w.Write(data)
+	if err != nil {
+		return plumbing.ZeroHash, err
+	}
+	err = w.Close()
+	if err != nil {
+		return plumbing.ZeroHash, err
+	}
+	return s.SetEncodedObject(obj)
+}
+
+// storeObject writes a commit, tree, or tag to s
+func storeObject(s storer.EncodedObjectStorer, o interface {
+	Encode(plumbing.EncodedObject) error
+}) (plumbing.Hash, error) {
+	obj := s.NewEncodedObject()
+	err := o.Encode(obj)
+	if err != nil {
+		return plumbing.ZeroHash, err
+	}
+	return s.SetEncodedObject(obj)
+}
+
+// sortTreeEntries sorts entries the way git orders them in a tree, comparing directories as if their
+// names ended with a slash
+func sortTreeEntries(entries []object.TreeEntry) {
+	key := func(e object.TreeEntry) string {
+		if e.Mode == filemode.Dir {
+			return e.Name + "/"
+		}
+		return e.Name
+	}
+	sort.Slice(entries, func(i, j int) bool { return key(entries[i]) < key(entries[j]) })
+}
@@ -0,0 +1,60 @@
+package githelpers
+
+import (
+	"os/exec"
+	"testing"
+)
+
+func TestFilterHistoryMerges(t *testing.T) {
+	requireGit(t)
+	tests := []struct {
+		name      string
+		side      map[string]string
+		wantMerge bool
+	}{
+		{"side only had what's removed", map[string]string{"secrets.env": "TOKEN=123\n"}, false},
+		{"side had more", map[string]string{"secrets.env": "TOKEN=123\n", "config.yaml": "debug: true\n"}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gr := newTestRepo(t, map[string]string{"README.md": "hello\n"})
+			runGit(t, gr.Dir, "checkout", "--quiet", "-b", "side")
+			commitTestFiles(t, gr, "Add secrets", tt.side)
+			runGit(t, gr.Dir, "checkout", "--quiet", "master")
+			notes := commitTestFiles(t, gr, "Add notes", map[string]string{"notes.txt": "notes\n"})
+			runGit(t, gr.Dir, "merge", "--quiet", "--no-edit", "side")
+			merge := headCommit(t, gr)
+
+			res, err := gr.FilterHistory(WithFilterRemovePaths("secrets.env"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			head := headCommit(t, gr)
+			if _, err := head.File("secrets.env"); err == nil {
+				t.Error("secrets.env is still there")
+			}
+			if !tt.wantMerge {
+				if head.Hash != notes || res.Commits[merge.Hash] != notes {
+					t.Errorf("HEAD is %s %q, want the merge dropped for %s", head.Hash, head.Message, notes)
+				}
+				return
+			}
+			if len(head.ParentHashes) != 2 || head.ParentHashes[0] != notes {
+				t.Errorf("HEAD %q has parents %v, want a merge of %s", head.Message, head.ParentHashes, notes)
+			}
+			if _, err := head.File("config.yaml"); err != nil {
+				t.Errorf("config.yaml was lost: %v", err)
+			}
+		})
+	}
+}
+
+func runGit(t *testing.T, dir string, args ...string) {
+	t.Helper()
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		t.Fatalf("git %v: %v: %s", args, err, out)
+	}
+}
@@ -0,0 +1,48 @@
+package githelpers
+
+import (
+	"fmt"
+)
+
+// PrePushHook validates a GitRepo before its commits are pushed, e.g. by scanning for secrets, running
+// a linter, or checking the size of the diff. Returning an error aborts the push
+type PrePushHook func(gr *GitRepo) error
+
+// PrePushError is returned by Push when a pre-push hook rejects the push
+type PrePushError struct {
+	Hook int // Position of the failed hook in the order the hooks were added, starting at 1
+	Err  error
+}
+
+func (e *PrePushError) Error() string {
+	return fmt.Sprintf("pre-push hook %d: %s", e.Hook, e.Err)
+}
+
+func (e *PrePushError) Unwrap() error {
+	return e.Err
+}
+
+// AddPrePushHook adds hook to the hooks Push runs, in the order they were added, before it talks to
+// the remote. CommitAndPushAll pushes with Push, so a failing hook leaves its commit local
+func (gr *GitRepo) AddPrePushHook(hook PrePushHook) {
+	gr.mu.Lock()
+	defer gr.mu.Unlock()
+
+	gr.prePushHooks = append(gr.prePushHooks, hook)
+}
+
+// runPrePushHooks runs the hooks without holding gr.mu, since hooks are free to call the GitRepo's
+// locking methods
+func (gr *GitRepo) runPrePushHooks() error {
+	gr.mu.Lock()
+	hooks := append([]PrePushHook(nil), gr.prePushHooks...)
+	gr.mu.Unlock()
+
+	for i, hook := range hooks {
+		err := hook(gr)
+		if err != nil {
+			return &PrePushError{Hook: i + 1, Err: err}
+		}
+	}
+	return nil
+}
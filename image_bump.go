@@ -0,0 +1,120 @@
+package githelpers
+
+import (
+	"errors"
+	"fmt"
+
+	"gopkg.in/yaml.v3"
+)
+
+var (
+	// ErrImageNotFound is returned by the image bump helpers when no file references the image
+	ErrImageNotFound = errors.New("image not found")
+
+	// kustomizationFiles are the names kustomize looks for in a directory
+	kustomizationFiles = []string{"kustomization.yaml", "kustomization.yml", "Kustomization"}
+)
+
+type kustomization struct {
+	Images []struct {
+		Name   string `yaml:"name"`
+		NewTag string `yaml:"newTag"`
+		Digest string `yaml:"digest"`
+	} `yaml:"images"`
+}
+
+// BumpKustomizeImage sets the newTag of the image called name in the images list of every
+// kustomization file in the GitRepo's worktree, and returns the files it changed. Returns
+// ErrImageNotFound when no kustomization lists the image, and an error when one pins it to a
+// digest, which kustomize would use over the tag
+func (gr *GitRepo) BumpKustomizeImage(name, newTag string) (changed []string, err error) {
+	files, err := gr.worktreeFiles(kustomizationFiles...)
+	if err != nil {
+		return changed, err
+	}
+
+	found := false
+	fs := gr.worktreeFilesystem()
+	for _, f := range files {
+		data, err := readWorktreeFile(fs, f.Path)
+		if err != nil {
+			return changed, err
+		}
+		var k kustomization
+		err = yaml.Unmarshal(data, &k)
+		if err != nil {
+			return changed, fmt.Errorf("%s: %w", f.Path, err)
+		}
+
+		fileChanged := false
+		for i, img := range k.Images {
+			if img.Name != name {
+				continue
+			}
+			found = true
+			if img.Digest != "" {
+				return changed, fmt.Errorf("%s: image %s is pinned to digest %s", f.Path, name, img.Digest)
+			}
+			if img.NewTag == newTag {
+				continue
+			}
+			err = setYAMLFile(gr, f.Path, fmt.Sprintf("images[%d].newTag", i), newTag)
+			if err != nil {
+				return changed, err
+			}
+			fileChanged = true
+		}
+		if fileChanged {
+			changed = append(changed, f.Path)
+		}
+	}
+
+	if !found {
+		return changed, fmt.Errorf("%s: %w", name, ErrImageNotFound)
+	}
+	return changed, nil
+}
+
+// BumpHelmValuesImage sets the tag of the image at key in the Helm values file at path. key is a key
+// path as SetYAMLValue takes them, e.g. image or backend.image, pointing either to a mapping like
+// {repository: app, tag: "1.0"}, whose tag is set, or to a whole image reference like app:1.0, whose
+// tag, or digest, is replaced. Only the line with the tag changes, unless the mapping has no tag yet
+func (gr *GitRepo) BumpHelmValuesImage(path, key, newTag string) error {
+	data, err := readWorktreeFile(gr.worktreeFilesystem(), path)
+	if err != nil {
+		return err
+	}
+	steps, err := parseYAMLPath(key)
+	if err != nil {
+		return err
+	}
+
+	docs, err := decodeYAMLDocuments(data)
+	if err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+	_, node := findYAMLDocument(docs, key, steps)
+	if node == nil {
+		return fmt.Errorf("%s: %s: %w", path, key, ErrImageNotFound)
+	}
+	if node.Kind == yaml.AliasNode {
+		node = node.Alias
+	}
+
+	switch {
+	case node.Kind == yaml.MappingNode:
+		return setYAMLFile(gr, path, key+".tag", newTag)
+	case node.Kind == yaml.ScalarNode && node.Tag == "!!str" && node.Value != "":
+		return setYAMLFile(gr, path, key, replaceImageTag(node.Value, newTag))
+	case node.Kind == yaml.ScalarNode && node.Tag == "!!null":
+		return fmt.Errorf("%s: %s: %w", path, key, ErrImageNotFound)
+	}
+	return fmt.Errorf("%s: %s is neither an image mapping nor an image reference", path, key)
+}
+
+// replaceImageTag returns the image reference ref with its tag or digest replaced by tag, minding
+// registry ports, e.g. registry:5000/app:1.0
+func replaceImageTag(ref, tag string) string {
+	name, _, _ := splitImageRef(ref)
+	return name + ":" + tag
+}
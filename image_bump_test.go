@@ -0,0 +1,124 @@
+package githelpers
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/go-git/go-billy/v5/memfs"
+)
+
+const testKustomization = `apiVersion: kustomize.config.k8s.io/v1beta1
+kind: Kustomization
+namespace: payments
+
+resources:
+- deployment.yaml
+- service.yaml
+
+images:
+- name: registry.example.com/payments/api
+  newTag: 1.4.0 # release
+- name: registry.example.com/payments/worker
+  newName: registry.example.com/payments/worker-v2
+  newTag: "1.4.0"
+
+patches:
+- path: replicas.yaml
+  target:
+    kind: Deployment
+`
+
+const testValues = `# Default values for payments.
+replicaCount: 2
+
+image:
+  repository: registry.example.com/payments/api
+  pullPolicy: IfNotPresent
+  # Overrides the image tag whose default is the chart appVersion.
+  tag: "1.4.0"
+
+worker:
+  image: registry.example.com/payments/worker:1.4.0
+  resources:
+    limits: {cpu: 500m, memory: 256Mi}
+`
+
+func newTestFileRepo(t *testing.T, path, content string) *GitRepo {
+	t.Helper()
+	gr := &GitRepo{Filesystem: memfs.New()}
+	err := gr.WriteFile(path, []byte(content), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return gr
+}
+
+func readTestFile(t *testing.T, gr *GitRepo, path string) string {
+	t.Helper()
+	data, err := readWorktreeFile(gr.worktreeFilesystem(), path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+func TestBumpKustomizeImage(t *testing.T) {
+	tests := []struct {
+		image string
+		want  string
+	}{
+		{"registry.example.com/payments/api", "  newTag: 1.5.0 # release\n"},
+		{"registry.example.com/payments/worker", "  newTag: \"1.5.0\"\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.image, func(t *testing.T) {
+			gr := newTestFileRepo(t, "overlays/prod/kustomization.yaml", testKustomization)
+			changed, err := gr.BumpKustomizeImage(tt.image, "1.5.0")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(changed) != 1 || changed[0] != "overlays/prod/kustomization.yaml" {
+				t.Errorf("changed %v", changed)
+			}
+			assertOneLineChanged(t, testKustomization, readTestFile(t, gr, "overlays/prod/kustomization.yaml"), tt.want)
+		})
+	}
+}
+
+func TestBumpHelmValuesImage(t *testing.T) {
+	tests := []struct {
+		key  string
+		want string
+	}{
+		{"image", "  tag: \"1.5.0\"\n"},
+		{"worker.image", "  image: registry.example.com/payments/worker:1.5.0\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.key, func(t *testing.T) {
+			gr := newTestFileRepo(t, "values.yaml", testValues)
+			err := gr.BumpHelmValuesImage("values.yaml", tt.key, "1.5.0")
+			if err != nil {
+				t.Fatal(err)
+			}
+			assertOneLineChanged(t, testValues, readTestFile(t, gr, "values.yaml"), tt.want)
+		})
+	}
+}
+
+// assertOneLineChanged checks that got is before with exactly one line replaced by want
+func assertOneLineChanged(t *testing.T, before, got, want string) {
+	t.Helper()
+	oldLines, newLines := strings.SplitAfter(before, "\n"), strings.SplitAfter(got, "\n")
+	if len(oldLines) != len(newLines) {
+		t.Fatalf("line count changed from %d to %d:\n%s", len(oldLines), len(newLines), got)
+	}
+	var diff []string
+	for i := range oldLines {
+		if oldLines[i] != newLines[i] {
+			diff = append(diff, newLines[i])
+		}
+	}
+	if len(diff) != 1 || diff[0] != want {
+		t.Errorf("changed lines %q, want only %q", diff, want)
+	}
+}
@@ -0,0 +1,94 @@
+package githelpers
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"os"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/go-git/go-git/v5/plumbing/storer"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	// AppliedChangeKey is the key of the trailer a Fleet with a ChangeID records the change it applied in.
+	// Unlike Gerrit's Change-Id, which ChangeIDTrailer makes, the ID comes from the change's contents
+	AppliedChangeKey = "Applied-Change"
+
+	changeIDLength = 16
+)
+
+// ContentChangeID returns an ID for the change made of parts, e.g. the edits and settings a run applies,
+// derived from their contents, so the same change always gets the same ID and any difference another one
+func ContentChangeID(parts ...[]byte) string {
+	h := sha256.New()
+	for _, p := range parts {
+		// Length prefixed, so moving bytes from one part to the next changes the ID
+		var n [8]byte
+		binary.BigEndian.PutUint64(n[:], uint64(len(p)))
+		h.Write(n[:])
+		h.Write(p)
+	}
+	return hex.EncodeToString(h.Sum(nil))[:changeIDLength]
+}
+
+// AlreadyApplied reports whether a commit in the history of HEAD records changeID in an Applied-Change
+// trailer, i.e. whether the change was applied, and merged, on the checked out branch already. MRs
+// merged by squashing only keep the trailer when the squash commit keeps the commit messages
+func (gr *GitRepo) AlreadyApplied(changeID string) (applied bool, err error) {
+	return gr.changeApplied("HEAD", changeID)
+}
+
+// changeApplied reports whether a commit in the history of rev records changeID, stopping at the first
+func (gr *GitRepo) changeApplied(rev, changeID string) (applied bool, err error) {
+	from, err := gr.commitAt(rev)
+	if err != nil {
+		return applied, err
+	}
+	iter, err := gr.Repo.Log(&git.LogOptions{From: from.Hash})
+	if err != nil {
+		return applied, err
+	}
+	defer iter.Close()
+
+	err = iter.ForEach(func(c *object.Commit) error {
+		if hasTrailer(c.Message, AppliedChangeKey, changeID) {
+			applied = true
+			return storer.ErrStop
+		}
+		return nil
+	})
+	if errors.Is(err, storer.ErrStop) {
+		err = nil
+	}
+	return applied, err
+}
+
+// ChangeID returns the ID of the change the spec makes, derived from its edits, with the contents of
+// template files rather than their paths, its vars, and its commit message. Which repos it targets,
+// and how, are left out, so running the same change on more repos later keeps the ID
+func (s *RunSpec) ChangeID() (id string, err error) {
+	edits := append([]EditSpec(nil), s.Edits...)
+	for i, e := range edits {
+		if e.TemplateFile == "" {
+			continue
+		}
+		data, err := os.ReadFile(e.TemplateFile)
+		if err != nil {
+			return id, err
+		}
+		edits[i].Template, edits[i].TemplateFile = string(data), ""
+	}
+
+	change, err := yaml.Marshal(struct {
+		Edits []EditSpec
+		Vars  TemplateVars
+	}{edits, s.Vars})
+	if err != nil {
+		return id, err
+	}
+	return ContentChangeID(change, []byte(s.CommitMessage)), nil
+}
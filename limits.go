@@ -0,0 +1,204 @@
+package githelpers
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/go-git/go-git/v5/utils/diff"
+	"github.com/sergi/go-diff/diffmatchpatch"
+)
+
+const (
+	// LimitFiles is the ChangeLimitError kind for too many changed files
+	LimitFiles = "files"
+	// LimitLines is the ChangeLimitError kind for too many added and deleted lines
+	LimitLines = "lines"
+)
+
+// ChangeLimits are guardrails on the size and reach of a change, so a buggy edit can't propose an MR
+// touching thousands of files. Zero limits aren't enforced
+type ChangeLimits struct {
+	MaxFiles       int      `yaml:"max_files"`       // Most files a change may touch
+	MaxLines       int      `yaml:"max_lines"`       // Most lines a change may add and delete, together
+	ForbiddenPaths []string `yaml:"forbidden_paths"` // .gitignore style globs of files a change must not touch, e.g. ".gitlab-ci.yml"
+}
+
+// DiffStat is the number of lines added and deleted in one file. Binary files count no lines
+type DiffStat struct {
+	Path    string
+	Added   int
+	Deleted int
+}
+
+// ChangeLimitError is returned when a change has more files or lines than its ChangeLimits allow
+type ChangeLimitError struct {
+	Kind   string // LimitFiles or LimitLines
+	Max    int
+	Actual int
+}
+
+func (e *ChangeLimitError) Error() string {
+	return fmt.Sprintf("change touches %d %s, more than the limit of %d", e.Actual, e.Kind, e.Max)
+}
+
+// ForbiddenPathError is returned when a change touches files its ChangeLimits forbid
+type ForbiddenPathError struct {
+	Paths []string
+}
+
+func (e *ForbiddenPathError) Error() string {
+	return "change touches forbidden paths: " + strings.Join(e.Paths, ", ")
+}
+
+// Check returns a *ForbiddenPathError, or else a *ChangeLimitError, when stats break the limits
+func (l *ChangeLimits) Check(stats []DiffStat) error {
+	forbidden := parseGlobs(l.ForbiddenPaths)
+	var paths []string
+	lines := 0
+	for _, s := range stats {
+		if matchesAny(forbidden, strings.Split(s.Path, "/"), false) {
+			paths = append(paths, s.Path)
+		}
+		lines += s.Added + s.Deleted
+	}
+
+	switch {
+	case len(paths) > 0:
+		sort.Strings(paths)
+		return &ForbiddenPathError{Paths: paths}
+	case l.MaxFiles > 0 && len(stats) > l.MaxFiles:
+		return &ChangeLimitError{Kind: LimitFiles, Max: l.MaxFiles, Actual: len(stats)}
+	case l.MaxLines > 0 && lines > l.MaxLines:
+		return &ChangeLimitError{Kind: LimitLines, Max: l.MaxLines, Actual: lines}
+	}
+	return nil
+}
+
+// PrePushHook returns a hook for AddPrePushHook enforcing the limits on everything HEAD adds on top
+// of base, e.g. origin/main, rather than on a single commit
+func (l *ChangeLimits) PrePushHook(base string) PrePushHook {
+	return func(gr *GitRepo) error {
+		stats, err := gr.DiffStats(base, "HEAD")
+		if err != nil {
+			return err
+		}
+		return l.Check(stats)
+	}
+}
+
+// DiffStats returns the lines added and deleted per file between the trees of the base and head refs,
+// as git diff --numstat does. An empty head means HEAD
+func (gr *GitRepo) DiffStats(base, head string) (stats []DiffStat, err error) {
+	if head == "" {
+		head = "HEAD"
+	}
+	from, err := gr.commitAt(base)
+	if err != nil {
+		return stats, err
+	}
+	to, err := gr.commitAt(head)
+	if err != nil {
+		return stats, err
+	}
+
+	patch, err := from.Patch(to)
+	if err != nil {
+		return stats, err
+	}
+	for _, s := range patch.Stats() {
+		stats = append(stats, DiffStat{Path: s.Name, Added: s.Addition, Deleted: s.Deletion})
+	}
+	return stats, nil
+}
+
+// pendingDiffStats returns the DiffStats of the changes CommitAll would commit to files, comparing them
+// with the HEAD commit
+func pendingDiffStats(repo *git.Repository, files []pendingFile) (stats []DiffStat, err error) {
+	var tree *object.Tree
+	head, err := repo.Head()
+	switch {
+	case err == plumbing.ErrReferenceNotFound:
+		// Nothing committed yet, so every file is new
+	case err != nil:
+		return stats, err
+	default:
+		c, err := repo.CommitObject(head.Hash())
+		if err != nil {
+			return stats, err
+		}
+		tree, err = c.Tree()
+		if err != nil {
+			return stats, err
+		}
+	}
+
+	for _, f := range files {
+		old, oldBinary, err := headFileContents(tree, f.path)
+		if err != nil {
+			return stats, err
+		}
+		new, newBinary, err := pendingFileContents(f)
+		if err != nil {
+			return stats, err
+		}
+
+		stat := DiffStat{Path: f.path}
+		if !oldBinary && !newBinary {
+			stat.Added, stat.Deleted = countChangedLines(old, new)
+		}
+		stats = append(stats, stat)
+	}
+	sort.Slice(stats, func(i, j int) bool { return stats[i].Path < stats[j].Path })
+	return stats, nil
+}
+
+func headFileContents(tree *object.Tree, path string) (contents string, binary bool, err error) {
+	if tree == nil {
+		return "", false, nil
+	}
+	f, err := tree.File(path)
+	if err == object.ErrFileNotFound {
+		return "", false, nil
+	}
+	if err != nil {
+		return "", false, err
+	}
+	binary, err = f.IsBinary()
+	if err != nil || binary {
+		return "", binary, err
+	}
+	contents, err = f.Contents()
+	return contents, false, err
+}
+
+func pendingFileContents(f pendingFile) (contents string, binary bool, err error) {
+	if f.deleted || !f.regular {
+		return "", false, nil
+	}
+	binary, err = isBinaryFile(f)
+	if err != nil || binary {
+		return "", binary, err
+	}
+	data, err := f.contents()
+	return string(data), false, err
+}
+
+func countChangedLines(old, new string) (added, deleted int) {
+	for _, d := range diff.Do(old, new) {
+		n := strings.Count(d.Text, "\n")
+		if !strings.HasSuffix(d.Text, "\n") && d.Text != "" {
+			n++
+		}
+		switch d.Type {
+		case diffmatchpatch.DiffInsert:
+			added += n
+		case diffmatchpatch.DiffDelete:
+			deleted += n
+		}
+	}
+	return added, deleted
+}
@@ -0,0 +1,174 @@
+package githelpers
+
+import (
+	"strings"
+	"time"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/revlist"
+	"github.com/go-git/go-git/v5/plumbing/storer"
+)
+
+const (
+	// Same grace period as git's gc.pruneExpire default
+	defaultPruneExpire = 14 * 24 * time.Hour
+)
+
+// GCOption customizes what GC removes
+type GCOption func(*gcConfig)
+
+type gcConfig struct {
+	pruneExpire time.Duration
+}
+
+// WithGCPruneExpire removes unreachable loose objects older than d instead of two weeks. Zero removes them all
+func WithGCPruneExpire(d time.Duration) GCOption {
+	return func(cfg *gcConfig) {
+		cfg.pruneExpire = d
+	}
+}
+
+// Prune deletes the remote-tracking refs of origin whose branches no longer exist on it, as git remote
+// prune does, and returns the names of the refs it deleted
+func (gr *GitRepo) Prune() (pruned []string, err error) {
+	gr.mu.Lock()
+	defer gr.mu.Unlock()
+
+	remote, err := gr.Repo.Remote(gr.remoteName())
+	if err != nil {
+		return pruned, err
+	}
+
+	remoteRefs, err := remote.List(&git.ListOptions{Auth: gr.remoteAuth(gr.remoteName())})
+	if err != nil {
+		return pruned, err
+	}
+	live := map[plumbing.ReferenceName]bool{}
+	for _, ref := range remoteRefs {
+		if ref.Name().IsBranch() {
+			live[plumbing.NewRemoteReferenceName(gr.remoteName(), ref.Name().Short())] = true
+		}
+	}
+
+	prefix := plumbing.NewRemoteReferenceName(gr.remoteName(), "").String()
+	refs, err := gr.Repo.References()
+	if err != nil {
+		return pruned, err
+	}
+	var stale []plumbing.ReferenceName
+	err = refs.ForEach(func(ref *plumbing.Reference) error {
+		name := ref.Name()
+		// refs/remotes/origin/HEAD is symbolic and follows whatever the remote's default branch is
+		if ref.Type() == plumbing.HashReference && strings.HasPrefix(name.String(), prefix) && !live[name] {
+			stale = append(stale, name)
+		}
+		return nil
+	})
+	if err != nil {
+		return pruned, err
+	}
+
+	for _, name := range stale {
+		err = gr.Repo.Storer.RemoveReference(name)
+		if err != nil {
+			return pruned, err
+		}
+		pruned = append(pruned, name.String())
+	}
+	return pruned, nil
+}
+
+// Repack writes every object reachable from a ref into a single new packfile and deletes the old
+// packs, dropping any unreachable objects they held
+func (gr *GitRepo) Repack() error {
+	gr.mu.Lock()
+	defer gr.mu.Unlock()
+
+	return gr.Repo.RepackObjects(&git.RepackConfig{})
+}
+
+// GC keeps a long-lived clone from growing without bound, much like git gc: it repacks the repo,
+// deletes the loose objects that are now packed, and deletes unreachable loose objects older than
+// two weeks unless WithGCPruneExpire says otherwise
+func (gr *GitRepo) GC(opts ...GCOption) error {
+	cfg := gcConfig{pruneExpire: defaultPruneExpire}
+	for _, o := range opts {
+		o(&cfg)
+	}
+
+	gr.mu.Lock()
+	defer gr.mu.Unlock()
+
+	los, ok := gr.Repo.Storer.(storer.LooseObjectStorer)
+	if !ok {
+		return git.ErrLooseObjectsNotSupported
+	}
+
+	reachable, err := gr.reachableObjects()
+	if err != nil {
+		return err
+	}
+
+	err = gr.Repo.RepackObjects(&git.RepackConfig{})
+	if err != nil {
+		return err
+	}
+
+	var loose []plumbing.Hash
+	err = los.ForEachObjectHash(func(h plumbing.Hash) error {
+		loose = append(loose, h)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	expire := time.Now().Add(-cfg.pruneExpire)
+	for _, h := range loose {
+		if !reachable[h] {
+			t, err := los.LooseObjectTime(h)
+			if err != nil {
+				return err
+			}
+			if t.After(expire) {
+				continue
+			}
+		}
+
+		err = los.DeleteLooseObject(h)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (gr *GitRepo) reachableObjects() (reachable map[plumbing.Hash]bool, err error) {
+	refs, err := gr.Repo.Storer.IterReferences()
+	if err != nil {
+		return reachable, err
+	}
+
+	var tips []plumbing.Hash
+	err = refs.ForEach(func(ref *plumbing.Reference) error {
+		if ref.Type() == plumbing.HashReference {
+			tips = append(tips, ref.Hash())
+		}
+		return nil
+	})
+	if err != nil {
+		return reachable, err
+	}
+
+	hashes, err := revlist.Objects(gr.Repo.Storer, tips, nil)
+	if err != nil {
+		return reachable, err
+	}
+
+	reachable = make(map[plumbing.Hash]bool, len(hashes))
+	for _, h := range hashes {
+		reachable[h] = true
+	}
+	return reachable, nil
+}
@@ -0,0 +1,258 @@
+package githelpers
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/config"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/transport"
+	gitSSH "github.com/go-git/go-git/v5/plumbing/transport/ssh"
+)
+
+const (
+	// MirrorPolicyForce overwrites destination refs that diverged from the source, as git push --mirror does
+	MirrorPolicyForce = "force"
+	// MirrorPolicySkip leaves diverged destination refs alone and syncs the rest
+	MirrorPolicySkip = "skip"
+	// MirrorPolicyFail stops the sync without pushing anything when any destination ref diverged
+	MirrorPolicyFail = "fail"
+
+	defaultMirrorInterval = 5 * time.Minute
+	mirrorDestRemote      = "mirror-destination"
+)
+
+// MirrorConflictError is returned by a sync under MirrorPolicyFail when destination refs have commits
+// the source doesn't
+type MirrorConflictError struct {
+	Refs []string
+}
+
+func (e *MirrorConflictError) Error() string {
+	return fmt.Sprintf("destination refs diverged from the source: %s", strings.Join(e.Refs, ", "))
+}
+
+// MirrorSyncResult lists the destination refs a sync changed or left alone
+type MirrorSyncResult struct {
+	Updated []string
+	Deleted []string
+	Skipped []string // Diverged refs left alone under MirrorPolicySkip
+}
+
+// MirrorStats are the running totals of a Mirror, for exporting as metrics
+type MirrorStats struct {
+	Syncs        int
+	Failures     int
+	RefsUpdated  int
+	RefsDeleted  int
+	RefsSkipped  int
+	LastSync     time.Time // When the last sync finished, successful or not
+	LastSuccess  time.Time
+	LastDuration time.Duration
+	LastError    error
+}
+
+// Mirror keeps the branches and tags of a destination repo in sync with a source repo, e.g. a read-only
+// copy on another GitLab instance. The source is fetched into a CloneCache mirror, so each sync only
+// transfers new objects. Call Sync once, or Run to sync on an interval and whenever Trigger is called
+type Mirror struct {
+	SourceURL  string
+	DestURL    string
+	SourceAuth *gitSSH.PublicKeys
+	DestAuth   *gitSSH.PublicKeys
+	Cache      *CloneCache
+	Interval   time.Duration // Time between syncs in Run. Defaults to 5 minutes
+	Policy     string        // What to do with diverged destination refs. Defaults to MirrorPolicyForce
+	Prune      bool          // Deletes destination branches and tags the source no longer has
+
+	mu      sync.Mutex
+	syncMu  sync.Mutex
+	stats   MirrorStats
+	trigger chan struct{}
+}
+
+// NewMirror returns a Mirror from sourceURL to destURL that keeps its copy of the source in cache
+func NewMirror(sourceURL, destURL string, cache *CloneCache) *Mirror {
+	return &Mirror{
+		SourceURL: sourceURL,
+		DestURL:   destURL,
+		Cache:     cache,
+		Policy:    MirrorPolicyForce,
+	}
+}
+
+// Run syncs right away and then every Interval, or sooner when Trigger is called, until ctx is canceled.
+// Failed syncs are recorded in Stats and retried on the next tick rather than stopping Run
+func (m *Mirror) Run(ctx context.Context) error {
+	interval := m.Interval
+	if interval <= 0 {
+		interval = defaultMirrorInterval
+	}
+	trigger := m.triggerChan()
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		m.Sync()
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		case <-trigger:
+		}
+	}
+}
+
+// Trigger asks a running Run loop to sync now, e.g. from a push webhook. It never blocks, and triggers
+// arriving while a sync is pending are folded into that one
+func (m *Mirror) Trigger() {
+	select {
+	case m.triggerChan() <- struct{}{}:
+	default:
+	}
+}
+
+// Stats returns the Mirror's running totals
+func (m *Mirror) Stats() MirrorStats {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.stats
+}
+
+// Sync brings the destination up to date with the source once. Syncs of the same Mirror never overlap
+func (m *Mirror) Sync() (res MirrorSyncResult, err error) {
+	m.syncMu.Lock()
+	defer m.syncMu.Unlock()
+
+	start := time.Now()
+	res, err = m.sync()
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.stats.Syncs++
+	m.stats.RefsUpdated += len(res.Updated)
+	m.stats.RefsDeleted += len(res.Deleted)
+	m.stats.RefsSkipped += len(res.Skipped)
+	m.stats.LastSync = time.Now()
+	m.stats.LastDuration = m.stats.LastSync.Sub(start)
+	m.stats.LastError = err
+	if err != nil {
+		m.stats.Failures++
+	} else {
+		m.stats.LastSuccess = m.stats.LastSync
+	}
+	return res, err
+}
+
+func (m *Mirror) sync() (res MirrorSyncResult, err error) {
+	src, err := m.Cache.Update(m.SourceURL, m.SourceAuth)
+	if err != nil {
+		return res, fmt.Errorf("fetching %s: %w", m.SourceURL, err)
+	}
+
+	dest := git.NewRemote(src.Storer, &config.RemoteConfig{Name: mirrorDestRemote, URLs: []string{m.DestURL}})
+	destRefs, err := dest.List(&git.ListOptions{Auth: m.DestAuth})
+	if err != nil && err != transport.ErrEmptyRemoteRepository {
+		return res, fmt.Errorf("listing %s: %w", m.DestURL, err)
+	}
+	existing := map[plumbing.ReferenceName]plumbing.Hash{}
+	for _, r := range destRefs {
+		if r.Name().IsBranch() || r.Name().IsTag() {
+			existing[r.Name()] = r.Hash()
+		}
+	}
+
+	refs, err := src.References()
+	if err != nil {
+		return res, err
+	}
+	var specs []config.RefSpec
+	var conflicts []string
+	seen := map[plumbing.ReferenceName]bool{}
+	err = refs.ForEach(func(r *plumbing.Reference) error {
+		name := r.Name()
+		if r.Type() != plumbing.HashReference || !(name.IsBranch() || name.IsTag()) {
+			return nil
+		}
+		seen[name] = true
+
+		destHash, ok := existing[name]
+		switch {
+		case ok && destHash == r.Hash():
+			return nil
+		case ok && !m.fastForward(src, destHash, r.Hash(), name):
+			if m.Policy == MirrorPolicySkip || m.Policy == MirrorPolicyFail {
+				conflicts = append(conflicts, name.String())
+				return nil
+			}
+			specs = append(specs, config.RefSpec("+"+name+":"+name))
+		default:
+			specs = append(specs, config.RefSpec(name+":"+name))
+		}
+		res.Updated = append(res.Updated, name.String())
+		return nil
+	})
+	if err != nil {
+		return res, err
+	}
+
+	sort.Strings(conflicts)
+	if len(conflicts) > 0 && m.Policy == MirrorPolicyFail {
+		return MirrorSyncResult{}, &MirrorConflictError{Refs: conflicts}
+	}
+	res.Skipped = conflicts
+
+	if m.Prune {
+		for name := range existing {
+			if !seen[name] {
+				specs = append(specs, config.RefSpec(":"+name))
+				res.Deleted = append(res.Deleted, name.String())
+			}
+		}
+	}
+	sort.Strings(res.Updated)
+	sort.Strings(res.Deleted)
+
+	if len(specs) == 0 {
+		return res, nil
+	}
+	err = dest.Push(&git.PushOptions{RemoteName: mirrorDestRemote, RefSpecs: specs, Auth: m.DestAuth})
+	if err != nil && err != git.NoErrAlreadyUpToDate {
+		return MirrorSyncResult{Skipped: res.Skipped}, fmt.Errorf("pushing to %s: %w", m.DestURL, err)
+	}
+	return res, nil
+}
+
+func (m *Mirror) fastForward(src *git.Repository, from, to plumbing.Hash, name plumbing.ReferenceName) bool {
+	// Moving a tag is never a fast-forward, and a destination commit the source doesn't have means
+	// the destination has work of its own
+	if name.IsTag() {
+		return false
+	}
+	fromCommit, err := src.CommitObject(from)
+	if err != nil {
+		return false
+	}
+	toCommit, err := src.CommitObject(to)
+	if err != nil {
+		return false
+	}
+	ok, err := fromCommit.IsAncestor(toCommit)
+	return err == nil && ok
+}
+
+func (m *Mirror) triggerChan() chan struct{} {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.trigger == nil {
+		m.trigger = make(chan struct{}, 1)
+	}
+	return m.trigger
+}
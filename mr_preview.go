@@ -0,0 +1,141 @@
+package githelpers
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/xanzy/go-gitlab"
+)
+
+// MRPreview is what a merge request from one branch into another would contain
+type MRPreview struct {
+	Files []ChangedPath
+	Stats []DiffStat
+	Diff  string // Unified diff of every file, as git diff prints it
+}
+
+// PreviewMergeRequest returns the changes an MR from src into dest would show, so they can be logged
+// or gated on, e.g. with ChangeLimits.Check, before calling NewGitlabMergeRequest. Like GitLab, it
+// diffs src against its merge base with dest. The local refs are used when the clone has both
+// branches, preferring origin's dest and the local src, otherwise CompareRefs is, as it is for a
+// GitRepo without a clone
+func (gr *GitRepo) PreviewMergeRequest(src, dest string) (preview *MRPreview, resp *gitlab.Response, err error) {
+	if gr.Repo == nil {
+		return gr.previewGitlabMergeRequest(src, dest)
+	}
+	preview, err = gr.previewLocalMergeRequest(src, dest)
+	if !errors.Is(err, plumbing.ErrReferenceNotFound) {
+		return preview, resp, err
+	}
+	if _, clientErr := gr.gitlabClient(); clientErr != nil {
+		return preview, resp, err
+	}
+	return gr.previewGitlabMergeRequest(src, dest)
+}
+
+func (gr *GitRepo) previewLocalMergeRequest(src, dest string) (preview *MRPreview, err error) {
+	head, _, err := gr.branchCommit(src, false)
+	if err != nil {
+		return preview, err
+	}
+	target, _, err := gr.branchCommit(dest, true)
+	if err != nil {
+		return preview, err
+	}
+	bases, err := target.MergeBase(head)
+	if err != nil {
+		return preview, err
+	}
+	if len(bases) == 0 {
+		return preview, fmt.Errorf("%s and %s have no common history", src, dest)
+	}
+	base := bases[0]
+
+	preview = &MRPreview{}
+	preview.Files, err = gr.ChangedPaths(base.Hash.String(), head.Hash.String())
+	if err != nil {
+		return preview, err
+	}
+	patch, err := base.Patch(head)
+	if err != nil {
+		return preview, err
+	}
+	for _, s := range patch.Stats() {
+		preview.Stats = append(preview.Stats, DiffStat{Path: s.Name, Added: s.Addition, Deleted: s.Deletion})
+	}
+	preview.Diff = patch.String()
+	return preview, nil
+}
+
+func (gr *GitRepo) previewGitlabMergeRequest(src, dest string) (preview *MRPreview, resp *gitlab.Response, err error) {
+	cmp, resp, err := gr.CompareRefs(dest, src)
+	if err != nil {
+		return preview, resp, err
+	}
+
+	preview = &MRPreview{}
+	var diff strings.Builder
+	for _, d := range cmp.Diffs {
+		preview.Files = append(preview.Files, gitlabChangedPath(d))
+		preview.Stats = append(preview.Stats, gitlabDiffStat(d))
+		writeGitlabDiff(&diff, d)
+	}
+	sort.Slice(preview.Files, func(i, j int) bool { return preview.Files[i].Path < preview.Files[j].Path })
+	sort.Slice(preview.Stats, func(i, j int) bool { return preview.Stats[i].Path < preview.Stats[j].Path })
+	preview.Diff = diff.String()
+	return preview, resp, nil
+}
+
+func gitlabChangedPath(d *gitlab.Diff) ChangedPath {
+	switch {
+	case d.NewFile:
+		return ChangedPath{Type: ChangeAdded, Path: d.NewPath}
+	case d.DeletedFile:
+		return ChangedPath{Type: ChangeDeleted, Path: d.OldPath}
+	case d.RenamedFile:
+		return ChangedPath{Type: ChangeRenamed, Path: d.NewPath, OldPath: d.OldPath}
+	}
+	return ChangedPath{Type: ChangeModified, Path: d.NewPath}
+}
+
+func gitlabDiffStat(d *gitlab.Diff) DiffStat {
+	s := DiffStat{Path: d.NewPath}
+	for _, line := range strings.Split(d.Diff, "\n") {
+		switch {
+		case strings.HasPrefix(line, "+"):
+			s.Added++
+		case strings.HasPrefix(line, "-"):
+			s.Deleted++
+		}
+	}
+	return s
+}
+
+// writeGitlabDiff adds the git diff headers GitLab leaves out of a file's diff, which starts at the first hunk
+func writeGitlabDiff(b *strings.Builder, d *gitlab.Diff) {
+	from, to := "a/"+d.OldPath, "b/"+d.NewPath
+	fmt.Fprintf(b, "diff --git %s %s\n", from, to)
+	switch {
+	case d.NewFile:
+		fmt.Fprintf(b, "new file mode %s\n", d.BMode)
+		from = "/dev/null"
+	case d.DeletedFile:
+		fmt.Fprintf(b, "deleted file mode %s\n", d.AMode)
+		to = "/dev/null"
+	case d.AMode != d.BMode:
+		fmt.Fprintf(b, "old mode %s\nnew mode %s\n", d.AMode, d.BMode)
+	}
+	if d.RenamedFile {
+		fmt.Fprintf(b, "rename from %s\nrename to %s\n", d.OldPath, d.NewPath)
+	}
+	if d.Diff == "" {
+		return
+	}
+	fmt.Fprintf(b, "--- %s\n+++ %s\n%s", from, to, d.Diff)
+	if !strings.HasSuffix(d.Diff, "\n") {
+		b.WriteString("\n")
+	}
+}
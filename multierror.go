@@ -0,0 +1,85 @@
+package githelpers
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ItemError is the error a batch operation hit on one of its items, e.g. a file or a repo URL
+type ItemError struct {
+	Item string
+	Err  error
+}
+
+func (e *ItemError) Error() string {
+	return fmt.Sprintf("%s: %v", e.Item, e.Err)
+}
+
+// Unwrap returns the item's error, so errors.Is and errors.As see through an ItemError
+func (e *ItemError) Unwrap() error {
+	return e.Err
+}
+
+// MultiError collects the errors of a batch operation, one ItemError per failed item, in the order they
+// were added. errors.Is and errors.As match a MultiError when they match any of its errors. A MultiError
+// isn't safe for concurrent use, so goroutines adding to the same one need their own lock
+type MultiError struct {
+	Errors []*ItemError
+}
+
+// Add records err against item. A nil err is ignored, so Add can be called with every item's result
+func (m *MultiError) Add(item string, err error) {
+	if err != nil {
+		m.Errors = append(m.Errors, &ItemError{Item: item, Err: err})
+	}
+}
+
+// ErrorOrNil returns m as an error if it holds any errors, and nil otherwise. Return this rather than m
+// itself so a batch with no failures doesn't return a non-nil error interface
+func (m *MultiError) ErrorOrNil() error {
+	if m == nil || len(m.Errors) == 0 {
+		return nil
+	}
+	return m
+}
+
+// Items returns the items that failed, in the order they were added
+func (m *MultiError) Items() (items []string) {
+	for _, e := range m.Errors {
+		items = append(items, e.Item)
+	}
+	return items
+}
+
+func (m *MultiError) Error() string {
+	if len(m.Errors) == 1 {
+		return m.Errors[0].Error()
+	}
+
+	reasons := make([]string, len(m.Errors))
+	for i, e := range m.Errors {
+		reasons[i] = e.Error()
+	}
+	return fmt.Sprintf("%d errors: %s", len(m.Errors), strings.Join(reasons, "; "))
+}
+
+// Is reports whether any of the errors matches target
+func (m *MultiError) Is(target error) bool {
+	for _, e := range m.Errors {
+		if errors.Is(e, target) {
+			return true
+		}
+	}
+	return false
+}
+
+// As finds the first of the errors that matches target, and if one does, sets target to it
+func (m *MultiError) As(target interface{}) bool {
+	for _, e := range m.Errors {
+		if errors.As(e, target) {
+			return true
+		}
+	}
+	return false
+}
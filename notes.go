@@ -0,0 +1,318 @@
+package githelpers
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/config"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/filemode"
+	"github.com/go-git/go-git/v5/plumbing/object"
+)
+
+const (
+	// DefaultNotesRef is where git notes keeps notes unless told otherwise
+	DefaultNotesRef = "refs/notes/commits"
+)
+
+var (
+	// ErrNoteNotFound is returned when the commit has no note
+	ErrNoteNotFound = errors.New("commit has no note")
+)
+
+// NotesOption picks the notes ref the notes helpers work on
+type NotesOption func(*notesConfig)
+
+type notesConfig struct {
+	ref plumbing.ReferenceName
+}
+
+// WithNotesRef works on the notes under ref, e.g. refs/notes/ci or just ci, instead of refs/notes/commits.
+// Keeping each tool's notes under a ref of its own keeps them from mixing with the others
+func WithNotesRef(ref string) NotesOption {
+	return func(cfg *notesConfig) {
+		if !strings.HasPrefix(ref, "refs/") {
+			ref = "refs/notes/" + ref
+		}
+		cfg.ref = plumbing.ReferenceName(ref)
+	}
+}
+
+func newNotesConfig(opts []NotesOption) (cfg notesConfig) {
+	cfg.ref = DefaultNotesRef
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	return cfg
+}
+
+// Note returns the note attached to the commit at rev, without its final newline, or ErrNoteNotFound
+func (gr *GitRepo) Note(rev string, opts ...NotesOption) (note string, err error) {
+	cfg := newNotesConfig(opts)
+
+	gr.mu.Lock()
+	defer gr.mu.Unlock()
+
+	c, err := gr.commitAt(rev)
+	if err != nil {
+		return note, err
+	}
+	tree, err := gr.notesTree(cfg.ref)
+	if err != nil || tree == nil {
+		return note, noteNotFound(rev, err)
+	}
+
+	note, found, err := gr.readNote(tree, c.Hash.String())
+	if err == nil && !found {
+		err = noteNotFound(rev, nil)
+	}
+	return note, err
+}
+
+// readNote returns the note in tree for the commit with hex hash, without its final newline
+func (gr *GitRepo) readNote(tree *object.Tree, hex string) (note string, found bool, err error) {
+	h, ok := findNote(tree, hex)
+	if !ok {
+		return note, false, nil
+	}
+	b, err := gr.Repo.BlobObject(h)
+	if err != nil {
+		return note, true, err
+	}
+	r, err := b.Reader()
+	if err != nil {
+		return note, true, err
+	}
+	defer r.Close()
+	data, err := io.ReadAll(r)
+	return strings.TrimSuffix(string(data), "\n"), true, err
+}
+
+// SetNote attaches note to the commit at rev, replacing any note it had, as git notes add -f does
+func (gr *GitRepo) SetNote(rev, note string, opts ...NotesOption) error {
+	return gr.editNote(rev, newNotesConfig(opts), func(old string, found bool) (string, bool) {
+		return note, true
+	})
+}
+
+// AppendNote adds note to the end of the commit's note, a blank line apart, or attaches it when the
+// commit has none yet, as git notes append does
+func (gr *GitRepo) AppendNote(rev, note string, opts ...NotesOption) error {
+	return gr.editNote(rev, newNotesConfig(opts), func(old string, found bool) (string, bool) {
+		if !found || strings.TrimSpace(old) == "" {
+			return note, true
+		}
+		return strings.TrimRight(old, "\n") + "\n\n" + note, true
+	})
+}
+
+// RemoveNote removes the note of the commit at rev, or returns ErrNoteNotFound when it has none
+func (gr *GitRepo) RemoveNote(rev string, opts ...NotesOption) error {
+	return gr.editNote(rev, newNotesConfig(opts), func(old string, found bool) (string, bool) {
+		return "", false
+	})
+}
+
+// PushNotes pushes the notes ref to the same ref on the default remote, overwriting it, so notes
+// other clones pushed in the meantime should be fetched with FetchNotes and written again first
+func (gr *GitRepo) PushNotes(opts ...NotesOption) error {
+	cfg := newNotesConfig(opts)
+
+	gr.mu.Lock()
+	defer gr.mu.Unlock()
+
+	err := gr.Repo.Push(&git.PushOptions{
+		Auth:       gr.remoteAuth(gr.remoteName()),
+		RemoteName: gr.remoteName(),
+		RefSpecs:   []config.RefSpec{config.RefSpec("+" + cfg.ref + ":" + cfg.ref)},
+	})
+	if err != nil && err != git.NoErrAlreadyUpToDate {
+		return err
+	}
+	return nil
+}
+
+// FetchNotes fetches the notes ref from the default remote, which clones leave out, replacing the
+// local one, so notes written locally and not pushed yet are lost
+func (gr *GitRepo) FetchNotes(opts ...NotesOption) error {
+	cfg := newNotesConfig(opts)
+
+	gr.mu.Lock()
+	defer gr.mu.Unlock()
+
+	err := gr.Repo.Fetch(&git.FetchOptions{
+		Auth:       gr.remoteAuth(gr.remoteName()),
+		RemoteName: gr.remoteName(),
+		RefSpecs:   []config.RefSpec{config.RefSpec("+" + cfg.ref + ":" + cfg.ref)},
+		Tags:       git.NoTags,
+	})
+	if err != nil && err != git.NoErrAlreadyUpToDate {
+		return err
+	}
+	return nil
+}
+
+// editNote replaces the note of the commit at rev with what edit returns for the current one, or
+// removes it when edit returns false, and commits the result on the notes ref
+func (gr *GitRepo) editNote(rev string, cfg notesConfig, edit func(old string, found bool) (string, bool)) error {
+	// The current note is read under the lock too, so concurrent edits, e.g. appends, build on each other
+	gr.mu.Lock()
+	defer gr.mu.Unlock()
+
+	c, err := gr.commitAt(rev)
+	if err != nil {
+		return err
+	}
+
+	tree, err := gr.notesTree(cfg.ref)
+	if err != nil {
+		return err
+	}
+	if tree == nil {
+		tree = &object.Tree{}
+	}
+	old, found, err := gr.readNote(tree, c.Hash.String())
+	if err != nil {
+		return err
+	}
+	note, keep := edit(old, found)
+	if !keep && !found {
+		return noteNotFound(rev, nil)
+	}
+
+	var blob plumbing.Hash
+	verb := "removed"
+	if keep {
+		verb = "added"
+		// Notes end with a newline, as git writes them
+		blob, err = storeBlob(gr.Repo.Storer, []byte(strings.TrimRight(note, "\n")+"\n"))
+		if err != nil {
+			return err
+		}
+	}
+	root, err := gr.setNoteEntry(tree, c.Hash.String(), blob)
+	if err != nil {
+		return err
+	}
+	return gr.commitNotes(cfg.ref, root, fmt.Sprintf("Notes %s by githelpers", verb))
+}
+
+// commitNotes commits tree on top of the notes ref
+func (gr *GitRepo) commitNotes(ref plumbing.ReferenceName, tree plumbing.Hash, msg string) error {
+	name, email, err := commitAuthor(gr.Repo)
+	if err != nil {
+		return err
+	}
+	sig := object.Signature{Name: name, Email: email, When: time.Now()}
+	c := &object.Commit{Author: sig, Committer: sig, Message: msg, TreeHash: tree}
+
+	tip, err := gr.Repo.Reference(ref, true)
+	switch {
+	case err == nil:
+		c.ParentHashes = []plumbing.Hash{tip.Hash()}
+	case err != plumbing.ErrReferenceNotFound:
+		return err
+	}
+
+	h, err := storeObject(gr.Repo.Storer, c)
+	if err != nil {
+		return err
+	}
+	return gr.Repo.Storer.SetReference(plumbing.NewHashReference(ref, h))
+}
+
+// notesTree returns the tree of the notes ref, or nil when there are no notes yet
+func (gr *GitRepo) notesTree(ref plumbing.ReferenceName) (*object.Tree, error) {
+	tip, err := gr.Repo.Reference(ref, true)
+	if err == plumbing.ErrReferenceNotFound {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	c, err := gr.Repo.CommitObject(tip.Hash())
+	if err != nil {
+		return nil, err
+	}
+	return c.Tree()
+}
+
+// findNote finds the note for the commit with the given hex hash, in a notes tree that may fan
+// notes out into subtrees named after the first bytes of the hash, e.g. ab/cdef...
+func findNote(tree *object.Tree, hex string) (plumbing.Hash, bool) {
+	for _, e := range tree.Entries {
+		switch {
+		case e.Name == hex && e.Mode != filemode.Dir:
+			return e.Hash, true
+		case e.Mode == filemode.Dir && len(e.Name) == 2 && strings.HasPrefix(hex, e.Name):
+			sub, err := tree.Tree(e.Name)
+			if err != nil {
+				continue
+			}
+			if h, ok := findNote(sub, hex[2:]); ok {
+				return h, true
+			}
+		}
+	}
+	return plumbing.ZeroHash, false
+}
+
+// setNoteEntry returns tree with the note for the commit with hex hash set to blob, or removed when
+// blob is zero. Existing notes are removed wherever they're fanned out to, and new ones are added at
+// the top, which git reads just as well
+func (gr *GitRepo) setNoteEntry(tree *object.Tree, hex string, blob plumbing.Hash) (plumbing.Hash, error) {
+	fanned, err := gr.removeFannedNote(tree, hex)
+	if err != nil {
+		return plumbing.ZeroHash, err
+	}
+
+	var entries []object.TreeEntry
+	for _, e := range fanned.Entries {
+		if e.Name != hex {
+			entries = append(entries, e)
+		}
+	}
+	if !blob.IsZero() {
+		entries = append(entries, object.TreeEntry{Name: hex, Mode: filemode.Regular, Hash: blob})
+	}
+	sortTreeEntries(entries)
+	return storeObject(gr.Repo.Storer, &object.Tree{Entries: entries})
+}
+
+// removeFannedNote returns tree without the note for hex in its fan out subtrees
+func (gr *GitRepo) removeFannedNote(tree *object.Tree, hex string) (*object.Tree, error) {
+	out := &object.Tree{}
+	for _, e := range tree.Entries {
+		if e.Mode != filemode.Dir || len(e.Name) != 2 || !strings.HasPrefix(hex, e.Name) {
+			out.Entries = append(out.Entries, e)
+			continue
+		}
+		sub, err := tree.Tree(e.Name)
+		if err != nil {
+			return nil, err
+		}
+		h, err := gr.setNoteEntry(sub, hex[2:], plumbing.ZeroHash)
+		if err != nil {
+			return nil, err
+		}
+		subTree, err := gr.Repo.TreeObject(h)
+		if err != nil {
+			return nil, err
+		}
+		if len(subTree.Entries) > 0 {
+			out.Entries = append(out.Entries, object.TreeEntry{Name: e.Name, Mode: e.Mode, Hash: h})
+		}
+	}
+	return out, nil
+}
+
+func noteNotFound(rev string, err error) error {
+	if err != nil {
+		return err
+	}
+	return fmt.Errorf("%s: %w", rev, ErrNoteNotFound)
+}
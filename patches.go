@@ -0,0 +1,699 @@
+package githelpers
+
+import (
+	"bytes"
+	"compress/zlib"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/mail"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/filemode"
+	"github.com/go-git/go-git/v5/plumbing/format/diff"
+	"github.com/go-git/go-git/v5/plumbing/object"
+)
+
+const (
+	// The fixed date git format-patch puts on the mbox From line
+	mboxFromDate = "Mon Sep 17 00:00:00 2001"
+	base85Chars  = "0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz!#$%&()*+-;<=>?@^_`{|}~"
+)
+
+var (
+	// ErrPatchDoesNotApply is wrapped by the errors ApplyPatch returns when a hunk doesn't match the worktree
+	ErrPatchDoesNotApply = errors.New("patch does not apply")
+
+	mboxFromPattern     = regexp.MustCompile(`(?m)^From [0-9a-f]{40} `)
+	patchSubjectPattern = regexp.MustCompile(`^\[PATCH[^\]]*\]\s*`)
+	hunkHeaderPattern   = regexp.MustCompile(`^@@ -(\d+)(?:,(\d+))? \+(\d+)(?:,(\d+))? @@`)
+)
+
+type filePatch struct {
+	oldPath, newPath string
+	oldMode, newMode filemode.FileMode
+	binary           []byte // New content of a GIT binary patch
+	isBinary         bool
+	hunks            []patchHunk
+}
+
+type patchHunk struct {
+	oldStart         int
+	oldLeft, newLeft int      // Lines still to be read while parsing
+	lines            []string // Each with its ' ', '-', or '+' prefix and its newline, if it has one
+}
+
+// ExportPatches returns the commits reachable from head but not from base as an mbox of patches, oldest
+// first, in the format git format-patch writes. git am and ApplyPatch can apply it to another repo or fork.
+// Merge commits are left out, as format-patch does, and binary files are written as GIT binary patches
+func (gr *GitRepo) ExportPatches(base, head string) (mbox []byte, err error) {
+	commits, err := gr.commitsBetween(base, head)
+	if err != nil {
+		return mbox, err
+	}
+
+	var series []*object.Commit
+	for i := len(commits) - 1; i >= 0; i-- {
+		if commits[i].NumParents() <= 1 {
+			series = append(series, commits[i])
+		}
+	}
+
+	var b bytes.Buffer
+	for i, c := range series {
+		err = gr.writeMboxPatch(&b, c, i+1, len(series))
+		if err != nil {
+			return mbox, err
+		}
+	}
+	return b.Bytes(), nil
+}
+
+// ApplyPatch applies an mbox of patches, like the ones ExportPatches and git format-patch write, to the
+// worktree and commits each one with its original author, date, and message, as git am does. It returns
+// the hashes of the new commits. A hunk that doesn't match the worktree stops the run with an error
+// wrapping ErrPatchDoesNotApply, leaving the commits made so far in place
+func (gr *GitRepo) ApplyPatch(r io.Reader) (hashes []plumbing.Hash, err error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return hashes, err
+	}
+
+	gr.mu.Lock()
+	defer gr.mu.Unlock()
+
+	for _, msg := range splitMbox(string(data)) {
+		author, message, files, err := parsePatchEmail(msg)
+		if err != nil {
+			return hashes, err
+		}
+
+		for _, fp := range files {
+			err = gr.applyFilePatch(fp)
+			if err != nil {
+				return hashes, err
+			}
+		}
+
+		h, err := gr.Worktree.Commit(message, &git.CommitOptions{Author: author})
+		if err != nil {
+			return hashes, err
+		}
+		hashes = append(hashes, h)
+	}
+	return hashes, nil
+}
+
+func (gr *GitRepo) writeMboxPatch(w io.Writer, c *object.Commit, n, total int) error {
+	var parent *object.Commit
+	if c.NumParents() == 1 {
+		p, err := c.Parent(0)
+		if err != nil {
+			return err
+		}
+		parent = p
+	}
+
+	changes, err := diffCommits(parent, c)
+	if err != nil {
+		return err
+	}
+	patch, err := changes.Patch()
+	if err != nil {
+		return err
+	}
+
+	prefix := "[PATCH]"
+	if total > 1 {
+		prefix = fmt.Sprintf("[PATCH %d/%d]", n, total)
+	}
+	subject, body := splitCommitMessage(c.Message)
+	from := mail.Address{Name: c.Author.Name, Address: c.Author.Email}
+
+	fmt.Fprintf(w, "From %s %s\n", c.Hash, mboxFromDate)
+	fmt.Fprintf(w, "From: %s\n", from.String())
+	fmt.Fprintf(w, "Date: %s\n", c.Author.When.Format(time.RFC1123Z))
+	fmt.Fprintf(w, "Subject: %s\n\n", mime.QEncoding.Encode("utf-8", prefix+" "+subject))
+	if body != "" {
+		fmt.Fprintf(w, "%s\n\n", body)
+	}
+	fmt.Fprintf(w, "---\n%s\n", patch.Stats().String())
+
+	for _, fp := range patch.FilePatches() {
+		if fp.IsBinary() {
+			err = gr.writeBinaryPatch(w, fp)
+		} else {
+			err = diff.NewUnifiedEncoder(w, diff.DefaultContextLines).Encode(singleFilePatch{fp})
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	_, err = fmt.Fprint(w, "-- \ngithelpers\n\n")
+	return err
+}
+
+// singleFilePatch lets the unified diff encoder write one file of a patch at a time
+type singleFilePatch struct {
+	fp diff.FilePatch
+}
+
+func (p singleFilePatch) FilePatches() []diff.FilePatch {
+	return []diff.FilePatch{p.fp}
+}
+
+func (p singleFilePatch) Message() string {
+	return ""
+}
+
+func (gr *GitRepo) writeBinaryPatch(w io.Writer, fp diff.FilePatch) error {
+	from, to := fp.Files()
+
+	oldPath, newPath := "", ""
+	oldHash, newHash := plumbing.ZeroHash, plumbing.ZeroHash
+	if from != nil {
+		oldPath, oldHash = from.Path(), from.Hash()
+	}
+	if to != nil {
+		newPath, newHash = to.Path(), to.Hash()
+	}
+	if oldPath == "" {
+		oldPath = newPath
+	}
+	if newPath == "" {
+		newPath = oldPath
+	}
+
+	fmt.Fprintf(w, "diff --git a/%s b/%s\n", oldPath, newPath)
+	switch {
+	case from == nil:
+		fmt.Fprintf(w, "new file mode %o\n", to.Mode())
+	case to == nil:
+		fmt.Fprintf(w, "deleted file mode %o\n", from.Mode())
+	case from.Mode() != to.Mode():
+		fmt.Fprintf(w, "old mode %o\nnew mode %o\n", from.Mode(), to.Mode())
+	}
+	fmt.Fprintf(w, "index %s..%s\nGIT binary patch\n", oldHash, newHash)
+
+	// The forward literal is what ApplyPatch uses, the reverse one lets git apply -R undo it
+	for _, h := range []plumbing.Hash{newHash, oldHash} {
+		content, err := gr.blobContent(h)
+		if err != nil {
+			return err
+		}
+		err = writeBinaryLiteral(w, content)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (gr *GitRepo) blobContent(h plumbing.Hash) ([]byte, error) {
+	if h.IsZero() {
+		return nil, nil
+	}
+	blob, err := gr.Repo.BlobObject(h)
+	if err != nil {
+		return nil, err
+	}
+	r, err := blob.Reader()
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	return io.ReadAll(r)
+}
+
+func writeBinaryLiteral(w io.Writer, content []byte) error {
+	var compressed bytes.Buffer
+	zw := zlib.NewWriter(&compressed)
+	_, err := zw.Write(content)
+	if err != nil {
+		return err
+	}
+	err = zw.Close()
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(w, "literal %d\n", len(content))
+	data := compressed.Bytes()
+	for len(data) > 0 {
+		n := len(data)
+		if n > 52 {
+			n = 52
+		}
+		// The first character of a line gives its byte count, A-Z for 1-26 and a-z for 27-52
+		lenChar := byte('A' + n - 1)
+		if n > 26 {
+			lenChar = byte('a' + n - 27)
+		}
+		fmt.Fprintf(w, "%c%s\n", lenChar, encodeBase85(data[:n]))
+		data = data[n:]
+	}
+	_, err = fmt.Fprintln(w)
+	return err
+}
+
+func encodeBase85(data []byte) string {
+	var b strings.Builder
+	for i := 0; i < len(data); i += 4 {
+		var acc uint32
+		for j := 0; j < 4; j++ {
+			acc <<= 8
+			if i+j < len(data) {
+				acc |= uint32(data[i+j])
+			}
+		}
+		var group [5]byte
+		for j := 4; j >= 0; j-- {
+			group[j] = base85Chars[acc%85]
+			acc /= 85
+		}
+		b.Write(group[:])
+	}
+	return b.String()
+}
+
+func decodeBase85(s string, n int) ([]byte, error) {
+	var out []byte
+	for i := 0; i < len(s); i += 5 {
+		if i+5 > len(s) {
+			return nil, errors.New("truncated base85 data")
+		}
+		var acc uint64
+		for _, c := range []byte(s[i : i+5]) {
+			v := strings.IndexByte(base85Chars, c)
+			if v < 0 {
+				return nil, fmt.Errorf("invalid base85 character %q", c)
+			}
+			acc = acc*85 + uint64(v)
+		}
+		if acc > 0xffffffff {
+			return nil, errors.New("base85 group overflows")
+		}
+		out = append(out, byte(acc>>24), byte(acc>>16), byte(acc>>8), byte(acc))
+	}
+	if n > len(out) {
+		return nil, errors.New("truncated base85 data")
+	}
+	return out[:n], nil
+}
+
+func splitCommitMessage(msg string) (subject, body string) {
+	// As format-patch does, the first paragraph becomes the subject, unwrapped onto one line
+	parts := strings.SplitN(strings.TrimSpace(msg), "\n\n", 2)
+	subject = strings.Join(strings.Fields(parts[0]), " ")
+	if len(parts) == 2 {
+		body = strings.TrimSpace(parts[1])
+	}
+	return subject, body
+}
+
+func splitMbox(data string) (msgs []string) {
+	starts := mboxFromPattern.FindAllStringIndex(data, -1)
+	if len(starts) == 0 {
+		return []string{data}
+	}
+	for i, s := range starts {
+		end := len(data)
+		if i+1 < len(starts) {
+			end = starts[i+1][0]
+		}
+		// Drop the From line itself, which isn't a header
+		msg := data[s[0]:end]
+		if j := strings.IndexByte(msg, '\n'); j >= 0 {
+			msg = msg[j+1:]
+		}
+		msgs = append(msgs, msg)
+	}
+	return msgs
+}
+
+func parsePatchEmail(raw string) (author *object.Signature, message string, files []*filePatch, err error) {
+	msg, err := mail.ReadMessage(strings.NewReader(raw))
+	if err != nil {
+		return author, message, files, fmt.Errorf("reading patch email: %w", err)
+	}
+
+	from, err := mail.ParseAddress(msg.Header.Get("From"))
+	if err != nil {
+		return author, message, files, fmt.Errorf("reading patch author: %w", err)
+	}
+	author = &object.Signature{Name: from.Name, Email: from.Address, When: time.Now()}
+	if date, err := msg.Header.Date(); err == nil {
+		author.When = date
+	}
+
+	subject, err := new(mime.WordDecoder).DecodeHeader(msg.Header.Get("Subject"))
+	if err != nil {
+		return author, message, files, err
+	}
+	subject = patchSubjectPattern.ReplaceAllString(subject, "")
+
+	content, err := io.ReadAll(msg.Body)
+	if err != nil {
+		return author, message, files, err
+	}
+	lines := strings.SplitAfter(string(content), "\n")
+
+	// The commit message runs up to the --- line, and the diff starts at the first diff --git line
+	var body []string
+	i := 0
+	for ; i < len(lines); i++ {
+		line := strings.TrimRight(lines[i], "\r\n")
+		if line == "---" || strings.HasPrefix(line, "diff --git ") {
+			break
+		}
+		body = append(body, line)
+	}
+	message = subject + "\n"
+	if b := strings.TrimSpace(strings.Join(body, "\n")); b != "" {
+		message = subject + "\n\n" + b + "\n"
+	}
+
+	for ; i < len(lines) && !strings.HasPrefix(lines[i], "diff --git "); i++ {
+	}
+	files, err = parseDiff(lines[i:])
+	return author, message, files, err
+}
+
+func parseDiff(lines []string) (files []*filePatch, err error) {
+	var fp *filePatch
+	var h *patchHunk
+	for i := 0; i < len(lines); i++ {
+		raw := lines[i]
+		line := strings.TrimRight(raw, "\r\n")
+
+		switch {
+		case h != nil && (h.oldLeft > 0 || h.newLeft > 0):
+			// Some editors strip the space off blank context lines
+			if line == "" {
+				raw = " \n"
+			}
+			switch raw[0] {
+			case ' ':
+				h.oldLeft--
+				h.newLeft--
+			case '-':
+				h.oldLeft--
+			case '+':
+				h.newLeft--
+			case '\\':
+				h.noNewline()
+				continue
+			default:
+				return files, fmt.Errorf("malformed hunk line: %s", line)
+			}
+			h.lines = append(h.lines, raw)
+		case h != nil && strings.HasPrefix(line, `\`):
+			h.noNewline()
+		case line == "-- ":
+			// The signature format-patch ends each email with
+			return files, nil
+		case strings.HasPrefix(line, "diff --git "):
+			fp = &filePatch{}
+			h = nil
+			files = append(files, fp)
+			paths := strings.SplitN(strings.TrimPrefix(line, "diff --git "), " b/", 2)
+			if len(paths) == 2 {
+				fp.oldPath = strings.TrimPrefix(paths[0], "a/")
+				fp.newPath = paths[1]
+			}
+		case fp == nil:
+			continue
+		case strings.HasPrefix(line, "@@ "):
+			m := hunkHeaderPattern.FindStringSubmatch(line)
+			if m == nil {
+				return files, fmt.Errorf("malformed hunk header: %s", line)
+			}
+			fp.hunks = append(fp.hunks, patchHunk{
+				oldStart: atoi(m[1], 0),
+				oldLeft:  atoi(m[2], 1),
+				newLeft:  atoi(m[4], 1),
+			})
+			h = &fp.hunks[len(fp.hunks)-1]
+		case strings.HasPrefix(line, "new file mode "):
+			fp.oldPath = ""
+			fp.newMode, err = filemode.New(strings.TrimPrefix(line, "new file mode "))
+		case strings.HasPrefix(line, "deleted file mode "):
+			fp.newPath = ""
+			fp.oldMode, err = filemode.New(strings.TrimPrefix(line, "deleted file mode "))
+		case strings.HasPrefix(line, "old mode "):
+			fp.oldMode, err = filemode.New(strings.TrimPrefix(line, "old mode "))
+		case strings.HasPrefix(line, "new mode "):
+			fp.newMode, err = filemode.New(strings.TrimPrefix(line, "new mode "))
+		case strings.HasPrefix(line, "rename from "):
+			fp.oldPath = strings.TrimPrefix(line, "rename from ")
+		case strings.HasPrefix(line, "rename to "):
+			fp.newPath = strings.TrimPrefix(line, "rename to ")
+		case strings.HasPrefix(line, "index "):
+			// "index <old>..<new> <mode>" gives the mode of files whose mode didn't change
+			fields := strings.Fields(line)
+			if len(fields) == 3 && fp.oldMode == filemode.Empty && fp.newMode == filemode.Empty {
+				fp.oldMode, err = filemode.New(fields[2])
+				fp.newMode = fp.oldMode
+			}
+		case strings.HasPrefix(line, "Binary files "):
+			fp.isBinary = true
+		case line == "GIT binary patch":
+			fp.isBinary = true
+			fp.binary, i, err = parseBinaryLiteral(lines, i+1)
+			i--
+		}
+		if err != nil {
+			return files, err
+		}
+	}
+	return files, nil
+}
+
+// noNewline handles a "\ No newline at end of file" line, which applies to the line before it
+func (h *patchHunk) noNewline() {
+	if n := len(h.lines); n > 0 {
+		h.lines[n-1] = strings.TrimSuffix(h.lines[n-1], "\n")
+	}
+}
+
+func atoi(s string, def int) int {
+	if s == "" {
+		return def
+	}
+	n, err := strconv.Atoi(s)
+	if err != nil {
+		return def
+	}
+	return n
+}
+
+func parseBinaryLiteral(lines []string, i int) (content []byte, next int, err error) {
+	header := strings.TrimSpace(lines[i])
+	if !strings.HasPrefix(header, "literal ") {
+		return content, i, fmt.Errorf("unsupported binary patch %q, only literal ones are", header)
+	}
+	size, err := strconv.Atoi(strings.TrimPrefix(header, "literal "))
+	if err != nil {
+		return content, i, err
+	}
+
+	var compressed []byte
+	for i++; i < len(lines); i++ {
+		line := strings.TrimRight(lines[i], "\r\n")
+		if line == "" {
+			i++
+			break
+		}
+		n := int(line[0]-'A') + 1
+		if line[0] >= 'a' {
+			n = int(line[0]-'a') + 27
+		}
+		chunk, err := decodeBase85(line[1:], n)
+		if err != nil {
+			return content, i, err
+		}
+		compressed = append(compressed, chunk...)
+	}
+
+	// Skip the reverse hunk, ApplyPatch only goes forward
+	if i < len(lines) && (strings.HasPrefix(lines[i], "literal ") || strings.HasPrefix(lines[i], "delta ")) {
+		for i++; i < len(lines) && strings.TrimRight(lines[i], "\r\n") != ""; i++ {
+		}
+		i++
+	}
+
+	zr, err := zlib.NewReader(bytes.NewReader(compressed))
+	if err != nil {
+		return content, i, err
+	}
+	content, err = io.ReadAll(zr)
+	if err != nil {
+		return content, i, err
+	}
+	if len(content) != size {
+		return content, i, fmt.Errorf("binary patch is %d bytes, expected %d", len(content), size)
+	}
+	return content, i, nil
+}
+
+func (gr *GitRepo) applyFilePatch(fp *filePatch) error {
+	fs := gr.Worktree.Filesystem
+
+	if fp.newPath == "" {
+		_, err := gr.Worktree.Remove(fp.oldPath)
+		return err
+	}
+
+	var old []byte
+	if fp.oldPath != "" {
+		info, err := fs.Lstat(fp.oldPath)
+		if err != nil {
+			return fmt.Errorf("%w: %s: %v", ErrPatchDoesNotApply, fp.oldPath, err)
+		}
+		// Pure renames don't say what mode the file has, so it keeps the one it had
+		if fp.newMode == filemode.Empty {
+			fp.newMode, err = filemode.NewFromOSFileMode(info.Mode())
+			if err != nil {
+				return err
+			}
+		}
+		if info.Mode()&os.ModeSymlink != 0 {
+			target, err := fs.Readlink(fp.oldPath)
+			if err != nil {
+				return err
+			}
+			old = []byte(target)
+		} else {
+			f, err := fs.Open(fp.oldPath)
+			if err != nil {
+				return err
+			}
+			old, err = io.ReadAll(f)
+			f.Close()
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	content := old
+	switch {
+	case fp.binary != nil:
+		content = fp.binary
+	case fp.isBinary:
+		return fmt.Errorf("%w: %s: binary patch without content, export it with ExportPatches or git format-patch --binary", ErrPatchDoesNotApply, fp.newPath)
+	case len(fp.hunks) > 0:
+		var err error
+		content, err = applyHunks(old, fp.hunks)
+		if err != nil {
+			return fmt.Errorf("%w: %s: %v", ErrPatchDoesNotApply, fp.newPath, err)
+		}
+	}
+
+	if fp.oldPath != "" && (fp.oldPath != fp.newPath || fp.newMode == filemode.Symlink || fp.oldMode == filemode.Symlink) {
+		err := fs.Remove(fp.oldPath)
+		if err != nil {
+			return err
+		}
+		if fp.oldPath != fp.newPath {
+			_, err = gr.Worktree.Remove(fp.oldPath)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	var err error
+	switch fp.newMode {
+	case filemode.Symlink:
+		err = gr.Symlink(string(content), fp.newPath)
+	case filemode.Executable:
+		err = gr.WriteFile(fp.newPath, content, 0755)
+	default:
+		err = gr.WriteFile(fp.newPath, content, 0644)
+	}
+	if err != nil {
+		return err
+	}
+
+	_, err = gr.Worktree.Add(fp.newPath)
+	return err
+}
+
+func applyHunks(old []byte, hunks []patchHunk) ([]byte, error) {
+	lines := strings.SplitAfter(string(old), "\n")
+	if len(lines) > 0 && lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+
+	// offset tracks how far earlier hunks moved the lines of later ones
+	offset := 0
+	for _, h := range hunks {
+		var from, to []string
+		for _, l := range h.lines {
+			switch l[0] {
+			case ' ':
+				from = append(from, l[1:])
+				to = append(to, l[1:])
+			case '-':
+				from = append(from, l[1:])
+			case '+':
+				to = append(to, l[1:])
+			}
+		}
+
+		start := h.oldStart - 1 + offset
+		if len(from) == 0 {
+			// A hunk adding to an empty file or the start of one says it starts at line 0
+			start = h.oldStart + offset
+			if h.oldStart == 0 {
+				start = 0
+			}
+		}
+
+		pos := findHunk(lines, from, start)
+		if pos < 0 {
+			return nil, fmt.Errorf("hunk at line %d doesn't match", h.oldStart)
+		}
+
+		patched := append([]string{}, lines[:pos]...)
+		patched = append(patched, to...)
+		patched = append(patched, lines[pos+len(from):]...)
+		lines = patched
+		offset += len(to) - len(from)
+		offset += pos - start
+	}
+	return []byte(strings.Join(lines, "")), nil
+}
+
+func findHunk(lines, from []string, start int) int {
+	// Look at the expected line first, then further and further away from it, as patch does
+	for d := 0; d <= len(lines); d++ {
+		for _, pos := range []int{start - d, start + d} {
+			if pos < 0 || pos+len(from) > len(lines) {
+				continue
+			}
+			if linesEqual(lines[pos:pos+len(from)], from) {
+				return pos
+			}
+		}
+	}
+	return -1
+}
+
+func linesEqual(a, b []string) bool {
+	for i := range b {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
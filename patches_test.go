@@ -0,0 +1,215 @@
+package githelpers
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+)
+
+// testBinary has every byte value, so it can't pass as text, and compresses to several literal lines
+var testBinary = bytes.Repeat(func() []byte {
+	b := make([]byte, 256)
+	for i := range b {
+		b[i] = byte(i)
+	}
+	return b
+}(), 3)
+
+// newTestRepo initializes a repo in a temporary directory and commits files to it
+func newTestRepo(t *testing.T, files map[string]string) *GitRepo {
+	t.Helper()
+	gr := &GitRepo{Dir: t.TempDir()}
+	err := gr.Init(false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	commitTestFiles(t, gr, "Initial commit", files)
+	return gr
+}
+
+// commitTestFiles writes files, removing those set to "", and commits them
+func commitTestFiles(t *testing.T, gr *GitRepo, msg string, files map[string]string) plumbing.Hash {
+	t.Helper()
+	for path, content := range files {
+		var err error
+		if content == "" {
+			err = os.Remove(filepath.Join(gr.Dir, path))
+		} else {
+			err = gr.WriteFile(path, []byte(content), 0644)
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	h, err := gr.CommitAll(msg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return h
+}
+
+func headCommit(t *testing.T, gr *GitRepo) *object.Commit {
+	t.Helper()
+	ref, err := gr.Repo.Head()
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, err := gr.Repo.CommitObject(ref.Hash())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
+
+func TestExportPatchesRoundTrip(t *testing.T) {
+	base := map[string]string{"README.md": "hello\nworld\n", "old.bin": string(testBinary[:100])}
+	src := newTestRepo(t, base)
+	first := headCommit(t, src)
+
+	commitTestFiles(t, src, "Add a logo\n\nIt's binary, so it goes in as a literal.", map[string]string{
+		"logo.bin":  string(testBinary),
+		"README.md": "hello\nthere\nworld\n",
+	})
+	commitTestFiles(t, src, "Update the logo and drop the old one", map[string]string{
+		"logo.bin": string(testBinary[10:]) + "\x00\x01",
+		"old.bin":  "",
+	})
+	commitTestFiles(t, src, "Add notes", map[string]string{"docs/notes.txt": "no newline at the end"})
+
+	mbox, err := src.ExportPatches(first.Hash.String(), "HEAD")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := strings.Count(string(mbox), "\nSubject: [PATCH "); n != 3 {
+		t.Errorf("exported %d patches, want 3:\n%s", n, mbox)
+	}
+
+	dst := newTestRepo(t, base)
+	hashes, err := dst.ApplyPatch(bytes.NewReader(mbox))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(hashes) != 3 {
+		t.Fatalf("applied %d patches, want 3", len(hashes))
+	}
+
+	want, got := headCommit(t, src), headCommit(t, dst)
+	for i := 0; i < 3; i++ {
+		if got.TreeHash != want.TreeHash {
+			t.Errorf("%q: tree %s, want %s", got.Message, got.TreeHash, want.TreeHash)
+		}
+		// A commit from a patch ends in a newline, as git am writes them
+		if got.Message != strings.TrimRight(want.Message, "\n")+"\n" {
+			t.Errorf("message %q, want %q", got.Message, want.Message)
+		}
+		if got.Author.Email != want.Author.Email || !got.Author.When.Equal(want.Author.When.Truncate(time.Second)) {
+			t.Errorf("author %v, want %v", got.Author, want.Author)
+		}
+		if want, err = want.Parent(0); err != nil {
+			t.Fatal(err)
+		}
+		if got, err = got.Parent(0); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestExportPatchesGitAm(t *testing.T) {
+	requireGit(t)
+	base := map[string]string{"README.md": "hello\nworld\n"}
+	src := newTestRepo(t, base)
+	first := headCommit(t, src)
+	commitTestFiles(t, src, "Add a logo", map[string]string{"logo.bin": string(testBinary), "README.md": "hello\nthere\nworld\n"})
+	commitTestFiles(t, src, "Update the logo", map[string]string{"logo.bin": string(testBinary[10:])})
+	mbox, err := src.ExportPatches(first.Hash.String(), "HEAD")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dst := newTestRepo(t, base)
+	cmd := exec.Command("git", "am", "--quiet")
+	cmd.Dir, cmd.Stdin = dst.Dir, bytes.NewReader(mbox)
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		t.Fatalf("git am: %v: %s", err, out)
+	}
+	if got, want := headCommit(t, dst).TreeHash, headCommit(t, src).TreeHash; got != want {
+		t.Errorf("tree %s, want %s", got, want)
+	}
+}
+
+func TestApplyPatchFormatPatch(t *testing.T) {
+	// Written by git format-patch --binary, onto a repo with this README
+	fixture, err := os.ReadFile(filepath.Join("testdata", "format-patch-binary.patch"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	gr := newTestRepo(t, map[string]string{"README.md": "hello\nworld\n"})
+
+	_, err = gr.ApplyPatch(bytes.NewReader(fixture))
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := headCommit(t, gr)
+	// The tree of the commit the patch was made from
+	if want := plumbing.NewHash("301ef8ae509cd2b37141e137670119b8a7513f27"); c.TreeHash != want {
+		t.Errorf("tree %s, want %s", c.TreeHash, want)
+	}
+	logo, err := os.ReadFile(filepath.Join(gr.Dir, "logo.bin"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(logo, testBinary) {
+		t.Errorf("logo.bin has %d bytes that don't match", len(logo))
+	}
+
+	if want := "Add logo and notes\n\nThe logo is binary, so the patch carries it as a literal.\n"; c.Message != want {
+		t.Errorf("message %q, want %q", c.Message, want)
+	}
+	when := time.Date(2026, 1, 3, 9, 0, 0, 0, time.UTC)
+	if c.Author.Name != "Ada Lovelace" || c.Author.Email != "ada@example.com" || !c.Author.When.Equal(when) {
+		t.Errorf("author %v", c.Author)
+	}
+}
+
+func TestApplyPatchDoesNotApply(t *testing.T) {
+	fixture, err := os.ReadFile(filepath.Join("testdata", "format-patch-binary.patch"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	gr := newTestRepo(t, map[string]string{"README.md": "something\nelse\n"})
+
+	_, err = gr.ApplyPatch(bytes.NewReader(fixture))
+	if !errors.Is(err, ErrPatchDoesNotApply) {
+		t.Errorf("got %v, want ErrPatchDoesNotApply", err)
+	}
+}
+
+func TestBase85(t *testing.T) {
+	for n := 0; n <= 9; n++ {
+		data := testBinary[250 : 250+n]
+		got, err := decodeBase85(encodeBase85(data), n)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(got, data) {
+			t.Errorf("%d bytes: got %x, want %x", n, got, data)
+		}
+	}
+	// The zlib stream git wrote for the empty side of the fixture's binary patch
+	got, err := decodeBase85("cmV?d00001", 8)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []byte{0x78, 0x01, 0x03, 0x00, 0x00, 0x00, 0x00, 0x01}; !bytes.Equal(got, want) {
+		t.Errorf("got %x, want %x", got, want)
+	}
+}
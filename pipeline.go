@@ -0,0 +1,136 @@
+package githelpers
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/xanzy/go-gitlab"
+)
+
+// PushChangeFunc mutates the worktree of a target repo in response to a push to a source repo
+type PushChangeFunc func(event *gitlab.PushEvent, gr *GitRepo) error
+
+// PipelineRule says which target repos to change, and how, when a source repo is pushed to
+type PipelineRule struct {
+	Name      string
+	Source    string   // Full path of the source project, e.g. group/lib
+	Branch    string   // Source branch whose pushes trigger the rule. Defaults to the project's default branch
+	Targets   []string // SSH URLs of the repos to change
+	NewBranch string   // Branch the change is committed on in each target. Defaults to githelpers/<Name>
+	CommitMsg string   // Commit message and MR title. Defaults to one naming the source commit
+	Change    PushChangeFunc
+}
+
+// PipelineRun is the outcome of one rule triggered by one push
+type PipelineRun struct {
+	Rule    string
+	Event   *gitlab.PushEvent
+	Results []FleetResult
+	Err     error // A *MultiError of the failed targets, as returned by FleetErrors
+}
+
+// Pipeline turns GitLab push events into changes on other repos, for example to propagate a library
+// release to the repos that consume it. Each triggered rule runs as a Fleet over the rule's targets,
+// built from the Pipeline's Fleet, in the background, so webhook deliveries return right away
+type Pipeline struct {
+	Fleet    *Fleet            // Template for the per-run fleets: credentials, VCS client, concurrency, and cache
+	OnResult func(PipelineRun) // Called after every run. Calls may overlap when runs do
+
+	ctx   context.Context
+	mu    sync.RWMutex
+	rules []PipelineRule
+	wg    sync.WaitGroup
+}
+
+// NewPipeline returns a Pipeline whose runs use the settings of f and stop starting new repos once ctx is canceled
+func NewPipeline(ctx context.Context, f *Fleet) *Pipeline {
+	return &Pipeline{Fleet: f, ctx: ctx}
+}
+
+// Register adds a rule to the Pipeline
+func (p *Pipeline) Register(rule PipelineRule) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.rules = append(p.rules, rule)
+}
+
+// Attach makes the Pipeline handle the push events wr receives
+func (p *Pipeline) Attach(wr *WebhookReceiver) {
+	wr.OnPush(p.HandlePush)
+}
+
+// HandlePush starts a run of every rule matching the push and returns without waiting for them.
+// Pushes deleting a branch don't trigger anything
+func (p *Pipeline) HandlePush(event *gitlab.PushEvent) error {
+	if plumbing.NewHash(event.After).IsZero() {
+		return nil
+	}
+
+	for _, rule := range p.matching(event) {
+		p.wg.Add(1)
+		go func(rule PipelineRule) {
+			defer p.wg.Done()
+			run := p.run(rule, event)
+			if p.OnResult != nil {
+				p.OnResult(run)
+			}
+		}(rule)
+	}
+	return nil
+}
+
+// Wait blocks until every run started so far has finished, e.g. before shutting down
+func (p *Pipeline) Wait() {
+	p.wg.Wait()
+}
+
+func (p *Pipeline) matching(event *gitlab.PushEvent) (rules []PipelineRule) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	branch := strings.TrimPrefix(event.Ref, "refs/heads/")
+	if branch == event.Ref {
+		return nil
+	}
+
+	for _, rule := range p.rules {
+		want := rule.Branch
+		if want == "" {
+			want = event.Project.DefaultBranch
+		}
+		if strings.EqualFold(rule.Source, event.Project.PathWithNamespace) && want == branch {
+			rules = append(rules, rule)
+		}
+	}
+	return rules
+}
+
+func (p *Pipeline) run(rule PipelineRule, event *gitlab.PushEvent) PipelineRun {
+	f := *p.Fleet
+	f.URLs = rule.Targets
+
+	branch := rule.NewBranch
+	if branch == "" {
+		branch = "githelpers/" + rule.Name
+	}
+	msg := rule.CommitMsg
+	if msg == "" {
+		sha := event.After
+		if len(sha) > 7 {
+			sha = sha[:7]
+		}
+		msg = fmt.Sprintf("Update for %s@%s", event.Project.PathWithNamespace, sha)
+	}
+
+	ctx := p.ctx
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	results := f.Run(ctx, msg, branch, func(gr *GitRepo) error {
+		return rule.Change(event, gr)
+	})
+	return PipelineRun{Rule: rule.Name, Event: event, Results: results, Err: FleetErrors(results)}
+}
@@ -0,0 +1,212 @@
+package githelpers
+
+import (
+	"errors"
+	"fmt"
+	"path"
+	"strings"
+
+	"github.com/go-git/go-billy/v5/util"
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/filemode"
+	"github.com/go-git/go-git/v5/plumbing/object"
+)
+
+var (
+	// ErrNothingToPromote is returned by Promote when the destination branch already has the source's paths
+	ErrNothingToPromote = errors.New("nothing to promote, the paths are already the same")
+)
+
+// PromoteOption customizes the branch, commit, and MR Promote makes
+type PromoteOption func(*promoteConfig)
+
+type promoteConfig struct {
+	branch     string
+	branchOpts []BranchOption
+	message    string
+}
+
+// WithPromoteBranch names the promotion branch from name and opts, as NewBranchWithOptions does,
+// instead of promote-<srcRef>-to-<destBranch>
+func WithPromoteBranch(name string, opts ...BranchOption) PromoteOption {
+	return func(cfg *promoteConfig) {
+		cfg.branch = name
+		cfg.branchOpts = opts
+	}
+}
+
+// WithPromoteMessage is the commit message and MR title, instead of one listing the promoted paths
+func WithPromoteMessage(msg string) PromoteOption {
+	return func(cfg *promoteConfig) {
+		cfg.message = msg
+	}
+}
+
+// Promote copies paths from srcRef onto destBranch and proposes the result as an MR into destBranch,
+// e.g. to promote overlays from staging to prod. Each path is a file or directory, copied to the same
+// path, or written source:destination to copy it elsewhere, e.g. overlays/staging:overlays/prod.
+// Directories are replaced, so files removed at srcRef are removed from destBranch too. The branch
+// starts from origin's destBranch when the clone has it. Returns ErrNothingToPromote when the copy
+// changes nothing
+func (gr *GitRepo) Promote(srcRef, destBranch string, paths []string, opts ...PromoteOption) (mr MergeRequestInfo, err error) {
+	cfg := promoteConfig{
+		branch:     "promote-" + srcRef + "-to-" + destBranch,
+		branchOpts: []BranchOption{WithBranchCollisionCheck()},
+		message:    fmt.Sprintf("Promote %s from %s to %s", strings.Join(paths, ", "), srcRef, destBranch),
+	}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+
+	src, _, err := gr.branchCommit(srcRef, true)
+	if err != nil {
+		return mr, err
+	}
+	srcTree, err := src.Tree()
+	if err != nil {
+		return mr, err
+	}
+	dest, _, err := gr.branchCommit(destBranch, true)
+	if err != nil {
+		return mr, err
+	}
+
+	branch, err := gr.BranchName(cfg.branch, cfg.branchOpts...)
+	if err != nil {
+		return mr, err
+	}
+	err = gr.checkoutNewBranchAt(branch, dest.Hash)
+	if err != nil {
+		return mr, err
+	}
+
+	for _, p := range paths {
+		from, to := p, p
+		if i := strings.Index(p, ":"); i >= 0 {
+			from, to = p[:i], p[i+1:]
+		}
+		err = gr.copyTreePath(srcTree, strings.Trim(from, "/"), strings.Trim(to, "/"))
+		if err != nil {
+			return mr, fmt.Errorf("promoting %s: %w", p, err)
+		}
+	}
+
+	status, err := gr.Worktree.Status()
+	if err != nil {
+		return mr, err
+	}
+	if status.IsClean() {
+		return mr, ErrNothingToPromote
+	}
+
+	err = gr.CommitAndPushAll(cfg.message)
+	if err != nil {
+		return mr, err
+	}
+	return gr.NewMergeRequest(cfg.message, branch, destBranch)
+}
+
+func (gr *GitRepo) checkoutNewBranchAt(name string, h plumbing.Hash) error {
+	gr.mu.Lock()
+	defer gr.mu.Unlock()
+
+	wt, err := gr.Repo.Worktree()
+	if err != nil {
+		return err
+	}
+
+	err = wt.Checkout(&git.CheckoutOptions{
+		Hash:   h,
+		Branch: plumbing.NewBranchReferenceName(name),
+		Create: true,
+	})
+
+	gr.Worktree = wt
+
+	return err
+}
+
+// treeFile is a file read out of a commit's tree
+type treeFile struct {
+	contents string
+	mode     filemode.FileMode
+}
+
+// copyTreePath replaces to in the worktree with the file or directory at from in tree, keeping file modes
+func (gr *GitRepo) copyTreePath(tree *object.Tree, from, to string) error {
+	files, err := readTreePath(tree, from, to)
+	if err != nil {
+		return err
+	}
+	return gr.replaceWorktreePath(to, files)
+}
+
+// readTreePath reads the file or directory at from in tree, or the whole tree when from is empty,
+// keyed by where it goes under to
+func readTreePath(tree *object.Tree, from, to string) (files map[string]treeFile, err error) {
+	if to == "" {
+		return files, errors.New("empty path")
+	}
+
+	var found []*object.File
+	f, err := tree.File(from)
+	file := from != "" && err == nil
+	if file {
+		found = append(found, f)
+	} else {
+		if from != "" {
+			tree, err = tree.Tree(from)
+			if err != nil {
+				return files, fmt.Errorf("%s: %w", from, err)
+			}
+		}
+		err = tree.Files().ForEach(func(f *object.File) error {
+			found = append(found, f)
+			return nil
+		})
+		if err != nil {
+			return files, err
+		}
+	}
+
+	files = map[string]treeFile{}
+	for _, f := range found {
+		contents, err := f.Contents()
+		if err != nil {
+			return files, err
+		}
+		dest := to
+		if !file {
+			dest = path.Join(to, f.Name)
+		}
+		files[dest] = treeFile{contents: contents, mode: f.Mode}
+	}
+	return files, nil
+}
+
+// replaceWorktreePath removes to from the worktree and writes files in its place
+func (gr *GitRepo) replaceWorktreePath(to string, files map[string]treeFile) error {
+	if to == "" {
+		return errors.New("empty path")
+	}
+	err := util.RemoveAll(gr.worktreeFilesystem(), to)
+	if err != nil {
+		return err
+	}
+
+	for dest, f := range files {
+		switch f.mode {
+		case filemode.Symlink:
+			err = gr.Symlink(f.contents, dest)
+		case filemode.Executable:
+			err = gr.WriteFile(dest, []byte(f.contents), 0755)
+		default:
+			err = gr.WriteFile(dest, []byte(f.contents), 0644)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
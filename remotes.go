@@ -0,0 +1,126 @@
+package githelpers
+
+import (
+	"sort"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/config"
+	"github.com/go-git/go-git/v5/plumbing/transport"
+)
+
+// AddRemote adds a remote called name pointing at url, e.g. a GitHub mirror of a GitLab repo, which
+// authenticates with auth, or with SSHKey when auth is nil
+func (gr *GitRepo) AddRemote(name, url string, auth transport.AuthMethod) error {
+	gr.mu.Lock()
+	defer gr.mu.Unlock()
+
+	_, err := gr.Repo.CreateRemote(&config.RemoteConfig{
+		Name: name,
+		URLs: []string{url},
+	})
+	if err != nil {
+		return err
+	}
+
+	if auth != nil {
+		if gr.RemoteAuth == nil {
+			gr.RemoteAuth = map[string]transport.AuthMethod{}
+		}
+		gr.RemoteAuth[name] = auth
+	}
+	return nil
+}
+
+// PushAllRemotes pushes the commits to every remote of the repo, as Push does to one, so the same
+// commit is published to the primary repo and its mirrors in one call. Every remote is tried even
+// when an earlier one fails, and the failures are returned as a *MultiError keyed by remote name.
+// The hooks added with AddPrePushHook run once, before any remote is pushed to, and TrackUpstream
+// only applies to the GitRepo's own remote
+func (gr *GitRepo) PushAllRemotes() error {
+	err := gr.runPrePushHooks()
+	if err != nil {
+		return err
+	}
+
+	gr.mu.Lock()
+	defer gr.mu.Unlock()
+
+	remotes, err := gr.Repo.Remotes()
+	if err != nil {
+		return err
+	}
+	names := make([]string, 0, len(remotes))
+	for _, r := range remotes {
+		names = append(names, r.Config().Name)
+	}
+	sort.Strings(names)
+
+	failed := &MultiError{}
+	for _, name := range names {
+		err := gr.Repo.Push(&git.PushOptions{
+			Auth:       gr.remoteAuth(name),
+			RemoteName: name,
+		})
+		if err == git.NoErrAlreadyUpToDate {
+			err = nil
+		}
+		if err == nil && name == gr.remoteName() && gr.TrackUpstream {
+			err = gr.trackHead()
+		}
+		failed.Add(name, err)
+	}
+	return failed.ErrorOrNil()
+}
+
+// remoteAuth returns how to authenticate with the remote called name: its RemoteAuth entry, or SSHKey,
+// set up for the SSH route of its host when it has one
+func (gr *GitRepo) remoteAuth(name string) transport.AuthMethod {
+	var auth transport.AuthMethod
+	switch {
+	case gr.RemoteAuth[name] != nil:
+		auth = gr.RemoteAuth[name]
+	case gr.SSHKey != nil:
+		// A nil *PublicKeys would make a non-nil AuthMethod that go-git then tries to use
+		auth = gr.SSHKey
+	}
+	return routedSSHAuth(gr.remoteURL(name), auth)
+}
+
+// remoteURL returns the URL of the remote called name, or SSHURL for the GitRepo's remote before it's cloned
+func (gr *GitRepo) remoteURL(name string) string {
+	if gr.Repo != nil && gr.Repo.Storer != nil {
+		r, err := gr.Repo.Remote(name)
+		if err == nil && len(r.Config().URLs) > 0 {
+			return r.Config().URLs[0]
+		}
+	}
+	if name == gr.remoteName() {
+		return gr.SSHURL
+	}
+	return ""
+}
+
+// urlAuth returns how to authenticate with the repo at url, e.g. one a subtree is pushed to or fetched
+// from without a remote of its own: as the configured remote with that URL does, or else with SSHKey
+// for SSH URLs and without auth for the others
+func (gr *GitRepo) urlAuth(url string) transport.AuthMethod {
+	remotes, err := gr.Repo.Remotes()
+	if err == nil {
+		for _, r := range remotes {
+			for _, u := range r.Config().URLs {
+				if u == url {
+					return gr.remoteAuth(r.Config().Name)
+				}
+			}
+		}
+	}
+
+	ep, err := transport.NewEndpoint(url)
+	if err != nil || ep.Protocol != "ssh" {
+		return nil
+	}
+	if gr.SSHKey == nil {
+		return routedSSHAuth(url, nil)
+	}
+	return routedSSHAuth(url, gr.SSHKey)
+}
@@ -0,0 +1,170 @@
+package githelpers
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+const (
+	azureHTTPSHost = "dev.azure.com"
+	azureSSHHost   = "ssh.dev.azure.com"
+)
+
+// RepoURL is a parsed Git remote URL. It understands https:// and http:// URLs, ssh:// URLs, and
+// scp-like git@host:path URLs, with or without ports and nested subgroups
+type RepoURL struct {
+	Scheme    string // "https", "http", or "ssh". Empty for scp-like URLs
+	User      string
+	Host      string // Host name without the port
+	Port      string
+	Namespace string // Full path of the group, user, or organization, e.g. group/subgroup
+	Name      string // Repo name without the .git suffix
+}
+
+// ParseRepoURL parses a Git remote URL into its parts
+func ParseRepoURL(rawURL string) (RepoURL, error) {
+	var r RepoURL
+	var repoPath string
+
+	if strings.Contains(rawURL, "://") {
+		u, err := url.Parse(rawURL)
+		if err != nil {
+			return r, err
+		}
+		switch u.Scheme {
+		case "https", "http", "ssh", "git+ssh":
+		default:
+			return r, fmt.Errorf("unsupported repo URL scheme %q in %s", u.Scheme, rawURL)
+		}
+		r.Scheme = strings.TrimPrefix(u.Scheme, "git+")
+		if u.User != nil {
+			r.User = u.User.Username()
+		}
+		r.Host = u.Hostname()
+		r.Port = u.Port()
+		repoPath = u.Path
+	} else {
+		i := strings.Index(rawURL, ":")
+		if i < 0 {
+			return r, fmt.Errorf("unrecognized repo URL: %s", rawURL)
+		}
+		hostPart := rawURL[:i]
+		repoPath = rawURL[i+1:]
+		if at := strings.LastIndex(hostPart, "@"); at >= 0 {
+			r.User = hostPart[:at]
+			hostPart = hostPart[at+1:]
+		}
+		r.Host = hostPart
+	}
+
+	repoPath = strings.TrimSuffix(strings.Trim(repoPath, "/"), ".git")
+	segments := strings.Split(repoPath, "/")
+	r.Name = segments[len(segments)-1]
+	segments = segments[:len(segments)-1]
+	// Azure DevOps HTTPS URLs put a _git segment between the project and the repo, and
+	// SSH URLs start with v3. Both are dropped so the namespace is always org/project
+	if len(segments) > 0 && segments[len(segments)-1] == "_git" {
+		segments = segments[:len(segments)-1]
+	}
+	if r.Host == azureSSHHost && len(segments) > 0 && segments[0] == "v3" {
+		segments = segments[1:]
+	}
+	r.Namespace = strings.Join(segments, "/")
+
+	if r.Host == "" || r.Name == "" {
+		return r, fmt.Errorf("repo URL has no host or repo name: %s", rawURL)
+	}
+	return r, nil
+}
+
+// FullPath returns the namespace and name of the repo, e.g. group/subgroup/repo
+func (r RepoURL) FullPath() string {
+	if r.Namespace == "" {
+		return r.Name
+	}
+	return r.Namespace + "/" + r.Name
+}
+
+// HostWithPort returns the host, followed by the port when one was given
+func (r RepoURL) HostWithPort() string {
+	if r.Port == "" {
+		return r.Host
+	}
+	return r.Host + ":" + r.Port
+}
+
+// String formats the RepoURL back into a URL of the same kind it was parsed from
+func (r RepoURL) String() string {
+	if r.Scheme == "" {
+		host := r.Host
+		if r.User != "" {
+			host = r.User + "@" + host
+		}
+		return host + ":" + r.sshPath()
+	}
+
+	u := url.URL{Scheme: r.Scheme, Host: r.HostWithPort(), Path: "/" + r.sshPath()}
+	if r.Scheme != "ssh" {
+		u.Path = r.httpPath() + ".git"
+		if r.Host == azureHTTPSHost {
+			u.Path = r.httpPath()
+		}
+	}
+	if r.User != "" {
+		u.User = url.User(r.User)
+	}
+	return u.String()
+}
+
+// ToSSH returns the scp-like SSH clone URL of the repo, e.g. git@gitlab.com:group/repo.git. An
+// ssh:// URL is returned instead when the RepoURL already is one with a port, since scp-like URLs
+// can't carry a port
+func (r RepoURL) ToSSH() string {
+	if r.Scheme == "ssh" && r.Port != "" {
+		return r.String()
+	}
+
+	ssh := RepoURL{User: "git", Host: r.Host, Namespace: r.Namespace, Name: r.Name}
+	if r.User != "" && (r.Scheme == "ssh" || r.Scheme == "") {
+		ssh.User = r.User
+	}
+	if r.Host == azureHTTPSHost {
+		ssh.Host = azureSSHHost
+	}
+	return ssh.String()
+}
+
+// ToHTTPS returns the HTTPS clone URL of the repo, without any user, e.g.
+// https://gitlab.com/group/repo.git. The port is kept for HTTP(S) URLs only, as the port of an SSH
+// URL belongs to the SSH daemon
+func (r RepoURL) ToHTTPS() string {
+	https := RepoURL{Scheme: "https", Host: r.Host, Namespace: r.Namespace, Name: r.Name}
+	if r.Scheme == "https" || r.Scheme == "http" {
+		https.Scheme, https.Port = r.Scheme, r.Port
+	}
+	if r.Host == azureSSHHost {
+		https.Host = azureHTTPSHost
+	}
+	return https.String()
+}
+
+// WebURL returns the address of the repo's home page, e.g. https://gitlab.com/group/repo. MR and
+// commit links are built by appending to it
+func (r RepoURL) WebURL() string {
+	return strings.TrimSuffix(r.ToHTTPS(), ".git")
+}
+
+func (r RepoURL) sshPath() string {
+	if r.Host == azureSSHHost {
+		return "v3/" + r.FullPath()
+	}
+	return r.FullPath() + ".git"
+}
+
+func (r RepoURL) httpPath() string {
+	if r.Host == azureHTTPSHost {
+		return "/" + r.Namespace + "/_git/" + r.Name
+	}
+	return "/" + r.FullPath()
+}
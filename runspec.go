@@ -0,0 +1,386 @@
+package githelpers
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"text/template"
+
+	gitSSH "github.com/go-git/go-git/v5/plumbing/transport/ssh"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	// EditReplace replaces text in every file matching the edit's path glob
+	EditReplace = "replace"
+	// EditYAMLSet sets a key in a YAML file, as SetYAMLValue does
+	EditYAMLSet = "yaml-set"
+	// EditTemplate renders a text/template into a file, creating or overwriting it
+	EditTemplate = "template"
+	// EditChanger applies a Changer registered with RegisterChanger
+	EditChanger = "changer"
+)
+
+// InvalidRunSpecError is returned when a RunSpec is missing settings or has ones that contradict each other
+type InvalidRunSpecError struct {
+	Reason string
+}
+
+func (e *InvalidRunSpecError) Error() string {
+	return "invalid run spec: " + e.Reason
+}
+
+// RunSpec describes a whole change run declaratively, so it can live in a YAML or JSON file next to
+// the automation that runs it. A spec changes the files of every target repo with its edits, commits
+// the result on a new branch, and opens an MR, as a Fleet does
+type RunSpec struct {
+	Repos         []string      `yaml:"repos"` // SSH URLs of the repos to change
+	Group         string        `yaml:"group"` // Full path of a GitLab group whose projects, subgroups included, are changed too
+	Branch        BranchSpec    `yaml:"branch"`
+	Edits         []EditSpec    `yaml:"edits"`
+	CommitMessage string        `yaml:"commit_message"`
+	MergeRequest  MRSpec        `yaml:"merge_request"`
+	Concurrency   int           `yaml:"concurrency"`  // Number of repos processed at the same time. Defaults to 4
+	CacheDir      string        `yaml:"cache_dir"`    // Clones from mirrors kept in this directory when set
+	Vars          TemplateVars  `yaml:"vars"`         // Made available to template edits as .Vars
+	Limits        *ChangeLimits `yaml:"limits"`       // Guardrails every repo's change must stay within
+	SkipApplied   bool          `yaml:"skip_applied"` // Records the spec's ChangeID on every commit and skips repos whose target branch has it
+}
+
+// TemplateVars are user defined values handed to template edits
+type TemplateVars map[string]interface{}
+
+// BranchSpec names the branch a RunSpec commits on
+type BranchSpec struct {
+	Name           string `yaml:"name"`
+	Prefix         string `yaml:"prefix"`          // Prepended to Name, e.g. bot/
+	Suffix         string `yaml:"suffix"`          // One of epoch, date, sha, or random. Left out means no suffix
+	CheckCollision bool   `yaml:"check_collision"` // Appends -2, -3, and so on when the branch already exists
+}
+
+// EditSpec is one file change of a RunSpec. Which fields apply depends on Type
+type EditSpec struct {
+	Type string `yaml:"type"` // One of replace, yaml-set, template, or changer
+	Path string `yaml:"path"` // File to change. For replace, a .gitignore style glob, e.g. "*.tf" or "ci/**". Unused by changer
+
+	// replace: Old is replaced with New, or with Regex set, matches of Regex are, expanding $1 style
+	// references to its groups
+	Old   string `yaml:"old"`
+	Regex string `yaml:"regex"`
+	New   string `yaml:"new"`
+
+	// yaml-set
+	Key   string      `yaml:"key"`
+	Value interface{} `yaml:"value"`
+
+	// template: Template is the template text, or TemplateFile a file holding it relative to the
+	// spec file. The template is executed with .Repo, .Branch, and .Vars
+	Template     string `yaml:"template"`
+	TemplateFile string `yaml:"template_file"`
+
+	// changer: Changer is the registered name, built with Options
+	Changer string                 `yaml:"changer"`
+	Options map[string]interface{} `yaml:"options"`
+}
+
+// MRSpec holds the MR options of a RunSpec
+type MRSpec struct {
+	Title        string `yaml:"title"`         // Defaults to the commit message
+	TargetBranch string `yaml:"target_branch"` // Defaults to each repo's default branch
+}
+
+// templateData is what template edits are executed with
+type templateData struct {
+	Repo   string
+	Branch string
+	Vars   TemplateVars
+}
+
+// LoadRunSpec reads a RunSpec from a YAML or JSON file. Relative template files are resolved against
+// the directory of path
+func LoadRunSpec(path string) (spec *RunSpec, err error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return spec, err
+	}
+	spec, err = ParseRunSpec(data)
+	if err != nil {
+		return spec, fmt.Errorf("%s: %w", path, err)
+	}
+
+	for i, e := range spec.Edits {
+		if e.TemplateFile != "" && !filepath.IsAbs(e.TemplateFile) {
+			spec.Edits[i].TemplateFile = filepath.Join(filepath.Dir(path), e.TemplateFile)
+		}
+	}
+	return spec, nil
+}
+
+// ParseRunSpec parses a RunSpec from YAML, or JSON, which YAML is a superset of. Unknown fields are
+// rejected so typos don't silently fall back to defaults
+func ParseRunSpec(data []byte) (spec *RunSpec, err error) {
+	spec = &RunSpec{}
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	err = dec.Decode(spec)
+	if err != nil {
+		return nil, err
+	}
+	return spec, spec.Validate()
+}
+
+// Validate checks that the spec has what Execute needs
+func (s *RunSpec) Validate() error {
+	invalid := func(format string, args ...interface{}) error {
+		return &InvalidRunSpecError{Reason: fmt.Sprintf(format, args...)}
+	}
+
+	switch {
+	case len(s.Repos) == 0 && s.Group == "":
+		return invalid("no repos or group to change")
+	case s.Branch.Name == "":
+		return invalid("branch name is required")
+	case s.CommitMessage == "":
+		return invalid("commit message is required")
+	case len(s.Edits) == 0:
+		return invalid("no edits")
+	}
+	switch s.Branch.Suffix {
+	case "", "epoch", "date", "sha", "random":
+	default:
+		return invalid("unknown branch suffix %q", s.Branch.Suffix)
+	}
+
+	for i, e := range s.Edits {
+		if e.Path == "" && e.Type != EditChanger {
+			return invalid("edit %d: path is required", i+1)
+		}
+		switch e.Type {
+		case EditReplace:
+			if (e.Old == "") == (e.Regex == "") {
+				return invalid("edit %d: replace needs exactly one of old or regex", i+1)
+			}
+			if e.Regex != "" {
+				_, err := regexp.Compile(e.Regex)
+				if err != nil {
+					return invalid("edit %d: %s", i+1, err)
+				}
+			}
+		case EditYAMLSet:
+			if e.Key == "" {
+				return invalid("edit %d: yaml-set needs a key", i+1)
+			}
+		case EditTemplate:
+			if (e.Template == "") == (e.TemplateFile == "") {
+				return invalid("edit %d: template needs exactly one of template or template_file", i+1)
+			}
+		case EditChanger:
+			if e.Changer == "" {
+				return invalid("edit %d: changer needs a changer name", i+1)
+			}
+		default:
+			return invalid("edit %d: unknown type %q", i+1, e.Type)
+		}
+	}
+	return nil
+}
+
+// Execute runs spec: it changes every target repo with the spec's edits and proposes the changes as
+// MRs through a Fleet. Results are returned per repo, along with a *MultiError of the repos that
+// failed, as FleetErrors builds. Repos the edits leave unchanged get no MR
+func Execute(ctx context.Context, spec *RunSpec, sshKey *gitSSH.PublicKeys, vcsToken string) (results []FleetResult, err error) {
+	err = spec.Validate()
+	if err != nil {
+		return results, err
+	}
+
+	var f *Fleet
+	if spec.Group != "" {
+		f, err = NewGitlabGroupFleet(spec.Group, sshKey, vcsToken)
+		if err == nil {
+			f.URLs = append(append([]string(nil), spec.Repos...), f.URLs...)
+		}
+	} else {
+		f, err = NewFleet(spec.Repos, sshKey, vcsToken)
+	}
+	if err != nil {
+		return results, err
+	}
+	if spec.CacheDir != "" {
+		f.Cache, err = NewCloneCache(spec.CacheDir)
+		if err != nil {
+			return results, err
+		}
+	}
+	f.Concurrency = spec.Concurrency
+	f.TargetBranch = spec.MergeRequest.TargetBranch
+	f.MRTitle = spec.MergeRequest.Title
+	f.BranchOpts = spec.Branch.options()
+	f.Limits = spec.Limits
+	if spec.SkipApplied {
+		f.ChangeID, err = spec.ChangeID()
+		if err != nil {
+			return results, err
+		}
+	}
+
+	edits, err := spec.compileEdits(ctx)
+	if err != nil {
+		return results, err
+	}
+	results = f.Run(ctx, spec.CommitMessage, spec.Branch.Name, func(gr *GitRepo) error {
+		for i, edit := range edits {
+			err := edit(gr)
+			if err != nil {
+				return fmt.Errorf("edit %d: %w", i+1, err)
+			}
+		}
+		return nil
+	})
+	return results, FleetErrors(results)
+}
+
+func (b BranchSpec) options() (opts []BranchOption) {
+	// Always go through NewBranchWithOptions, so the name is sanitized the same way with or without options
+	opts = append(opts, WithBranchPrefix(b.Prefix))
+	switch b.Suffix {
+	case "epoch":
+		opts = append(opts, WithBranchEpochSuffix())
+	case "date":
+		opts = append(opts, WithBranchDateSuffix("20060102"))
+	case "sha":
+		opts = append(opts, WithBranchSHASuffix())
+	case "random":
+		opts = append(opts, WithBranchRandomSuffix(0))
+	}
+	if b.CheckCollision {
+		opts = append(opts, WithBranchCollisionCheck())
+	}
+	return opts
+}
+
+// compileEdits turns the spec's edits into ChangeFuncs, parsing regexes and templates and building
+// changers once for all repos
+func (s *RunSpec) compileEdits(ctx context.Context) (edits []ChangeFunc, err error) {
+	for i, e := range s.Edits {
+		e := e
+		switch e.Type {
+		case EditReplace:
+			var re *regexp.Regexp
+			if e.Regex != "" {
+				re = regexp.MustCompile(e.Regex)
+			}
+			edits = append(edits, func(gr *GitRepo) error {
+				return replaceInFiles(gr, e.Path, e.Old, re, e.New)
+			})
+		case EditYAMLSet:
+			edits = append(edits, func(gr *GitRepo) error {
+				return setYAMLFile(gr, e.Path, e.Key, e.Value)
+			})
+		case EditChanger:
+			c, err := NewChanger(e.Changer, e.Options)
+			if err != nil {
+				return edits, fmt.Errorf("edit %d: %w", i+1, err)
+			}
+			edits = append(edits, ChangersFunc(ctx, c))
+		case EditTemplate:
+			text := e.Template
+			if e.TemplateFile != "" {
+				b, err := os.ReadFile(e.TemplateFile)
+				if err != nil {
+					return edits, fmt.Errorf("edit %d: %w", i+1, err)
+				}
+				text = string(b)
+			}
+			tmpl, err := template.New(e.Path).Option("missingkey=error").Parse(text)
+			if err != nil {
+				return edits, fmt.Errorf("edit %d: %w", i+1, err)
+			}
+			edits = append(edits, func(gr *GitRepo) error {
+				return renderTemplateFile(gr, e.Path, tmpl, templateData{
+					Repo:   gr.SSHURL,
+					Branch: headBranch(gr),
+					Vars:   s.Vars,
+				})
+			})
+		}
+	}
+	return edits, nil
+}
+
+func replaceInFiles(gr *GitRepo, glob, old string, re *regexp.Regexp, new string) error {
+	files, err := gr.worktreeFiles(glob)
+	if err != nil {
+		return err
+	}
+
+	fs := gr.worktreeFilesystem()
+	for _, f := range files {
+		data, err := readWorktreeFile(fs, f.Path)
+		if err != nil {
+			return err
+		}
+
+		var out []byte
+		if re != nil {
+			out = re.ReplaceAll(data, []byte(new))
+		} else {
+			out = bytes.ReplaceAll(data, []byte(old), []byte(new))
+		}
+		if !bytes.Equal(out, data) {
+			err = gr.WriteFile(f.Path, out, f.Mode.Perm())
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func setYAMLFile(gr *GitRepo, path, key string, value interface{}) error {
+	fs := gr.worktreeFilesystem()
+	data, err := readWorktreeFile(fs, path)
+	if err != nil {
+		return err
+	}
+	out, err := SetYAMLValue(data, key, value)
+	if err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+	if bytes.Equal(out, data) {
+		return nil
+	}
+
+	info, err := fs.Stat(path)
+	if err != nil {
+		return err
+	}
+	return gr.WriteFile(path, out, info.Mode().Perm())
+}
+
+func renderTemplateFile(gr *GitRepo, path string, tmpl *template.Template, data templateData) error {
+	var buf bytes.Buffer
+	err := tmpl.Execute(&buf, data)
+	if err != nil {
+		return err
+	}
+
+	perm := os.FileMode(0644)
+	info, err := gr.worktreeFilesystem().Stat(path)
+	if err == nil {
+		perm = info.Mode().Perm()
+	}
+	return gr.WriteFile(path, buf.Bytes(), perm)
+}
+
+func headBranch(gr *GitRepo) string {
+	head, err := gr.Repo.Head()
+	if err != nil {
+		return ""
+	}
+	return strings.TrimPrefix(head.Name().String(), "refs/heads/")
+}
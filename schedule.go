@@ -0,0 +1,268 @@
+package githelpers
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Schedule says when a recurring job runs next
+type Schedule interface {
+	// Next returns the first time after t the job is due
+	Next(t time.Time) time.Time
+}
+
+// InvalidScheduleError is returned by ParseSchedule for a spec it can't read
+type InvalidScheduleError struct {
+	Spec   string
+	Reason string
+}
+
+func (e *InvalidScheduleError) Error() string {
+	return fmt.Sprintf("invalid schedule %q: %s", e.Spec, e.Reason)
+}
+
+// cronField is one field of a cron expression, as a set of the values it matches
+type cronField struct {
+	min, max int
+	names    map[string]int
+}
+
+var (
+	cronMinute = cronField{min: 0, max: 59}
+	cronHour   = cronField{min: 0, max: 23}
+	cronDom    = cronField{min: 1, max: 31}
+	cronMonth  = cronField{min: 1, max: 12, names: map[string]int{
+		"jan": 1, "feb": 2, "mar": 3, "apr": 4, "may": 5, "jun": 6,
+		"jul": 7, "aug": 8, "sep": 9, "oct": 10, "nov": 11, "dec": 12,
+	}}
+	// 7 is accepted for Sunday as well as 0, as most crons do
+	cronDow = cronField{min: 0, max: 7, names: map[string]int{
+		"sun": 0, "mon": 1, "tue": 2, "wed": 3, "thu": 4, "fri": 5, "sat": 6,
+	}}
+
+	cronMacros = map[string]string{
+		"@yearly":   "0 0 1 1 *",
+		"@annually": "0 0 1 1 *",
+		"@monthly":  "0 0 1 * *",
+		"@weekly":   "0 0 * * 0",
+		"@daily":    "0 0 * * *",
+		"@midnight": "0 0 * * *",
+		"@hourly":   "0 * * * *",
+	}
+)
+
+// cronSchedule is a parsed five field cron expression. Each field is a bitmask of the values it matches
+type cronSchedule struct {
+	minute, hour, dom, month, dow uint64
+	// Set when the day of month or day of week field doesn't start with *. As in cron, a day matches
+	// when either restricted field does
+	domRestricted, dowRestricted bool
+	loc                          *time.Location
+}
+
+// everySchedule runs at a fixed interval
+type everySchedule struct {
+	interval time.Duration
+}
+
+// ParseSchedule parses a cron expression with the usual five fields (minute, hour, day of month, month,
+// and day of week), each a *, a value, a range, or a comma separated list of them, optionally with a
+// /step. Month and day names like jan or mon are accepted, as are the macros @hourly, @daily, @weekly,
+// @monthly, and @yearly, and "@every <duration>" for a fixed interval, e.g. "@every 90m".
+// Cron expressions are evaluated in loc, or in UTC when loc is nil
+func ParseSchedule(spec string, loc *time.Location) (Schedule, error) {
+	invalid := func(reason string) error {
+		return &InvalidScheduleError{Spec: spec, Reason: reason}
+	}
+
+	expr := strings.TrimSpace(spec)
+	if strings.HasPrefix(expr, "@every ") {
+		d, err := time.ParseDuration(strings.TrimSpace(strings.TrimPrefix(expr, "@every ")))
+		if err != nil {
+			return nil, invalid(err.Error())
+		}
+		if d < time.Second {
+			return nil, invalid("interval is shorter than a second")
+		}
+		return everySchedule{interval: d}, nil
+	}
+	if m, ok := cronMacros[strings.ToLower(expr)]; ok {
+		expr = m
+	}
+
+	fields := strings.Fields(expr)
+	if len(fields) != 5 {
+		return nil, invalid(fmt.Sprintf("want 5 fields, got %d", len(fields)))
+	}
+	if loc == nil {
+		loc = time.UTC
+	}
+	s := &cronSchedule{
+		domRestricted: !strings.HasPrefix(fields[2], "*"),
+		dowRestricted: !strings.HasPrefix(fields[4], "*"),
+		loc:           loc,
+	}
+	var err error
+	for i, f := range []struct {
+		field cronField
+		bits  *uint64
+	}{
+		{cronMinute, &s.minute},
+		{cronHour, &s.hour},
+		{cronDom, &s.dom},
+		{cronMonth, &s.month},
+		{cronDow, &s.dow},
+	} {
+		*f.bits, err = f.field.parse(fields[i])
+		if err != nil {
+			return nil, invalid(fmt.Sprintf("field %d: %s", i+1, err))
+		}
+	}
+	// Fold 7 onto Sunday
+	if s.dow&(1<<7) != 0 {
+		s.dow |= 1
+	}
+	return s, nil
+}
+
+func (f cronField) parse(expr string) (bits uint64, err error) {
+	for _, part := range strings.Split(expr, ",") {
+		rng, step := part, 1
+		if i := strings.Index(part, "/"); i >= 0 {
+			rng = part[:i]
+			step, err = strconv.Atoi(part[i+1:])
+			if err != nil || step <= 0 {
+				return 0, fmt.Errorf("invalid step in %q", part)
+			}
+		}
+
+		lo, hi := f.min, f.max
+		switch {
+		case rng == "*":
+		case strings.Contains(rng, "-"):
+			i := strings.Index(rng, "-")
+			lo, err = f.value(rng[:i])
+			if err != nil {
+				return 0, err
+			}
+			hi, err = f.value(rng[i+1:])
+			if err != nil {
+				return 0, err
+			}
+			if lo > hi {
+				return 0, fmt.Errorf("range %q runs backwards", rng)
+			}
+		default:
+			lo, err = f.value(rng)
+			if err != nil {
+				return 0, err
+			}
+			// A single value with a step, e.g. 5/15, runs from the value to the end of the field
+			if step > 1 {
+				hi = f.max
+			} else {
+				hi = lo
+			}
+		}
+
+		for v := lo; v <= hi; v += step {
+			bits |= 1 << uint(v)
+		}
+	}
+	return bits, nil
+}
+
+func (f cronField) value(s string) (int, error) {
+	if v, ok := f.names[strings.ToLower(s)]; ok {
+		return v, nil
+	}
+	v, err := strconv.Atoi(s)
+	if err != nil {
+		return 0, fmt.Errorf("invalid value %q", s)
+	}
+	if v < f.min || v > f.max {
+		return 0, fmt.Errorf("value %d is outside %d-%d", v, f.min, f.max)
+	}
+	return v, nil
+}
+
+func (s everySchedule) Next(t time.Time) time.Time {
+	return t.Add(s.interval)
+}
+
+// Next walks wall clock times in the schedule's location. When the clocks go back, a time in the
+// repeated hour is due only the first time round, and a time skipped when they go forward is due as
+// soon as they have, as Vixie cron does for jobs at fixed times
+func (s *cronSchedule) Next(t time.Time) time.Time {
+	orig := t.Location()
+	t = t.In(s.loc)
+	// Wall clock times are kept in UTC, which has no hours to skip or repeat
+	w := time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), 0, 0, time.UTC).Add(time.Minute)
+
+	// Every match repeats within a few years (Feb 29 on a given weekday is the slowest), so anything
+	// further out means the spec can never match, e.g. 0 0 31 2 *
+	limit := w.AddDate(5, 0, 0)
+	for w.Before(limit) {
+		switch {
+		case s.month&(1<<uint(w.Month())) == 0:
+			w = time.Date(w.Year(), w.Month()+1, 1, 0, 0, 0, 0, time.UTC)
+		case !s.dayMatches(w):
+			w = time.Date(w.Year(), w.Month(), w.Day()+1, 0, 0, 0, 0, time.UTC)
+		case s.hour&(1<<uint(w.Hour())) == 0:
+			w = time.Date(w.Year(), w.Month(), w.Day(), w.Hour()+1, 0, 0, 0, time.UTC)
+		case s.minute&(1<<uint(w.Minute())) == 0:
+			w = w.Add(time.Minute)
+		default:
+			if next, ok := s.instant(w); ok && next.After(t) {
+				return next.In(orig)
+			}
+			w = w.Add(time.Minute)
+		}
+	}
+	return time.Time{}
+}
+
+// instant returns the first time the clocks in the schedule's location read the wall clock time w,
+// or, if they skip it going forward, when they've just done so
+func (s *cronSchedule) instant(w time.Time) (time.Time, bool) {
+	// The offsets before and after any change of the clocks around w
+	_, before := w.Add(-24 * time.Hour).In(s.loc).Zone()
+	_, after := w.Add(24 * time.Hour).In(s.loc).Zone()
+
+	var first time.Time
+	for _, off := range []int{before, after} {
+		c := w.Add(-time.Duration(off) * time.Second)
+		if _, o := c.In(s.loc).Zone(); o == off && (first.IsZero() || c.Before(first)) {
+			first = c
+		}
+	}
+	if !first.IsZero() {
+		return first, true
+	}
+	if before == after {
+		return time.Time{}, false
+	}
+
+	// w is in a gap, which starts somewhere between when the clocks would read w with either offset
+	lo, hi := w.Add(-time.Duration(after)*time.Second), w.Add(-time.Duration(before)*time.Second)
+	for hi.Sub(lo) > time.Second {
+		mid := lo.Add(hi.Sub(lo) / 2).Truncate(time.Second)
+		if _, o := mid.In(s.loc).Zone(); o == after {
+			hi = mid
+		} else {
+			lo = mid
+		}
+	}
+	return hi, true
+}
+
+func (s *cronSchedule) dayMatches(t time.Time) bool {
+	dom := s.dom&(1<<uint(t.Day())) != 0
+	dow := s.dow&(1<<uint(t.Weekday())) != 0
+	if s.domRestricted && s.dowRestricted {
+		return dom || dow
+	}
+	return dom && dow
+}
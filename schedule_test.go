@@ -0,0 +1,74 @@
+package githelpers
+
+import (
+	"testing"
+	"time"
+	_ "time/tzdata"
+)
+
+func TestScheduleNext(t *testing.T) {
+	ny, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Fatal(err)
+	}
+	utc := func(s string) time.Time {
+		v, err := time.Parse(time.RFC3339, s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return v
+	}
+
+	tests := []struct {
+		spec string
+		loc  *time.Location
+		from string
+		want []string
+	}{
+		{"*/15 * * * *", nil, "2026-01-01T10:07:30Z", []string{"2026-01-01T10:15:00Z", "2026-01-01T10:30:00Z", "2026-01-01T10:45:00Z", "2026-01-01T11:00:00Z"}},
+		{"5/20 9-10 * * *", nil, "2026-01-01T09:50:00Z", []string{"2026-01-01T10:05:00Z", "2026-01-01T10:25:00Z", "2026-01-01T10:45:00Z", "2026-01-02T09:05:00Z"}},
+		{"0 12 * jan,feb mon-fri", nil, "2026-02-27T12:00:00Z", []string{"2027-01-01T12:00:00Z"}},
+		{"0 0 * * SUN", nil, "2026-01-01T00:00:00Z", []string{"2026-01-04T00:00:00Z", "2026-01-11T00:00:00Z"}},
+		{"0 0 * * 7", nil, "2026-01-01T00:00:00Z", []string{"2026-01-04T00:00:00Z"}},
+		// A day matches when either the day of month or the day of week does
+		{"0 0 1 * fri", nil, "2026-01-31T00:00:00Z", []string{"2026-02-01T00:00:00Z", "2026-02-06T00:00:00Z", "2026-02-13T00:00:00Z", "2026-02-20T00:00:00Z", "2026-02-27T00:00:00Z", "2026-03-01T00:00:00Z", "2026-03-06T00:00:00Z"}},
+		{"@monthly", nil, "2026-01-15T00:00:00Z", []string{"2026-02-01T00:00:00Z", "2026-03-01T00:00:00Z"}},
+		{"0 0 29 2 *", nil, "2026-01-01T00:00:00Z", []string{"2028-02-29T00:00:00Z"}},
+		{"0 0 31 2 *", nil, "2026-01-01T00:00:00Z", []string{"0001-01-01T00:00:00Z"}},
+		{"@every 90m", nil, "2026-01-01T10:07:30Z", []string{"2026-01-01T11:37:30Z", "2026-01-01T13:07:30Z"}},
+		{"0 9 * * *", ny, "2026-01-01T00:00:00Z", []string{"2026-01-01T14:00:00Z", "2026-01-02T14:00:00Z"}},
+		// 2026-11-01 01:30 happens twice in New York, first in EDT, and the job runs only then
+		{"30 1 * * *", ny, "2026-10-31T12:00:00Z", []string{"2026-11-01T05:30:00Z", "2026-11-02T06:30:00Z"}},
+		{"*/20 1 * * *", ny, "2026-11-01T05:00:00Z", []string{"2026-11-01T05:20:00Z", "2026-11-01T05:40:00Z", "2026-11-02T06:00:00Z"}},
+		// Starting in the repeated hour doesn't run what already ran in it
+		{"30 1 * * *", ny, "2026-11-01T06:10:00Z", []string{"2026-11-02T06:30:00Z"}},
+		// 2026-03-08 02:30 doesn't happen in New York, so the job runs when the clocks go forward at 3:00 EDT
+		{"30 2 * * *", ny, "2026-03-07T12:00:00Z", []string{"2026-03-08T07:00:00Z", "2026-03-09T06:30:00Z"}},
+		{"*/15 2 * * *", ny, "2026-03-08T00:00:00Z", []string{"2026-03-08T07:00:00Z", "2026-03-09T06:00:00Z"}},
+		{"15 3 * * *", ny, "2026-03-08T00:00:00Z", []string{"2026-03-08T07:15:00Z"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.spec, func(t *testing.T) {
+			s, err := ParseSchedule(tt.spec, tt.loc)
+			if err != nil {
+				t.Fatal(err)
+			}
+			next := utc(tt.from)
+			for _, w := range tt.want {
+				next = s.Next(next)
+				if !next.Equal(utc(w)) {
+					t.Fatalf("from %s: got %s, want %s", tt.from, next.UTC().Format(time.RFC3339), w)
+				}
+			}
+		})
+	}
+}
+
+func TestParseScheduleErrors(t *testing.T) {
+	for _, spec := range []string{"", "* * * *", "60 * * * *", "* 24 * * *", "5-1 * * * *", "*/0 * * * *", "* * * foo *", "@every 10ms", "@every soon"} {
+		_, err := ParseSchedule(spec, nil)
+		if _, ok := err.(*InvalidScheduleError); !ok {
+			t.Errorf("%q: got %v, want an InvalidScheduleError", spec, err)
+		}
+	}
+}
@@ -0,0 +1,285 @@
+package githelpers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	defaultSchedulerHistorySize = 20
+)
+
+var (
+	// ErrJobExists is returned when adding a job under a name that's already taken
+	ErrJobExists = errors.New("a job with this name already exists")
+	// ErrJobNotFound is returned for operations on a job name that was never added
+	ErrJobNotFound = errors.New("no job with this name")
+	// ErrJobRunning is returned by RunNow when the job's previous run hasn't finished
+	ErrJobRunning = errors.New("job is already running")
+)
+
+// JobFunc is the work of a scheduled job. ctx is canceled when the Scheduler stops
+type JobFunc func(ctx context.Context) error
+
+// JobRun records one time a job was due
+type JobRun struct {
+	Job     string
+	Start   time.Time
+	End     time.Time
+	Err     error
+	Skipped bool // The job was due while its previous run was still going, so this run didn't happen
+}
+
+// Duration returns how long the run took
+func (r JobRun) Duration() time.Duration {
+	return r.End.Sub(r.Start)
+}
+
+type scheduledJob struct {
+	name     string
+	schedule Schedule
+	fn       JobFunc
+	next     time.Time
+	running  bool
+	history  []JobRun
+}
+
+// Scheduler runs registered jobs on their schedules, e.g. a nightly fleet run, a mirror sync every few
+// minutes, and a weekly sweep of stale MRs. A job never overlaps itself: when it comes due while its
+// previous run is still going, the run is skipped and recorded as such in its history
+type Scheduler struct {
+	Location    *time.Location // Time zone cron expressions are evaluated in. Defaults to UTC
+	HistorySize int            // Number of runs kept per job. Defaults to 20
+
+	mu   sync.Mutex
+	jobs map[string]*scheduledJob
+	wake chan struct{}
+	wg   sync.WaitGroup
+}
+
+// NewScheduler returns a Scheduler with no jobs
+func NewScheduler() *Scheduler {
+	return &Scheduler{}
+}
+
+// AddJob registers fn to run on spec, in the format ParseSchedule reads
+func (s *Scheduler) AddJob(name, spec string, fn JobFunc) error {
+	sched, err := ParseSchedule(spec, s.Location)
+	if err != nil {
+		return err
+	}
+	return s.AddJobSchedule(name, sched, fn)
+}
+
+// AddJobSchedule registers fn to run on sched
+func (s *Scheduler) AddJobSchedule(name string, sched Schedule, fn JobFunc) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.jobs == nil {
+		s.jobs = map[string]*scheduledJob{}
+	}
+	if _, ok := s.jobs[name]; ok {
+		return fmt.Errorf("%s: %w", name, ErrJobExists)
+	}
+	s.jobs[name] = &scheduledJob{name: name, schedule: sched, fn: fn, next: sched.Next(time.Now())}
+	s.poke()
+	return nil
+}
+
+// RemoveJob unregisters a job. A run in progress is left to finish
+func (s *Scheduler) RemoveJob(name string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.jobs, name)
+	s.poke()
+}
+
+// Jobs returns the names of the registered jobs, sorted
+func (s *Scheduler) Jobs() (names []string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for name := range s.jobs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// NextRun returns when a job is next due, or the zero time if its schedule never matches again
+func (s *Scheduler) NextRun(name string) (time.Time, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	j, ok := s.jobs[name]
+	if !ok {
+		return time.Time{}, fmt.Errorf("%s: %w", name, ErrJobNotFound)
+	}
+	return j.next, nil
+}
+
+// History returns a job's most recent runs, oldest first
+func (s *Scheduler) History(name string) ([]JobRun, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	j, ok := s.jobs[name]
+	if !ok {
+		return nil, fmt.Errorf("%s: %w", name, ErrJobNotFound)
+	}
+	return append([]JobRun(nil), j.history...), nil
+}
+
+// RunNow runs a job right away, outside its schedule, and waits for it to finish. It returns
+// ErrJobRunning instead when the job is already running
+func (s *Scheduler) RunNow(ctx context.Context, name string) error {
+	s.mu.Lock()
+	j, ok := s.jobs[name]
+	if !ok {
+		s.mu.Unlock()
+		return fmt.Errorf("%s: %w", name, ErrJobNotFound)
+	}
+	if j.running {
+		s.mu.Unlock()
+		return fmt.Errorf("%s: %w", name, ErrJobRunning)
+	}
+	j.running = true
+	s.mu.Unlock()
+
+	return s.runJob(ctx, j)
+}
+
+// Run starts jobs as they come due until ctx is canceled, then waits for the runs in progress to
+// finish and returns ctx's error
+func (s *Scheduler) Run(ctx context.Context) error {
+	wake := s.wakeChan()
+
+	timer := time.NewTimer(0)
+	defer timer.Stop()
+	for {
+		now := time.Now()
+		next := s.startDue(ctx, now)
+
+		if !timer.Stop() {
+			select {
+			case <-timer.C:
+			default:
+			}
+		}
+		if next.IsZero() {
+			// Nothing is scheduled. Sleep until a job is added
+			next = now.Add(24 * time.Hour)
+		}
+		timer.Reset(next.Sub(now))
+
+		select {
+		case <-ctx.Done():
+			s.wg.Wait()
+			return ctx.Err()
+		case <-timer.C:
+		case <-wake:
+		}
+	}
+}
+
+// startDue starts every job due at now and returns when the next one is due
+func (s *Scheduler) startDue(ctx context.Context, now time.Time) (next time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, j := range s.jobs {
+		if !j.next.IsZero() && !j.next.After(now) {
+			if j.running {
+				s.record(j, JobRun{Job: j.name, Start: now, End: now, Skipped: true})
+			} else {
+				j.running = true
+				s.wg.Add(1)
+				go func(j *scheduledJob) {
+					defer s.wg.Done()
+					s.runJob(ctx, j)
+				}(j)
+			}
+			j.next = j.schedule.Next(now)
+		}
+
+		if !j.next.IsZero() && (next.IsZero() || j.next.Before(next)) {
+			next = j.next
+		}
+	}
+	return next
+}
+
+// runJob runs a job already marked as running and records the run
+func (s *Scheduler) runJob(ctx context.Context, j *scheduledJob) error {
+	run := JobRun{Job: j.name, Start: time.Now()}
+	run.Err = j.fn(ctx)
+	run.End = time.Now()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	j.running = false
+	s.record(j, run)
+	return run.Err
+}
+
+func (s *Scheduler) record(j *scheduledJob, run JobRun) {
+	size := s.HistorySize
+	if size <= 0 {
+		size = defaultSchedulerHistorySize
+	}
+	j.history = append(j.history, run)
+	if len(j.history) > size {
+		j.history = append([]JobRun(nil), j.history[len(j.history)-size:]...)
+	}
+}
+
+// poke wakes a running Run loop so it picks up changed jobs. s.mu must be held
+func (s *Scheduler) poke() {
+	if s.wake == nil {
+		s.wake = make(chan struct{}, 1)
+	}
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+}
+
+func (s *Scheduler) wakeChan() chan struct{} {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.wake == nil {
+		s.wake = make(chan struct{}, 1)
+	}
+	return s.wake
+}
+
+// FleetJob returns a JobFunc that runs change across f's repos, as Fleet.Run does, and fails with the
+// repos that failed
+func FleetJob(f *Fleet, commitMsg, branch string, change ChangeFunc) JobFunc {
+	return func(ctx context.Context) error {
+		return FleetErrors(f.Run(ctx, commitMsg, branch, change))
+	}
+}
+
+// MirrorJob returns a JobFunc that syncs m once
+func MirrorJob(m *Mirror) JobFunc {
+	return func(ctx context.Context) error {
+		_, err := m.Sync()
+		return err
+	}
+}
+
+// StaleMRJob returns a JobFunc that closes gr's stale MRs, as CloseStaleMRs does
+func StaleMRJob(gr *GitRepo, olderThan time.Duration, label string) JobFunc {
+	return func(ctx context.Context) error {
+		_, _, err := gr.CloseStaleMRs(olderThan, label)
+		return err
+	}
+}
@@ -0,0 +1,81 @@
+package githelpers
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	gitSSH "github.com/go-git/go-git/v5/plumbing/transport/ssh"
+)
+
+var (
+	// ErrSecretNotFound is wrapped by the errors SecretSources return for secrets they don't have
+	ErrSecretNotFound = errors.New("secret not found")
+)
+
+// SecretSource looks up secrets, e.g. SSH private keys or API tokens, by name wherever they're kept,
+// so credentials can go straight from a secrets manager into memory without ever being written to disk
+type SecretSource interface {
+	Secret(ctx context.Context, name string) ([]byte, error)
+}
+
+// SecretSourceFunc adapts a function to a SecretSource, e.g. to read secrets with a cloud SDK client
+type SecretSourceFunc func(ctx context.Context, name string) ([]byte, error)
+
+// Secret calls f
+func (f SecretSourceFunc) Secret(ctx context.Context, name string) ([]byte, error) {
+	return f(ctx, name)
+}
+
+// EnvSecretSource reads secrets from environment variables named Prefix followed by the secret's name,
+// e.g. GITLAB_TOKEN for the name TOKEN with the prefix GITLAB_
+type EnvSecretSource struct {
+	Prefix string
+}
+
+// Secret returns the value of the secret's environment variable, which must be set and not empty
+func (s EnvSecretSource) Secret(ctx context.Context, name string) ([]byte, error) {
+	v := os.Getenv(s.Prefix + name)
+	if v == "" {
+		return nil, fmt.Errorf("%s%s is not set: %w", s.Prefix, name, ErrSecretNotFound)
+	}
+	return []byte(v), nil
+}
+
+// FileSecretSource reads secrets from files, e.g. ones a secrets manager mounted into a container,
+// named relative to Dir, or to the working directory when Dir is empty
+type FileSecretSource struct {
+	Dir string
+}
+
+// Secret returns the contents of the secret's file. A missing file gives the error os.ReadFile does,
+// which matches os.ErrNotExist
+func (s FileSecretSource) Secret(ctx context.Context, name string) ([]byte, error) {
+	path := name
+	if s.Dir != "" && !filepath.IsAbs(name) {
+		path = filepath.Join(s.Dir, name)
+	}
+	return os.ReadFile(path)
+}
+
+// SSHKeyFromSecret returns the SSH auth for the PEM encoded private key src keeps under name
+func SSHKeyFromSecret(ctx context.Context, src SecretSource, name string) (*gitSSH.PublicKeys, error) {
+	pem, err := src.Secret(ctx, name)
+	if err != nil {
+		return &gitSSH.PublicKeys{}, err
+	}
+	return sshPublicKeys(pem)
+}
+
+// AddGitlabClientFromSecret creates a GitLab client, as AddGitlabClient does, with the token src keeps
+// under name. Whitespace around the token, e.g. a file's final newline, is dropped
+func (gr *GitRepo) AddGitlabClientFromSecret(ctx context.Context, src SecretSource, name string, opts ...GitlabClientOption) error {
+	token, err := src.Secret(ctx, name)
+	if err != nil {
+		return err
+	}
+	return gr.AddGitlabClient(string(bytes.TrimSpace(token)), opts...)
+}
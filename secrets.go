@@ -0,0 +1,219 @@
+package githelpers
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"math"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+const (
+	// SecretAllowMarker on a line keeps the scanner from flagging anything on it, e.g. a documented example key
+	SecretAllowMarker = "githelpers:allow-secret"
+
+	defaultGenericSecretEntropy = 3.5
+)
+
+// SecretRule is one kind of credential a SecretScanner looks for. When Pattern has a capture group, the
+// first one is the secret, otherwise the whole match is
+type SecretRule struct {
+	Name    string
+	Pattern *regexp.Regexp
+	// Matches with a lower Shannon entropy, in bits per character, are ignored. Keeps rules for
+	// generic assignments like password = ... from flagging placeholders. Zero means no threshold
+	MinEntropy float64
+}
+
+// DefaultSecretRules are the rules a SecretScanner uses when it has none of its own
+var DefaultSecretRules = []SecretRule{
+	{Name: "aws-access-key-id", Pattern: regexp.MustCompile(`\b((?:AKIA|ASIA|ABIA|ACCA)[0-9A-Z]{16})\b`)},
+	{Name: "aws-secret-access-key", Pattern: regexp.MustCompile(`(?i)aws.{0,20}(?:secret|private).{0,20}['"]?\s*[:=]\s*['"]?([0-9a-zA-Z/+]{40})\b`)},
+	{Name: "gitlab-token", Pattern: regexp.MustCompile(`\b(gl(?:pat|dt|rt|ptt|ft|soat|cbt)-[0-9a-zA-Z_\-]{20,})`)},
+	{Name: "github-token", Pattern: regexp.MustCompile(`\b((?:ghp|gho|ghu|ghs|ghr)_[0-9a-zA-Z]{36,}|github_pat_[0-9a-zA-Z_]{80,})\b`)},
+	{Name: "slack-token", Pattern: regexp.MustCompile(`\b(xox[baprs]-[0-9a-zA-Z\-]{10,})`)},
+	{Name: "private-key", Pattern: regexp.MustCompile(`-----BEGIN[ A-Z0-9]*PRIVATE KEY( BLOCK)?-----`)},
+	{
+		Name:       "generic-secret",
+		Pattern:    regexp.MustCompile(`(?i)(?:password|passwd|secret|token|api[_\-]?key|access[_\-]?key)["']?\s*[:=]\s*["']?([^\s"'$]{16,})`),
+		MinEntropy: defaultGenericSecretEntropy,
+	},
+}
+
+// SecretFinding is a potential secret found by a SecretScanner
+type SecretFinding struct {
+	Path   string
+	Line   int
+	Rule   string
+	Secret string // Redacted down to its first 4 characters
+}
+
+// SecretsFoundError lists the potential secrets that kept CommitAll from committing
+type SecretsFoundError struct {
+	Findings []SecretFinding
+}
+
+func (e *SecretsFoundError) Error() string {
+	var found []string
+	for _, f := range e.Findings {
+		found = append(found, fmt.Sprintf("%s:%d (%s)", f.Path, f.Line, f.Rule))
+	}
+	return "refusing to commit potential secrets: " + strings.Join(found, ", ")
+}
+
+// SecretScanner looks for credentials in file contents, combining regexes for well known token formats
+// with an entropy check for generic assignments. Findings can be allowed by path, by value, or by
+// putting SecretAllowMarker on the line
+type SecretScanner struct {
+	Rules        []SecretRule // Defaults to DefaultSecretRules
+	AllowPaths   []string     // .gitignore style globs of files never scanned, e.g. "testdata/**"
+	AllowSecrets []string     // Regexes of secrets that are fine to commit, e.g. AWS's documented example keys
+}
+
+// NewSecretScanner returns a SecretScanner with the default rules
+func NewSecretScanner() *SecretScanner {
+	return &SecretScanner{}
+}
+
+// Scan returns the potential secrets in data, the contents of the file at path
+func (s *SecretScanner) Scan(path string, data []byte) (findings []SecretFinding, err error) {
+	if matchesAny(parseGlobs(s.AllowPaths), strings.Split(path, "/"), false) {
+		return findings, nil
+	}
+	allowed, err := s.allowedSecrets()
+	if err != nil {
+		return findings, err
+	}
+	rules := s.Rules
+	if rules == nil {
+		rules = DefaultSecretRules
+	}
+
+	sc := bufio.NewScanner(bytes.NewReader(data))
+	sc.Buffer(nil, len(data)+1)
+	for line := 1; sc.Scan(); line++ {
+		text := sc.Text()
+		if strings.Contains(text, SecretAllowMarker) {
+			continue
+		}
+
+		// Rules overlap, e.g. a token assigned to a variable named token, so report each secret once
+		seen := map[string]bool{}
+		for _, rule := range rules {
+			for _, m := range rule.Pattern.FindAllStringSubmatch(text, -1) {
+				secret := m[0]
+				if len(m) > 1 && m[1] != "" {
+					secret = m[1]
+				}
+				if rule.MinEntropy > 0 && shannonEntropy(secret) < rule.MinEntropy {
+					continue
+				}
+				if seen[secret] || matchesAnyRegexp(allowed, secret) {
+					continue
+				}
+				seen[secret] = true
+				findings = append(findings, SecretFinding{Path: path, Line: line, Rule: rule.Name, Secret: redactSecret(secret)})
+			}
+		}
+	}
+	return findings, sc.Err()
+}
+
+// ScanForSecrets scans the files CommitAll would stage and returns a *SecretsFoundError listing the
+// potential secrets in them. A nil scanner uses NewSecretScanner
+func (gr *GitRepo) ScanForSecrets(scanner *SecretScanner) error {
+	if scanner == nil {
+		scanner = NewSecretScanner()
+	}
+	files, err := pendingFiles(gr.Repo, gr.Worktree, false)
+	if err != nil {
+		return err
+	}
+	return scanSecrets(files, scanner)
+}
+
+func scanSecrets(files []pendingFile, scanner *SecretScanner) error {
+	var findings []SecretFinding
+	for _, f := range files {
+		if f.deleted || !f.regular {
+			continue
+		}
+		binary, err := isBinaryFile(f)
+		if err != nil {
+			return err
+		}
+		if binary {
+			continue
+		}
+
+		data, err := f.contents()
+		if err != nil {
+			return err
+		}
+		found, err := scanner.Scan(f.path, data)
+		if err != nil {
+			return err
+		}
+		findings = append(findings, found...)
+	}
+
+	if len(findings) == 0 {
+		return nil
+	}
+	sort.Slice(findings, func(i, j int) bool {
+		if findings[i].Path != findings[j].Path {
+			return findings[i].Path < findings[j].Path
+		}
+		return findings[i].Line < findings[j].Line
+	})
+	return &SecretsFoundError{Findings: findings}
+}
+
+func (s *SecretScanner) allowedSecrets() (res []*regexp.Regexp, err error) {
+	for _, a := range s.AllowSecrets {
+		re, err := regexp.Compile(a)
+		if err != nil {
+			return res, fmt.Errorf("secret allowlist: %w", err)
+		}
+		res = append(res, re)
+	}
+	return res, nil
+}
+
+func matchesAnyRegexp(res []*regexp.Regexp, s string) bool {
+	for _, re := range res {
+		if re.MatchString(s) {
+			return true
+		}
+	}
+	return false
+}
+
+// shannonEntropy returns the entropy of s in bits per character. A random 20 character token scores
+// about 4, English words and placeholders like changeme-please well under 3.5
+func shannonEntropy(s string) float64 {
+	if s == "" {
+		return 0
+	}
+	counts := map[rune]int{}
+	n := 0
+	for _, r := range s {
+		counts[r]++
+		n++
+	}
+	var h float64
+	for _, c := range counts {
+		p := float64(c) / float64(n)
+		h -= p * math.Log2(p)
+	}
+	return h
+}
+
+func redactSecret(s string) string {
+	if len(s) <= 4 {
+		return strings.Repeat("*", len(s))
+	}
+	return s[:4] + strings.Repeat("*", 8)
+}
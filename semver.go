@@ -0,0 +1,269 @@
+package githelpers
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/config"
+	"github.com/go-git/go-git/v5/plumbing"
+)
+
+const (
+	// BumpMajor, BumpMinor, and BumpPatch name the version component NextVersion increments
+	BumpMajor = "major"
+	BumpMinor = "minor"
+	BumpPatch = "patch"
+	// BumpAuto picks the bump from the Conventional Commits since the latest tag: major for breaking
+	// changes, minor for features, and patch for anything else
+	BumpAuto = "auto"
+)
+
+var (
+	semverPattern = regexp.MustCompile(`^(v?)(0|[1-9]\d*)\.(0|[1-9]\d*)\.(0|[1-9]\d*)(?:-([0-9A-Za-z.-]+))?(?:\+[0-9A-Za-z.-]+)?$`)
+)
+
+// Version is a parsed semantic version, see https://semver.org
+type Version struct {
+	Prefix     string // "v" when the tag had one
+	Major      int
+	Minor      int
+	Patch      int
+	Prerelease string
+}
+
+// ParseVersion parses a semantic version such as v1.2.3 or 1.2.3-rc.1. Build metadata is dropped
+func ParseVersion(s string) (v Version, err error) {
+	m := semverPattern.FindStringSubmatch(s)
+	if m == nil {
+		return v, fmt.Errorf("not a semantic version: %s", s)
+	}
+	v.Prefix, v.Prerelease = m[1], m[5]
+	v.Major, _ = strconv.Atoi(m[2])
+	v.Minor, _ = strconv.Atoi(m[3])
+	v.Patch, _ = strconv.Atoi(m[4])
+	return v, nil
+}
+
+// String formats the Version back into a tag name
+func (v Version) String() string {
+	s := fmt.Sprintf("%s%d.%d.%d", v.Prefix, v.Major, v.Minor, v.Patch)
+	if v.Prerelease != "" {
+		s += "-" + v.Prerelease
+	}
+	return s
+}
+
+// Less reports whether v has a lower precedence than o
+func (v Version) Less(o Version) bool {
+	if v.Major != o.Major {
+		return v.Major < o.Major
+	}
+	if v.Minor != o.Minor {
+		return v.Minor < o.Minor
+	}
+	if v.Patch != o.Patch {
+		return v.Patch < o.Patch
+	}
+	// A prerelease comes before the release itself
+	if v.Prerelease == "" || o.Prerelease == "" {
+		return v.Prerelease != "" && o.Prerelease == ""
+	}
+	return prereleaseLess(v.Prerelease, o.Prerelease)
+}
+
+// Bump returns the release following v for the given bump. Bumping a prerelease of the same
+// component releases it, e.g. a patch bump of 1.2.3-rc.1 is 1.2.3
+func (v Version) Bump(bump string) (next Version, err error) {
+	next = Version{Prefix: v.Prefix, Major: v.Major, Minor: v.Minor, Patch: v.Patch}
+	pre := v.Prerelease != ""
+
+	switch bump {
+	case BumpMajor:
+		if !pre || v.Minor != 0 || v.Patch != 0 {
+			next.Major, next.Minor, next.Patch = v.Major+1, 0, 0
+		}
+	case BumpMinor:
+		if !pre || v.Patch != 0 {
+			next.Minor, next.Patch = v.Minor+1, 0
+		}
+	case BumpPatch:
+		if !pre {
+			next.Patch = v.Patch + 1
+		}
+	default:
+		return next, fmt.Errorf("unknown version bump: %s", bump)
+	}
+	return next, nil
+}
+
+// LatestSemverTag returns the tag with the highest semantic version in the repo, ignoring tags that
+// aren't semantic versions. It returns an empty tag when there are none
+func (gr *GitRepo) LatestSemverTag() (tag string, err error) {
+	return gr.latestTag(func(name string) (string, bool) { return name, true })
+}
+
+// NextVersion returns the tag name of the version after LatestSemverTag for bump, which is one of
+// BumpMajor, BumpMinor, BumpPatch, or BumpAuto. With no semver tags yet, the first version is v0.1.0
+func (gr *GitRepo) NextVersion(bump string) (tag string, err error) {
+	return gr.nextVersion("", "", bump)
+}
+
+// TagNextVersion creates the tag NextVersion returns on HEAD, annotated with message, and returns it.
+// An empty message creates a lightweight tag. Push it with PushTag
+func (gr *GitRepo) TagNextVersion(bump, message string) (tag string, err error) {
+	tag, err = gr.NextVersion(bump)
+	if err != nil {
+		return tag, err
+	}
+	return tag, gr.createTag(tag, message)
+}
+
+// PushTag pushes the named tag to the default remote
+func (gr *GitRepo) PushTag(tag string) error {
+	gr.mu.Lock()
+	defer gr.mu.Unlock()
+
+	ref := config.RefSpec(fmt.Sprintf("refs/tags/%s:refs/tags/%s", tag, tag))
+	return gr.Repo.Push(&git.PushOptions{
+		Auth:       gr.remoteAuth(gr.remoteName()),
+		RemoteName: gr.remoteName(),
+		RefSpecs:   []config.RefSpec{ref},
+	})
+}
+
+func (gr *GitRepo) latestTag(version func(name string) (string, bool)) (tag string, err error) {
+	// version maps a tag name to the part holding the version, or reports that the tag doesn't apply
+	tags, err := gr.Repo.Tags()
+	if err != nil {
+		return tag, err
+	}
+
+	var latest Version
+	err = tags.ForEach(func(ref *plumbing.Reference) error {
+		name := ref.Name().Short()
+		s, ok := version(name)
+		if !ok {
+			return nil
+		}
+		v, err := ParseVersion(s)
+		if err != nil {
+			return nil
+		}
+		if tag == "" || latest.Less(v) {
+			tag, latest = name, v
+		}
+		return nil
+	})
+	return tag, err
+}
+
+func (gr *GitRepo) nextVersion(prefix, path, bump string) (tag string, err error) {
+	latest, err := gr.latestTag(func(name string) (string, bool) {
+		if !strings.HasPrefix(name, prefix) {
+			return "", false
+		}
+		return strings.TrimPrefix(name, prefix), true
+	})
+	if err != nil {
+		return tag, err
+	}
+
+	if bump == BumpAuto {
+		bump, err = gr.autoBump(latest, path)
+		if err != nil {
+			return tag, err
+		}
+	}
+
+	if latest == "" {
+		if bump != BumpMajor && bump != BumpMinor && bump != BumpPatch {
+			return tag, fmt.Errorf("unknown version bump: %s", bump)
+		}
+		return prefix + "v0.1.0", nil
+	}
+
+	v, err := ParseVersion(strings.TrimPrefix(latest, prefix))
+	if err != nil {
+		return tag, err
+	}
+	next, err := v.Bump(bump)
+	if err != nil {
+		return tag, err
+	}
+	return prefix + next.String(), nil
+}
+
+func (gr *GitRepo) autoBump(sinceTag, path string) (bump string, err error) {
+	// Only commits touching path count when it's set
+	commits, err := gr.commitsBetween(sinceTag, "")
+	if err != nil {
+		return bump, err
+	}
+
+	bump = BumpPatch
+	for _, c := range commits {
+		if path != "" {
+			touched, err := commitTouches(c, path)
+			if err != nil {
+				return bump, err
+			}
+			if !touched {
+				continue
+			}
+		}
+
+		msg, err := ParseCommitMessage(c.Message)
+		if err != nil {
+			continue
+		}
+		if msg.Breaking {
+			return BumpMajor, nil
+		}
+		if msg.Type == "feat" {
+			bump = BumpMinor
+		}
+	}
+	return bump, nil
+}
+
+func (gr *GitRepo) createTag(tag, message string) error {
+	gr.mu.Lock()
+	defer gr.mu.Unlock()
+
+	head, err := gr.Repo.Head()
+	if err != nil {
+		return err
+	}
+
+	var opts *git.CreateTagOptions
+	if message != "" {
+		opts = &git.CreateTagOptions{Message: message}
+	}
+	_, err = gr.Repo.CreateTag(tag, head.Hash(), opts)
+	return err
+}
+
+func prereleaseLess(a, b string) bool {
+	// Compares dot separated identifiers, numeric ones numerically and before alphanumeric ones
+	as, bs := strings.Split(a, "."), strings.Split(b, ".")
+	for i := 0; i < len(as) && i < len(bs); i++ {
+		if as[i] == bs[i] {
+			continue
+		}
+		an, aErr := strconv.Atoi(as[i])
+		bn, bErr := strconv.Atoi(bs[i])
+		switch {
+		case aErr == nil && bErr == nil:
+			return an < bn
+		case aErr == nil:
+			return true
+		case bErr == nil:
+			return false
+		}
+		return as[i] < bs[i]
+	}
+	return len(as) < len(bs)
+}
@@ -0,0 +1,122 @@
+package githelpers
+
+import (
+	"path"
+	"strings"
+
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+)
+
+// Component is a separately released part of a monorepo, versioned with tags like service-a/v1.2.3
+type Component struct {
+	TagPrefix string // Prepended to the version in tag names, e.g. "service-a/"
+	Path      string // Directory holding the component, e.g. "services/a"
+}
+
+// LatestComponentTag returns the component's tag with the highest semantic version, or an empty tag
+// when it has none yet
+func (gr *GitRepo) LatestComponentTag(c Component) (tag string, err error) {
+	return gr.latestTag(func(name string) (string, bool) {
+		if !strings.HasPrefix(name, c.TagPrefix) {
+			return "", false
+		}
+		return strings.TrimPrefix(name, c.TagPrefix), true
+	})
+}
+
+// ComponentChanged reports whether anything under the component's path changed between its latest
+// tag and HEAD. A component that was never tagged counts as changed
+func (gr *GitRepo) ComponentChanged(c Component) (changed bool, err error) {
+	tag, err := gr.LatestComponentTag(c)
+	if err != nil || tag == "" {
+		return true, err
+	}
+
+	from, err := gr.commitAt(tag)
+	if err != nil {
+		return changed, err
+	}
+	to, err := gr.commitAt("HEAD")
+	if err != nil {
+		return changed, err
+	}
+
+	changes, err := diffCommits(from, to)
+	if err != nil {
+		return changed, err
+	}
+	return changesTouch(changes, c.Path), nil
+}
+
+// NextComponentVersion is NextVersion for a monorepo component. BumpAuto only looks at the commits
+// that touched the component's path
+func (gr *GitRepo) NextComponentVersion(c Component, bump string) (tag string, err error) {
+	return gr.nextVersion(c.TagPrefix, c.Path, bump)
+}
+
+// TagNextComponentVersion is TagNextVersion for a monorepo component
+func (gr *GitRepo) TagNextComponentVersion(c Component, bump, message string) (tag string, err error) {
+	tag, err = gr.NextComponentVersion(c, bump)
+	if err != nil {
+		return tag, err
+	}
+	return tag, gr.createTag(tag, message)
+}
+
+func (gr *GitRepo) commitAt(rev string) (*object.Commit, error) {
+	h, err := gr.Repo.ResolveRevision(plumbing.Revision(rev))
+	if err != nil {
+		return nil, err
+	}
+	return gr.Repo.CommitObject(*h)
+}
+
+func diffCommits(from, to *object.Commit) (object.Changes, error) {
+	// A nil from diffs against the empty tree
+	var fromTree *object.Tree
+	if from != nil {
+		t, err := from.Tree()
+		if err != nil {
+			return nil, err
+		}
+		fromTree = t
+	}
+	toTree, err := to.Tree()
+	if err != nil {
+		return nil, err
+	}
+	return object.DiffTree(fromTree, toTree)
+}
+
+func commitTouches(c *object.Commit, dir string) (bool, error) {
+	// Compares with the first parent, as git log does for merges
+	var parent *object.Commit
+	if c.NumParents() > 0 {
+		p, err := c.Parent(0)
+		if err != nil {
+			return false, err
+		}
+		parent = p
+	}
+	changes, err := diffCommits(parent, c)
+	if err != nil {
+		return false, err
+	}
+	return changesTouch(changes, dir), nil
+}
+
+func changesTouch(changes object.Changes, dir string) bool {
+	dir = path.Clean(strings.Trim(dir, "/"))
+	for _, ch := range changes {
+		for _, name := range []string{ch.From.Name, ch.To.Name} {
+			if name == "" {
+				continue
+			}
+			if dir == "." || name == dir || strings.HasPrefix(name, dir+"/") {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,246 @@
+package githelpers
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"strings"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"golang.org/x/crypto/openpgp"
+	pgperrors "golang.org/x/crypto/openpgp/errors"
+	"golang.org/x/crypto/ssh"
+)
+
+const (
+	// SignatureMissing is the UnverifiedCommit reason for a commit that isn't signed
+	SignatureMissing = "unsigned"
+	// SignatureUnknownKey is the UnverifiedCommit reason for a commit signed by a key not in the keyring
+	SignatureUnknownKey = "unknown key"
+	// SignatureInvalid is the UnverifiedCommit reason for a signature that doesn't match the commit
+	SignatureInvalid = "invalid signature"
+
+	beginPGPKeyBlock = "-----BEGIN PGP PUBLIC KEY BLOCK-----"
+	endPGPKeyBlock   = "-----END PGP PUBLIC KEY BLOCK-----"
+	beginSSHSig      = "-----BEGIN SSH SIGNATURE-----"
+
+	sshSigMagic     = "SSHSIG"
+	sshSigNamespace = "git"
+)
+
+// UnverifiedCommit is a commit VerifyCommitSignatures couldn't verify, and why
+type UnverifiedCommit struct {
+	Hash   plumbing.Hash
+	Reason string // SignatureMissing, SignatureUnknownKey, or SignatureInvalid
+	Err    error  // What went wrong checking an invalid signature
+}
+
+// signatureKeyring holds the keys commit signatures are checked against
+type signatureKeyring struct {
+	pgp openpgp.EntityList
+	ssh []ssh.PublicKey
+}
+
+// VerifyCommitSignatures walks the history of ref and checks every commit's GPG or SSH signature
+// against keyring, returning the commits that aren't signed by one of its keys. keyring holds armored
+// or binary OpenPGP public keys, or SSH public keys one per line in authorized_keys or allowed_signers
+// format, or both mixed. OpenPGP keys must be RSA, DSA, or ECDSA; EdDSA ones aren't supported by the
+// openpgp package go-git uses. An empty ref means HEAD
+func (gr *GitRepo) VerifyCommitSignatures(ref string, keyring io.Reader) (unverified []UnverifiedCommit, err error) {
+	if ref == "" {
+		ref = "HEAD"
+	}
+	keys, err := readSignatureKeyring(keyring)
+	if err != nil {
+		return unverified, err
+	}
+	h, err := gr.Repo.ResolveRevision(plumbing.Revision(ref))
+	if err != nil {
+		return unverified, fmt.Errorf("resolving %s: %w", ref, err)
+	}
+
+	iter, err := gr.Repo.Log(&git.LogOptions{From: *h})
+	if err != nil {
+		return unverified, err
+	}
+	err = iter.ForEach(func(c *object.Commit) error {
+		reason, verr := keys.verify(c)
+		if reason != "" {
+			unverified = append(unverified, UnverifiedCommit{Hash: c.Hash, Reason: reason, Err: verr})
+		}
+		return nil
+	})
+	return unverified, err
+}
+
+// verify returns an empty reason when c is signed by one of the keys
+func (k *signatureKeyring) verify(c *object.Commit) (reason string, err error) {
+	if c.PGPSignature == "" {
+		return SignatureMissing, nil
+	}
+
+	encoded := &plumbing.MemoryObject{}
+	if err := c.EncodeWithoutSignature(encoded); err != nil {
+		return SignatureInvalid, err
+	}
+	r, err := encoded.Reader()
+	if err != nil {
+		return SignatureInvalid, err
+	}
+	defer r.Close()
+
+	if strings.HasPrefix(strings.TrimSpace(c.PGPSignature), beginSSHSig) {
+		return k.verifySSH(r, c.PGPSignature)
+	}
+	_, err = openpgp.CheckArmoredDetachedSignature(k.pgp, r, strings.NewReader(c.PGPSignature))
+	switch {
+	case err == pgperrors.ErrUnknownIssuer:
+		return SignatureUnknownKey, nil
+	case err != nil:
+		return SignatureInvalid, err
+	}
+	return "", nil
+}
+
+// verifySSH checks an armored signature in the SSHSIG format ssh-keygen -Y sign makes, which git uses
+// when gpg.format is ssh
+func (k *signatureKeyring) verifySSH(message io.Reader, armored string) (reason string, err error) {
+	block, _ := pem.Decode([]byte(armored))
+	if block == nil || !bytes.HasPrefix(block.Bytes, []byte(sshSigMagic)) {
+		return SignatureInvalid, errors.New("malformed SSH signature")
+	}
+	var sig struct {
+		Version       uint32
+		PublicKey     []byte
+		Namespace     string
+		Reserved      string
+		HashAlgorithm string
+		Signature     []byte
+	}
+	if err := ssh.Unmarshal(block.Bytes[len(sshSigMagic):], &sig); err != nil {
+		return SignatureInvalid, fmt.Errorf("malformed SSH signature: %w", err)
+	}
+	if sig.Namespace != sshSigNamespace {
+		return SignatureInvalid, fmt.Errorf("SSH signature for namespace %q, not %q", sig.Namespace, sshSigNamespace)
+	}
+
+	pub, err := ssh.ParsePublicKey(sig.PublicKey)
+	if err != nil {
+		return SignatureInvalid, err
+	}
+	if !k.hasSSHKey(pub) {
+		return SignatureUnknownKey, nil
+	}
+
+	var h hash.Hash
+	switch sig.HashAlgorithm {
+	case "sha512":
+		h = sha512.New()
+	case "sha256":
+		h = sha256.New()
+	default:
+		return SignatureInvalid, fmt.Errorf("unsupported SSH signature hash %q", sig.HashAlgorithm)
+	}
+	if _, err := io.Copy(h, message); err != nil {
+		return SignatureInvalid, err
+	}
+
+	var s ssh.Signature
+	if err := ssh.Unmarshal(sig.Signature, &s); err != nil {
+		return SignatureInvalid, fmt.Errorf("malformed SSH signature: %w", err)
+	}
+	signed := append([]byte(sshSigMagic), ssh.Marshal(struct {
+		Namespace     string
+		Reserved      string
+		HashAlgorithm string
+		Hash          []byte
+	}{sig.Namespace, sig.Reserved, sig.HashAlgorithm, h.Sum(nil)})...)
+	if err := pub.Verify(signed, &s); err != nil {
+		return SignatureInvalid, err
+	}
+	return "", nil
+}
+
+func (k *signatureKeyring) hasSSHKey(pub ssh.PublicKey) bool {
+	want := pub.Marshal()
+	for _, key := range k.ssh {
+		if bytes.Equal(key.Marshal(), want) {
+			return true
+		}
+	}
+	return false
+}
+
+func readSignatureKeyring(r io.Reader) (keys *signatureKeyring, err error) {
+	keys = &signatureKeyring{}
+	if r == nil {
+		return keys, nil
+	}
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return keys, err
+	}
+
+	// Binary OpenPGP packets start with the tag bit set, which no text keyring does
+	if len(data) > 0 && data[0]&0x80 != 0 {
+		keys.pgp, err = openpgp.ReadKeyRing(bytes.NewReader(data))
+		if err != nil {
+			return keys, fmt.Errorf("reading keyring: %w", err)
+		}
+		return keys, nil
+	}
+
+	text := string(data)
+	for {
+		start := strings.Index(text, beginPGPKeyBlock)
+		if start < 0 {
+			break
+		}
+		end := strings.Index(text[start:], endPGPKeyBlock)
+		if end < 0 {
+			return keys, errors.New("reading keyring: unterminated PGP public key block")
+		}
+		end += start + len(endPGPKeyBlock)
+
+		// ReadArmoredKeyRing only reads the first armored block, so go through them one by one
+		entities, err := openpgp.ReadArmoredKeyRing(strings.NewReader(text[start:end]))
+		if err != nil {
+			return keys, fmt.Errorf("reading keyring: %w", err)
+		}
+		keys.pgp = append(keys.pgp, entities...)
+		text = text[:start] + text[end:]
+	}
+
+	for _, line := range strings.Split(text, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		pub, ok := parseSSHKeyLine(line)
+		if !ok {
+			return keys, fmt.Errorf("reading keyring: not a public key: %q", line)
+		}
+		keys.ssh = append(keys.ssh, pub)
+	}
+	return keys, nil
+}
+
+// parseSSHKeyLine parses an authorized_keys line, or an allowed_signers one, which puts principals
+// and options before the key
+func parseSSHKeyLine(line string) (pub ssh.PublicKey, ok bool) {
+	fields := strings.Fields(line)
+	for i := range fields {
+		pub, _, _, _, err := ssh.ParseAuthorizedKey([]byte(strings.Join(fields[i:], " ")))
+		if err == nil {
+			return pub, true
+		}
+	}
+	return nil, false
+}
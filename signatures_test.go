@@ -0,0 +1,152 @@
+package githelpers
+
+import (
+	"bytes"
+	"encoding/pem"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/go-git/go-git/v5/storage/memory"
+)
+
+// The signatures in testdata/sshsig were made by ssh-keygen -Y sign over testdata/sshsig/commit, which
+// is the commit newSignedTestRepo makes with the message "Signed commit"
+
+func readSSHSigFixture(t *testing.T, name string) string {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join("testdata", "sshsig", name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+// newSignedTestRepo returns a repo in memory whose HEAD is a commit with message and signature
+func newSignedTestRepo(t *testing.T, message, signature string) *GitRepo {
+	t.Helper()
+	repo, err := git.Init(memory.NewStorage(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	who := object.Signature{Name: "Test", Email: "test@example.com", When: time.Unix(1767225600, 0).UTC()}
+	c := &object.Commit{
+		TreeHash:     plumbing.NewHash("4b825dc642cb6eb9a060e54bf8d69288fbee4904"),
+		Author:       who,
+		Committer:    who,
+		Message:      message,
+		PGPSignature: signature,
+	}
+
+	unsigned := repo.Storer.NewEncodedObject()
+	err = c.EncodeWithoutSignature(unsigned)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if message == "Signed commit\n" {
+		r, err := unsigned.Reader()
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := io.ReadAll(r)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(got) != readSSHSigFixture(t, "commit") {
+			t.Fatalf("the commit doesn't match the signed fixture:\n%s", got)
+		}
+	}
+
+	obj := repo.Storer.NewEncodedObject()
+	err = c.Encode(obj)
+	if err != nil {
+		t.Fatal(err)
+	}
+	h, err := repo.Storer.SetEncodedObject(obj)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = repo.Storer.SetReference(plumbing.NewHashReference(plumbing.NewBranchReferenceName("main"), h))
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = repo.Storer.SetReference(plumbing.NewSymbolicReference(plumbing.HEAD, plumbing.NewBranchReferenceName("main")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &GitRepo{Repo: repo}
+}
+
+func TestVerifyCommitSignaturesSSH(t *testing.T) {
+	keyring := readSSHSigFixture(t, "ed25519.pub") + readSSHSigFixture(t, "rsa.pub")
+	tests := []struct {
+		name      string
+		message   string
+		signature string
+		keyring   string
+		want      string
+	}{
+		{"ed25519", "Signed commit\n", "commit.ed25519.sig", keyring, ""},
+		{"rsa with sha256", "Signed commit\n", "commit.rsa-sha256.sig", keyring, ""},
+		{"allowed signers", "Signed commit\n", "commit.ed25519.sig", "test@example.com namespaces=\"git\" " + readSSHSigFixture(t, "ed25519.pub"), ""},
+		{"wrong namespace", "Signed commit\n", "commit.file-namespace.sig", keyring, SignatureInvalid},
+		{"tampered", "Signed commit, not\n", "commit.ed25519.sig", keyring, SignatureInvalid},
+		{"unknown key", "Signed commit\n", "commit.ed25519.sig", readSSHSigFixture(t, "rsa.pub"), SignatureUnknownKey},
+		{"unsigned", "Signed commit\n", "", keyring, SignatureMissing},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sig := ""
+			if tt.signature != "" {
+				sig = readSSHSigFixture(t, tt.signature)
+			}
+			gr := newSignedTestRepo(t, tt.message, sig)
+
+			unverified, err := gr.VerifyCommitSignatures("", strings.NewReader(tt.keyring))
+			if err != nil {
+				t.Fatal(err)
+			}
+			switch {
+			case tt.want == "" && len(unverified) != 0:
+				t.Errorf("unverified: %s: %v", unverified[0].Reason, unverified[0].Err)
+			case tt.want != "" && (len(unverified) != 1 || unverified[0].Reason != tt.want):
+				t.Errorf("got %+v, want one %s commit", unverified, tt.want)
+			}
+		})
+	}
+}
+
+func TestVerifySSHSignatureHashAlgorithm(t *testing.T) {
+	keys, err := readSignatureKeyring(strings.NewReader(readSSHSigFixture(t, "ed25519.pub")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	message := readSSHSigFixture(t, "commit")
+	block, _ := pem.Decode([]byte(readSSHSigFixture(t, "commit.ed25519.sig")))
+
+	tests := []struct {
+		hash string
+		want string
+	}{
+		{"sha512", ""},
+		// The signature covers the hash algorithm, so claiming another one doesn't verify
+		{"sha256", SignatureInvalid},
+		{"sha384", SignatureInvalid},
+	}
+	for _, tt := range tests {
+		t.Run(tt.hash, func(t *testing.T) {
+			blob := bytes.Replace(block.Bytes, []byte("sha512"), []byte(tt.hash), 1)
+			armored := string(pem.EncodeToMemory(&pem.Block{Type: block.Type, Bytes: blob}))
+			reason, err := keys.verifySSH(strings.NewReader(message), armored)
+			if reason != tt.want {
+				t.Errorf("got %q (%v), want %q", reason, err, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,92 @@
+package githelpers
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+)
+
+var (
+	// ErrNothingToSquash is returned by SquashBranch when the checked out branch has no commits of its own since base
+	ErrNothingToSquash = errors.New("no commits to squash")
+)
+
+// SquashBranch collapses the commits the checked out branch made since it forked from base into a
+// single commit with message, or with the messages of the squashed commits, oldest first, when
+// message is empty, e.g. so a bot that commits each run still proposes one clean commit. base is a
+// branch, read from origin when the clone has it, or any revision. The new commit goes through the
+// same checks CommitAll does. The worktree must be clean, and a branch that was pushed already must
+// be pushed again with ForcePush
+func (gr *GitRepo) SquashBranch(base, message string) (hash plumbing.Hash, err error) {
+	// One lock for the reset and the commit, so nothing gets committed in between
+	gr.mu.Lock()
+	defer gr.mu.Unlock()
+
+	status, err := gr.Worktree.Status()
+	if err != nil {
+		return hash, err
+	}
+	if !status.IsClean() {
+		return hash, ErrUncommittedChanges
+	}
+
+	head, err := gr.commitAt("HEAD")
+	if err != nil {
+		return hash, err
+	}
+	target, _, err := gr.branchCommit(base, true)
+	if err != nil {
+		return hash, err
+	}
+	bases, err := target.MergeBase(head)
+	if err != nil {
+		return hash, err
+	}
+	if len(bases) == 0 {
+		return hash, fmt.Errorf("HEAD and %s have no common history", base)
+	}
+	forkPoint := bases[0]
+
+	commits, err := gr.commitsBetween(forkPoint.Hash.String(), head.Hash.String())
+	if err != nil {
+		return hash, err
+	}
+	if len(commits) == 0 {
+		return hash, ErrNothingToSquash
+	}
+	if message == "" {
+		if len(commits) == 1 {
+			return head.Hash, nil
+		}
+		var messages []string
+		for i := len(commits) - 1; i >= 0; i-- {
+			messages = append(messages, strings.TrimSpace(commits[i].Message))
+		}
+		message = strings.Join(messages, "\n\n")
+	}
+
+	err = gr.resetSoft(forkPoint.Hash)
+	if err != nil {
+		return hash, err
+	}
+	hash, err = gr.commit(message, commitOptions{})
+	if err != nil {
+		// Put the branch back where it was, so a rejected commit doesn't lose any work
+		resetErr := gr.resetSoft(head.Hash)
+		if resetErr != nil {
+			failed := &MultiError{}
+			failed.Add("squash commit", err)
+			failed.Add("resetting back to "+head.Hash.String(), resetErr)
+			return hash, failed
+		}
+	}
+	return hash, err
+}
+
+// resetSoft points the checked out branch at h, leaving the worktree as it is, as git reset --soft does
+func (gr *GitRepo) resetSoft(h plumbing.Hash) error {
+	return gr.Worktree.Reset(&git.ResetOptions{Commit: h, Mode: git.SoftReset})
+}
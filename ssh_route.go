@@ -0,0 +1,338 @@
+package githelpers
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-git/go-git/v5/plumbing/transport"
+	gitSSH "github.com/go-git/go-git/v5/plumbing/transport/ssh"
+	"golang.org/x/crypto/ssh"
+)
+
+const (
+	defaultSSHPort = 22
+)
+
+// SSHRouteOption customizes how RouteGitSSH reaches a host
+type SSHRouteOption func(*sshRouteConfig)
+
+type sshRouteConfig struct {
+	port     int
+	timeout  time.Duration
+	jumpHost string
+	jumpAuth *gitSSH.PublicKeys
+}
+
+// WithSSHPort connects to port instead of 22, or the port the repo URL names, e.g. for scp-like
+// git@host:group/repo.git URLs, which can't name one
+func WithSSHPort(port int) SSHRouteOption {
+	return func(cfg *sshRouteConfig) {
+		cfg.port = port
+	}
+}
+
+// WithSSHTimeout gives up on connecting to the host, through the jump host when there is one, after d
+func WithSSHTimeout(d time.Duration) SSHRouteOption {
+	return func(cfg *sshRouteConfig) {
+		cfg.timeout = d
+	}
+}
+
+// WithSSHJumpHost reaches the host through the bastion at jumpHost, given as [user@]host[:port], as
+// ssh -J does, logging in to the bastion with auth
+func WithSSHJumpHost(jumpHost string, auth *gitSSH.PublicKeys) SSHRouteOption {
+	return func(cfg *sshRouteConfig) {
+		cfg.jumpHost = jumpHost
+		cfg.jumpAuth = auth
+	}
+}
+
+// SSHRoute is how go-git reaches one SSH host, as set up by RouteGitSSH
+type SSHRoute struct {
+	host     string
+	dialHost string
+	dialPort int
+	target   string
+	cfg      sshRouteConfig
+
+	listener net.Listener
+	mu       sync.Mutex
+	jump     *ssh.Client
+}
+
+// sshRouter answers go-git's ssh_config lookups for routed hosts, and passes the rest on to the
+// ssh_config go-git read before
+type sshRouter struct {
+	mu     sync.Mutex
+	routes map[string]*SSHRoute
+	next   interface {
+		Get(alias, key string) string
+	}
+}
+
+var (
+	// Installed as go-git's ssh_config on the first RouteGitSSH
+	sshRoutes     = &sshRouter{routes: map[string]*SSHRoute{}}
+	sshRoutesOnce sync.Once
+)
+
+// RouteGitSSH changes how go-git reaches host, e.g. gitlab.internal, over SSH: on another port, with a
+// connection timeout, or through a jump host. Repo URLs stay as they are. go-git keeps this process
+// wide, like its ssh_config, so the route applies to every GitRepo until it's closed. GitRepos apply
+// the timeout, and check host keys as host's, through the auth they use for the host's URLs, which
+// code calling go-git directly gets from the route's Auth. A route through a jump host goes through a
+// local tunnel, which drops connections it can't take on to host, and go-git reports that as a failed
+// handshake. Routing a host again replaces its route
+func RouteGitSSH(host string, opts ...SSHRouteOption) (route *SSHRoute, err error) {
+	route = &SSHRoute{host: host, dialHost: host, dialPort: defaultSSHPort}
+	for _, o := range opts {
+		o(&route.cfg)
+	}
+	if route.cfg.port > 0 {
+		route.dialPort = route.cfg.port
+	}
+	route.target = net.JoinHostPort(host, strconv.Itoa(route.dialPort))
+
+	if route.cfg.jumpHost != "" {
+		if route.cfg.jumpAuth == nil {
+			return nil, errors.New("jump host needs auth")
+		}
+		route.listener, err = net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			return nil, err
+		}
+		addr := route.listener.Addr().(*net.TCPAddr)
+		route.dialHost, route.dialPort = addr.IP.String(), addr.Port
+		go route.serve()
+	}
+
+	sshRoutesOnce.Do(func() {
+		sshRoutes.next = gitSSH.DefaultSSHConfig
+		gitSSH.DefaultSSHConfig = sshRoutes
+	})
+	sshRoutes.mu.Lock()
+	old := sshRoutes.routes[host]
+	sshRoutes.routes[host] = route
+	sshRoutes.mu.Unlock()
+	if old != nil {
+		old.shutdown()
+	}
+	return route, nil
+}
+
+// Close removes the route, so go-git reaches the host as it did before, and closes its tunnel
+func (r *SSHRoute) Close() error {
+	sshRoutes.mu.Lock()
+	if sshRoutes.routes[r.host] == r {
+		delete(sshRoutes.routes, r.host)
+	}
+	sshRoutes.mu.Unlock()
+	return r.shutdown()
+}
+
+func (s *sshRouter) Get(alias, key string) string {
+	s.mu.Lock()
+	route := s.routes[alias]
+	s.mu.Unlock()
+
+	switch {
+	case route == nil || (route.listener == nil && route.cfg.port == 0):
+		// Nothing to change about where to connect to, e.g. for a route with only a timeout
+		if s.next != nil {
+			return s.next.Get(alias, key)
+		}
+	case key == "Hostname":
+		return route.dialHost
+	case key == "Port":
+		return strconv.Itoa(route.dialPort)
+	}
+	return ""
+}
+
+// Auth returns auth set up for the route: connecting within its timeout, and checking host keys as the
+// host's rather than its tunnel's
+func (r *SSHRoute) Auth(auth gitSSH.AuthMethod) gitSSH.AuthMethod {
+	return &routedAuth{AuthMethod: auth, route: r}
+}
+
+// routedAuth sets up the client config of the auth it wraps for an SSHRoute. go-git dials within the
+// config's timeout, and has no other way to set one
+type routedAuth struct {
+	gitSSH.AuthMethod
+	route *SSHRoute
+}
+
+func (a *routedAuth) ClientConfig() (*ssh.ClientConfig, error) {
+	cfg, err := a.AuthMethod.ClientConfig()
+	if err != nil {
+		return cfg, err
+	}
+	cfg.Timeout = a.route.cfg.timeout
+
+	check := cfg.HostKeyCallback
+	if a.route.listener != nil && check != nil {
+		// go-git hands the callback the tunnel's address, which known_hosts has no keys for
+		cfg.HostKeyCallback = func(_ string, remote net.Addr, key ssh.PublicKey) error {
+			return check(a.route.target, remote, key)
+		}
+	}
+	return cfg, nil
+}
+
+// routedSSHAuth returns auth set up for the route of the host url is on, if it's an SSH URL and the host
+// has one. Without auth, the route gets the auth go-git would use, from the SSH agent
+func routedSSHAuth(url string, auth transport.AuthMethod) transport.AuthMethod {
+	ep, err := transport.NewEndpoint(url)
+	if err != nil || ep.Protocol != "ssh" {
+		return auth
+	}
+	sshRoutes.mu.Lock()
+	route := sshRoutes.routes[ep.Host]
+	sshRoutes.mu.Unlock()
+	if route == nil {
+		return auth
+	}
+
+	if auth == nil {
+		agentAuth, err := gitSSH.DefaultAuthBuilder(ep.User)
+		if err != nil {
+			return auth
+		}
+		return route.Auth(agentAuth)
+	}
+	sshAuth, ok := auth.(gitSSH.AuthMethod)
+	if !ok {
+		return auth
+	}
+	return route.Auth(sshAuth)
+}
+
+func (r *SSHRoute) shutdown() error {
+	if r.listener == nil {
+		return nil
+	}
+	err := r.listener.Close()
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.jump != nil {
+		r.jump.Close()
+		r.jump = nil
+	}
+	return err
+}
+
+// serve tunnels every connection go-git makes to the route's local address on to the host, through the jump host
+func (r *SSHRoute) serve() {
+	for {
+		conn, err := r.listener.Accept()
+		if err != nil {
+			return
+		}
+		go r.tunnel(conn)
+	}
+}
+
+func (r *SSHRoute) tunnel(local net.Conn) {
+	defer local.Close()
+
+	remote, err := r.dial()
+	if err != nil {
+		return
+	}
+	defer remote.Close()
+
+	done := make(chan struct{}, 2)
+	pipe := func(dst, src net.Conn) {
+		io.Copy(dst, src)
+		done <- struct{}{}
+	}
+	go pipe(remote, local)
+	go pipe(local, remote)
+	<-done
+}
+
+// dial connects to the host through the jump host, within the route's timeout
+func (r *SSHRoute) dial() (net.Conn, error) {
+	jump, err := r.jumpClient()
+	if err != nil {
+		return nil, err
+	}
+	conn, err := dialTimeout(func() (net.Conn, error) { return jump.Dial("tcp", r.target) }, r.cfg.timeout)
+	if err != nil {
+		// The bastion may have dropped the connection, so start over with a new one next time
+		r.mu.Lock()
+		if r.jump == jump {
+			r.jump.Close()
+			r.jump = nil
+		}
+		r.mu.Unlock()
+	}
+	return conn, err
+}
+
+// jumpClient returns the connection to the jump host, making it on first use and sharing it after
+func (r *SSHRoute) jumpClient() (*ssh.Client, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.jump != nil {
+		return r.jump, nil
+	}
+	cfg, err := r.cfg.jumpAuth.ClientConfig()
+	if err != nil {
+		return nil, err
+	}
+	cfg.Timeout = r.cfg.timeout
+
+	addr := r.cfg.jumpHost
+	if at := strings.LastIndex(addr, "@"); at >= 0 {
+		cfg.User, addr = addr[:at], addr[at+1:]
+	}
+	if _, _, err := net.SplitHostPort(addr); err != nil {
+		addr = net.JoinHostPort(addr, strconv.Itoa(defaultSSHPort))
+	}
+
+	r.jump, err = ssh.Dial("tcp", addr, cfg)
+	if err != nil {
+		return nil, fmt.Errorf("connecting to jump host %s: %w", r.cfg.jumpHost, err)
+	}
+	return r.jump, nil
+}
+
+// dialTimeout runs dial, giving up after timeout unless it's zero. A connection made after giving up is closed
+func dialTimeout(dial func() (net.Conn, error), timeout time.Duration) (net.Conn, error) {
+	if timeout <= 0 {
+		return dial()
+	}
+
+	type result struct {
+		conn net.Conn
+		err  error
+	}
+	ch := make(chan result, 1)
+	go func() {
+		conn, err := dial()
+		ch <- result{conn, err}
+	}()
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	select {
+	case res := <-ch:
+		return res.conn, res.err
+	case <-timer.C:
+		go func() {
+			if res := <-ch; res.conn != nil {
+				res.conn.Close()
+			}
+		}()
+		return nil, fmt.Errorf("dial timed out after %s", timeout)
+	}
+}
@@ -0,0 +1,217 @@
+package githelpers
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/config"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+)
+
+const (
+	extractRemoteName = "extract"
+	extractRefPrefix  = "refs/githelpers/extract/"
+)
+
+var (
+	// ErrPathNotInHistory is returned by SplitSubtree and ExtractSubtree when no commit has the path
+	ErrPathNotInHistory = errors.New("no commit has the path")
+)
+
+// ExtractOption customizes what ExtractSubtree splits and where it pushes it
+type ExtractOption func(*extractConfig)
+
+type extractConfig struct {
+	ref    string
+	branch string
+}
+
+// WithExtractRef splits the history of ref, instead of HEAD
+func WithExtractRef(ref string) ExtractOption {
+	return func(cfg *extractConfig) {
+		cfg.ref = ref
+	}
+}
+
+// WithExtractBranch is the branch pushed to in the new repo, instead of main
+func WithExtractBranch(name string) ExtractOption {
+	return func(cfg *extractConfig) {
+		cfg.branch = name
+	}
+}
+
+// ExtractSubtree splits the history of the directory at path out of the GitRepo, as SplitSubtree
+// does, and pushes it to the main branch of the repo at newRepoURL, e.g. to break a project out of a
+// monorepo. The new repo must exist and must not have diverging history on that branch. Returns the
+// tip of the pushed history
+func (gr *GitRepo) ExtractSubtree(path, newRepoURL string, opts ...ExtractOption) (head plumbing.Hash, err error) {
+	cfg := extractConfig{branch: "main"}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+
+	gr.mu.Lock()
+	defer gr.mu.Unlock()
+
+	head, err = gr.splitSubtree(path, cfg.ref)
+	if err != nil {
+		return head, err
+	}
+
+	// Pushes only take refs, so point a scratch one at the split history for the duration of the push
+	local := plumbing.ReferenceName(extractRefPrefix + strings.Trim(path, "/"))
+	err = gr.Repo.Storer.SetReference(plumbing.NewHashReference(local, head))
+	if err != nil {
+		return head, err
+	}
+	defer gr.Repo.Storer.RemoveReference(local)
+
+	remote := git.NewRemote(gr.Repo.Storer, &config.RemoteConfig{Name: extractRemoteName, URLs: []string{newRepoURL}})
+	err = remote.Push(&git.PushOptions{
+		RemoteName: extractRemoteName,
+		RefSpecs:   []config.RefSpec{config.RefSpec(local.String() + ":" + plumbing.NewBranchReferenceName(cfg.branch).String())},
+		Auth:       gr.urlAuth(newRepoURL),
+	})
+	if err != nil && err != git.NoErrAlreadyUpToDate {
+		return head, fmt.Errorf("pushing to %s: %w", newRepoURL, err)
+	}
+	return head, nil
+}
+
+// SplitSubtree rewrites the history of ref, or HEAD when ref is empty, into one where the directory at
+// path is the root, as git subtree split does, and returns its tip. Commits that don't change the
+// directory are left out, and the others keep their authors, committers, and messages, but lose
+// their signatures. The new commits are only written to the object store, no ref points to them
+func (gr *GitRepo) SplitSubtree(path, ref string) (head plumbing.Hash, err error) {
+	gr.mu.Lock()
+	defer gr.mu.Unlock()
+
+	return gr.splitSubtree(path, ref)
+}
+
+func (gr *GitRepo) splitSubtree(path, ref string) (head plumbing.Hash, err error) {
+	path = strings.Trim(path, "/")
+	if path == "" {
+		return head, errors.New("empty path")
+	}
+	if ref == "" {
+		ref = "HEAD"
+	}
+	tip, err := gr.commitAt(ref)
+	if err != nil {
+		return head, err
+	}
+
+	s := &subtreeSplitter{
+		gr:    gr,
+		path:  path,
+		split: map[plumbing.Hash]plumbing.Hash{},
+		trees: map[plumbing.Hash]plumbing.Hash{},
+	}
+	head, err = s.commit(tip)
+	if err != nil {
+		return head, err
+	}
+	if head.IsZero() {
+		return head, fmt.Errorf("%s: %w", path, ErrPathNotInHistory)
+	}
+	return head, nil
+}
+
+// subtreeSplitter rewrites commits parents first, remembering what each one was rewritten to
+type subtreeSplitter struct {
+	gr   *GitRepo
+	path string
+
+	split map[plumbing.Hash]plumbing.Hash // Original commit to the split commit standing in for it, zero when none
+	trees map[plumbing.Hash]plumbing.Hash // Split commit to its tree
+}
+
+// commit returns the split commit standing in for tip, rewriting its ancestors first. The walk keeps
+// its own stack, as histories can be far deeper than recursion allows
+func (s *subtreeSplitter) commit(tip *object.Commit) (plumbing.Hash, error) {
+	stack := []*object.Commit{tip}
+	for len(stack) > 0 {
+		c := stack[len(stack)-1]
+		if _, done := s.split[c.Hash]; done {
+			stack = stack[:len(stack)-1]
+			continue
+		}
+
+		pending := false
+		for _, p := range c.ParentHashes {
+			if _, done := s.split[p]; done {
+				continue
+			}
+			parent, err := s.gr.Repo.CommitObject(p)
+			if err != nil {
+				return plumbing.ZeroHash, err
+			}
+			stack = append(stack, parent)
+			pending = true
+		}
+		if pending {
+			continue
+		}
+
+		h, err := s.rewrite(c)
+		if err != nil {
+			return plumbing.ZeroHash, err
+		}
+		s.split[c.Hash] = h
+		stack = stack[:len(stack)-1]
+	}
+	return s.split[tip.Hash], nil
+}
+
+// rewrite returns the split commit for c, whose parents have all been rewritten already
+func (s *subtreeSplitter) rewrite(c *object.Commit) (plumbing.Hash, error) {
+	var parents []plumbing.Hash
+	seen := map[plumbing.Hash]bool{}
+	for _, p := range c.ParentHashes {
+		h := s.split[p]
+		if h.IsZero() || seen[h] {
+			continue
+		}
+		seen[h] = true
+		parents = append(parents, h)
+	}
+
+	tree, err := c.Tree()
+	if err != nil {
+		return plumbing.ZeroHash, err
+	}
+	sub, err := tree.Tree(s.path)
+	if err == object.ErrDirectoryNotFound {
+		// The directory doesn't exist yet, or anymore, so the first split parent stands in for c
+		if len(parents) == 0 {
+			return plumbing.ZeroHash, nil
+		}
+		return parents[0], nil
+	}
+	if err != nil {
+		return plumbing.ZeroHash, err
+	}
+
+	if len(parents) == 1 && s.trees[parents[0]] == sub.Hash {
+		// c didn't touch the directory
+		return parents[0], nil
+	}
+
+	split := &object.Commit{
+		Author:       c.Author,
+		Committer:    c.Committer,
+		Message:      c.Message,
+		TreeHash:     sub.Hash,
+		ParentHashes: parents,
+	}
+	h, err := storeObject(s.gr.Repo.Storer, split)
+	if err != nil {
+		return plumbing.ZeroHash, err
+	}
+	s.trees[h] = sub.Hash
+	return h, nil
+}
@@ -0,0 +1,228 @@
+package githelpers
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/config"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/go-git/go-git/v5/plumbing/storer"
+)
+
+const (
+	importRemoteName = "import"
+	importRefPrefix  = "refs/githelpers/import/"
+
+	// The trailers git subtree records imports with, so either tool can update what the other imported
+	subtreeDirKey   = "git-subtree-dir"
+	subtreeSplitKey = "git-subtree-split"
+)
+
+var (
+	// ErrUncommittedChanges is returned by operations that commit on their own when the worktree has changes
+	ErrUncommittedChanges = errors.New("the worktree has uncommitted changes")
+	// ErrSubtreeUpToDate is returned by ImportSubtree when the path already holds that commit of the source repo
+	ErrSubtreeUpToDate = errors.New("subtree is already up to date")
+)
+
+// ImportOption customizes the commit ImportSubtree makes
+type ImportOption func(*importConfig)
+
+type importConfig struct {
+	history bool
+	message string
+}
+
+// WithImportHistory merges the history of the source repo in along with its files, as git subtree
+// does without --squash, instead of committing the files alone
+func WithImportHistory() ImportOption {
+	return func(cfg *importConfig) {
+		cfg.history = true
+	}
+}
+
+// WithImportMessage is the message of the import commit, instead of one naming the source repo and ref
+func WithImportMessage(msg string) ImportOption {
+	return func(cfg *importConfig) {
+		cfg.message = msg
+	}
+}
+
+// ImportSubtree vendors ref of the repo at srcURL into destPath of the GitRepo and commits it, as git
+// subtree add does. An empty ref imports the source's default branch. Calling it again for the same
+// destPath updates the import, replacing the files under destPath, and returns ErrSubtreeUpToDate
+// when the source hasn't moved. Imports are recorded with git subtree's trailers, so updates work
+// on paths git subtree added too. The worktree must be clean, as everything it holds is committed
+func (gr *GitRepo) ImportSubtree(srcURL, ref, destPath string, opts ...ImportOption) (hash plumbing.Hash, err error) {
+	var cfg importConfig
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+
+	destPath = strings.Trim(destPath, "/")
+	if destPath == "" {
+		return hash, errors.New("empty path")
+	}
+	status, err := gr.Worktree.Status()
+	if err != nil {
+		return hash, err
+	}
+	if !status.IsClean() {
+		return hash, ErrUncommittedChanges
+	}
+
+	head, err := gr.commitAt("HEAD")
+	if err != nil {
+		return hash, err
+	}
+	previous, err := lastSubtreeImport(head, destPath)
+	if err != nil {
+		return hash, err
+	}
+	if previous.IsZero() {
+		tree, err := head.Tree()
+		if err != nil {
+			return hash, err
+		}
+		_, err = tree.FindEntry(destPath)
+		if err == nil {
+			return hash, fmt.Errorf("%s already exists and wasn't imported as a subtree", destPath)
+		}
+	}
+
+	src, err := gr.fetchSubtreeSource(srcURL, ref, destPath)
+	if err != nil {
+		return hash, err
+	}
+	if src.Hash == previous {
+		return hash, ErrSubtreeUpToDate
+	}
+	srcTree, err := src.Tree()
+	if err != nil {
+		return hash, err
+	}
+	files, err := readTreePath(srcTree, "", destPath)
+	if err != nil {
+		return hash, err
+	}
+	err = gr.replaceWorktreePath(destPath, files)
+	if err != nil {
+		return hash, err
+	}
+
+	msg := cfg.message
+	if msg == "" {
+		verb, at := "Add", ref
+		if !previous.IsZero() {
+			verb = "Update"
+		}
+		if at == "" {
+			at = src.Hash.String()[:7]
+		}
+		msg = fmt.Sprintf("%s %s from %s %s", verb, destPath, srcURL, at)
+	}
+	msg = appendTrailer(msg, Trailer{Key: subtreeDirKey, Value: destPath})
+	msg = appendTrailer(msg, Trailer{Key: subtreeSplitKey, Value: src.Hash.String()})
+
+	var parents []plumbing.Hash
+	if cfg.history {
+		parents = []plumbing.Hash{head.Hash, src.Hash}
+	}
+	return gr.commitAll(msg, commitOptions{parents: parents})
+}
+
+// lastSubtreeImport returns the source commit last imported into dir in the history of c, or the zero
+// hash when nothing was
+func lastSubtreeImport(c *object.Commit, dir string) (split plumbing.Hash, err error) {
+	iter := object.NewCommitPreorderIter(c, nil, nil)
+	defer iter.Close()
+
+	err = iter.ForEach(func(c *object.Commit) error {
+		var importedDir, importedSplit string
+		for _, t := range messageTrailers(c.Message) {
+			switch t.Key {
+			case subtreeDirKey:
+				importedDir = strings.Trim(t.Value, "/")
+			case subtreeSplitKey:
+				importedSplit = t.Value
+			}
+		}
+		if importedDir != dir || importedSplit == "" {
+			return nil
+		}
+		split = plumbing.NewHash(importedSplit)
+		return storer.ErrStop
+	})
+	return split, err
+}
+
+// fetchSubtreeSource fetches ref of the repo at srcURL into the GitRepo's object store and returns its commit
+func (gr *GitRepo) fetchSubtreeSource(srcURL, ref, destPath string) (c *object.Commit, err error) {
+	gr.mu.Lock()
+	defer gr.mu.Unlock()
+
+	remote := git.NewRemote(gr.Repo.Storer, &config.RemoteConfig{Name: importRemoteName, URLs: []string{srcURL}})
+	refs, err := remote.List(&git.ListOptions{Auth: gr.urlAuth(srcURL)})
+	if err != nil {
+		return c, fmt.Errorf("listing %s: %w", srcURL, err)
+	}
+	name, err := resolveRemoteRef(refs, ref)
+	if err != nil {
+		return c, fmt.Errorf("%s: %w", srcURL, err)
+	}
+
+	local := plumbing.ReferenceName(importRefPrefix + destPath)
+	defer gr.Repo.Storer.RemoveReference(local)
+	err = remote.Fetch(&git.FetchOptions{
+		RemoteName: importRemoteName,
+		RefSpecs:   []config.RefSpec{config.RefSpec("+" + name.String() + ":" + local.String())},
+		Auth:       gr.urlAuth(srcURL),
+		Tags:       git.NoTags,
+	})
+	if err != nil && err != git.NoErrAlreadyUpToDate {
+		return c, fmt.Errorf("fetching %s: %w", srcURL, err)
+	}
+
+	fetched, err := gr.Repo.Reference(local, true)
+	if err != nil {
+		return c, err
+	}
+	if tag, err := gr.Repo.TagObject(fetched.Hash()); err == nil {
+		return tag.Commit()
+	}
+	return gr.Repo.CommitObject(fetched.Hash())
+}
+
+// resolveRemoteRef finds the full name of ref, a branch, tag, or full ref name, among the refs of a
+// remote. An empty ref is the remote's HEAD
+func resolveRemoteRef(refs []*plumbing.Reference, ref string) (plumbing.ReferenceName, error) {
+	byName := map[plumbing.ReferenceName]*plumbing.Reference{}
+	for _, r := range refs {
+		byName[r.Name()] = r
+	}
+
+	if ref == "" {
+		head, ok := byName[plumbing.HEAD]
+		if !ok {
+			return "", fmt.Errorf("HEAD: %w", plumbing.ErrReferenceNotFound)
+		}
+		if head.Type() == plumbing.SymbolicReference {
+			return head.Target(), nil
+		}
+		return plumbing.HEAD, nil
+	}
+
+	for _, name := range []plumbing.ReferenceName{
+		plumbing.ReferenceName(ref),
+		plumbing.NewBranchReferenceName(ref),
+		plumbing.NewTagReferenceName(ref),
+	} {
+		if _, ok := byName[name]; ok && name != plumbing.HEAD {
+			return name, nil
+		}
+	}
+	return "", fmt.Errorf("%s: %w", ref, plumbing.ErrReferenceNotFound)
+}
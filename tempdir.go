@@ -0,0 +1,93 @@
+package githelpers
+
+import (
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+)
+
+const (
+	defaultTempDirPrefix = "githelpers-"
+)
+
+var (
+	tempDirsMu     sync.Mutex
+	tempDirs       = map[string]struct{}{}
+	tempDirsSignal sync.Once
+)
+
+// TempDirOption customizes the tmp directory created by NewTempDir
+type TempDirOption func(*tempDirConfig)
+
+type tempDirConfig struct {
+	baseDir       string
+	prefix        string
+	signalCleanup bool
+}
+
+// WithTempDirBase creates the tmp directory under baseDir instead of the system tmp dir, e.g. a
+// scratch volume on a CI runner
+func WithTempDirBase(baseDir string) TempDirOption {
+	return func(cfg *tempDirConfig) {
+		cfg.baseDir = baseDir
+	}
+}
+
+// WithTempDirPrefix starts the tmp directory's name with prefix instead of "githelpers-"
+func WithTempDirPrefix(prefix string) TempDirOption {
+	return func(cfg *tempDirConfig) {
+		cfg.prefix = prefix
+	}
+}
+
+// WithTempDirSignalCleanup removes the tmp directory if the process is interrupted or terminated
+// before it's closed. It also makes the directory part of what CleanupTempDirs removes
+func WithTempDirSignalCleanup() TempDirOption {
+	return func(cfg *tempDirConfig) {
+		cfg.signalCleanup = true
+	}
+}
+
+// CleanupTempDirs removes every tmp directory created with WithTempDirSignalCleanup that hasn't been
+// closed yet. Go has no exit hooks, so defer it in main to clean up on a normal exit too
+func CleanupTempDirs() {
+	tempDirsMu.Lock()
+	defer tempDirsMu.Unlock()
+
+	for d := range tempDirs {
+		os.RemoveAll(d)
+		delete(tempDirs, d)
+	}
+}
+
+func registerTempDir(dir string) {
+	tempDirsMu.Lock()
+	tempDirs[dir] = struct{}{}
+	tempDirsMu.Unlock()
+
+	tempDirsSignal.Do(func() {
+		sigs := make(chan os.Signal, 1)
+		signal.Notify(sigs, os.Interrupt, syscall.SIGTERM)
+		go func() {
+			sig := <-sigs
+			CleanupTempDirs()
+
+			// Hand the signal back to the default handler so the process still exits the way it would have
+			signal.Reset(sig)
+			p, err := os.FindProcess(os.Getpid())
+			if err == nil {
+				err = p.Signal(sig)
+			}
+			if err != nil {
+				os.Exit(1)
+			}
+		}()
+	})
+}
+
+func unregisterTempDir(dir string) {
+	tempDirsMu.Lock()
+	delete(tempDirs, dir)
+	tempDirsMu.Unlock()
+}
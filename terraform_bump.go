@@ -0,0 +1,208 @@
+package githelpers
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+const (
+	terraformRegistryHost = "registry.terraform.io/"
+)
+
+var (
+	// ErrTerraformNotFound is returned by the Terraform bump helpers when no .tf file uses the module or provider
+	ErrTerraformNotFound = errors.New("no Terraform configuration uses it")
+)
+
+// hclEdit replaces src[start:end] with text
+type hclEdit struct {
+	start, end int
+	text       string
+}
+
+// BumpTerraformModule points every module block whose source is source at version, in the .tf files
+// of the GitRepo's worktree, and returns the files it changed. Git sources, like
+// git::https://example.com/modules.git//vpc, get their ref query parameter set, and registry sources,
+// like terraform-aws-modules/vpc/aws, their version attribute, which is added when missing. source is
+// compared without any ref. Commit the result with CommitAll, or run the bump through a Fleet to
+// propose it as MRs
+func (gr *GitRepo) BumpTerraformModule(source, version string) (changed []string, err error) {
+	return gr.editTerraformFiles(source, func(root *hclBlock, src string) (edits []hclEdit, found bool) {
+		for _, b := range root.blocks {
+			if b.typ != "module" {
+				continue
+			}
+			s := b.attr("source")
+			if s == nil || s.value.kind != hclString || stripModuleRef(s.value.str) != stripModuleRef(source) {
+				continue
+			}
+			found = true
+
+			if isGitModuleSource(s.value.str) {
+				edits = append(edits, hclEdit{s.value.start, s.value.end, hclQuote(setModuleRef(s.value.str, version))})
+				continue
+			}
+			edits = append(edits, setHCLAttr(src, b.attrs, s, b.open, b.close, "version", version))
+		}
+		return edits, found
+	})
+}
+
+// BumpTerraformProvider sets the version constraint of the provider with the given source, e.g.
+// hashicorp/aws, in the required_providers of every .tf file in the GitRepo's worktree, and returns
+// the files it changed. constraint is a Terraform version constraint, e.g. "~> 5.0". Providers
+// required without a source are taken to be hashicorp ones, as Terraform does
+func (gr *GitRepo) BumpTerraformProvider(source, constraint string) (changed []string, err error) {
+	return gr.editTerraformFiles(source, func(root *hclBlock, src string) (edits []hclEdit, found bool) {
+		for _, tf := range root.blocks {
+			if tf.typ != "terraform" {
+				continue
+			}
+			for _, rp := range tf.blocks {
+				if rp.typ != "required_providers" {
+					continue
+				}
+				for _, p := range rp.attrs {
+					v := p.value
+					providerSource := "hashicorp/" + p.name
+					if v.kind == hclObject {
+						if s := v.attr("source"); s != nil && s.value.kind == hclString {
+							providerSource = s.value.str
+						}
+					}
+					if normalizeProviderSource(providerSource) != normalizeProviderSource(source) {
+						continue
+					}
+					found = true
+
+					switch v.kind {
+					case hclObject:
+						edits = append(edits, setHCLAttr(src, v.attrs, v.attr("source"), v.start, v.end-1, "version", constraint))
+					default:
+						// The pre 0.13 form, aws = "~> 3.0"
+						edits = append(edits, hclEdit{v.start, v.end, hclQuote(constraint)})
+					}
+				}
+			}
+		}
+		return edits, found
+	})
+}
+
+// editTerraformFiles applies the edits returned by edit to each .tf file, outside of .terraform dirs
+func (gr *GitRepo) editTerraformFiles(target string, edit func(root *hclBlock, src string) ([]hclEdit, bool)) (changed []string, err error) {
+	files, err := gr.worktreeFiles("*.tf")
+	if err != nil {
+		return changed, err
+	}
+
+	found := false
+	fs := gr.worktreeFilesystem()
+	for _, f := range files {
+		if strings.HasPrefix(f.Path, ".terraform/") || strings.Contains(f.Path, "/.terraform/") {
+			// Modules and providers downloaded by terraform init
+			continue
+		}
+		data, err := readWorktreeFile(fs, f.Path)
+		if err != nil {
+			return changed, err
+		}
+
+		src := string(data)
+		edits, ok := edit(parseHCL(src), src)
+		found = found || ok
+		out := applyHCLEdits(src, edits)
+		if out == src {
+			continue
+		}
+		err = gr.WriteFile(f.Path, []byte(out), f.Mode.Perm())
+		if err != nil {
+			return changed, err
+		}
+		changed = append(changed, f.Path)
+	}
+
+	if !found {
+		return changed, fmt.Errorf("%s: %w", target, ErrTerraformNotFound)
+	}
+	return changed, nil
+}
+
+// setHCLAttr returns the edit setting name to the string value in the block or object whose braces
+// are at open and close. A missing attribute is added after after, or after the last attribute,
+// on a line of its own unless the braces are on the same line
+func setHCLAttr(src string, attrs []*hclAttr, after *hclAttr, open, close int, name, value string) hclEdit {
+	if a := findHCLAttr(attrs, name); a != nil {
+		return hclEdit{a.value.start, a.value.end, hclQuote(value)}
+	}
+	if after == nil && len(attrs) > 0 {
+		after = attrs[len(attrs)-1]
+	}
+	line := name + " = " + hclQuote(value)
+
+	multiline := strings.Contains(src[open:close], "\n")
+	switch {
+	case after == nil && multiline:
+		lineStart := strings.LastIndexByte(src[:close], '\n') + 1
+		return hclEdit{lineStart, lineStart, src[lineStart:close] + "  " + line + "\n"}
+	case after == nil:
+		return hclEdit{open + 1, close, " " + line + " "}
+	case !multiline:
+		return hclEdit{after.value.end, after.value.end, ", " + line}
+	}
+
+	lineStart := strings.LastIndexByte(src[:after.nameStart], '\n') + 1
+	indent := src[lineStart:after.nameStart]
+	end := strings.IndexByte(src[after.value.end:], '\n')
+	if end < 0 {
+		return hclEdit{len(src), len(src), "\n" + indent + line}
+	}
+	pos := after.value.end + end + 1
+	return hclEdit{pos, pos, indent + line + "\n"}
+}
+
+func applyHCLEdits(src string, edits []hclEdit) string {
+	sort.Slice(edits, func(i, j int) bool { return edits[i].start > edits[j].start })
+	for _, e := range edits {
+		src = src[:e.start] + e.text + src[e.end:]
+	}
+	return src
+}
+
+func isGitModuleSource(source string) bool {
+	return strings.HasPrefix(source, "git::") || strings.HasPrefix(source, "git@") ||
+		strings.Contains(source, ".git") || strings.Contains(source, "?ref=") || strings.Contains(source, "&ref=")
+}
+
+// stripModuleRef returns a module source without its ref query parameter
+func stripModuleRef(source string) string {
+	return setModuleRef(source, "")
+}
+
+// setModuleRef returns a module source with its ref query parameter set to ref, or removed when ref is empty
+func setModuleRef(source, ref string) string {
+	base, query := source, ""
+	if i := strings.Index(source, "?"); i >= 0 {
+		base, query = source[:i], source[i+1:]
+	}
+
+	var params []string
+	for _, p := range strings.Split(query, "&") {
+		if p != "" && !strings.HasPrefix(p, "ref=") {
+			params = append(params, p)
+		}
+	}
+	if ref != "" {
+		params = append(params, "ref="+ref)
+	}
+	if len(params) == 0 {
+		return base
+	}
+	return base + "?" + strings.Join(params, "&")
+}
+
+func normalizeProviderSource(source string) string {
+	return strings.TrimPrefix(strings.ToLower(source), terraformRegistryHost)
+}
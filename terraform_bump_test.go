@@ -0,0 +1,260 @@
+package githelpers
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestBumpTerraformProvider(t *testing.T) {
+	tests := []struct {
+		name       string
+		source     string
+		constraint string
+		in, want   string
+	}{
+		{
+			name:       "object form",
+			source:     "hashicorp/aws",
+			constraint: "~> 5.0",
+			in: `terraform {
+  required_version = ">= 1.3"
+
+  required_providers {
+    aws = {
+      source  = "hashicorp/aws"
+      version = "~> 4.0" # pinned for the VPC module
+    }
+    random = {
+      source  = "hashicorp/random"
+      version = "~> 3.0"
+    }
+  }
+}
+`,
+			want: `terraform {
+  required_version = ">= 1.3"
+
+  required_providers {
+    aws = {
+      source  = "hashicorp/aws"
+      version = "~> 5.0" # pinned for the VPC module
+    }
+    random = {
+      source  = "hashicorp/random"
+      version = "~> 3.0"
+    }
+  }
+}
+`,
+		},
+		{
+			name:       "object form without a version",
+			source:     "registry.terraform.io/integrations/github",
+			constraint: "~> 6.0",
+			in: `terraform {
+  required_providers {
+    github = {
+      source = "integrations/github"
+    }
+  }
+}
+`,
+			want: `terraform {
+  required_providers {
+    github = {
+      source = "integrations/github"
+      version = "~> 6.0"
+    }
+  }
+}
+`,
+		},
+		{
+			name:       "one line object",
+			source:     "hashicorp/aws",
+			constraint: "~> 5.0",
+			in:         "terraform {\n  required_providers {\n    aws = { source = \"hashicorp/aws\" }\n  }\n}\n",
+			want:       "terraform {\n  required_providers {\n    aws = { source = \"hashicorp/aws\", version = \"~> 5.0\" }\n  }\n}\n",
+		},
+		{
+			name:       "inline form without a source",
+			source:     "hashicorp/aws",
+			constraint: "~> 5.0",
+			in:         "terraform {\n  required_providers {\n    aws = \"~> 3.0\"\n  }\n}\n",
+			want:       "terraform {\n  required_providers {\n    aws = \"~> 5.0\"\n  }\n}\n",
+		},
+		{
+			name:       "look-alikes in comments and heredocs",
+			source:     "hashicorp/aws",
+			constraint: "~> 5.0",
+			in: `# required_providers { aws = { version = "~> 1.0" } }
+/* terraform {
+  required_providers {
+    aws = "~> 1.0"
+  }
+} */
+locals {
+  readme = <<-EOT
+    terraform {
+      required_providers {
+        aws = { source = "hashicorp/aws", version = "~> 1.0" }
+      }
+    }
+  EOT
+}
+
+terraform {
+  required_providers {
+    aws = {
+      // version = "~> 2.0"
+      source  = "hashicorp/aws"
+      version = "~> 4.0"
+    }
+  }
+}
+`,
+			want: `# required_providers { aws = { version = "~> 1.0" } }
+/* terraform {
+  required_providers {
+    aws = "~> 1.0"
+  }
+} */
+locals {
+  readme = <<-EOT
+    terraform {
+      required_providers {
+        aws = { source = "hashicorp/aws", version = "~> 1.0" }
+      }
+    }
+  EOT
+}
+
+terraform {
+  required_providers {
+    aws = {
+      // version = "~> 2.0"
+      source  = "hashicorp/aws"
+      version = "~> 5.0"
+    }
+  }
+}
+`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gr := newTestFileRepo(t, "versions.tf", tt.in)
+			changed, err := gr.BumpTerraformProvider(tt.source, tt.constraint)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(changed) != 1 {
+				t.Errorf("changed %v", changed)
+			}
+			if got := readTestFile(t, gr, "versions.tf"); got != tt.want {
+				t.Errorf("got\n%s\nwant\n%s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBumpTerraformModule(t *testing.T) {
+	tests := []struct {
+		name     string
+		source   string
+		version  string
+		in, want string
+	}{
+		{
+			name:    "registry module with a version",
+			source:  "terraform-aws-modules/vpc/aws",
+			version: "5.1.0",
+			in:      "module \"vpc\" {\n  source  = \"terraform-aws-modules/vpc/aws\"\n  version = \"5.0.0\"\n\n  cidr = \"10.0.0.0/16\"\n}\n",
+			want:    "module \"vpc\" {\n  source  = \"terraform-aws-modules/vpc/aws\"\n  version = \"5.1.0\"\n\n  cidr = \"10.0.0.0/16\"\n}\n",
+		},
+		{
+			name:    "registry module without a version",
+			source:  "terraform-aws-modules/vpc/aws",
+			version: "5.1.0",
+			in:      "module \"vpc\" {\n  source = \"terraform-aws-modules/vpc/aws\"\n  name   = \"main\"\n}\n",
+			want:    "module \"vpc\" {\n  source = \"terraform-aws-modules/vpc/aws\"\n  version = \"5.1.0\"\n  name   = \"main\"\n}\n",
+		},
+		{
+			name:    "git module ref",
+			source:  "git::https://example.com/modules.git//vpc?depth=1",
+			version: "v1.3.0",
+			in:      "module \"vpc\" {\n  source = \"git::https://example.com/modules.git//vpc?depth=1&ref=v1.2.0\"\n}\n",
+			want:    "module \"vpc\" {\n  source = \"git::https://example.com/modules.git//vpc?depth=1&ref=v1.3.0\"\n}\n",
+		},
+		{
+			name:    "git module without a ref",
+			source:  "git@example.com:infra/modules.git//vpc?ref=v1.0.0",
+			version: "v1.3.0",
+			in:      "module \"vpc\" {\n  source = \"git@example.com:infra/modules.git//vpc\"\n}\n",
+			want:    "module \"vpc\" {\n  source = \"git@example.com:infra/modules.git//vpc?ref=v1.3.0\"\n}\n",
+		},
+		{
+			name:    "only the matching module, not look-alikes",
+			source:  "terraform-aws-modules/vpc/aws",
+			version: "5.1.0",
+			in: `module "eks" {
+  source  = "terraform-aws-modules/eks/aws"
+  version = "19.0.0"
+}
+
+module "vpc" {
+  # version = "1.0.0"
+  source = "terraform-aws-modules/vpc/aws"
+  tags = {
+    version = "5.0.0"
+  }
+  description = <<EOT
+version = "5.0.0"
+EOT
+  version = "5.0.0"
+}
+`,
+			want: `module "eks" {
+  source  = "terraform-aws-modules/eks/aws"
+  version = "19.0.0"
+}
+
+module "vpc" {
+  # version = "1.0.0"
+  source = "terraform-aws-modules/vpc/aws"
+  tags = {
+    version = "5.0.0"
+  }
+  description = <<EOT
+version = "5.0.0"
+EOT
+  version = "5.1.0"
+}
+`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gr := newTestFileRepo(t, "main.tf", tt.in)
+			_, err := gr.BumpTerraformModule(tt.source, tt.version)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := readTestFile(t, gr, "main.tf"); got != tt.want {
+				t.Errorf("got\n%s\nwant\n%s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBumpTerraformNotFound(t *testing.T) {
+	gr := newTestFileRepo(t, "main.tf", "# module \"vpc\" { source = \"terraform-aws-modules/vpc/aws\" }\n")
+	_, err := gr.BumpTerraformModule("terraform-aws-modules/vpc/aws", "5.1.0")
+	if !errors.Is(err, ErrTerraformNotFound) {
+		t.Errorf("got %v, want ErrTerraformNotFound", err)
+	}
+	_, err = gr.BumpTerraformProvider("hashicorp/aws", "~> 5.0")
+	if !errors.Is(err, ErrTerraformNotFound) {
+		t.Errorf("got %v, want ErrTerraformNotFound", err)
+	}
+}
@@ -0,0 +1,62 @@
+package githelpers
+
+import (
+	"strings"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing/object"
+)
+
+// AddTrailers returns msg with trailers added to its trailer paragraph, e.g. Automation-Run: 1234,
+// leaving out the ones it carries already
+func AddTrailers(msg string, trailers ...Trailer) string {
+	for _, t := range trailers {
+		msg = appendTrailer(msg, t)
+	}
+	return msg
+}
+
+// CommitTrailers returns the trailers of the message of the commit at rev
+func (gr *GitRepo) CommitTrailers(rev string) (trailers []Trailer, err error) {
+	c, err := gr.commitAt(rev)
+	if err != nil {
+		return trailers, err
+	}
+	return messageTrailers(c.Message), nil
+}
+
+// FindCommitsWithTrailer returns the commits in the history of rev, newest first, carrying a trailer
+// with key, compared case insensitively as git does, and value, or any value when value is empty.
+// An empty rev means HEAD. Automation that records its runs with trailers can use it to tell what a
+// previous run applied already
+func (gr *GitRepo) FindCommitsWithTrailer(rev, key, value string) (commits []*object.Commit, err error) {
+	if rev == "" {
+		rev = "HEAD"
+	}
+	from, err := gr.commitAt(rev)
+	if err != nil {
+		return commits, err
+	}
+	iter, err := gr.Repo.Log(&git.LogOptions{From: from.Hash})
+	if err != nil {
+		return commits, err
+	}
+	defer iter.Close()
+
+	err = iter.ForEach(func(c *object.Commit) error {
+		if hasTrailer(c.Message, key, value) {
+			commits = append(commits, c)
+		}
+		return nil
+	})
+	return commits, err
+}
+
+func hasTrailer(msg, key, value string) bool {
+	for _, t := range messageTrailers(msg) {
+		if strings.EqualFold(t.Key, key) && (value == "" || t.Value == value) {
+			return true
+		}
+	}
+	return false
+}
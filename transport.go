@@ -0,0 +1,141 @@
+package githelpers
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+
+	"github.com/go-git/go-git/v5/plumbing/transport/client"
+	githttp "github.com/go-git/go-git/v5/plumbing/transport/http"
+	"github.com/hashicorp/go-cleanhttp"
+)
+
+// TransportOption customizes the HTTP transport NewHTTPTransport builds
+type TransportOption func(*transportConfig)
+
+type transportConfig struct {
+	proxyURL  string
+	caFiles   []string
+	tlsConfig *tls.Config
+	certFile  string
+	keyFile   string
+}
+
+// WithProxy sends connections through the proxy at proxyURL, e.g. http://proxy.example.com:3128 or
+// socks5://localhost:1080, instead of the one HTTPS_PROXY, HTTP_PROXY, and NO_PROXY pick
+func WithProxy(proxyURL string) TransportOption {
+	return func(cfg *transportConfig) {
+		cfg.proxyURL = proxyURL
+	}
+}
+
+// WithCACert trusts the PEM encoded CA certificates in the file at path, on top of the system pool,
+// e.g. a corporate CA bundle for a TLS inspecting proxy
+func WithCACert(path string) TransportOption {
+	return func(cfg *transportConfig) {
+		cfg.caFiles = append(cfg.caFiles, path)
+	}
+}
+
+// WithTLSConfig sets the TLS configuration connections start from
+func WithTLSConfig(tlsConfig *tls.Config) TransportOption {
+	return func(cfg *transportConfig) {
+		cfg.tlsConfig = tlsConfig
+	}
+}
+
+// WithClientCert presents the PEM encoded certificate and key in certFile and keyFile to servers
+// asking for one, for mutual TLS
+func WithClientCert(certFile, keyFile string) TransportOption {
+	return func(cfg *transportConfig) {
+		cfg.certFile = certFile
+		cfg.keyFile = keyFile
+	}
+}
+
+// NewHTTPTransport returns a pooled HTTP transport, like the one the GitLab client uses by default,
+// with opts applied
+func NewHTTPTransport(opts ...TransportOption) (*http.Transport, error) {
+	cfg := &transportConfig{}
+	for _, o := range opts {
+		o(cfg)
+	}
+	return cfg.transport()
+}
+
+// InstallGitHTTPTransport makes every HTTP and HTTPS clone, fetch, and push go-git does go through rt,
+// e.g. one NewHTTPTransport built for a proxy. go-git keeps its transports process wide, so this
+// affects every GitRepo, and should be called once, before any of them talks to a remote
+func InstallGitHTTPTransport(rt http.RoundTripper) {
+	c := githttp.NewClient(&http.Client{Transport: rt})
+	client.InstallProtocol("http", c)
+	client.InstallProtocol("https", c)
+}
+
+func (cfg *transportConfig) transport() (*http.Transport, error) {
+	t := cleanhttp.DefaultPooledTransport()
+	if cfg.proxyURL != "" {
+		u, err := url.Parse(cfg.proxyURL)
+		if err != nil {
+			return nil, fmt.Errorf("parsing proxy URL: %w", err)
+		}
+		switch u.Scheme {
+		case "http", "https", "socks5":
+		default:
+			return nil, fmt.Errorf("unsupported proxy URL scheme %q", u.Scheme)
+		}
+		t.Proxy = http.ProxyURL(u)
+	}
+
+	tlsConfig, err := cfg.buildTLSConfig()
+	if err != nil {
+		return nil, err
+	}
+	t.TLSClientConfig = tlsConfig
+	return t, nil
+}
+
+func (cfg *transportConfig) buildTLSConfig() (*tls.Config, error) {
+	if cfg.tlsConfig == nil && len(cfg.caFiles) == 0 && cfg.certFile == "" {
+		return nil, nil
+	}
+
+	tlsConfig := &tls.Config{}
+	if cfg.tlsConfig != nil {
+		tlsConfig = cfg.tlsConfig.Clone()
+	}
+	if cfg.certFile != "" {
+		cert, err := tls.LoadX509KeyPair(cfg.certFile, cfg.keyFile)
+		if err != nil {
+			return tlsConfig, err
+		}
+		tlsConfig.Certificates = append(tlsConfig.Certificates, cert)
+	}
+	if len(cfg.caFiles) == 0 {
+		return tlsConfig, nil
+	}
+
+	pool := tlsConfig.RootCAs
+	if pool == nil {
+		var err error
+		pool, err = x509.SystemCertPool()
+		if err != nil {
+			pool = x509.NewCertPool()
+		}
+	}
+	for _, f := range cfg.caFiles {
+		pem, err := os.ReadFile(f)
+		if err != nil {
+			return tlsConfig, err
+		}
+		if !pool.AppendCertsFromPEM(pem) {
+			return tlsConfig, fmt.Errorf("no CA certificates found in %s", f)
+		}
+	}
+	tlsConfig.RootCAs = pool
+
+	return tlsConfig, nil
+}
@@ -0,0 +1,51 @@
+package githelpers
+
+import (
+	"github.com/go-git/go-git/v5/config"
+	"github.com/go-git/go-git/v5/plumbing"
+)
+
+// SetUpstream makes branch track the branch of the same name on remote, writing branch.<name>.remote
+// and branch.<name>.merge to the repo config so a plain git pull works on it later. An empty remote means the GitRepo's RemoteName
+func (gr *GitRepo) SetUpstream(branch, remote string) error {
+	gr.mu.Lock()
+	defer gr.mu.Unlock()
+
+	return gr.setUpstream(branch, remote)
+}
+
+func (gr *GitRepo) setUpstream(branch, remote string) error {
+	if remote == "" {
+		remote = gr.remoteName()
+	}
+
+	cfg, err := gr.Repo.Config()
+	if err != nil {
+		return err
+	}
+
+	b := &config.Branch{
+		Name:   branch,
+		Remote: remote,
+		Merge:  plumbing.NewBranchReferenceName(branch),
+	}
+	err = b.Validate()
+	if err != nil {
+		return err
+	}
+	cfg.Branches[branch] = b
+
+	return gr.Repo.SetConfig(cfg)
+}
+
+func (gr *GitRepo) trackHead() error {
+	// Only a checked out branch can track anything, so a detached HEAD is left alone
+	head, err := gr.Repo.Head()
+	if err != nil {
+		return err
+	}
+	if !head.Name().IsBranch() {
+		return nil
+	}
+	return gr.setUpstream(head.Name().Short(), gr.remoteName())
+}
@@ -0,0 +1,108 @@
+package githelpers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/hashicorp/go-cleanhttp"
+)
+
+const (
+	defaultVaultMount = "secret"
+	defaultVaultField = "value"
+)
+
+// VaultSecretSource reads secrets from a HashiCorp Vault KV version 2 secrets engine over its HTTP API.
+// A secret's name is its path in the engine, optionally followed by # and the field to read, e.g.
+// ci/gitlab#token. The field defaults to Field, or to value when Field is empty
+type VaultSecretSource struct {
+	Addr   string // e.g. https://vault.example.com:8200. Defaults to VAULT_ADDR
+	Token  string // Defaults to VAULT_TOKEN
+	Mount  string // Path the KV engine is mounted at. Defaults to secret
+	Field  string
+	Client *http.Client // Defaults to a pooled client of its own
+}
+
+// Secret reads the latest version of the named secret's field, which must be a string
+func (s *VaultSecretSource) Secret(ctx context.Context, name string) ([]byte, error) {
+	path, field := splitSecretField(name, s.Field)
+	if field == "" {
+		field = defaultVaultField
+	}
+	addr, token, mount := s.Addr, s.Token, s.Mount
+	if addr == "" {
+		addr = os.Getenv("VAULT_ADDR")
+	}
+	if token == "" {
+		token = os.Getenv("VAULT_TOKEN")
+	}
+	if mount == "" {
+		mount = defaultVaultMount
+	}
+	if addr == "" || token == "" {
+		return nil, fmt.Errorf("reading %s from vault: no address or token set", name)
+	}
+
+	url := strings.TrimRight(addr, "/") + "/v1/" + strings.Trim(mount, "/") + "/data/" + strings.TrimLeft(path, "/")
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("X-Vault-Token", token)
+	if ns := os.Getenv("VAULT_NAMESPACE"); ns != "" {
+		req.Header.Set("X-Vault-Namespace", ns)
+	}
+
+	client := s.Client
+	if client == nil {
+		client = cleanhttp.DefaultPooledClient()
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	switch {
+	case resp.StatusCode == http.StatusNotFound:
+		return nil, fmt.Errorf("vault secret %s: %w", path, ErrSecretNotFound)
+	case resp.StatusCode != http.StatusOK:
+		return nil, fmt.Errorf("reading vault secret %s: %s: %s", path, resp.Status, strings.TrimSpace(string(body)))
+	}
+
+	var secret struct {
+		Data struct {
+			Data map[string]interface{} `json:"data"`
+		} `json:"data"`
+	}
+	err = json.Unmarshal(body, &secret)
+	if err != nil {
+		return nil, fmt.Errorf("reading vault secret %s: %w", path, err)
+	}
+	v, ok := secret.Data.Data[field]
+	if !ok {
+		return nil, fmt.Errorf("vault secret %s has no field %s: %w", path, field, ErrSecretNotFound)
+	}
+	str, ok := v.(string)
+	if !ok {
+		return nil, fmt.Errorf("field %s of vault secret %s isn't a string", field, path)
+	}
+	return []byte(str), nil
+}
+
+// splitSecretField splits a secret name of the form name#field, falling back to field when it has none
+func splitSecretField(name, field string) (string, string) {
+	if i := strings.LastIndex(name, "#"); i >= 0 {
+		return name[:i], name[i+1:]
+	}
+	return name, field
+}
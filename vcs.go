@@ -0,0 +1,206 @@
+package githelpers
+
+import (
+	"errors"
+	"fmt"
+	"path"
+	"strings"
+)
+
+// ProviderKind names a VCS platform
+type ProviderKind string
+
+const (
+	// ProviderGitlab is GitLab.com or a self-managed GitLab instance
+	ProviderGitlab ProviderKind = "gitlab"
+	// ProviderGithub is GitHub.com or GitHub Enterprise
+	ProviderGithub ProviderKind = "github"
+	// ProviderGitea is a Gitea or Forgejo instance
+	ProviderGitea ProviderKind = "gitea"
+	// ProviderBitbucket is Bitbucket Cloud or a Bitbucket Server/Data Center instance
+	ProviderBitbucket ProviderKind = "bitbucket"
+	// ProviderAzureDevOps is Azure DevOps Repos
+	ProviderAzureDevOps ProviderKind = "azuredevops"
+)
+
+var (
+	// ErrNoVCSClient is returned when a GitRepo needs a VCS client but none was added
+	ErrNoVCSClient = errors.New("no VCS client configured")
+	// ErrNotGitlab is returned by GitLab specific helpers when the GitRepo's VCS client is another provider
+	ErrNotGitlab = errors.New("VCS client is not a GitLab provider")
+	// ErrNotSupported is returned by a VCSProvider for operations its platform doesn't offer
+	ErrNotSupported = errors.New("operation not supported by this VCS provider")
+)
+
+// VCSProvider is the set of hosting platform operations the package needs, independent of whether
+// the repo lives on GitLab or elsewhere. Every method takes the URL of the repo it acts on so that a
+// single provider can be shared by many GitRepos. The providers in this package are safe for concurrent use
+type VCSProvider interface {
+	// GetProjectID returns the platform's identifier for the repo at repoURL
+	GetProjectID(repoURL string) (string, error)
+	// CreateProject creates a new repo called name under namespacePath
+	CreateProject(namespacePath, name string, opts ProjectOptions) (RemoteProject, error)
+	// CreateMergeRequest opens an MR/PR from src into dest
+	CreateMergeRequest(repoURL, title, src, dest string) (MergeRequestInfo, error)
+	// SetStatus reports the state of an external check on the commit with the given SHA
+	SetStatus(repoURL, sha, state, name, targetURL string) error
+}
+
+// ReleaseCreator is implemented by VCSProviders whose platform supports releases
+type ReleaseCreator interface {
+	// CreateRelease publishes a release for tag, creating the tag if it doesn't exist yet
+	CreateRelease(repoURL, tag, name, notes string) (ReleaseInfo, error)
+}
+
+// BranchProtector is implemented by VCSProviders whose platform supports protected branches
+type BranchProtector interface {
+	// ProtectBranch restricts who can push and merge to branch
+	ProtectBranch(repoURL, branch string, opts ProtectOptions) error
+}
+
+// RepoSizer is implemented by VCSProviders that can report how big a repo is before it's cloned
+type RepoSizer interface {
+	// RepoSize returns the approximate size of the repo at repoURL in bytes
+	RepoSize(repoURL string) (int64, error)
+}
+
+// RemoteProject is a repo on a VCS platform
+type RemoteProject struct {
+	ID       string
+	FullPath string
+	SSHURL   string
+	HTTPURL  string
+	WebURL   string
+}
+
+// MergeRequestInfo is an MR/PR on a VCS platform
+type MergeRequestInfo struct {
+	ID           string
+	Number       int // The MR IID on GitLab, or the PR number elsewhere
+	Title        string
+	SourceBranch string
+	TargetBranch string
+	URL          string
+}
+
+// ReleaseInfo is a release on a VCS platform
+type ReleaseInfo struct {
+	Tag  string
+	Name string
+	URL  string
+}
+
+// DetectProvider guesses the VCS platform hosting the repo at repoURL from its host name. Hosts it
+// can't place are assumed to be self-managed GitLab instances
+func DetectProvider(repoURL string) ProviderKind {
+	host := strings.ToLower(repoHost(repoURL))
+	switch {
+	case host == "github.com" || strings.Contains(host, "github"):
+		return ProviderGithub
+	case host == "codeberg.org" || strings.Contains(host, "gitea") || strings.Contains(host, "forgejo"):
+		return ProviderGitea
+	case strings.Contains(host, "bitbucket"):
+		return ProviderBitbucket
+	case strings.Contains(host, "dev.azure.com") || strings.HasSuffix(host, "visualstudio.com"):
+		return ProviderAzureDevOps
+	}
+	return ProviderGitlab
+}
+
+// NewVCSProvider returns the VCSProvider for kind, authenticated with the given token. An empty
+// baseURL means the platform's public instance, except for Gitea and Azure DevOps where it's required.
+// For Azure DevOps it's the organization URL
+func NewVCSProvider(kind ProviderKind, vcsToken, baseURL string) (VCSProvider, error) {
+	switch kind {
+	case ProviderGitlab:
+		var opts []GitlabClientOption
+		if baseURL != "" {
+			opts = append(opts, WithGitlabBaseURL(baseURL))
+		}
+		return NewGitlabProvider(vcsToken, opts...)
+	case ProviderGithub:
+		return NewGithubProvider(vcsToken, baseURL)
+	case ProviderGitea:
+		return NewGiteaProvider(vcsToken, baseURL)
+	case ProviderBitbucket:
+		return NewBitbucketProvider("", vcsToken, baseURL), nil
+	case ProviderAzureDevOps:
+		return NewAzureDevOpsProvider(vcsToken, baseURL)
+	}
+	return nil, fmt.Errorf("unknown VCS provider: %s", kind)
+}
+
+// AddVCSClient creates a client for the platform hosting the GitRepo and saves it to the receiver.
+// An empty kind detects the platform from the SSH URL with DetectProvider
+func (gr *GitRepo) AddVCSClient(vcsToken string, kind ProviderKind) error {
+	if kind == "" {
+		kind = DetectProvider(gr.SSHURL)
+	}
+
+	host := repoHost(gr.SSHURL)
+	baseURL := "https://" + host
+	switch {
+	case kind == ProviderGithub && host == "github.com", kind == ProviderGitlab && host == "gitlab.com",
+		kind == ProviderBitbucket && host == "bitbucket.org":
+		baseURL = ""
+	case kind == ProviderGithub:
+		baseURL += "/api/v3/"
+	case kind == ProviderAzureDevOps:
+		// Azure DevOps namespaces are org/project
+		_, ns, _ := splitRepoURL(gr.SSHURL)
+		baseURL = "https://" + azureHTTPSHost + "/" + path.Dir(ns)
+	}
+
+	p, err := NewVCSProvider(kind, vcsToken, baseURL)
+	if err != nil {
+		return err
+	}
+
+	gr.VCSClient = p
+	return nil
+}
+
+// NewMergeRequest opens an MR/PR from src into dest on whichever platform the GitRepo's VCS client talks to
+func (gr *GitRepo) NewMergeRequest(title, src, dest string) (MergeRequestInfo, error) {
+	if gr.VCSClient == nil {
+		return MergeRequestInfo{}, ErrNoVCSClient
+	}
+	return gr.VCSClient.CreateMergeRequest(gr.SSHURL, title, src, dest)
+}
+
+// NewRelease publishes a release for tag on whichever platform the GitRepo's VCS client talks to
+func (gr *GitRepo) NewRelease(tag, name, notes string) (ReleaseInfo, error) {
+	if gr.VCSClient == nil {
+		return ReleaseInfo{}, ErrNoVCSClient
+	}
+	rc, ok := gr.VCSClient.(ReleaseCreator)
+	if !ok {
+		return ReleaseInfo{}, ErrNotSupported
+	}
+	return rc.CreateRelease(gr.SSHURL, tag, name, notes)
+}
+
+// ProtectRemoteBranch protects branch on whichever platform the GitRepo's VCS client talks to
+func (gr *GitRepo) ProtectRemoteBranch(branch string, opts ProtectOptions) error {
+	if gr.VCSClient == nil {
+		return ErrNoVCSClient
+	}
+	bp, ok := gr.VCSClient.(BranchProtector)
+	if !ok {
+		return ErrNotSupported
+	}
+	return bp.ProtectBranch(gr.SSHURL, branch, opts)
+}
+
+func commitStatusState(state string) string {
+	// Translates GitLab commit status states to the ones used by GitHub style APIs
+	switch state {
+	case "running":
+		return "pending"
+	case "failed":
+		return "failure"
+	case "canceled":
+		return "error"
+	}
+	return state
+}
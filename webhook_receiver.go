@@ -0,0 +1,142 @@
+package githelpers
+
+import (
+	"crypto/subtle"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"sync"
+
+	"github.com/xanzy/go-gitlab"
+)
+
+const (
+	defaultWebhookMaxBodySize = 25 << 20
+)
+
+// WebhookHandler handles a parsed GitLab webhook event. The event is one of the go-gitlab event types,
+// e.g. *gitlab.PushEvent or *gitlab.MergeEvent
+type WebhookHandler func(event interface{}) error
+
+// WebhookReceiver is an http.Handler for GitLab webhooks. It checks the secret token GitLab sends in
+// X-Gitlab-Token, parses the payload into the go-gitlab event type for its X-Gitlab-Event header, and
+// runs the handlers registered for that event in the order they were added. GitLab gives up on a hook
+// after 10 seconds, so handlers with slow work should hand it off rather than do it inline. Without a
+// Secret every request is rejected, as anyone could send one
+type WebhookReceiver struct {
+	Secret      string
+	MaxBodySize int64       // Payloads bigger than this many bytes are rejected. Defaults to 25 MB
+	ErrorLog    *log.Logger // Where handler errors go, as GitLab is only told the hook failed. Defaults to the log package's logger
+
+	mu       sync.RWMutex
+	handlers map[gitlab.EventType][]WebhookHandler
+}
+
+// NewWebhookReceiver returns a WebhookReceiver accepting requests signed with secret, the secret token
+// the hook was registered with, e.g. by EnsureProjectWebhook
+func NewWebhookReceiver(secret string) *WebhookReceiver {
+	return &WebhookReceiver{Secret: secret}
+}
+
+// On registers h for events of the given type
+func (wr *WebhookReceiver) On(eventType gitlab.EventType, h WebhookHandler) {
+	wr.mu.Lock()
+	defer wr.mu.Unlock()
+
+	if wr.handlers == nil {
+		wr.handlers = map[gitlab.EventType][]WebhookHandler{}
+	}
+	wr.handlers[eventType] = append(wr.handlers[eventType], h)
+}
+
+// OnPush registers h for push events
+func (wr *WebhookReceiver) OnPush(h func(*gitlab.PushEvent) error) {
+	wr.On(gitlab.EventTypePush, func(event interface{}) error {
+		return h(event.(*gitlab.PushEvent))
+	})
+}
+
+// OnTagPush registers h for tag push events
+func (wr *WebhookReceiver) OnTagPush(h func(*gitlab.TagEvent) error) {
+	wr.On(gitlab.EventTypeTagPush, func(event interface{}) error {
+		return h(event.(*gitlab.TagEvent))
+	})
+}
+
+// OnMergeRequest registers h for merge request events
+func (wr *WebhookReceiver) OnMergeRequest(h func(*gitlab.MergeEvent) error) {
+	wr.On(gitlab.EventTypeMergeRequest, func(event interface{}) error {
+		return h(event.(*gitlab.MergeEvent))
+	})
+}
+
+// OnPipeline registers h for pipeline events
+func (wr *WebhookReceiver) OnPipeline(h func(*gitlab.PipelineEvent) error) {
+	wr.On(gitlab.EventTypePipeline, func(event interface{}) error {
+		return h(event.(*gitlab.PipelineEvent))
+	})
+}
+
+func (wr *WebhookReceiver) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	if wr.Secret == "" {
+		http.Error(w, "no webhook secret configured", http.StatusInternalServerError)
+		return
+	}
+	token := r.Header.Get("X-Gitlab-Token")
+	if subtle.ConstantTimeCompare([]byte(token), []byte(wr.Secret)) != 1 {
+		http.Error(w, "invalid webhook token", http.StatusUnauthorized)
+		return
+	}
+
+	limit := wr.MaxBodySize
+	if limit <= 0 {
+		limit = defaultWebhookMaxBodySize
+	}
+	payload, err := io.ReadAll(http.MaxBytesReader(w, r.Body, limit))
+	if err != nil {
+		http.Error(w, "reading payload: "+err.Error(), http.StatusRequestEntityTooLarge)
+		return
+	}
+
+	eventType := gitlab.WebhookEventType(r)
+	event, err := gitlab.ParseWebhook(eventType, payload)
+	if err != nil {
+		http.Error(w, "parsing payload: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	err = wr.dispatch(eventType, event)
+	if err != nil {
+		wr.logf("webhook: %v", err)
+		http.Error(w, "handling event failed", http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (wr *WebhookReceiver) dispatch(eventType gitlab.EventType, event interface{}) error {
+	wr.mu.RLock()
+	handlers := wr.handlers[eventType]
+	wr.mu.RUnlock()
+
+	failed := &MultiError{}
+	for i, h := range handlers {
+		failed.Add(fmt.Sprintf("%s handler %d", eventType, i+1), h(event))
+	}
+	return failed.ErrorOrNil()
+}
+
+func (wr *WebhookReceiver) logf(format string, args ...interface{}) {
+	if wr.ErrorLog != nil {
+		wr.ErrorLog.Printf(format, args...)
+		return
+	}
+	log.Printf(format, args...)
+}
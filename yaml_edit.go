@@ -0,0 +1,396 @@
+package githelpers
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"gopkg.in/yaml.v3"
+)
+
+// YAMLPathError is returned when a key path passed to SetYAMLValue can't be followed through a document
+type YAMLPathError struct {
+	Path   string
+	Reason string
+}
+
+func (e *YAMLPathError) Error() string {
+	return fmt.Sprintf("yaml path %q: %s", e.Path, e.Reason)
+}
+
+// SetYAMLValue sets the value at keyPath in the YAML data and returns the new YAML. keyPath is a dot
+// separated list of mapping keys, each optionally followed by sequence indexes, e.g. image.tag or
+// spec.containers[0].image. In a file with several documents, the value is set in the first one
+// keyPath is found in. Missing mapping keys are created, in a multi-document file only when a mapping
+// below the top holds the last one. Setting a scalar, or adding one next to the first key of a mapping,
+// changes only that line, keeping the quoting of a replaced string. Other edits keep comments, but
+// re-indent the documents with two spaces
+func SetYAMLValue(data []byte, keyPath string, value interface{}) ([]byte, error) {
+	steps, err := parseYAMLPath(keyPath)
+	if err != nil {
+		return nil, err
+	}
+	docs, err := decodeYAMLDocuments(data)
+	if err != nil {
+		return nil, err
+	}
+	if len(docs) == 0 {
+		// An empty document
+		docs = []*yaml.Node{{Kind: yaml.DocumentNode, Content: []*yaml.Node{{Kind: yaml.MappingNode}}}}
+	}
+
+	var repl yaml.Node
+	err = repl.Encode(value)
+	if err != nil {
+		return nil, err
+	}
+
+	i, target := findYAMLDocument(docs, keyPath, steps)
+	if target != nil {
+		if out, ok := replaceYAMLScalar(data, target, &repl); ok && yamlValueSet(out, i, keyPath, steps, &repl) {
+			return out, nil
+		}
+	} else {
+		last := steps[len(steps)-1]
+		var parent *yaml.Node
+		if last.key != "" && (len(steps) > 1 || len(docs) == 1) {
+			i, parent = findYAMLDocument(docs, keyPath, steps[:len(steps)-1])
+		}
+		if parent != nil {
+			out, ok := insertYAMLKey(data, parent, last.key, &repl)
+			if ok && yamlValueSet(out, i, keyPath, steps, &repl) {
+				return out, nil
+			}
+		} else if len(docs) > 1 {
+			return nil, &YAMLPathError{Path: keyPath, Reason: fmt.Sprintf("not found in any of the %d documents", len(docs))}
+		} else {
+			i = 0
+		}
+		target, err = walkYAMLPath(docs[i].Content[0], keyPath, steps, true)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if target.Kind == yaml.ScalarNode && repl.Kind == yaml.ScalarNode {
+		target.Style = replacementStyle(target, &repl)
+		target.Value = repl.Value
+		target.Tag = repl.Tag
+	} else {
+		repl.HeadComment, repl.LineComment, repl.FootComment = target.HeadComment, target.LineComment, target.FootComment
+		*target = repl
+	}
+
+	var buf bytes.Buffer
+	enc := yaml.NewEncoder(&buf)
+	enc.SetIndent(2)
+	for _, doc := range docs {
+		err = enc.Encode(doc)
+		if err != nil {
+			return nil, err
+		}
+	}
+	err = enc.Close()
+	return buf.Bytes(), err
+}
+
+func decodeYAMLDocuments(data []byte) (docs []*yaml.Node, err error) {
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	for {
+		var doc yaml.Node
+		err := dec.Decode(&doc)
+		if errors.Is(err, io.EOF) {
+			return docs, nil
+		}
+		if err != nil {
+			return docs, err
+		}
+		docs = append(docs, &doc)
+	}
+}
+
+// findYAMLDocument returns the first of docs keyPath is found in, and the node it points to there,
+// or nil when no document has it
+func findYAMLDocument(docs []*yaml.Node, keyPath string, steps []yamlStep) (int, *yaml.Node) {
+	for i, doc := range docs {
+		if len(doc.Content) == 0 {
+			continue
+		}
+		node, err := walkYAMLPath(doc.Content[0], keyPath, steps, false)
+		if err == nil && node != nil {
+			return i, node
+		}
+	}
+	return -1, nil
+}
+
+// replacementStyle keeps the quotes of a string replaced by another string
+func replacementStyle(target, repl *yaml.Node) yaml.Style {
+	quoted := target.Style&(yaml.DoubleQuotedStyle|yaml.SingleQuotedStyle) != 0
+	if quoted && repl.Tag == "!!str" {
+		return target.Style
+	}
+	return repl.Style
+}
+
+// replaceYAMLScalar replaces the text of the scalar target in data with repl, when both are scalars
+// written on one line
+func replaceYAMLScalar(data []byte, target, repl *yaml.Node) ([]byte, bool) {
+	if target.Kind != yaml.ScalarNode || repl.Kind != yaml.ScalarNode {
+		return nil, false
+	}
+	start, end, ok := yamlScalarSpan(data, target)
+	if !ok {
+		return nil, false
+	}
+	text, ok := yamlScalarText(repl.Tag, repl.Value, replacementStyle(target, repl))
+	if !ok {
+		return nil, false
+	}
+
+	out := make([]byte, 0, len(data)+len(text))
+	out = append(out, data[:start]...)
+	out = append(out, text...)
+	return append(out, data[end:]...), true
+}
+
+// insertYAMLKey adds key, set to repl, to the block mapping parent in data, on a line of its own
+// after the mapping's first key, when that key's value is a scalar on the same line
+func insertYAMLKey(data []byte, parent *yaml.Node, key string, repl *yaml.Node) ([]byte, bool) {
+	if parent.Kind != yaml.MappingNode || parent.Style&yaml.FlowStyle != 0 || len(parent.Content) < 2 || repl.Kind != yaml.ScalarNode {
+		return nil, false
+	}
+	first, value := parent.Content[0], parent.Content[1]
+	if value.Kind != yaml.ScalarNode || value.Line != first.Line {
+		return nil, false
+	}
+	_, end, ok := yamlScalarSpan(data, value)
+	if !ok {
+		return nil, false
+	}
+	keyText, ok := yamlScalarText("!!str", key, 0)
+	if !ok {
+		return nil, false
+	}
+	valueText, ok := yamlScalarText(repl.Tag, repl.Value, repl.Style)
+	if !ok {
+		return nil, false
+	}
+
+	// After any comment on the line, and before its line break
+	at, newline := len(data), "\n"
+	if i := bytes.IndexByte(data[end:], '\n'); i >= 0 {
+		at = end + i
+		if at > 0 && data[at-1] == '\r' {
+			at, newline = at-1, "\r\n"
+		}
+	}
+	line := newline + strings.Repeat(" ", first.Column-1) + keyText + ": " + valueText
+
+	out := make([]byte, 0, len(data)+len(line))
+	out = append(out, data[:at]...)
+	out = append(out, line...)
+	return append(out, data[at:]...), true
+}
+
+// yamlScalarSpan returns where the text of the scalar node starts and ends in data, if it's a plain or
+// quoted scalar on one line, with no tag or anchor
+func yamlScalarSpan(data []byte, node *yaml.Node) (start, end int, ok bool) {
+	if node.Anchor != "" || node.Style&(yaml.TaggedStyle|yaml.LiteralStyle|yaml.FoldedStyle) != 0 {
+		return 0, 0, false
+	}
+	start = yamlOffset(data, node.Line, node.Column)
+	if start < 0 {
+		return 0, 0, false
+	}
+	line := data[start:]
+	if i := bytes.IndexByte(line, '\n'); i >= 0 {
+		line = line[:i]
+	}
+	line = bytes.TrimSuffix(line, []byte("\r"))
+
+	var candidates []int
+	switch {
+	case node.Style&yaml.DoubleQuotedStyle != 0:
+		for i := 1; i < len(line); i++ {
+			if line[i] == '\\' {
+				i++
+			} else if line[i] == '"' {
+				candidates = append(candidates, i+1)
+				break
+			}
+		}
+	case node.Style&yaml.SingleQuotedStyle != 0:
+		for i := 1; i < len(line); i++ {
+			if line[i] == '\'' {
+				if i+1 < len(line) && line[i+1] == '\'' {
+					i++
+					continue
+				}
+				candidates = append(candidates, i+1)
+				break
+			}
+		}
+	default:
+		// A plain scalar ends before a comment, or, in a flow collection, at the next indicator
+		n := len(line)
+		for i := 1; i < len(line); i++ {
+			if line[i] == '#' && (line[i-1] == ' ' || line[i-1] == '\t') {
+				n = i
+				break
+			}
+		}
+		candidates = append(candidates, len(bytes.TrimRight(line[:n], " \t")))
+		if i := bytes.IndexAny(line[:n], ",]}"); i >= 0 {
+			candidates = append(candidates, len(bytes.TrimRight(line[:i], " \t")))
+		}
+	}
+
+	// The text has to read back as the same value, which rules out multi-line scalars
+	for _, n := range candidates {
+		var doc yaml.Node
+		err := yaml.Unmarshal(line[:n], &doc)
+		if err != nil || len(doc.Content) != 1 {
+			continue
+		}
+		if v := doc.Content[0]; v.Kind == yaml.ScalarNode && v.Value == node.Value && v.Tag == node.Tag {
+			return start, start + n, true
+		}
+	}
+	return 0, 0, false
+}
+
+// yamlOffset returns the offset in data of the 1-based line and column yaml reports, or -1
+func yamlOffset(data []byte, line, column int) int {
+	off := 0
+	for l := 1; l < line; l++ {
+		i := bytes.IndexByte(data[off:], '\n')
+		if i < 0 {
+			return -1
+		}
+		off += i + 1
+	}
+	for c := 1; c < column; c++ {
+		if off >= len(data) || data[off] == '\n' {
+			return -1
+		}
+		_, size := utf8.DecodeRune(data[off:])
+		off += size
+	}
+	return off
+}
+
+// yamlScalarText returns a scalar as it's written in a YAML file, if it fits on one line
+func yamlScalarText(tag, value string, style yaml.Style) (string, bool) {
+	out, err := yaml.Marshal(&yaml.Node{Kind: yaml.ScalarNode, Tag: tag, Value: value, Style: style})
+	if err != nil {
+		return "", false
+	}
+	text := strings.TrimSuffix(string(out), "\n")
+	return text, !strings.Contains(text, "\n")
+}
+
+// yamlValueSet checks that document i of the edited YAML out has repl at keyPath, and the same
+// documents as before otherwise
+func yamlValueSet(out []byte, i int, keyPath string, steps []yamlStep, repl *yaml.Node) bool {
+	docs, err := decodeYAMLDocuments(out)
+	if err != nil || i >= len(docs) || len(docs[i].Content) == 0 {
+		return false
+	}
+	node, err := walkYAMLPath(docs[i].Content[0], keyPath, steps, false)
+	return err == nil && node != nil && node.Kind == yaml.ScalarNode && node.Value == repl.Value && node.Tag == repl.Tag
+}
+
+// yamlStep is one key or sequence index of a key path
+type yamlStep struct {
+	key   string
+	index int // Used when key is empty
+}
+
+func parseYAMLPath(keyPath string) (steps []yamlStep, err error) {
+	invalid := func(reason string) error {
+		return &YAMLPathError{Path: keyPath, Reason: reason}
+	}
+	if keyPath == "" {
+		return nil, invalid("path is empty")
+	}
+
+	for _, part := range strings.Split(keyPath, ".") {
+		key := part
+		var indexes []int
+		if i := strings.Index(part, "["); i >= 0 {
+			key = part[:i]
+			for rest := part[i:]; rest != ""; {
+				end := strings.Index(rest, "]")
+				if rest[0] != '[' || end < 0 {
+					return nil, invalid(fmt.Sprintf("malformed index in %q", part))
+				}
+				n, err := strconv.Atoi(rest[1:end])
+				if err != nil || n < 0 {
+					return nil, invalid(fmt.Sprintf("invalid index in %q", part))
+				}
+				indexes = append(indexes, n)
+				rest = rest[end+1:]
+			}
+		}
+		if key == "" && len(indexes) == 0 {
+			return nil, invalid("empty key")
+		}
+		if key != "" {
+			steps = append(steps, yamlStep{key: key})
+		}
+		for _, n := range indexes {
+			steps = append(steps, yamlStep{index: n})
+		}
+	}
+	return steps, nil
+}
+
+// walkYAMLPath follows steps from node. Missing mapping keys are added when create is set, and
+// otherwise make it return nil
+func walkYAMLPath(node *yaml.Node, keyPath string, steps []yamlStep, create bool) (*yaml.Node, error) {
+	for _, step := range steps {
+		if node.Kind == yaml.AliasNode {
+			node = node.Alias
+		}
+
+		if step.key == "" {
+			if node.Kind != yaml.SequenceNode {
+				return nil, &YAMLPathError{Path: keyPath, Reason: fmt.Sprintf("index %d applied to a non-sequence", step.index)}
+			}
+			if step.index >= len(node.Content) {
+				return nil, &YAMLPathError{Path: keyPath, Reason: fmt.Sprintf("index %d is out of range", step.index)}
+			}
+			node = node.Content[step.index]
+			continue
+		}
+
+		if create && node.Kind == yaml.ScalarNode && node.Tag == "!!null" {
+			// A key with no value yet, e.g. "image:", becomes a mapping
+			*node = yaml.Node{Kind: yaml.MappingNode, HeadComment: node.HeadComment, LineComment: node.LineComment}
+		}
+		if node.Kind != yaml.MappingNode {
+			return nil, &YAMLPathError{Path: keyPath, Reason: fmt.Sprintf("key %q applied to a non-mapping", step.key)}
+		}
+		var next *yaml.Node
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			if node.Content[i].Value == step.key {
+				next = node.Content[i+1]
+				break
+			}
+		}
+		if next == nil && !create {
+			return nil, nil
+		}
+		if next == nil {
+			next = &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!null"}
+			node.Content = append(node.Content, &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: step.key}, next)
+		}
+		node = next
+	}
+	return node, nil
+}
@@ -0,0 +1,127 @@
+package githelpers
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestSetYAMLValue(t *testing.T) {
+	tests := []struct {
+		name  string
+		in    string
+		path  string
+		value interface{}
+		want  string
+	}{
+		{
+			name:  "replaces a scalar in place",
+			in:    "# Settings\nimage:\n    repository: app   # the app\n    tag: \"1.0\" # bumped by CI\nreplicas: 2\n",
+			path:  "image.tag",
+			value: "1.1",
+			want:  "# Settings\nimage:\n    repository: app   # the app\n    tag: \"1.1\" # bumped by CI\nreplicas: 2\n",
+		},
+		{
+			name:  "keeps single quotes",
+			in:    "tag: 'v1'\n",
+			path:  "tag",
+			value: "v2",
+			want:  "tag: 'v2'\n",
+		},
+		{
+			name:  "replaces a number",
+			in:    "replicas: 2\n",
+			path:  "replicas",
+			value: 3,
+			want:  "replicas: 3\n",
+		},
+		{
+			name:  "replaces in a flow mapping",
+			in:    "image: {repository: app, tag: v1}\n",
+			path:  "image.tag",
+			value: "v2",
+			want:  "image: {repository: app, tag: v2}\n",
+		},
+		{
+			name:  "keeps sequence indentation",
+			in:    "images:\n- name: app\n  newTag: v1\n- name: db\n  newTag: v1\n",
+			path:  "images[1].newTag",
+			value: "v2",
+			want:  "images:\n- name: app\n  newTag: v1\n- name: db\n  newTag: v2\n",
+		},
+		{
+			name:  "adds a key after the first one",
+			in:    "images:\n- name: app # main\n  newName: registry/app\n",
+			path:  "images[0].newTag",
+			value: "v2",
+			want:  "images:\n- name: app # main\n  newTag: v2\n  newName: registry/app\n",
+		},
+		{
+			name:  "creates missing mappings",
+			in:    "a: 1\n",
+			path:  "b.c",
+			value: "x",
+			want:  "a: 1\nb:\n  c: x\n",
+		},
+		{
+			name:  "creates a document",
+			in:    "",
+			path:  "a",
+			value: 1,
+			want:  "a: 1\n",
+		},
+		{
+			name:  "edits the first of several documents",
+			in:    "a: 1\n---\nb: 2\n",
+			path:  "a",
+			value: 3,
+			want:  "a: 3\n---\nb: 2\n",
+		},
+		{
+			name:  "edits the document the path is found in",
+			in:    "kind: Service\nspec:\n  ports: []\n---\nkind: Deployment\nspec:\n  replicas: 1\n---\nkind: ConfigMap\n",
+			path:  "spec.replicas",
+			value: 4,
+			want:  "kind: Service\nspec:\n  ports: []\n---\nkind: Deployment\nspec:\n  replicas: 4\n---\nkind: ConfigMap\n",
+		},
+		{
+			name:  "keeps every document when re-encoding",
+			in:    "a: 1\n---\nb: {c: 2}\n",
+			path:  "b.c",
+			value: []string{"x"},
+			want:  "a: 1\n---\nb: {c: [x]}\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			out, err := SetYAMLValue([]byte(tt.in), tt.path, tt.value)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(out) != tt.want {
+				t.Errorf("got\n%s\nwant\n%s", out, tt.want)
+			}
+		})
+	}
+}
+
+func TestSetYAMLValueErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		path string
+	}{
+		{"missing from every document", "a: 1\n---\nb: 2\n", "c"},
+		{"key of a sequence", "a: [1]\n", "a.b"},
+		{"index out of range", "a: [1]\n", "a[1]"},
+		{"malformed index", "a: [1]\n", "a[x]"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := SetYAMLValue([]byte(tt.in), tt.path, 1)
+			var pathErr *YAMLPathError
+			if !errors.As(err, &pathErr) {
+				t.Errorf("got %v, want a YAMLPathError", err)
+			}
+		})
+	}
+}